@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// SpectrogramImage draws a spectrogram (as returned by Spectrogram): time goes from left to right,
+// frequency from bottom (0 Hz) to top (Nyquist), and the color goes from black to white between floor and 0 dB.
+func SpectrogramImage(columns [][]float64, floor float64) image.Image {
+	height := 0
+	if len(columns) > 0 {
+		height = len(columns[0])
+	}
+	img := image.NewRGBA(image.Rect(0, 0, len(columns), height))
+	for x, col := range columns {
+		for bin, m := range col {
+			db := synth.AmpToDB(math.Max(m, 1e-12))
+			img.Set(x, height-1-bin, heat(1-db/floor))
+		}
+	}
+	return img
+}
+
+// WriteSpectrogramPNG computes the spectrogram of the frames (with a Hann window) and writes it as a PNG image,
+// with one column per hop frames and size/2+1 rows, showing levels down to -100 dB.
+func WriteSpectrogramPNG(w io.Writer, frames []float64, size, hop int) error {
+	return png.Encode(w, SpectrogramImage(Spectrogram(frames, Hann, size, hop), -100))
+}
+
+// heat returns the color of a level between 0 and 1, from black to white through blue, red and yellow.
+func heat(v float64) color.RGBA {
+	v = math.Max(0, math.Min(v, 1))
+	stops := []color.RGBA{{0, 0, 0, 255}, {32, 0, 128, 255}, {200, 0, 64, 255}, {255, 160, 0, 255}, {255, 255, 255, 255}}
+	pos := v * float64(len(stops)-1)
+	i := min(int(pos), len(stops)-2)
+	t := pos - float64(i)
+	mix := func(a, b uint8) uint8 { return uint8(math.Round(float64(a) + t*(float64(b)-float64(a)))) }
+	a, b := stops[i], stops[i+1]
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+// WaveformImage draws the frames as a waveform of the given size: each column shows the range
+// between the lowest and highest frames it covers (min/max peaks), from -1 (bottom) to 1 (top).
+func WaveformImage(frames []float64, width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	background, axis, wave := color.RGBA{255, 255, 255, 255}, color.RGBA{200, 200, 200, 255}, color.RGBA{32, 64, 160, 255}
+	row := func(v float64) int {
+		v = math.Max(-1, math.Min(v, 1))
+		return int(math.Round((1 - v) / 2 * float64(height-1)))
+	}
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, background)
+		}
+		img.Set(x, row(0), axis)
+
+		from, to := x*len(frames)/width, (x+1)*len(frames)/width
+		if from >= len(frames) {
+			continue
+		}
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, v := range frames[from:max(to, from+1)] {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+		for y := row(hi); y <= row(lo); y++ {
+			img.Set(x, y, wave)
+		}
+	}
+	return img
+}
+
+// RenderWaveformPNG draws the frames as a waveform (see WaveformImage) and encodes it as a PNG image.
+func RenderWaveformPNG(frames []float64, width, height int) ([]byte, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, WaveformImage(frames, width, height))
+	return buf.Bytes(), err
+}
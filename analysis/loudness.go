@@ -0,0 +1,130 @@
+package analysis
+
+import (
+	"math"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Peak returns the highest absolute value of the frames.
+func Peak(frames []float64) (peak float64) {
+	for _, v := range frames {
+		peak = math.Max(peak, math.Abs(v))
+	}
+	return peak
+}
+
+// RMS returns the root mean square of the frames (their average power).
+func RMS(frames []float64) float64 {
+	if len(frames) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range frames {
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(len(frames)))
+}
+
+// LUFS returns the integrated loudness (in LUFS) of a mono signal, see LoudnessMulti.
+func LUFS(frames []float64, rate int) float64 {
+	return LoudnessMulti([][]float64{frames}, rate)
+}
+
+// LoudnessMulti returns the integrated loudness (in LUFS, loudness units relative to full scale) of the channels,
+// following ITU-R BS.1770-4 and EBU R128: K-weighting, 400ms blocks overlapping by 75%,
+// an absolute gate at -70 LUFS and a relative gate 10 LU below the loudness of the blocks above it.
+// All channels are weighted equally (which is correct for mono, stereo and the front channels of surround).
+// It returns -Inf for silence or signals shorter than a block.
+func LoudnessMulti(channels [][]float64, rate int) float64 {
+	if len(channels) == 0 || rate <= 0 {
+		return math.Inf(-1)
+	}
+	weighted := make([][]float64, len(channels))
+	for c, ch := range channels {
+		weighted[c] = kWeight(ch, float64(rate))
+	}
+
+	// Mean square of each block, summed over channels.
+	size, step := rate*4/10, rate/10
+	var blocks []float64
+	for start := 0; start+size <= len(weighted[0]); start += step {
+		var z float64
+		for _, ch := range weighted {
+			for _, v := range ch[start : start+size] {
+				z += v * v
+			}
+		}
+		blocks = append(blocks, z/float64(size))
+	}
+
+	loudness := func(z float64) float64 { return -0.691 + 10*math.Log10(z) }
+	gated := func(threshold float64) float64 {
+		var sum float64
+		n := 0
+		for _, z := range blocks {
+			if loudness(z) > threshold {
+				sum += z
+				n++
+			}
+		}
+		if n == 0 {
+			return math.Inf(-1)
+		}
+		return loudness(sum / float64(n))
+	}
+	relative := gated(-70) - 10
+	return gated(math.Max(relative, -70))
+}
+
+// kWeight returns the frames filtered by the K-weighting curve of BS.1770 (a high shelf and a high-pass),
+// with coefficients computed for the sample rate (the reference ones being for 48 kHz).
+func kWeight(frames []float64, rate float64) []float64 {
+	// High shelf modelling the acoustic effect of the head.
+	k := math.Tan(math.Pi * 1681.974450955533 / rate)
+	q := 0.7071752369554196
+	vh := math.Pow(10, 3.999843853973347/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := synth.Biquad{
+		B0: (vh + vb*k/q + k*k) / a0, B1: 2 * (k*k - vh) / a0, B2: (vh - vb*k/q + k*k) / a0,
+		A1: 2 * (k*k - 1) / a0, A2: (1 - k/q + k*k) / a0,
+	}
+	// High-pass (the "revised low-frequency B-curve").
+	k = math.Tan(math.Pi * 38.13547087602444 / rate)
+	q = 0.5003270373238773
+	a0 = 1 + k/q + k*k
+	highPass := synth.Biquad{B0: 1, B1: -2, B2: 1, A1: 2 * (k*k - 1) / a0, A2: (1 - k/q + k*k) / a0}
+
+	return biquad(highPass, biquad(shelf, frames))
+}
+
+// biquad returns the frames filtered by b.
+func biquad(b synth.Biquad, frames []float64) []float64 {
+	out := make([]float64, len(frames))
+	var x1, x2, y1, y2 float64
+	for i, v := range frames {
+		y := b.B0*v + b.B1*x1 + b.B2*x2 - b.A1*y1 - b.A2*y2
+		x2, x1 = x1, v
+		y2, y1 = y1, y
+		out[i] = y
+	}
+	return out
+}
+
+// Normalize returns the frames scaled so their integrated loudness reaches the target (in LUFS, like -14 or -23),
+// along with the gain applied (in decibels). Silent frames are returned unchanged.
+// Raising the loudness can push peaks above full scale: limit the signal before if needed.
+func Normalize(frames []float64, rate int, target float64) (normalized []float64, gain float64) {
+	loudness := LUFS(frames, rate)
+	if math.IsInf(loudness, -1) {
+		return frames, 0
+	}
+	gain = target - loudness
+	amp := synth.DBToAmp(gain)
+	normalized = make([]float64, len(frames))
+	for i, v := range frames {
+		normalized[i] = amp * v
+	}
+	return normalized, gain
+}
@@ -0,0 +1,155 @@
+package analysis
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// OnsetDetector finds the onsets of audio (the starts of notes and drum hits) from its spectral flux:
+// how much the spectrum gains from a window to the next, which peaks at transients.
+// Zero fields use the default values.
+type OnsetDetector struct {
+	Rate      int           // Sample rate, in Hertz.
+	Size      int           // Window size, in frames (1024 by default).
+	Hop       int           // Frames between the start of windows (Size/4 by default).
+	Threshold float64       // How far above the local median of the flux (normalized to 1) a peak must be (0.05 by default).
+	MinGap    time.Duration // Shortest time between onsets (50 ms by default).
+}
+
+// Onsets returns the positions (in frames) of the onsets of the frames, with the default detector settings.
+func Onsets(frames []float64, rate int) []int {
+	d := &OnsetDetector{Rate: rate}
+	return d.Detect(frames)
+}
+
+// Detect returns the positions (in frames) of the onsets of the frames, in order.
+// Positions are refined from the windows to where the level starts rising, at a zero crossing,
+// so audio cut there starts cleanly.
+func (d *OnsetDetector) Detect(frames []float64) []int {
+	size := d.Size
+	if size <= 0 {
+		size = 1024
+	}
+	hop := d.Hop
+	if hop <= 0 {
+		hop = max(size/4, 1)
+	}
+	threshold := d.Threshold
+	if threshold <= 0 {
+		threshold = 0.05
+	}
+	gap := d.MinGap
+	if gap <= 0 {
+		gap = 50 * time.Millisecond
+	}
+
+	// Windows are centered on their position, so the flux of a window is the change at its center.
+	// The last windows are left out: the end of the frames would look like a change of the whole spectrum.
+	padded := append(make([]float64, size/2), frames...)
+	columns := Spectrogram(padded, Hann, size, hop)
+	columns = columns[:max(len(columns)-size/hop, 0)]
+	for _, c := range columns {
+		for k, m := range c {
+			c[k] = math.Log1p(logCompression * m) // So quiet hits count as much as loud ones.
+		}
+	}
+	flux := make([]float64, len(columns))
+	var peak float64
+	for i := fluxLag; i < len(columns); i++ {
+		prev := columns[i-fluxLag]
+		for k, m := range columns[i] {
+			// Compared to the loudest neighbor bin a few windows before, so vibrato doesn't count as onsets
+			// (the SuperFlux method).
+			ref := prev[k]
+			if k > 0 {
+				ref = math.Max(ref, prev[k-1])
+			}
+			if k+1 < len(prev) {
+				ref = math.Max(ref, prev[k+1])
+			}
+			if diff := m - ref; diff > 0 {
+				flux[i] += diff
+			}
+		}
+		peak = math.Max(peak, flux[i])
+	}
+	if peak == 0 {
+		return nil
+	}
+	for i := range flux {
+		flux[i] /= peak
+	}
+
+	// Peaks: local maxima above the local median by the threshold, far enough from the previous onset.
+	const near, around = 3, 10 // In windows, for local maxima and medians.
+	minGap := int(gap.Seconds() * float64(d.Rate))
+	var onsets []int
+	for i := 1; i < len(flux); i++ {
+		local := flux[max(i-near, 0):min(i+near+1, len(flux))]
+		if flux[i] < slices.Max(local) {
+			continue
+		}
+		window := slices.Clone(flux[max(i-around, 0):min(i+around+1, len(flux))])
+		slices.Sort(window)
+		if flux[i] < window[len(window)/2]+threshold {
+			continue
+		}
+		pos := refineOnset(frames, i*hop, size)
+		if len(onsets) > 0 && pos-onsets[len(onsets)-1] < minGap {
+			continue
+		}
+		onsets = append(onsets, pos)
+	}
+	return onsets
+}
+
+const (
+	logCompression = 100 // Of magnitudes, before their flux.
+	fluxLag        = 2   // Windows between the compared spectra.
+)
+
+// refineOnset returns where the level starts rising around an onset found at pos by windows of the given size:
+// the first block of frames reaching a fifth of the loudest block after the quietest one before it,
+// moved back to the previous zero crossing.
+func refineOnset(frames []float64, pos, size int) int {
+	const block = 32
+	from, to := max(pos-size/2, 0), min(pos+size/2, len(frames))
+	var energy []float64
+	for i := from; i < to; i += block {
+		var e float64
+		for _, v := range frames[i:min(i+block, to)] {
+			e += v * v
+		}
+		energy = append(energy, e)
+	}
+	if len(energy) == 0 {
+		return min(pos, len(frames))
+	}
+	loudest := 0
+	for i, e := range energy {
+		if e > energy[loudest] {
+			loudest = i
+		}
+	}
+	quietest := 0
+	for i, e := range energy[:loudest+1] {
+		if e <= energy[quietest] {
+			quietest = i
+		}
+	}
+	start := loudest
+	for i := quietest; i <= loudest; i++ {
+		if energy[i] >= energy[loudest]/5 {
+			start = i
+			break
+		}
+	}
+	onset := from + start*block
+	for i := onset; i > max(onset-block, from, 1); i-- {
+		if frames[i-1] <= 0 && frames[i] >= 0 || frames[i-1] >= 0 && frames[i] <= 0 {
+			return i - 1
+		}
+	}
+	return onset
+}
@@ -0,0 +1,144 @@
+package analysis
+
+import (
+	"math"
+	"time"
+)
+
+// PitchEstimate is the fundamental frequency detected in a window of frames.
+type PitchEstimate struct {
+	At         time.Duration // Start of the window.
+	Freq       float64       // In Hertz, 0 if no pitch was found (silence, noise, etc.).
+	Confidence float64       // From 0 (no periodicity) to 1 (perfectly periodic).
+}
+
+// PitchDetector detects the fundamental frequency of successive windows of frames with the YIN algorithm,
+// from rendered audio (see Pitch and PitchTrack) or a live stream written as it comes (see Write).
+// Zero fields use the default values.
+type PitchDetector struct {
+	Rate      int     // Sample rate, in Hertz.
+	Size      int     // Window size, in frames (2048 by default), it must hold two periods of the lowest frequency.
+	Hop       int     // Frames between the start of windows (Size/4 by default).
+	MinFreq   float64 // Lowest detected frequency, in Hertz (50 by default).
+	MaxFreq   float64 // Highest detected frequency, in Hertz (2000 by default).
+	Threshold float64 // Highest aperiodicity of a detected pitch, from 0 to 1 (0.15 by default).
+
+	buf  []float64 // Frames not analyzed yet (starting at the next window).
+	read int       // Frames removed from buf.
+}
+
+// Write analyzes the frames following the previously written ones,
+// and returns the estimates of the windows completed by them.
+func (d *PitchDetector) Write(frames []float64) []PitchEstimate {
+	size, hop := d.size(), d.hop()
+	d.buf = append(d.buf, frames...)
+	var estimates []PitchEstimate
+	for len(d.buf) >= size {
+		e := d.detect(d.buf[:size])
+		e.At = time.Duration(float64(d.read) / float64(d.Rate) * float64(time.Second))
+		estimates = append(estimates, e)
+		d.buf = d.buf[hop:]
+		d.read += hop
+	}
+	d.buf = append(d.buf[:0:0], d.buf...) // Don't keep analyzed frames in memory.
+	return estimates
+}
+
+// Pitch returns the fundamental frequency of the frames analyzed as a single window
+// (like a rendered note), 0 if no pitch was found.
+func Pitch(frames []float64, rate int) (freq, confidence float64) {
+	d := &PitchDetector{Rate: rate, Size: len(frames)}
+	e := d.detect(frames)
+	return e.Freq, e.Confidence
+}
+
+// PitchTrack returns the fundamental frequency of the frames over time, with the default detector settings.
+func PitchTrack(frames []float64, rate int) []PitchEstimate {
+	d := &PitchDetector{Rate: rate}
+	return d.Write(frames)
+}
+
+func (d *PitchDetector) size() int {
+	if d.Size <= 0 {
+		return 2048
+	}
+	return d.Size
+}
+
+func (d *PitchDetector) hop() int {
+	if d.Hop <= 0 {
+		return max(d.size()/4, 1)
+	}
+	return d.Hop
+}
+
+// detect runs YIN on a window: the difference between the frames and the frames delayed by each lag,
+// normalized by its mean over shorter lags, is lowest at the period.
+// The first lag under the threshold is picked (avoiding octave errors on multiples of the period),
+// then refined between lags with a parabola.
+func (d *PitchDetector) detect(frames []float64) PitchEstimate {
+	minFreq, maxFreq, threshold := d.MinFreq, d.MaxFreq, d.Threshold
+	if minFreq <= 0 {
+		minFreq = 50
+	}
+	if maxFreq <= 0 {
+		maxFreq = 2000
+	}
+	if threshold <= 0 {
+		threshold = 0.15
+	}
+	rate := float64(d.Rate)
+	maxLag := min(int(rate/minFreq)+1, len(frames)/2)
+	minLag := max(int(rate/maxFreq), 2)
+	width := len(frames) - maxLag // Frames compared at each lag.
+	if maxLag <= minLag || width <= 0 {
+		return PitchEstimate{}
+	}
+
+	// Cumulative mean normalized difference.
+	diff := make([]float64, maxLag+1)
+	diff[0] = 1
+	var sum float64
+	for lag := 1; lag <= maxLag; lag++ {
+		var z float64
+		for i := 0; i < width; i++ {
+			v := frames[i] - frames[i+lag]
+			z += v * v
+		}
+		sum += z
+		if sum == 0 {
+			diff[lag] = 1 // Silence.
+		} else {
+			diff[lag] = z * float64(lag) / sum
+		}
+	}
+
+	best := -1
+	for lag := minLag; lag < maxLag; lag++ {
+		if diff[lag] < threshold {
+			for lag+1 < maxLag && diff[lag+1] < diff[lag] {
+				lag++ // Down to the local minimum.
+			}
+			best = lag
+			break
+		}
+	}
+	if best < 0 {
+		return PitchEstimate{Confidence: math.Max(0, 1-minimum(diff[minLag:maxLag]))}
+	}
+
+	period := float64(best)
+	a, b, c := diff[best-1], diff[best], diff[best+1]
+	if den := a - 2*b + c; den > 0 {
+		period += (a - c) / (2 * den)
+	}
+	return PitchEstimate{Freq: rate / period, Confidence: math.Max(0, 1-b)}
+}
+
+func minimum(values []float64) float64 {
+	m := math.Inf(1)
+	for _, v := range values {
+		m = math.Min(m, v)
+	}
+	return m
+}
@@ -0,0 +1,150 @@
+package analysis
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/fft"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Processor is a filter or an effect to measure: any function of package synth taking an input and returning its output
+// (wrap it in a closure to set its other parameters).
+type Processor func(in synth.Signal) synth.Signal
+
+// Response is the frequency response of a processor:
+// the gain and the phase shift it applies to each frequency, from 0 Hz to Nyquist.
+type Response struct {
+	Rate      int
+	Magnitude []float64 // In decibels, by bin (see BinFreq with a size of 2*(len(Magnitude)-1)).
+	Phase     []float64 // In radians, between -π and π.
+}
+
+// ImpulseResponse returns the output of a processor to an impulse, over the given duration.
+func ImpulseResponse(p Processor, rate int, length time.Duration) []float64 {
+	// The impulse comes on the second frame since most processors only know the sample rate from there.
+	start := synth.AtFrame(1, rate)
+	return synth.Sample(p(synth.Impulse(start)), rate, 0, start+length)[1:]
+}
+
+// MeasureImpulse returns the frequency response of a processor from its impulse response over the given duration,
+// which must be longer than the filter rings.
+// It is exact for linear processors (like filters), see MeasureSweep for the others.
+func MeasureImpulse(p Processor, rate int, length time.Duration) Response {
+	ir := ImpulseResponse(p, rate, length)
+	buf := make([]complex128, fft.NextPow2(len(ir)))
+	for i, v := range ir {
+		buf[i] = complex(v, 0)
+	}
+	fft.Forward(buf)
+	return newResponse(rate, buf[:len(buf)/2+1])
+}
+
+// MeasureSweep returns the frequency response of a processor from its output to a logarithmic sweep
+// from 20 Hz to near the Nyquist frequency over the given duration, followed by a second of silence for its tail.
+// A sweep has more energy than an impulse, so it measures processors that react to the level of the input
+// (like compressors and saturation) closer to how they are used.
+func MeasureSweep(p Processor, rate int, sweep time.Duration) Response {
+	nyquist := float64(rate) / 2
+	in := synth.LogSweep(20, 0.95*nyquist, sweep)
+	length := sweep + time.Second
+	x, y := synth.Sample(in, rate, 0, length), synth.Sample(p(in), rate, 0, length)
+	size := fft.NextPow2(len(x))
+	bx, by := make([]complex128, size), make([]complex128, size)
+	for i := range x {
+		bx[i], by[i] = complex(x[i], 0), complex(y[i], 0)
+	}
+	fft.Forward(bx)
+	fft.Forward(by)
+
+	// Deconvolution, regularized so bins with little energy in the sweep (outside its range) don't blow up.
+	var peak float64
+	for _, v := range bx[:size/2+1] {
+		peak = math.Max(peak, cmplx.Abs(v))
+	}
+	epsilon := complex(peak*peak*1e-6, 0)
+	h := make([]complex128, size/2+1)
+	for i := range h {
+		h[i] = by[i] * cmplx.Conj(bx[i]) / (bx[i]*cmplx.Conj(bx[i]) + epsilon)
+	}
+	return newResponse(rate, h)
+}
+
+func newResponse(rate int, spectrum []complex128) Response {
+	r := Response{Rate: rate, Magnitude: make([]float64, len(spectrum)), Phase: make([]float64, len(spectrum))}
+	for i, v := range spectrum {
+		r.Magnitude[i] = synth.AmpToDB(math.Max(cmplx.Abs(v), 1e-12))
+		r.Phase[i] = cmplx.Phase(v)
+	}
+	return r
+}
+
+// At returns the gain (in decibels) and phase shift (in radians) at the given frequency (in Hertz),
+// from the closest bin.
+func (r Response) At(freq float64) (db, phase float64) {
+	if len(r.Magnitude) == 0 {
+		return math.Inf(-1), 0
+	}
+	bin := int(math.Round(freq / (float64(r.Rate) / 2) * float64(len(r.Magnitude)-1)))
+	bin = max(0, min(bin, len(r.Magnitude)-1))
+	return r.Magnitude[bin], r.Phase[bin]
+}
+
+// ResponseImage plots the magnitude of a response from 20 Hz to the Nyquist frequency (on a logarithmic scale)
+// and from minDB to maxDB, with lines at each decade and every 6 dB (0 dB is darker).
+func ResponseImage(r Response, width, height int, minDB, maxDB float64) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	background, grid, axis, curve := color.RGBA{255, 255, 255, 255}, color.RGBA{225, 225, 225, 255},
+		color.RGBA{160, 160, 160, 255}, color.RGBA{32, 64, 160, 255}
+	low, high := math.Log10(20), math.Log10(float64(r.Rate)/2)
+	freq := func(x int) float64 { return math.Pow(10, low+(high-low)*float64(x)/float64(max(width-1, 1))) }
+	row := func(db float64) int {
+		db = math.Max(minDB, math.Min(db, maxDB))
+		return int(math.Round((maxDB - db) / (maxDB - minDB) * float64(height-1)))
+	}
+
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, background)
+		}
+	}
+	for db := math.Ceil(minDB/6) * 6; db <= maxDB; db += 6 {
+		c := grid
+		if db == 0 {
+			c = axis
+		}
+		for x := 0; x < width; x++ {
+			img.Set(x, row(db), c)
+		}
+	}
+	for decade := 10.0; decade < float64(r.Rate)/2; decade *= 10 {
+		x := int(math.Round((math.Log10(decade) - low) / (high - low) * float64(width-1)))
+		for y := 0; y < height && x >= 0; y++ {
+			img.Set(x, y, grid)
+		}
+	}
+
+	prev := -1
+	for x := 0; x < width; x++ {
+		db, _ := r.At(freq(x))
+		y := row(db)
+		if prev < 0 {
+			prev = y
+		}
+		for i := min(y, prev); i <= max(y, prev); i++ {
+			img.Set(x, i, curve) // Joined to the previous column so steep slopes stay visible.
+		}
+		prev = y
+	}
+	return img
+}
+
+// WriteResponsePNG plots the magnitude of a response from -48 to +24 dB (see ResponseImage) as a PNG image.
+func WriteResponsePNG(w io.Writer, r Response, width, height int) error {
+	return png.Encode(w, ResponseImage(r, width, height, -48, 24))
+}
@@ -0,0 +1,93 @@
+// Package analysis measures rendered audio (spectrum, level, etc.) and draws it as images,
+// to check aliasing, filter responses and the harmonic content of patches.
+package analysis
+
+import (
+	"math"
+	"math/cmplx"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/fft"
+)
+
+// Window returns the weights of a window function of the given size, applied to frames before a FFT
+// to reduce spectral leakage.
+type Window func(size int) []float64
+
+// Rectangular doesn't weight frames (the best frequency resolution, but the most leakage).
+func Rectangular(size int) []float64 { return cosineWindow(size, 1, 0, 0) }
+
+// Hann is a good general purpose window.
+func Hann(size int) []float64 { return cosineWindow(size, 0.5, 0.5, 0) }
+
+// Hamming has a lower first side lobe than Hann, but side lobes decreasing more slowly.
+func Hamming(size int) []float64 { return cosineWindow(size, 0.54, 0.46, 0) }
+
+// Blackman has very low side lobes, to see quiet components (like aliasing) next to loud ones.
+func Blackman(size int) []float64 { return cosineWindow(size, 0.42, 0.5, 0.08) }
+
+// cosineWindow returns a0 - a1 cos(2πn/N) + a2 cos(4πn/N).
+func cosineWindow(size int, a0, a1, a2 float64) []float64 {
+	w := make([]float64, size)
+	for i := range w {
+		t := 2 * math.Pi * float64(i) / float64(size)
+		w[i] = a0 - a1*math.Cos(t) + a2*math.Cos(2*t)
+	}
+	return w
+}
+
+// Spectrum returns the magnitude spectrum of the frames (size/2+1 bins from 0 Hz to Nyquist, see BinFreq),
+// averaged over windows of the given size (rounded up to a power of two) overlapping by half.
+// Magnitudes are scaled so a full scale sine gives 1 at its frequency (use synth.AmpToDB for decibels).
+func Spectrum(frames []float64, window Window, size int) []float64 {
+	size = fft.NextPow2(size)
+	sum := make([]float64, size/2+1)
+	count := 0
+	for start := 0; start == 0 || start+size <= len(frames); start += size / 2 {
+		for i, m := range magnitudes(frames, start, window(size)) {
+			sum[i] += m
+		}
+		count++
+	}
+	for i := range sum {
+		sum[i] /= float64(count)
+	}
+	return sum
+}
+
+// Spectrogram returns the magnitude spectrum (like Spectrum) of successive windows of the frames,
+// each starting hop frames after the previous one.
+func Spectrogram(frames []float64, window Window, size, hop int) [][]float64 {
+	size = fft.NextPow2(size)
+	w := window(size)
+	var columns [][]float64
+	for start := 0; start < len(frames); start += max(hop, 1) {
+		columns = append(columns, magnitudes(frames, start, w))
+	}
+	return columns
+}
+
+// BinFreq returns the frequency (in Hertz) of a bin of a spectrum computed with the given size and sample rate.
+func BinFreq(bin, size, rate int) float64 {
+	return float64(bin) * float64(rate) / float64(fft.NextPow2(size))
+}
+
+// magnitudes returns the scaled magnitudes of the windowed frames starting at start (zero-padded).
+func magnitudes(frames []float64, start int, window []float64) []float64 {
+	size := len(window)
+	buf := make([]complex128, size)
+	var gain float64
+	for i, w := range window {
+		if start+i < len(frames) {
+			buf[i] = complex(frames[start+i]*w, 0)
+		}
+		gain += w
+	}
+	fft.Forward(buf)
+	mags := make([]float64, size/2+1)
+	for i := range mags {
+		mags[i] = 2 * cmplx.Abs(buf[i]) / gain
+	}
+	mags[0] /= 2 // DC and Nyquist aren't split between positive and negative frequencies.
+	mags[size/2] /= 2
+	return mags
+}
@@ -0,0 +1,97 @@
+// Package block renders signals block by block instead of sample by sample.
+//
+// Evaluating a synth.Signal costs a function call per node and per sample, which adds up for deep patches.
+// A Source instead fills a whole buffer at once, so each node runs a tight loop over its frames.
+// Adapters convert between both worlds so block-based and per-sample nodes can be mixed in a patch.
+package block
+
+import (
+	"io"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Source renders audio frames block by block.
+//
+// Process fills out with the frames starting at the given frame index.
+// Sources are usually processed with contiguous blocks (each block starting where the previous one ended),
+// stateful sources restart from a fresh state when that's not the case.
+type Source interface {
+	Process(out []float64, start int)
+}
+
+// Func is a stateless source implemented by a function.
+type Func func(out []float64, start int)
+
+// Process calls f.
+func (f Func) Process(out []float64, start int) { f(out, start) }
+
+// FromSignal returns a source evaluating the signal at each frame (at the given rate, in frames per second).
+func FromSignal(s synth.Signal, rate int) Source {
+	return Func(func(out []float64, start int) {
+		for i := range out {
+			out[i] = s(synth.AtFrame(start+i, rate))
+		}
+	})
+}
+
+// ToSignal returns a signal reading the frames of a source (at the given rate),
+// rendered in blocks of the given size as the signal is evaluated.
+func ToSignal(src Source, rate, size int) synth.Signal {
+	buf := make([]float64, size)
+	start := -1 // Index of the first frame in the buffer.
+	return func(x time.Duration) float64 {
+		frame := synth.FrameAt(x, rate)
+		if start < 0 || frame < start || frame >= start+size {
+			if start < 0 || frame != start+size {
+				start = frame // Not contiguous: jump to the requested frame.
+			} else {
+				start += size
+			}
+			src.Process(buf, start)
+		}
+		return buf[frame-start]
+	}
+}
+
+// Sample renders n frames of the source, starting from the first one.
+func Sample(src Source, n int) []float64 {
+	out := make([]float64, n)
+	src.Process(out, 0)
+	return out
+}
+
+// Reader reads the frames of a source block by block, it can be encoded with the encode package.
+type Reader struct {
+	src         Source
+	frame, last int
+}
+
+// NewReader returns a reader of the first n frames of the source.
+func NewReader(src Source, n int) *Reader {
+	return &Reader{src: src, last: n}
+}
+
+// Read renders the next frames of the source into frames.
+// It returns io.EOF once all frames have been read.
+func (r *Reader) Read(frames []float64) (n int, err error) {
+	if r.frame >= r.last {
+		return 0, io.EOF
+	}
+	n = min(len(frames), r.last-r.frame)
+	r.src.Process(frames[:n], r.frame)
+	r.frame += n
+	return n, nil
+}
+
+// Len returns the number of frames left to read.
+func (r *Reader) Len() int { return r.last - r.frame }
+
+// scratch returns a buffer of n frames, reusing buf if it is large enough.
+func scratch(buf *[]float64, n int) []float64 {
+	if cap(*buf) < n {
+		*buf = make([]float64, n)
+	}
+	return (*buf)[:n]
+}
@@ -0,0 +1,138 @@
+package block
+
+import (
+	"math"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Constant returns a source that always outputs v.
+func Constant(v float64) Source {
+	return Func(func(out []float64, start int) {
+		for i := range out {
+			out[i] = v
+		}
+	})
+}
+
+type sine struct {
+	freq  Source
+	rate  float64
+	next  int // Frame expected in the next block.
+	phase float64
+	buf   []float64
+}
+
+// Sine returns a sine wave oscillating at the given frequency (in Hertz), using a phase accumulator.
+func Sine(freq Source, rate int) Source {
+	return &sine{freq: freq, rate: float64(rate), next: -1}
+}
+
+func (s *sine) Process(out []float64, start int) {
+	freq := scratch(&s.buf, len(out))
+	s.freq.Process(freq, start)
+	if start != s.next {
+		s.phase = math.Mod(float64(start)/s.rate*freq[0], 1)
+	}
+	for i, f := range freq {
+		out[i] = math.Sin(2 * math.Pi * s.phase)
+		s.phase += f / s.rate
+		s.phase -= math.Floor(s.phase)
+	}
+	s.next = start + len(out)
+}
+
+type sum struct {
+	srcs []Source
+	buf  []float64
+}
+
+// Add returns the sum of the given sources.
+func Add(srcs ...Source) Source { return &sum{srcs: srcs} }
+
+func (s *sum) Process(out []float64, start int) {
+	clear(out)
+	tmp := scratch(&s.buf, len(out))
+	for _, src := range s.srcs {
+		src.Process(tmp, start)
+		for i, v := range tmp {
+			out[i] += v
+		}
+	}
+}
+
+type product struct {
+	srcs []Source
+	buf  []float64
+}
+
+// Mul returns the product of the given sources.
+func Mul(srcs ...Source) Source { return &product{srcs: srcs} }
+
+func (p *product) Process(out []float64, start int) {
+	for i := range out {
+		out[i] = 1
+	}
+	tmp := scratch(&p.buf, len(out))
+	for _, src := range p.srcs {
+		src.Process(tmp, start)
+		for i, v := range tmp {
+			out[i] *= v
+		}
+	}
+}
+
+// Gain returns the source amplified by the given gain (in decibels).
+func Gain(src Source, db float64) Source {
+	factor := synth.DBToAmp(db)
+	return Func(func(out []float64, start int) {
+		src.Process(out, start)
+		for i := range out {
+			out[i] *= factor
+		}
+	})
+}
+
+type filter struct {
+	in, freq, q, gain Source
+	design            synth.BiquadDesign
+	rate              float64
+	next              int
+	x1, x2, y1, y2    float64
+	buf               []float64
+}
+
+// Filter runs the input through a biquad filter (see synth.Filter), its parameters are read once per block.
+func Filter(in Source, rate int, design synth.BiquadDesign, freq, q, gain Source) Source {
+	return &filter{in: in, freq: freq, q: q, gain: gain, design: design, rate: float64(rate), next: -1}
+}
+
+// LowPass attenuates frequencies above the cutoff (in Hertz), see synth.LowPass.
+func LowPass(in Source, rate int, cutoff, q Source) Source {
+	return Filter(in, rate, synth.LowPassBiquad, cutoff, q, Constant(0))
+}
+
+func (f *filter) Process(out []float64, start int) {
+	if start != f.next {
+		f.x1, f.x2, f.y1, f.y2 = 0, 0, 0, 0
+	}
+	f.next = start + len(out)
+	f.in.Process(out, start)
+
+	// Parameters are read at the first frame of the block
+	// (whole blocks are still processed so stateful parameters stay contiguous).
+	param := scratch(&f.buf, len(out))
+	f.freq.Process(param, start)
+	freq := param[0]
+	f.q.Process(param, start)
+	q := param[0]
+	f.gain.Process(param, start)
+	b := f.design(f.rate, freq, q, param[0])
+
+	for i, v := range out {
+		y := b.B0*v + b.B1*f.x1 + b.B2*f.x2 - b.A1*f.y1 - b.A2*f.y2
+		f.x2, f.x1 = f.x1, v
+		f.y2, f.y1 = f.y1, y
+		out[i] = y
+	}
+}
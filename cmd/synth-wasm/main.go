@@ -0,0 +1,72 @@
+//go:build js && wasm
+
+// Command synth-wasm runs patches in the browser (see the web directory for the JavaScript side).
+//
+// Build it with:
+//
+//	GOOS=js GOARCH=wasm go build -o cmd/synth-wasm/web/synth.wasm ./cmd/synth-wasm
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" cmd/synth-wasm/web/
+//
+// It exposes a goSynth object to JavaScript:
+//
+//	goSynth.load(patchJSON, sampleRate) // Builds a patch, returns an error message or null.
+//	goSynth.render(frames)              // Returns the next frames of the patch as a Float32Array.
+//
+// Rendering happens on the main thread (where Go runs), blocks are then posted to an AudioWorklet
+// that plays them from a queue (see bridge.js and worklet.js).
+package main
+
+import (
+	"syscall/js"
+	"time"
+	"unsafe"
+
+	"github.com/ejuju/poc-go-audio-synthesis/patch"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// maxLength is the length of the stream of a patch, which plays regardless of its duration.
+const maxLength = 24 * time.Hour
+
+func main() {
+	var stream *synth.Stream
+	var frames []float64
+	var block []float32
+
+	js.Global().Set("goSynth", js.ValueOf(map[string]any{
+		"load": js.FuncOf(func(this js.Value, args []js.Value) any {
+			p, err := patch.Parse([]byte(args[0].String()))
+			if err != nil {
+				return err.Error()
+			}
+			if len(args) > 1 && args[1].Truthy() {
+				p.Rate = args[1].Int()
+			}
+			signal, err := p.Build()
+			if err != nil {
+				return err.Error()
+			}
+			stream = synth.SampleStream(signal, p.Rate, 0, maxLength)
+			return nil
+		}),
+		"render": js.FuncOf(func(this js.Value, args []js.Value) any {
+			n := args[0].Int()
+			if cap(frames) < n {
+				frames, block = make([]float64, n), make([]float32, n)
+			}
+			frames, block = frames[:n], block[:n]
+			clear(frames)
+			if stream != nil {
+				stream.Read(frames)
+			}
+			for i, v := range frames {
+				block[i] = float32(v)
+			}
+			out := js.Global().Get("Float32Array").New(n)
+			bytes := unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(block))), 4*n)
+			js.CopyBytesToJS(js.Global().Get("Uint8Array").New(out.Get("buffer")), bytes)
+			return out
+		}),
+	}))
+	select {} // Keep the functions available.
+}
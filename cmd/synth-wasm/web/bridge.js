@@ -0,0 +1,30 @@
+// Runs synth.wasm on the main thread and feeds the blocks it renders to the worklet.
+
+const blockSize = 4096; // Frames rendered at once (about 90ms at 44.1kHz).
+
+// start loads a patch (as JSON) and plays it, it returns the audio context (close it to stop).
+export async function start(patchJSON) {
+  if (!globalThis.goSynth) {
+    const go = new Go(); // From wasm_exec.js.
+    const { instance } = await WebAssembly.instantiateStreaming(fetch("synth.wasm"), go.importObject);
+    go.run(instance);
+  }
+
+  const ctx = new AudioContext();
+  const err = goSynth.load(patchJSON, ctx.sampleRate);
+  if (err) {
+    await ctx.close();
+    throw new Error(err);
+  }
+  await ctx.audioWorklet.addModule("worklet.js");
+  const node = new AudioWorkletNode(ctx, "synth", { outputChannelCount: [2] });
+  const send = () => {
+    const block = goSynth.render(blockSize);
+    node.port.postMessage(block, [block.buffer]);
+  };
+  node.port.onmessage = send;
+  send();
+  send();
+  node.connect(ctx.destination);
+  return ctx;
+}
@@ -0,0 +1,41 @@
+// Plays blocks of frames posted by bridge.js, asking for more when the queue runs low.
+class SynthProcessor extends AudioWorkletProcessor {
+  constructor() {
+    super();
+    this.queue = []; // Float32Array blocks.
+    this.offset = 0; // Position in the first block.
+    this.queued = 0; // Number of frames queued.
+    this.waiting = false; // Whether more frames were asked for.
+    this.port.onmessage = (e) => {
+      this.queue.push(e.data);
+      this.queued += e.data.length;
+      this.waiting = false;
+    };
+  }
+
+  process(inputs, outputs) {
+    const out = outputs[0];
+    const mono = out[0];
+    for (let i = 0; i < mono.length; i++) {
+      if (this.queue.length === 0) {
+        mono.fill(0, i); // Underrun: the main thread is rendering too slowly.
+        break;
+      }
+      const block = this.queue[0];
+      mono[i] = block[this.offset++];
+      if (this.offset === block.length) {
+        this.queue.shift();
+        this.offset = 0;
+      }
+    }
+    this.queued = Math.max(0, this.queued - mono.length);
+    for (let c = 1; c < out.length; c++) out[c].set(mono);
+    if (this.queued < sampleRate / 10 && !this.waiting) {
+      this.waiting = true;
+      this.port.postMessage("more");
+    }
+    return true;
+  }
+}
+
+registerProcessor("synth", SynthProcessor);
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/live"
+	"github.com/ejuju/poc-go-audio-synthesis/param"
+	"github.com/ejuju/poc-go-audio-synthesis/playback"
+	"github.com/ejuju/poc-go-audio-synthesis/sampler"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+func runLive(args []string) (err error) {
+	fs := flag.NewFlagSet("synth live", flag.ContinueOnError)
+	device := fs.String("midi", "", "raw MIDI input device (like /dev/snd/midiC1D0), - for the standard input")
+	wave := fs.String("wave", "saw", "waveform: sine, saw, square, triangle")
+	voices := fs.Int("voices", 8, "number of voices")
+	rate := fs.Int("rate", 44100, "sample rate (in Hz)")
+	oscAddr := fs.String("osc", "", `UDP address receiving OSC messages (like ":9000"), for "/filter/cutoff", "/filter/q" and notes`)
+	sfz := fs.String("sfz", "", "play a multi-sampled instrument (an SFZ file) instead of the waveform")
+	jack := fs.String("jack", "", "play through a JACK client with this name (run by GStreamer, connected to the system outputs) instead of the default output")
+	record := fs.String("record", "", "also record the playback to this WAV file (finished when interrupted)")
+	recordParams := fs.String("record-params", "", "record the changes of the filter (from MIDI or OSC) to this JSON file (written when interrupted)")
+	automation := fs.String("automation", "", "play the changes of the filter recorded in this JSON file (instead of the MIDI or OSC ones)")
+	err = fs.Parse(args)
+	if err != nil {
+		return err
+	} else if *device == "" && *oscAddr == "" {
+		return errors.New("usage: synth live [--midi /dev/snd/midiC1D0] [--osc :9000] [--record session.wav] [--record-params params.json] [--automation params.json] [flags]")
+	}
+	osc, ok := waves[*wave]
+	if !ok {
+		return fmt.Errorf("unknown waveform %q", *wave)
+	}
+
+	// The modulation wheel (CC 1) opens the filter.
+	params := param.NewRegistry()
+	cutoff, q := params.Add("filter/cutoff", 2000), params.Add("filter/q", 0.707)
+	var rec *param.Recording
+	if *automation != "" {
+		rec, err = param.LoadRecording(*automation)
+		if err != nil {
+			return err
+		}
+	}
+	// smooth returns the smoothed parameter, or its recorded changes (like the "param" modules of patches).
+	smooth := func(p *param.Param, name string) synth.Signal {
+		if rec != nil && rec.Points(name) != nil {
+			return synth.Glide(rec.Signal(name), synth.Constant(param.DefaultSmoothing))
+		}
+		return p.Smooth(param.DefaultSmoothing)
+	}
+	filter := func(in synth.Signal) synth.Signal {
+		return synth.LowPass(in, smooth(cutoff, "filter/cutoff"), smooth(q, "filter/q"))
+	}
+	voice := func(freq, gate synth.Signal) synth.Signal {
+		env := synth.ADSR(gate, 5*time.Millisecond, 200*time.Millisecond, 0.6, 300*time.Millisecond)
+		return synth.Mul(env, filter(osc(freq)))
+	}
+	if *sfz != "" {
+		instrument, err := sampler.LoadSFZ(*sfz)
+		if err != nil {
+			return err
+		}
+		voice = func(freq, gate synth.Signal) synth.Signal { return filter(instrument.Voice(freq, gate)) }
+	}
+	poly := live.NewPoly(*voices, voice)
+	out := synth.Gain(poly.Signal(), -12)
+	player := playback.Player{}
+	if *jack != "" {
+		player = playback.Player{Backend: playback.JACK{Client: *jack, Connect: true}, BufferSize: 256}
+	}
+	player, err = recordingPlayer(player, *record, *rate)
+	if err != nil {
+		return err
+	}
+	if *recordParams != "" {
+		head := &playhead{}
+		recorder := params.RecordAt(head.now)
+		defer func() { err = errors.Join(err, saveRecording(recorder, *recordParams)) }()
+		out = head.track(out)
+	}
+	stopper, err := player.Play(out, *rate)
+	if err != nil {
+		return err
+	}
+
+	errs := make(chan error, 2)
+	if *oscAddr != "" {
+		conn, err := net.ListenPacket("udp", *oscAddr)
+		if err != nil {
+			return errors.Join(err, stopper.Stop())
+		}
+		defer conn.Close()
+		go func() { errs <- live.ListenOSC(conn, poly, params) }()
+	}
+	if *device != "" {
+		in := os.Stdin
+		if *device != "-" {
+			in, err = os.Open(*device)
+			if err != nil {
+				return errors.Join(err, stopper.Stop())
+			}
+			defer in.Close()
+		}
+		go func() { errs <- live.ListenMIDI(in, poly, map[int]live.CC{1: {Param: cutoff, Min: 200, Max: 10000}}) }()
+	}
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	select {
+	case err := <-errs:
+		return errors.Join(err, stopper.Stop())
+	case <-interrupted:
+		return stopInterrupted(stopper)
+	}
+}
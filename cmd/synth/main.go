@@ -0,0 +1,399 @@
+// Command synth renders sounds to an audio file (or to the standard output).
+//
+// Usage:
+//
+//	synth [--wave sine] [--freq 440] [--dur 5s] [--rate 44100] [-o -] [--format f64be]
+//	synth render [-o -] [--format f64be] [--stems dir] [--automation take.json] patch.json
+//	synth render --sf2 font.sf2 [-o -] [--format f64be] song.mid
+//	synth play [--watch] [--loop] [--record session.wav] [--profile] [--osc :9000] [--record-params take.json] [--automation take.json] [--link 4] patch.json
+//	synth live [--midi /dev/snd/midiC1D0] [--osc :9000] [--wave saw] [--sfz instrument.sfz] [--voices 8] [--jack name] [--record session.wav] [--record-params take.json] [--automation take.json]
+//	synth resample --rate 44100 -o out.wav in.wav
+//	synth serve [--addr :8080] [--loop] [--format wav] patch.json
+//	synth verify [--update] [--case name] suite.json
+//
+// Without a command, synth renders a simple tone. The render command renders a patch file (see package patch),
+// or a MIDI file with the sounds of a SoundFont;
+// the play command plays it in real time (reloading it on changes with --watch, for live coding).
+// The live command plays notes from a MIDI keyboard (or OSC messages) in real time, and the resample command converts the sample rate of a WAV file.
+// Both play and live record what they play to a WAV file with --record, and the changes of their parameters
+// to a JSON file with --record-params, played back instead of the live changes with --automation
+// (and rendered offline by render --automation).
+// The serve command streams a patch over HTTP in real time, to listen to it in a browser.
+// The verify command checks that patches still render like their golden renders (see package golden).
+//
+// The output format is inferred from the file extension (".wav", ".aiff", ".flac", ".opus" and ".ogg" files
+// are encoded in their format, other files as raw F64BE PCM) unless --format is given.
+// Opus and Ogg Vorbis files are encoded by opusenc and oggenc, which must be installed.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/analysis"
+	"github.com/ejuju/poc-go-audio-synthesis/encode"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// commands are the subcommands, by name.
+var commands = map[string]func(args []string) error{
+	"render":   runRender,
+	"play":     runPlay,
+	"live":     runLive,
+	"resample": runResample,
+	"serve":    runServe,
+	"verify":   runVerify,
+}
+
+func main() {
+	args := os.Args[1:]
+	run := runTone
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			run, args = cmd, args[1:]
+		}
+	}
+	err := run(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "synth:", err)
+		os.Exit(1)
+	}
+}
+
+var waves = map[string]func(freq synth.Signal) synth.Signal{
+	"sine":     synth.Sine,
+	"saw":      synth.Saw,
+	"square":   synth.Square,
+	"triangle": synth.Triangle,
+	"noise":    func(synth.Signal) synth.Signal { return synth.WhiteNoise(1) },
+}
+
+func runTone(args []string) error {
+	fs := flag.NewFlagSet("synth", flag.ContinueOnError)
+	wave := fs.String("wave", "sine", "waveform: sine, saw, square, triangle or noise")
+	freq := fs.Float64("freq", 440, "frequency in Hertz")
+	dur := fs.Duration("dur", 5*time.Second, "duration")
+	rate := fs.Int("rate", 44100, "sample rate in frames per second")
+	gain := fs.Float64("gain", 0, "gain in decibels")
+	out := outputFlags(fs)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	newWave, ok := waves[*wave]
+	if !ok {
+		return fmt.Errorf("unknown waveform %q", *wave)
+	}
+	signal := synth.Gain(newWave(synth.Constant(*freq)), *gain)
+	frames := synth.SampleStream(signal, *rate, 0, *dur)
+	return out.write(frames)
+}
+
+// output holds the flags describing where and how to write a render.
+type output struct {
+	path, format *string
+	bits         *int
+	lufs         *float64
+	dither       *string
+	dc           *string
+	clip         *string
+	progress     *bool
+	timestamp    *bool
+	meta         encode.WAVMetadata // Written in WAV files only (see wavMetadata).
+}
+
+func outputFlags(fs *flag.FlagSet) output {
+	return output{
+		path:     fs.String("o", "-", `output file ("-" for the standard output)`),
+		format:   fs.String("format", "", `output format: "wav", "aiff", "flac", "opus", "ogg" or a raw PCM format like "s16le" (inferred from the output file by default)`),
+		bits:     fs.Int("bits", 16, "bit depth of WAV, AIFF and FLAC files: 16, 24 or 32 (float in WAV files, not supported by FLAC)"),
+		dither:   fs.String("dither", "none", `dither when quantizing to integers: "none", "tpdf" or "shaped"`),
+		dc:       fs.String("dc", "warn", `DC offset handling: "warn" (on the standard error), "fix" (remove it) or "ignore"`),
+		clip:     fs.String("clip", "", `handling of samples beyond ±1: "hard" (clamp), "soft" (saturate), "error" or "none" (default: "hard" for integer formats, "none" for float formats)`),
+		lufs:     fs.Float64("lufs", 0, "normalize the loudness to this target in LUFS (like -14), 0 to keep the level"),
+		progress: fs.Bool("progress", false, "report the progress of the render on the standard error"),
+		timestamp: fs.Bool("timestamp", false, "write the time of the render in WAV files (the time of $SOURCE_DATE_EPOCH if set, which also enables it),"+
+			" identical renders being byte-identical otherwise"),
+	}
+}
+
+// stream is a stream of frames to write, like synth.Stream.
+type stream interface {
+	encode.FrameReader
+	Len() int
+	Rate() int
+}
+
+// write encodes the frames to the output file (or the standard output for "-").
+func (o output) write(frames stream) (err error) {
+	path, format, bits := *o.path, *o.format, *o.bits
+	if format == "" {
+		format = inferFormat(path)
+	}
+	var w io.Writer = os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer func() { err = errors.Join(err, f.Close()) }()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	defer func() { err = errors.Join(err, bw.Flush()) }()
+
+	stopProgress := func() {}
+	if p, ok := frames.(interface{ Progress() (done, total int) }); ok && *o.progress {
+		stopProgress = sync.OnceFunc(reportProgress(p.Progress))
+		defer stopProgress()
+	}
+
+	n, channels := frames.Len(), channelCount(frames)
+	var r encode.FrameReader = frames
+	if *o.lufs != 0 {
+		// The whole render is measured before it is written (an interrupted render isn't written).
+		all, err := readAll(frames, n*channels)
+		stopProgress()
+		if err != nil {
+			return err
+		}
+		all, _ = analysis.Normalize(all, frames.Rate(), *o.lufs)
+		r, n = &sliceReader{frames: all, channels: channels}, len(all)/channels
+	}
+	var meter *encode.DCMeter
+	switch *o.dc {
+	case "warn":
+		meter = encode.NewDCMeter(r)
+		r = meter
+		defer func() {
+			if err == nil && meter.Significant() {
+				fmt.Fprintf(os.Stderr, "synth: warning: significant DC offset %.3f (use --dc fix to remove it)\n", meter.Offsets())
+			}
+		}()
+	case "fix":
+		r = encode.RemoveDC(r, frames.Rate())
+	case "ignore":
+	default:
+		return fmt.Errorf("invalid DC offset handling %q", *o.dc)
+	}
+
+	clipName := *o.clip
+	if clipName == "" {
+		clipName = "hard"
+		if floatFormat(format, bits) {
+			clipName = "none" // Overs are encoded losslessly.
+		}
+	}
+	clipping, err := encode.ParseClipping(clipName)
+	if err != nil {
+		return err
+	}
+	clip := encode.Clip(r, clipping)
+	r = clip
+	defer func() {
+		if err != nil || clip.Clipped() == 0 {
+			return
+		} else if clipping == encode.NoClip {
+			fmt.Fprintf(os.Stderr, "synth: warning: %d samples beyond ±1 (kept in the float output)\n", clip.Clipped())
+		} else {
+			fmt.Fprintf(os.Stderr, "synth: warning: %d samples clipped\n", clip.Clipped())
+		}
+	}()
+
+	defer stopProgress() // Stopped before the warnings are printed.
+
+	dither, err := encode.ParseDither(*o.dither)
+	if err != nil {
+		return err
+	}
+	enc, err := encoder(format, bits, dither)
+	if err != nil {
+		return err
+	}
+	if format == "wav" {
+		meta, err := o.wavMetadata(frames.Rate(), bits, channels)
+		if err != nil {
+			return err
+		}
+		wav := encode.Format{BitDepth: bits, Float: bits == 32, Dither: dither}
+		enc = encode.EncoderFunc(func(w io.Writer, r encode.FrameReader, n, rate int) error {
+			return wav.WriteWAVWith(w, r, n, rate, meta)
+		})
+	} else if len(o.meta.Cues) > 0 || len(o.meta.Loops) > 0 {
+		fmt.Fprintln(os.Stderr, "synth: warning: cue points and loops are only written to WAV files")
+	}
+	return enc.Encode(bw, r, n, frames.Rate())
+}
+
+// reportProgress prints the progress of a render on the standard error every half second,
+// until the returned function is called.
+func reportProgress(progress func() (done, total int)) (stop func()) {
+	quit, finished := make(chan struct{}), make(chan struct{})
+	print := func() {
+		done, total := progress()
+		fmt.Fprintf(os.Stderr, "\rsynth: %3.0f%% (%d/%d frames)", 100*float64(done)/float64(max(total, 1)), done, total)
+	}
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				print()
+			case <-quit:
+				print()
+				fmt.Fprintln(os.Stderr)
+				return
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		<-finished
+	}
+}
+
+// wavMetadata returns the metadata of the output with its provenance:
+// the Broadcast Wave chunk and the software tag are always written, the time of the render only with --timestamp
+// or $SOURCE_DATE_EPOCH (so renders stay reproducible).
+func (o output) wavMetadata(rate, bits, channels int) (encode.WAVMetadata, error) {
+	meta := o.meta
+	bw := encode.Broadcast{}
+	if meta.Broadcast != nil {
+		bw = *meta.Broadcast
+	}
+	bw.Originator = "synth"
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" && bw.Origination.IsZero() {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return meta, fmt.Errorf("invalid SOURCE_DATE_EPOCH: %w", err)
+		}
+		bw.Origination = time.Unix(seconds, 0).UTC()
+	} else if *o.timestamp && bw.Origination.IsZero() {
+		bw.Origination = time.Now()
+	}
+	if bw.CodingHistory == "" {
+		// The modes of EBU R98 only describe mono and stereo files.
+		mode := map[int]string{1: ",M=mono", 2: ",M=stereo"}[channels]
+		bw.CodingHistory = fmt.Sprintf("A=PCM,F=%d,W=%d%s,T=synth\r\n", rate, bits, mode)
+	}
+	meta.Broadcast = &bw
+	info := map[string]string{"ISFT": "synth"}
+	for id, v := range meta.Info {
+		info[id] = v
+	}
+	meta.Info = info
+	return meta, nil
+}
+
+// channelCount returns the number of interleaved channels of a stream (like synth.MultiStream), 1 if it doesn't say.
+func channelCount(r encode.FrameReader) int {
+	if c, ok := r.(interface{ Channels() int }); ok {
+		return c.Channels()
+	}
+	return 1
+}
+
+// encoder returns the encoder of a format name ("wav", "opus", "s16le"...).
+func encoder(format string, bits int, dither encode.Dither) (encode.Encoder, error) {
+	switch format {
+	case "wav":
+		wav := encode.Format{BitDepth: bits, Float: bits == 32, Dither: dither}
+		return encode.EncoderFunc(wav.WriteWAV), nil
+	case "aiff":
+		aiff := encode.Format{BitDepth: bits, BigEndian: true, Dither: dither}
+		return encode.EncoderFunc(aiff.WriteAIFF), nil
+	case "flac":
+		flac := encode.Format{BitDepth: bits, Dither: dither}
+		return encode.EncoderFunc(flac.WriteFLAC), nil
+	case "opus":
+		return encode.Opus, nil
+	case "ogg", "vorbis":
+		return encode.Vorbis, nil
+	}
+	pcm, err := encode.ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	pcm.Dither = dither
+	return encode.EncoderFunc(func(w io.Writer, r encode.FrameReader, _, _ int) error {
+		_, err := io.Copy(w, pcm.NewReader(r))
+		return err
+	}), nil
+}
+
+// floatFormat reports whether the output format encodes floats (32-bit WAV files and float PCM formats like f64be).
+func floatFormat(format string, bits int) bool {
+	switch format {
+	case "wav":
+		return bits == 32
+	case "aiff", "flac", "opus", "ogg", "vorbis":
+		return false
+	}
+	pcm, err := encode.ParseFormat(format)
+	return err == nil && pcm.Float
+}
+
+// readAll reads the samples of a stream (up to n), until its end.
+func readAll(r encode.FrameReader, n int) ([]float64, error) {
+	all := make([]float64, n)
+	read := 0
+	for read < n {
+		m, err := r.Read(all[read:])
+		read += m
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return all[:read], nil
+}
+
+// sliceReader reads frames from memory.
+type sliceReader struct {
+	frames   []float64 // Interleaved samples.
+	channels int
+}
+
+func (r *sliceReader) Channels() int { return r.channels }
+
+func (r *sliceReader) Read(frames []float64) (int, error) {
+	if len(r.frames) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(frames, r.frames)
+	r.frames = r.frames[n:]
+	return n, nil
+}
+
+// inferFormat returns the format matching the extension of the output file.
+func inferFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav", ".wave":
+		return "wav"
+	case ".aif", ".aiff":
+		return "aiff"
+	case ".flac":
+		return "flac"
+	case ".opus":
+		return "opus"
+	case ".ogg", ".oga":
+		return "ogg"
+	default:
+		return encode.F64BE.String()
+	}
+}
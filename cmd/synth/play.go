@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/encode"
+	"github.com/ejuju/poc-go-audio-synthesis/link"
+	"github.com/ejuju/poc-go-audio-synthesis/live"
+	"github.com/ejuju/poc-go-audio-synthesis/param"
+	"github.com/ejuju/poc-go-audio-synthesis/patch"
+	"github.com/ejuju/poc-go-audio-synthesis/playback"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+	"github.com/ejuju/poc-go-audio-synthesis/transport"
+)
+
+func runPlay(args []string) (err error) {
+	fs := flag.NewFlagSet("synth play", flag.ContinueOnError)
+	watch := fs.Bool("watch", false, "reload the patch when the file changes (and play until interrupted)")
+	loop := fs.Bool("loop", false, "loop the patch (for its duration)")
+	fade := fs.Duration("fade", 50*time.Millisecond, "crossfade duration when the patch is reloaded")
+	record := fs.String("record", "", "also record the playback to this WAV file (finished when stopped)")
+	profile := fs.Bool("profile", false, "show the DSP load while playing, and the time spent computing each module when stopped")
+	oscAddr := fs.String("osc", "", `UDP address receiving OSC messages (like ":9000") changing the "param" modules of the patch`)
+	recordParams := fs.String("record-params", "", `record the changes of the "param" modules to this JSON file (written when stopped)`)
+	linkQuantum := fs.Float64("link", 0, "join an Ableton Link session, the sequencer modules following its tempo and its phase in this quantum (in beats, like 4)")
+	automation := fs.String("automation", "", `play the changes of the "param" modules recorded in this JSON file (instead of the OSC messages)`)
+	err = fs.Parse(args)
+	if err != nil {
+		return err
+	} else if fs.NArg() != 1 {
+		return errors.New("usage: synth play [--watch] [--loop] [--record session.wav] [--profile] [--osc :9000] [--record-params params.json] [--automation params.json] [--link 4] patch.json")
+	} else if *linkQuantum > 0 && *loop {
+		return errors.New("patches following a Link session can't be looped")
+	} else if *recordParams != "" && (*watch || *loop) {
+		// The changes are played back against the time of the patch, which restarts when reloaded or looped.
+		return errors.New("parameters can't be recorded while reloading or looping the patch")
+	}
+	path := fs.Arg(0)
+
+	var profiler *synth.Profiler
+	if *profile {
+		profiler = &synth.Profiler{}
+		stop := showLoad(profiler)
+		defer func() {
+			stop()
+			fmt.Fprint(os.Stderr, profiler.Report())
+		}()
+	}
+	opts := patch.BuildOptions{Profiler: profiler, Params: param.NewRegistry()}
+	if *automation != "" {
+		opts.Automation, err = param.LoadRecording(*automation)
+		if err != nil {
+			return err
+		}
+	}
+	var clock *transport.Transport
+	var beat *param.Param
+	if *linkQuantum > 0 {
+		clock, beat = transport.New(120), param.New(0) // The tempo of the session once joined.
+		opts.Beat = beat.Signal()
+	}
+	p, s, err := loadPlayable(path, *loop, opts)
+	if err != nil {
+		return err
+	}
+	if *oscAddr != "" {
+		conn, err := net.ListenPacket("udp", *oscAddr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		go live.ListenOSC(conn, nil, opts.Params)
+	}
+	sw := live.NewSwitch(s, *fade)
+	player, err := recordingPlayer(playback.Player{}, *record, p.Rate)
+	if err != nil {
+		return err
+	}
+	out := sw.Signal()
+	if *recordParams != "" {
+		head := &playhead{}
+		recorder := opts.Params.RecordAt(head.now)
+		defer func() { err = errors.Join(err, saveRecording(recorder, *recordParams)) }()
+		out = head.track(out)
+	}
+	if clock != nil {
+		session, err := link.Join(clock.BPM())
+		if err != nil {
+			return err
+		}
+		defer session.Close()
+		// The player advances the transport, whatever patch plays (reloaded patches start again at 0),
+		// and the patches read its position.
+		position, played := clock.Position(), out
+		out = func(x time.Duration) float64 {
+			beat.Set(position(x))
+			return played(x)
+		}
+		clock.Play()
+		session.Follow(clock, *linkQuantum, time.Duration(playback.DefaultBufferSize)*time.Second/time.Duration(p.Rate))
+	}
+	stopper, err := player.Play(out, p.Rate)
+	if err != nil {
+		return err
+	}
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+
+	if !*watch {
+		end := time.After(time.Duration(p.Duration))
+		if *loop || p.Duration <= 0 {
+			end = nil // Until interrupted.
+		}
+		select {
+		case <-end:
+			return stopper.Stop()
+		case <-interrupted:
+			return stopInterrupted(stopper)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "synth: playing %s, reloading on changes (interrupt to stop)\n", path)
+	modified := modTime(path)
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-interrupted:
+			return stopInterrupted(stopper)
+		case <-ticker.C:
+		}
+		t := modTime(path)
+		if !t.After(modified) {
+			continue
+		}
+		modified = t
+		// Errors are reported but keep the previous version playing, so a typo doesn't stop the music.
+		reloaded, s, err := loadPlayable(path, *loop, opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "synth:", err)
+			continue
+		} else if reloaded.Rate != p.Rate {
+			fmt.Fprintf(os.Stderr, "synth: warning: the sample rate changed, playing at %d Hz\n", p.Rate)
+		}
+		sw.Set(s)
+		fmt.Fprintf(os.Stderr, "synth: reloaded %s\n", path)
+	}
+}
+
+// stopInterrupted stops a playback interrupted from the terminal,
+// which usually interrupted the external player too (making it fail), so only recording errors are returned.
+func stopInterrupted(stopper playback.Stopper) error {
+	err := stopper.Stop()
+	if errors.Is(err, playback.ErrRecord) {
+		return err
+	}
+	return nil
+}
+
+// recordingPlayer returns the player recording to a new 24-bit WAV file at the path, if not empty.
+func recordingPlayer(p playback.Player, path string, rate int) (playback.Player, error) {
+	if path == "" {
+		return p, nil
+	}
+	w, err := encode.S24LE.CreateWAV(path, 1, rate)
+	if err != nil {
+		return p, fmt.Errorf("record: %w", err)
+	}
+	p.Record = w
+	return p, nil
+}
+
+// loadPlayable loads and builds a patch with the options, looped for its duration if loop is true.
+func loadPlayable(path string, loop bool, opts patch.BuildOptions) (*patch.Patch, synth.Signal, error) {
+	p, err := patch.Load(path)
+	if err != nil {
+		return nil, nil, err
+	} else if loop && p.Duration <= 0 {
+		return nil, nil, errors.New("looped patches must have a duration")
+	}
+	s, err := p.BuildWith(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if loop {
+		period, once := time.Duration(p.Duration), s
+		s = func(x time.Duration) float64 { return once(x % period) }
+	}
+	return p, s, nil
+}
+
+// saveRecording stops the recording of the changes of parameters and writes it to a JSON file.
+func saveRecording(recorder *param.Recorder, path string) error {
+	rec := recorder.Stop()
+	err := rec.Save(path)
+	if err != nil {
+		return fmt.Errorf("record parameters: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "synth: recorded %d parameter events to %s\n", len(rec.Events), path)
+	return nil
+}
+
+// playhead is the time of the last frame sampled by the player, to stamp the recorded changes of parameters
+// with the time at which they were played rather than the wall-clock time (the player reads ahead by a buffer).
+type playhead struct{ x atomic.Int64 }
+
+func (p *playhead) now() time.Duration { return time.Duration(p.x.Load()) }
+
+// track returns the signal, moving the playhead to the frames sampled from it.
+func (p *playhead) track(s synth.Signal) synth.Signal {
+	return func(x time.Duration) float64 {
+		p.x.Store(int64(x))
+		return s(x)
+	}
+}
+
+// showLoad shows the DSP load measured by the profiler on the standard error every second, until stopped.
+func showLoad(profiler *synth.Profiler) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		var peak float64
+		for {
+			select {
+			case <-done:
+				fmt.Fprintln(os.Stderr)
+				return
+			case <-ticker.C:
+			}
+			load := profiler.Load()
+			peak = max(peak, load)
+			fmt.Fprintf(os.Stderr, "\rsynth: DSP load %5.1f%% (peak %.1f%%) ", 100*load, 100*peak)
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// modTime returns the modification time of a file (zero if it can't be read).
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
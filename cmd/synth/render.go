@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/encode"
+	"github.com/ejuju/poc-go-audio-synthesis/midi"
+	"github.com/ejuju/poc-go-audio-synthesis/param"
+	"github.com/ejuju/poc-go-audio-synthesis/patch"
+	"github.com/ejuju/poc-go-audio-synthesis/soundfont"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+func runRender(args []string) error {
+	fs := flag.NewFlagSet("synth render", flag.ContinueOnError)
+	dur := fs.Duration("dur", 0, "duration (overrides the duration of the patch)")
+	debug := fs.Bool("debug", false, "report statistics about the output of each module (to find NaN values)")
+	profile := fs.Bool("profile", false, "report the time spent computing each module")
+	stems := fs.String("stems", "", "directory to write each stem of the patch to its own file (WAV unless --format is given)")
+	timeout := fs.Duration("timeout", 0, "stop the render if it takes longer than this (0 for no limit)")
+	sf2 := fs.String("sf2", "", "render a MIDI file (given instead of the patch) with the sounds of this SoundFont")
+	automation := fs.String("automation", "", `render the changes of the "param" modules recorded in this JSON file (by play --record-params)`)
+	out := outputFlags(fs)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	} else if fs.NArg() != 1 {
+		return errors.New("usage: synth render [flags] patch.json, or synth render --sf2 font.sf2 [flags] song.mid")
+	}
+
+	// The render stops on interrupts (like Ctrl+C) with an error, so the output is closed properly.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	if *sf2 != "" {
+		if *stems != "" {
+			return errors.New("MIDI files have no stems")
+		}
+		return renderMIDI(ctx, fs.Arg(0), *sf2, *dur, out)
+	}
+
+	p, err := patch.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var debugger *synth.Debugger
+	if *debug {
+		debugger = &synth.Debugger{}
+		defer func() { fmt.Fprint(os.Stderr, debugger.Report()) }()
+	}
+	opts := patch.BuildOptions{Debugger: debugger}
+	if *automation != "" {
+		opts.Automation, err = param.LoadRecording(*automation)
+		if err != nil {
+			return err
+		}
+	}
+	if *profile {
+		profiler := &synth.Profiler{}
+		opts.Profiler = profiler
+		defer func() { fmt.Fprintf(os.Stderr, "%sDSP load: %.1f%%\n", profiler.Report(), 100*profiler.Load()) }()
+	}
+	length := time.Duration(p.Duration)
+	if *dur > 0 {
+		length = *dur
+	}
+
+	out.meta.Info = map[string]string{}
+	for id, v := range map[string]string{"INAM": p.Title, "IART": p.Artist, "ICMT": p.Comment} {
+		if v != "" {
+			out.meta.Info[id] = v
+		}
+	}
+	description := p.Comment
+	if description == "" {
+		description = filepath.Base(fs.Arg(0))
+	}
+	out.meta.Broadcast = &encode.Broadcast{Description: description}
+	for _, c := range p.Cues {
+		out.meta.Cues = append(out.meta.Cues, encode.Cue{Name: c.Name, Frame: synth.FrameAt(time.Duration(c.At), p.Rate)})
+	}
+	for _, l := range p.Loops {
+		start, end := synth.FrameAt(time.Duration(l.Start), p.Rate), synth.FrameAt(time.Duration(l.End), p.Rate)
+		if end <= start {
+			return fmt.Errorf("loop ends at %v before it starts at %v", time.Duration(l.End), time.Duration(l.Start))
+		}
+		out.meta.Loops = append(out.meta.Loops, encode.Loop{Start: start, End: end})
+	}
+
+	if *stems != "" {
+		return renderStems(ctx, p, opts, length, *stems, out)
+	}
+	signal, err := p.BuildWith(opts)
+	if err != nil {
+		return err
+	}
+	return out.write(synth.SampleStreamContext(ctx, signal, p.Rate, 0, length))
+}
+
+// midiRate is the sample rate of MIDI renders.
+const midiRate = 44100
+
+// midiTail is how long notes of MIDI renders keep sounding after they end, for the release of the SoundFont sounds.
+const midiTail = 2 * time.Second
+
+// renderMIDI renders a MIDI file with the presets of a SoundFont (see soundfont.SoundFont.Instruments).
+func renderMIDI(ctx context.Context, path, sf2 string, length time.Duration, out output) error {
+	song, err := midi.Load(path)
+	if err != nil {
+		return err
+	}
+	font, err := soundfont.Load(sf2)
+	if err != nil {
+		return err
+	}
+	if length <= 0 {
+		length = song.Duration + midiTail
+	}
+	out.meta.Info = map[string]string{}
+	if font.Name != "" {
+		out.meta.Info["ICMT"] = "SoundFont: " + font.Name
+	}
+	out.meta.Broadcast = &encode.Broadcast{Description: filepath.Base(path)}
+	signal := midi.RenderInstruments(song, font.Instruments(), midiTail)
+	return out.write(synth.SampleStreamContext(ctx, signal, midiRate, 0, length))
+}
+
+// renderStems writes each stem of the patch to its own file in the directory (named after the stem), in one pass.
+func renderStems(ctx context.Context, p *patch.Patch, opts patch.BuildOptions, length time.Duration, dir string, out output) error {
+	if len(p.Stems) == 0 {
+		return errors.New(`the patch has no stems (see the "stems" field)`)
+	} else if *out.lufs != 0 {
+		return errors.New("stems can't be normalized, it would change their balance")
+	}
+	signals, err := p.BuildStems(opts)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+	format := *out.format
+	if format == "" {
+		format = "wav"
+	}
+
+	names := make([]string, 0, len(signals))
+	for name := range signals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]synth.Signal, len(names))
+	for i, name := range names {
+		list[i] = signals[name]
+	}
+	streams := synth.SampleStreamsContext(ctx, list, p.Rate, 0, length)
+	if *out.progress {
+		defer reportProgress(func() (done, total int) { // Of the slowest stem.
+			done, total = streams[0].Progress()
+			for _, st := range streams[1:] {
+				d, _ := st.Progress()
+				done = min(done, d)
+			}
+			return done, total
+		})()
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(names))
+	for i, name := range names {
+		stem := out
+		path := filepath.Join(dir, name+"."+extension(format))
+		noProgress := false // Reported for all stems.
+		stem.path, stem.format, stem.progress = &path, &format, &noProgress
+		stem.meta.Info = map[string]string{"INAM": name}
+		for id, v := range out.meta.Info {
+			if id == "INAM" {
+				v += " (" + name + ")"
+			}
+			stem.meta.Info[id] = v
+		}
+		bw := *out.meta.Broadcast
+		bw.Description = name + ": " + bw.Description // The time reference stays 0, so stems line up in DAWs.
+		stem.meta.Broadcast = &bw
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer streams[i].Close()
+			errs[i] = stem.write(streams[i])
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("stem %q: %w", name, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// extension returns the file extension of a format name.
+func extension(format string) string {
+	switch format {
+	case "ogg", "vorbis":
+		return "ogg"
+	case "wav", "aiff", "flac", "opus":
+		return format
+	}
+	return "raw"
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ejuju/poc-go-audio-synthesis/decode"
+	"github.com/ejuju/poc-go-audio-synthesis/encode"
+	"github.com/ejuju/poc-go-audio-synthesis/resample"
+)
+
+func runResample(args []string) (err error) {
+	fs := flag.NewFlagSet("synth resample", flag.ContinueOnError)
+	rate := fs.Int("rate", 44100, "new sample rate in frames per second")
+	quality := fs.String("quality", "sinc", `interpolation: "sinc" or "linear"`)
+	path := fs.String("o", "", "output WAV file")
+	bits := fs.Int("bits", 16, "bit depth of the output: 16, 24 or 32")
+	err = fs.Parse(args)
+	if err != nil {
+		return err
+	} else if fs.NArg() != 1 || *path == "" {
+		return errors.New("usage: synth resample --rate 44100 -o out.wav in.wav")
+	}
+	q := resample.Sinc
+	switch *quality {
+	case "sinc":
+	case "linear":
+		q = resample.Linear
+	default:
+		return fmt.Errorf("invalid quality %q", *quality)
+	}
+
+	a, err := decode.LoadWAV(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	a = resample.Audio(a, *rate, q)
+	f, err := os.Create(*path)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, f.Close()) }()
+	return encode.WriteMultiWAV(f, a.Channels, a.Rate, *bits)
+}
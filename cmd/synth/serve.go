@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/encode"
+	"github.com/ejuju/poc-go-audio-synthesis/live"
+	"github.com/ejuju/poc-go-audio-synthesis/param"
+	"github.com/ejuju/poc-go-audio-synthesis/patch"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// servePage is the page served at the root, playing the stream.
+const servePage = `<!doctype html>
+<title>synth</title>
+<audio src="/stream" controls autoplay></audio>
+`
+
+// maxStream is the duration of looped and endless streams (for which frame times can't overflow).
+const maxStream = 24 * time.Hour
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("synth serve", flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	format := fs.String("format", "wav", `stream format: "wav" or "ogg" (requires oggenc)`)
+	loop := fs.Bool("loop", false, "loop the patch (for its duration, up to a day) instead of stopping at the end")
+	endless := fs.Bool("endless", false, "stream the patch for a day (for patches that never end)")
+	oscAddr := fs.String("osc", "", `UDP address receiving OSC messages (like ":9000") changing the "param" modules of the patch`)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	} else if fs.NArg() != 1 {
+		return errors.New("usage: synth serve [flags] patch.json")
+	}
+	path := fs.Arg(0)
+
+	var enc encode.Encoder
+	contentType := ""
+	switch *format {
+	case "wav":
+		enc, contentType = encode.EncoderFunc(encode.S16LE.WriteWAV), "audio/wav"
+	case "ogg":
+		enc, contentType = encode.Vorbis, "audio/ogg"
+	default:
+		return fmt.Errorf("unsupported stream format %q", *format)
+	}
+
+	// Parameters are shared by all listeners.
+	params := param.NewRegistry()
+	if *oscAddr != "" {
+		conn, err := net.ListenPacket("udp", *oscAddr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		go live.ListenOSC(conn, nil, params)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, servePage)
+	})
+	mux.HandleFunc("GET /stream", func(w http.ResponseWriter, r *http.Request) {
+		// The patch is loaded for each listener: it may have changed,
+		// and signals with a state can't be shared between streams.
+		p, err := patch.Load(path)
+		if err == nil && *loop && p.Duration <= 0 {
+			err = errors.New("looped patches must have a duration")
+		}
+		var signal synth.Signal
+		if err == nil {
+			signal, err = p.BuildWith(patch.BuildOptions{Params: params})
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		length, n := time.Duration(p.Duration), -1
+		switch {
+		case *loop:
+			period, once := length, signal
+			signal = func(x time.Duration) float64 { return once(x % period) }
+			length = maxStream
+		case *endless:
+			length = maxStream
+		default:
+			n = synth.FrameCount(0, length, p.Rate)
+		}
+		stream := synth.SampleStream(signal, p.Rate, 0, length)
+
+		log.Printf("streaming %s to %s", path, r.RemoteAddr)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "no-store")
+		paced := &pacedReader{r: stream, rate: p.Rate, start: time.Now(), lead: time.Second}
+		err = enc.Encode(&flushWriter{w: w, rc: http.NewResponseController(w)}, paced, n, p.Rate)
+		if err != nil {
+			log.Printf("stream to %s: %v", r.RemoteAddr, err)
+		}
+	})
+	log.Printf("listening on %s", *addr)
+	return http.ListenAndServe(*addr, mux)
+}
+
+// pacedReader reads frames no faster than real time (with some lead to absorb network jitter),
+// so listeners hear the stream live instead of buffering it as fast as it renders.
+type pacedReader struct {
+	r     *synth.Stream
+	rate  int
+	start time.Time
+	lead  time.Duration
+	read  int // Number of frames read.
+}
+
+func (pr *pacedReader) Read(frames []float64) (n int, err error) {
+	ahead := synth.AtFrame(pr.read, pr.rate) - time.Since(pr.start) - pr.lead
+	if ahead > 0 {
+		time.Sleep(ahead)
+	}
+	n, err = pr.r.Read(frames[:min(len(frames), pr.rate/10)])
+	pr.read += n
+	return n, err
+}
+
+// flushWriter sends each write to the client right away (as an HTTP chunk).
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (fw *flushWriter) Write(b []byte) (n int, err error) {
+	n, err = fw.w.Write(b)
+	if err == nil {
+		err = fw.rc.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ejuju/poc-go-audio-synthesis/golden"
+)
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("synth verify", flag.ContinueOnError)
+	update := fs.Bool("update", false, "store the renders as the golden ones instead of comparing them")
+	name := fs.String("case", "", "only this case of the suite")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	} else if fs.NArg() != 1 {
+		return errors.New("usage: synth verify [--update] [--case name] suite.json")
+	}
+	suite, err := golden.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *update {
+		return suite.Update(*name)
+	}
+
+	results, err := suite.Verify(*name)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			failed++
+			fmt.Fprintf(os.Stderr, "FAIL %s: %v\n", r.Case, r.Err)
+		case r.Exact:
+			fmt.Fprintf(os.Stderr, "ok   %s (%d frames, bit-for-bit)\n", r.Case, r.Frames)
+		default:
+			fmt.Fprintf(os.Stderr, "ok   %s (%d frames, differs by up to %g)\n", r.Case, r.Frames, r.MaxDiff)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d cases failed", failed, len(results))
+	}
+	return nil
+}
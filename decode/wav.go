@@ -0,0 +1,146 @@
+// Package decode reads audio files back into frames (the inverse of package encode).
+package decode
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Audio holds decoded audio frames, one slice per channel.
+type Audio struct {
+	Rate     int
+	Channels [][]float64
+}
+
+// Len returns the number of frames (per channel).
+func (a *Audio) Len() int {
+	if len(a.Channels) == 0 {
+		return 0
+	}
+	return len(a.Channels[0])
+}
+
+// Mono returns the average of all channels.
+func (a *Audio) Mono() []float64 {
+	if len(a.Channels) == 1 {
+		return a.Channels[0]
+	}
+	frames := make([]float64, a.Len())
+	for _, ch := range a.Channels {
+		for i, v := range ch {
+			frames[i] += v / float64(len(a.Channels))
+		}
+	}
+	return frames
+}
+
+// LoadWAV reads a WAV file from disk.
+func LoadWAV(path string) (*Audio, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return WAV(bufio.NewReader(f))
+}
+
+// WAV reads a WAV file with 8, 16, 24 or 32-bit integer samples or 32 or 64-bit float samples.
+// Samples are converted to floats between -1 and 1.
+func WAV(r io.Reader) (*Audio, error) {
+	var riff [12]byte
+	_, err := io.ReadFull(r, riff[:])
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	} else if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, errors.New("not a WAV file")
+	}
+
+	var format, channels, bitDepth uint16
+	var rate uint32
+	for {
+		var header [8]byte
+		_, err = io.ReadFull(r, header[:])
+		if err != nil {
+			return nil, fmt.Errorf("read chunk: %w", err)
+		}
+		id, size := string(header[:4]), binary.LittleEndian.Uint32(header[4:])
+		switch id {
+		case "fmt ":
+			data := make([]byte, size+size%2)
+			_, err = io.ReadFull(r, data)
+			if err != nil {
+				return nil, fmt.Errorf("read format: %w", err)
+			} else if size < 16 {
+				return nil, errors.New("invalid format chunk")
+			}
+			format = binary.LittleEndian.Uint16(data[0:2])
+			channels = binary.LittleEndian.Uint16(data[2:4])
+			rate = binary.LittleEndian.Uint32(data[4:8])
+			bitDepth = binary.LittleEndian.Uint16(data[14:16])
+			if format == 0xFFFE && size >= 26 { // WAVE_FORMAT_EXTENSIBLE: the actual format is in the sub-format GUID.
+				format = binary.LittleEndian.Uint16(data[24:26])
+			}
+		case "data":
+			if channels == 0 {
+				return nil, errors.New("data chunk before format chunk")
+			}
+			sample, err := sampleDecoder(format, bitDepth)
+			if err != nil {
+				return nil, err
+			}
+			data := make([]byte, size)
+			n, err := io.ReadFull(r, data)
+			if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, fmt.Errorf("read data: %w", err)
+			}
+			return newAudio(data[:n], int(rate), int(channels), int(bitDepth)/8, sample), nil
+		default:
+			_, err = io.CopyN(io.Discard, r, int64(size+size%2))
+			if err != nil {
+				return nil, fmt.Errorf("skip %q chunk: %w", id, err)
+			}
+		}
+	}
+}
+
+func newAudio(data []byte, rate, channels, size int, sample func([]byte) float64) *Audio {
+	n := len(data) / (size * channels)
+	a := &Audio{Rate: rate, Channels: make([][]float64, channels)}
+	for c := range a.Channels {
+		a.Channels[c] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for c := range a.Channels {
+			offset := (i*channels + c) * size
+			a.Channels[c][i] = sample(data[offset : offset+size])
+		}
+	}
+	return a
+}
+
+// sampleDecoder returns the function decoding a little-endian sample.
+func sampleDecoder(format, bitDepth uint16) (func([]byte) float64, error) {
+	switch {
+	case format == 1 && bitDepth == 8: // 8-bit WAV is unsigned.
+		return func(b []byte) float64 { return (float64(b[0]) - 128) / 127 }, nil
+	case format == 1 && bitDepth == 16:
+		return func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / math.MaxInt16 }, nil
+	case format == 1 && bitDepth == 24:
+		return func(b []byte) float64 {
+			v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8 // Sign-extended.
+			return float64(v) / (1<<23 - 1)
+		}, nil
+	case format == 1 && bitDepth == 32:
+		return func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / math.MaxInt32 }, nil
+	case format == 3 && bitDepth == 32:
+		return func(b []byte) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) }, nil
+	case format == 3 && bitDepth == 64:
+		return func(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) }, nil
+	}
+	return nil, fmt.Errorf("unsupported WAV format %d with %d-bit samples", format, bitDepth)
+}
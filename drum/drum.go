@@ -0,0 +1,137 @@
+// Package drum synthesizes percussion sounds (kick, snare, hi-hats and clap) in the spirit of analog drum machines,
+// so beats can be made without samples.
+//
+// Each voice is hit when its gate opens and plays until it dies out, however long the gate stays open.
+// Zero fields take default values, so drum.Kick{}.Play(gate) gives a usable kick.
+package drum
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// hit returns the sound played each time the gate opens, sound being called with the time elapsed since the last hit
+// (in seconds). It is silent until the first hit.
+func hit(gate synth.Signal, sound func(t float64) float64) synth.Signal {
+	return synth.Stateful(func() func(x time.Duration, dt float64) float64 {
+		var at time.Duration
+		hitOnce, wasOpen := false, false
+		return func(x time.Duration, dt float64) float64 {
+			open := gate(x) > 0
+			if open && !wasOpen {
+				at, hitOnce = x, true
+			}
+			wasOpen = open
+			if !hitOnce {
+				return 0
+			}
+			return sound((x - at).Seconds())
+		}
+	})
+}
+
+// decay returns an exponential decay starting at 1, reaching about 37% after d.
+func decay(t float64, d time.Duration) float64 {
+	return math.Exp(-t / d.Seconds())
+}
+
+// or returns v, or def if v is zero.
+func or[T comparable](v, def T) T {
+	var zero T
+	if v == zero {
+		return def
+	}
+	return v
+}
+
+// Kick is a bass drum made of a sine whose pitch falls quickly from Start to End.
+type Kick struct {
+	Start, End float64       // Pitch at the hit and at the end of the sweep (in Hertz), 160 and 50 by default.
+	Sweep      time.Duration // Time constant of the pitch sweep, 30ms by default.
+	Decay      time.Duration // Time constant of the level, 300ms by default.
+	Click      float64       // Level of a short noise click at the hit (for the attack), 0 by default.
+}
+
+// Play returns the kick hit by the gate.
+func (k Kick) Play(gate synth.Signal) synth.Signal {
+	start, end := or(k.Start, 160), or(k.End, 50)
+	sweep, length := or(k.Sweep, 30*time.Millisecond).Seconds(), or(k.Decay, 300*time.Millisecond)
+	body := hit(gate, func(t float64) float64 {
+		phase := end*t + (start-end)*sweep*(1-math.Exp(-t/sweep)) // Integral of the frequency.
+		return math.Sin(2*math.Pi*phase) * decay(t, length)
+	})
+	if k.Click == 0 {
+		return body
+	}
+	click := hit(gate, func(t float64) float64 { return k.Click * decay(t, time.Millisecond) })
+	return synth.Add(body, synth.Mul(click, synth.WhiteNoise(1)))
+}
+
+// Snare mixes a short tone (the drum skin) with high-passed noise (the snares).
+type Snare struct {
+	Tone      float64       // Pitch of the skin (in Hertz), 180 by default.
+	ToneDecay time.Duration // 80ms by default.
+	Noise     float64       // Level of the noise relative to the tone, 1 by default.
+	Decay     time.Duration // Decay of the noise, 150ms by default.
+	Seed      int64
+}
+
+// Play returns the snare hit by the gate.
+func (s Snare) Play(gate synth.Signal) synth.Signal {
+	tone, toneDecay := or(s.Tone, 180), or(s.ToneDecay, 80*time.Millisecond)
+	noise, length := or(s.Noise, 1), or(s.Decay, 150*time.Millisecond)
+	skin := hit(gate, func(t float64) float64 {
+		return 0.6 * math.Sin(2*math.Pi*tone*t) * decay(t, toneDecay)
+	})
+	env := hit(gate, func(t float64) float64 { return 0.5 * noise * decay(t, length) })
+	snares := synth.HighPass(synth.WhiteNoise(s.Seed), synth.Constant(1500), synth.Constant(0.707))
+	return synth.Add(skin, synth.Mul(env, snares))
+}
+
+// hatRatios are the frequencies of the square oscillators of the TR-808 cymbals (in Hertz).
+var hatRatios = []float64{205.3, 304.4, 369.6, 522.7, 540, 800}
+
+// HiHat is a metallic sound made of detuned square waves and noise, high-passed.
+type HiHat struct {
+	Decay time.Duration // 50ms by default (closed hi-hat), use a few hundred milliseconds for an open hi-hat.
+	Tone  float64       // Multiplies the frequencies of the oscillators, 1 by default.
+	Seed  int64
+}
+
+// Play returns the hi-hat hit by the gate.
+func (h HiHat) Play(gate synth.Signal) synth.Signal {
+	length, tone := or(h.Decay, 50*time.Millisecond), or(h.Tone, 1)
+	var metal []synth.Signal
+	for _, f := range hatRatios {
+		metal = append(metal, synth.NaiveSquare(synth.Constant(f*tone)))
+	}
+	source := synth.Add(synth.Gain(synth.Add(metal...), -16), synth.Gain(synth.WhiteNoise(h.Seed), -6))
+	filtered := synth.HighPass(synth.HighPass(source, synth.Constant(7000), synth.Constant(0.707)),
+		synth.Constant(7000), synth.Constant(0.707))
+	env := hit(gate, func(t float64) float64 { return decay(t, length) })
+	return synth.Mul(env, filtered)
+}
+
+// Clap is band-passed noise played as a few quick bursts followed by a longer tail,
+// like several hands clapping almost together.
+type Clap struct {
+	Bursts int           // Number of bursts before the tail, 3 by default.
+	Spread time.Duration // Time between bursts, 10ms by default.
+	Decay  time.Duration // Decay of the tail, 150ms by default.
+	Seed   int64
+}
+
+// Play returns the clap hit by the gate.
+func (c Clap) Play(gate synth.Signal) synth.Signal {
+	bursts, spread, length := or(c.Bursts, 3), or(c.Spread, 10*time.Millisecond).Seconds(), or(c.Decay, 150*time.Millisecond)
+	env := hit(gate, func(t float64) float64 {
+		if i := math.Floor(t / spread); i < float64(bursts) {
+			return decay(t-i*spread, 3*time.Millisecond)
+		}
+		return decay(t-float64(bursts)*spread, length)
+	})
+	noise := synth.BandPass(synth.WhiteNoise(c.Seed), synth.Constant(1200), synth.Constant(1.5))
+	return synth.Mul(env, synth.Gain(noise, 6))
+}
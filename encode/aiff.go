@@ -0,0 +1,80 @@
+package encode
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// WriteAIFF writes n frames read from r to w as an AIFF file (the WAV equivalent on Apple platforms).
+// AIFF files hold 8, 16, 24 or 32-bit signed big-endian integers, the format must be one of them.
+//
+// As with WriteWAVStream, multichannel readers are encoded as interleaved samples
+// and n (the number of frames per channel) must be known upfront.
+func (f Format) WriteAIFF(w io.Writer, r FrameReader, n int, rate int) (err error) {
+	if f.Float || f.Unsigned || !f.BigEndian || f.Validate() != nil {
+		return fmt.Errorf("unsupported AIFF format: %s", f)
+	}
+	channels := channelCount(r)
+	encoder := f.newEncoder(channels)
+	dataSize := n * channels * f.Size()
+	padding := dataSize % 2
+
+	// "FORM" header, "COMM" chunk and "SSND" chunk header.
+	header := make([]byte, 0, 54)
+	header = append(header, "FORM"...)
+	header = binary.BigEndian.AppendUint32(header, uint32(46+dataSize+padding))
+	header = append(header, "AIFF"...)
+	header = append(header, "COMM"...)
+	header = binary.BigEndian.AppendUint32(header, 18)
+	header = binary.BigEndian.AppendUint16(header, uint16(channels))
+	header = binary.BigEndian.AppendUint32(header, uint32(n))
+	header = binary.BigEndian.AppendUint16(header, uint16(f.BitDepth))
+	header = appendExtended(header, rate)
+	header = append(header, "SSND"...)
+	header = binary.BigEndian.AppendUint32(header, uint32(8+dataSize))
+	header = binary.BigEndian.AppendUint32(header, 0) // Offset.
+	header = binary.BigEndian.AppendUint32(header, 0) // Block size.
+	_, err = w.Write(header)
+	if err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+
+	written := 0
+	var data []byte
+	err = readBlocks(r, func(frames []float64) error {
+		written += len(frames) / channels
+		if written > n {
+			return fmt.Errorf("got more than %d frames", n)
+		}
+		data = data[:0]
+		for _, pulse := range frames {
+			data = encoder.append(data, pulse)
+		}
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("write data: %w", err)
+	} else if written != n {
+		return fmt.Errorf("write data: got %d frames instead of %d", written, n)
+	}
+	if padding != 0 {
+		_, err = w.Write([]byte{0})
+		if err != nil {
+			return fmt.Errorf("write padding: %w", err)
+		}
+	}
+	return nil
+}
+
+// appendExtended appends the sample rate as an 80-bit IEEE extended float, as stored in AIFF files.
+func appendExtended(b []byte, rate int) []byte {
+	if rate <= 0 {
+		return append(b, make([]byte, 10)...)
+	}
+	exponent := bits.Len64(uint64(rate)) - 1
+	b = binary.BigEndian.AppendUint16(b, uint16(16383+exponent))
+	return binary.BigEndian.AppendUint64(b, uint64(rate)<<(63-exponent))
+}
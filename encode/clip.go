@@ -0,0 +1,88 @@
+package encode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Clipping is what happens to samples beyond ±1, which can't be encoded as integers.
+type Clipping int
+
+const (
+	HardClip  Clipping = iota // Samples are clamped to ±1 (which encoding integers does anyway).
+	SoftClip                  // Samples above the knee are saturated smoothly (tanh), approaching ±1 without going beyond.
+	ClipError                 // Reading fails at the first sample beyond ±1.
+	NoClip                    // Samples are left untouched, for float formats (which encode them losslessly).
+)
+
+// SoftClipKnee is the level above which SoftClip saturates samples (below, they are left untouched).
+const SoftClipKnee = 0.9
+
+// ErrClipped is returned by ClipMeter with ClipError for samples beyond ±1.
+var ErrClipped = errors.New("sample out of range")
+
+// ParseClipping parses the name of a clipping behavior: "hard", "soft", "error" or "none".
+func ParseClipping(name string) (Clipping, error) {
+	switch name {
+	case "hard", "":
+		return HardClip, nil
+	case "soft":
+		return SoftClip, nil
+	case "error":
+		return ClipError, nil
+	case "none":
+		return NoClip, nil
+	}
+	return 0, fmt.Errorf("invalid clipping %q", name)
+}
+
+// ClipMeter is the output stage of a render: it clips the frames read through it
+// (before they are quantized by an encoder) and counts the samples beyond ±1.
+type ClipMeter struct {
+	r        FrameReader
+	clipping Clipping
+	channels int
+	n        int // Number of samples read.
+	clipped  int
+}
+
+// Clip returns a reader clipping the frames read from r.
+func Clip(r FrameReader, clipping Clipping) *ClipMeter {
+	return &ClipMeter{r: r, clipping: clipping, channels: channelCount(r)}
+}
+
+func (m *ClipMeter) Channels() int { return m.channels }
+
+func (m *ClipMeter) Read(frames []float64) (n int, err error) {
+	n, err = m.r.Read(frames)
+	for i, v := range frames[:n] {
+		if math.Abs(v) > 1 {
+			m.clipped++
+			if m.clipping == ClipError {
+				return i, fmt.Errorf("%w: %g at frame %d", ErrClipped, v, (m.n+i)/m.channels)
+			}
+		}
+		switch m.clipping {
+		case HardClip:
+			frames[i] = clamp(v)
+		case SoftClip:
+			frames[i] = softClip(v)
+		}
+	}
+	m.n += n
+	return n, err
+}
+
+// Clipped returns the number of samples beyond ±1 read so far (left untouched with NoClip).
+func (m *ClipMeter) Clipped() int { return m.clipped }
+
+// softClip saturates v above the knee with a tanh curve, whose slope matches the linear part at the knee.
+func softClip(v float64) float64 {
+	a := math.Abs(v)
+	if a <= SoftClipKnee {
+		return v
+	}
+	const room = 1 - SoftClipKnee
+	return math.Copysign(SoftClipKnee+room*math.Tanh((a-SoftClipKnee)/room), v)
+}
@@ -0,0 +1,91 @@
+package encode
+
+import "math"
+
+// DCThreshold is the average level (about -40 dBFS) above which a DC offset is considered significant.
+const DCThreshold = 0.01
+
+// DCMeter measures the DC offset (the average) of each channel of the frames read through it.
+type DCMeter struct {
+	r    FrameReader
+	sums []float64
+	n    int // Number of samples read.
+}
+
+// NewDCMeter returns a reader measuring the frames read from r.
+func NewDCMeter(r FrameReader) *DCMeter {
+	return &DCMeter{r: r, sums: make([]float64, channelCount(r))}
+}
+
+func (m *DCMeter) Channels() int { return len(m.sums) }
+
+func (m *DCMeter) Read(frames []float64) (n int, err error) {
+	n, err = m.r.Read(frames)
+	for _, v := range frames[:n] {
+		m.sums[m.n%len(m.sums)] += v
+		m.n++
+	}
+	return n, err
+}
+
+// Offsets returns the average of each channel of the frames read so far.
+func (m *DCMeter) Offsets() []float64 {
+	offsets := make([]float64, len(m.sums))
+	frames := m.n / len(m.sums)
+	for c, sum := range m.sums {
+		if frames > 0 {
+			offsets[c] = sum / float64(frames)
+		}
+	}
+	return offsets
+}
+
+// Significant reports whether a channel has an offset above DCThreshold.
+func (m *DCMeter) Significant() bool {
+	for _, o := range m.Offsets() {
+		if math.Abs(o) > DCThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveDC returns a reader of the frames read from r with their DC offset removed
+// by a one-pole high-pass filter at about 10 Hz (for frames at the given sample rate).
+func RemoveDC(r FrameReader, rate int) FrameReader {
+	channels := channelCount(r)
+	return &dcRemover{
+		r:     r,
+		coef:  math.Exp(-2 * math.Pi * 10 / float64(rate)),
+		state: make([][2]float64, channels),
+		first: true,
+	}
+}
+
+type dcRemover struct {
+	r       FrameReader
+	coef    float64
+	state   [][2]float64 // Previous input and output of each channel.
+	channel int
+	first   bool
+}
+
+func (d *dcRemover) Channels() int { return len(d.state) }
+
+func (d *dcRemover) Read(frames []float64) (n int, err error) {
+	n, err = d.r.Read(frames)
+	for i, v := range frames[:n] {
+		s := &d.state[d.channel]
+		if d.first {
+			s[0] = v // Start from the first value instead of a jump from 0.
+		}
+		y := v - s[0] + d.coef*s[1]
+		s[0], s[1] = v, y
+		frames[i] = y
+		d.channel = (d.channel + 1) % len(d.state)
+		if d.channel == 0 {
+			d.first = false
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,75 @@
+package encode
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Dither is the noise added to samples before quantizing them to integers,
+// which turns the quantization distortion of quiet passages into a constant, much less audible hiss.
+type Dither int
+
+const (
+	NoDither    Dither = iota // Samples are rounded to the nearest integer.
+	TPDF                      // Triangular noise of ±1 least significant bit.
+	NoiseShaped               // TPDF with the quantization error fed back, moving the noise to high frequencies.
+)
+
+// ParseDither parses the name of a dither: "none", "tpdf" or "shaped".
+func ParseDither(name string) (Dither, error) {
+	switch name {
+	case "none", "":
+		return NoDither, nil
+	case "tpdf":
+		return TPDF, nil
+	case "shaped":
+		return NoiseShaped, nil
+	}
+	return 0, fmt.Errorf("invalid dither %q", name)
+}
+
+// sampleEncoder encodes samples in a format, keeping the state of the dither of each channel.
+type sampleEncoder struct {
+	format   Format
+	rng      *rand.Rand
+	errors   []float64 // Last quantization error of each channel, for noise shaping.
+	channel  int       // Channel of the next sample.
+	maxValue float64
+}
+
+// newEncoder returns an encoder of interleaved samples of the given number of channels.
+// The dither uses a fixed seed so encoding is reproducible.
+func (f Format) newEncoder(channels int) *sampleEncoder {
+	return &sampleEncoder{
+		format:   f,
+		rng:      rand.New(rand.NewSource(1)),
+		errors:   make([]float64, max(channels, 1)),
+		maxValue: float64(int64(1)<<(f.BitDepth-1) - 1),
+	}
+}
+
+// append appends the encoding of the next sample to b.
+func (e *sampleEncoder) append(b []byte, pulse float64) []byte {
+	if e.format.Float || e.format.Dither == NoDither {
+		return e.format.AppendSample(b, pulse)
+	}
+	return e.format.AppendSample(b, float64(e.integer(pulse))/e.maxValue)
+}
+
+// integer returns the next sample quantized to a signed integer (with dither).
+func (e *sampleEncoder) integer(pulse float64) int64 {
+	c := e.channel
+	e.channel = (e.channel + 1) % len(e.errors)
+
+	v := clamp(pulse) * e.maxValue // In least significant bits.
+	if e.format.Dither == NoDither {
+		return int64(math.Round(v))
+	} else if e.format.Dither == NoiseShaped {
+		v -= e.errors[c]
+	}
+	q := math.Round(v + e.rng.Float64() - e.rng.Float64())
+	q = math.Max(-e.maxValue, math.Min(q, e.maxValue))
+	e.errors[c] = q - v
+	return int64(q)
+}
@@ -0,0 +1,78 @@
+package encode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// Encoder writes n frames read from r to w in a file format.
+// As with WriteWAVStream, multichannel readers are encoded as interleaved samples.
+type Encoder interface {
+	Encode(w io.Writer, r FrameReader, n int, rate int) error
+}
+
+// EncoderFunc is a function used as an Encoder, like a method value (for example Format.WriteFLAC).
+type EncoderFunc func(w io.Writer, r FrameReader, n int, rate int) error
+
+// Encode calls fn.
+func (fn EncoderFunc) Encode(w io.Writer, r FrameReader, n int, rate int) error {
+	return fn(w, r, n, rate)
+}
+
+// Command is an encoder running an external program that reads a WAV file on its standard input
+// and writes the encoded file on its standard output.
+// It gives access to lossy formats (like Opus or Vorbis) that would require a large codec library,
+// without writing an intermediate WAV file.
+type Command struct {
+	Name   string
+	Args   []string
+	Format Format // Encoding of the WAV file given to the program (24-bit integers if zero).
+}
+
+// Compressed formats, encoded by the reference tools (opus-tools and vorbis-tools), which must be installed.
+var (
+	Opus   = Command{Name: "opusenc", Args: []string{"--quiet", "-", "-"}}
+	Vorbis = Command{Name: "oggenc", Args: []string{"--quiet", "-o", "-", "-"}}
+)
+
+// WithArgs returns a copy of the command with extra arguments (like "--bitrate", "96") placed before the others.
+func (c Command) WithArgs(args ...string) Command {
+	c.Args = slices.Concat(args, c.Args)
+	return c
+}
+
+// Encode runs the program, writing the frames as a WAV file to its standard input.
+func (c Command) Encode(w io.Writer, r FrameReader, n int, rate int) error {
+	format := c.Format
+	if format.BitDepth == 0 {
+		format = S24LE
+	}
+	cmd := exec.Command(c.Name, c.Args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = w, stderr
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	err = format.WriteWAV(stdin, r, n, rate)
+	err = errors.Join(err, stdin.Close())
+	waitErr := cmd.Wait()
+	if waitErr != nil {
+		// The program failing explains why writing to it failed.
+		msg := strings.TrimSpace(stderr.String())
+		if msg != "" {
+			return fmt.Errorf("%s: %w: %s", c.Name, waitErr, msg)
+		}
+		return fmt.Errorf("%s: %w", c.Name, waitErr)
+	}
+	return err
+}
@@ -0,0 +1,324 @@
+package encode
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// flacBlockSize is the number of frames per FLAC frame.
+const flacBlockSize = 4096
+
+// WriteFLAC writes n frames read from r to w as a FLAC file (lossless compression).
+// FLAC files hold 8, 16 or 24-bit signed integers, the endianness of the format is ignored.
+//
+// Each channel is encoded with the best fixed predictor (of order 0 to 4) and Rice-coded residuals,
+// which is close to the compression of the reference encoder's fastest settings.
+// The MD5 signature of the audio data is left empty (which decoders treat as unknown).
+//
+// As with WriteWAVStream, multichannel readers are encoded as interleaved samples
+// and n (the number of frames per channel) must be known upfront.
+func (f Format) WriteFLAC(w io.Writer, r FrameReader, n int, rate int) (err error) {
+	if f.Float || f.Unsigned || f.BitDepth > 24 || f.Validate() != nil {
+		return fmt.Errorf("unsupported FLAC format: %s", f)
+	}
+	channels := channelCount(r)
+	if channels > 8 {
+		return fmt.Errorf("unsupported FLAC channel count: %d", channels)
+	} else if rate <= 0 || rate >= 1<<20 {
+		return fmt.Errorf("unsupported FLAC sample rate: %d", rate)
+	}
+	encoder := f.newEncoder(channels)
+
+	// Stream marker and STREAMINFO metadata block (marked as the last one).
+	bw := &bitWriter{}
+	bw.buf = append(bw.buf, "fLaC"...)
+	bw.write(1, 1)
+	bw.write(0, 7)
+	bw.write(34, 24)
+	bw.write(flacBlockSize, 16) // Minimum block size.
+	bw.write(flacBlockSize, 16) // Maximum block size.
+	bw.write(0, 24)             // Minimum frame size (unknown).
+	bw.write(0, 24)             // Maximum frame size (unknown).
+	bw.write(uint64(rate), 20)
+	bw.write(uint64(channels-1), 3)
+	bw.write(uint64(f.BitDepth-1), 5)
+	bw.write(uint64(n), 36)
+	bw.buf = append(bw.buf, make([]byte, 16)...) // MD5 signature (unknown).
+	_, err = w.Write(bw.buf)
+	if err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+
+	block := make([][]int64, channels)
+	written, frame, c := 0, 0, 0
+	flush := func() error {
+		bw.buf = bw.buf[:0]
+		f.appendFLACFrame(bw, block, frame)
+		for i := range block {
+			block[i] = block[i][:0]
+		}
+		frame++
+		_, err := w.Write(bw.buf)
+		return err
+	}
+	err = readBlocks(r, func(frames []float64) error {
+		for _, pulse := range frames {
+			block[c] = append(block[c], encoder.integer(pulse))
+			c = (c + 1) % channels
+			if c == 0 {
+				written++
+				if written > n {
+					return fmt.Errorf("got more than %d frames", n)
+				} else if len(block[0]) == flacBlockSize {
+					err := flush()
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err == nil && len(block[0]) > 0 {
+		err = flush()
+	}
+	if err != nil {
+		return fmt.Errorf("write data: %w", err)
+	} else if written != n {
+		return fmt.Errorf("write data: got %d frames instead of %d", written, n)
+	}
+	return nil
+}
+
+// appendFLACFrame appends a frame holding the samples of each channel (coded independently).
+func (f Format) appendFLACFrame(bw *bitWriter, channels [][]int64, index int) {
+	size := len(channels[0])
+	start := len(bw.buf)
+
+	// Frame header.
+	bw.write(0b11111111111110, 14) // Sync code.
+	bw.write(0, 2)                 // Reserved bit and fixed block size.
+	if size == flacBlockSize {
+		bw.write(0b1100, 4)
+	} else {
+		bw.write(0b0111, 4) // Size stored at the end of the header.
+	}
+	bw.write(0, 4) // Sample rate from STREAMINFO.
+	bw.write(uint64(len(channels)-1), 4)
+	bw.write(map[int]uint64{8: 1, 16: 4, 24: 6}[f.BitDepth], 3)
+	bw.write(0, 1)
+	bw.buf = appendUTF8(bw.buf, uint64(index))
+	if size != flacBlockSize {
+		bw.write(uint64(size-1), 16)
+	}
+	bw.buf = append(bw.buf, crc8(bw.buf[start:]))
+
+	for _, samples := range channels {
+		appendSubframe(bw, samples, f.BitDepth)
+	}
+	bw.align()
+	crc := crc16(bw.buf[start:])
+	bw.write(uint64(crc), 16)
+}
+
+// appendSubframe appends the samples of a channel with the smallest encoding.
+func appendSubframe(bw *bitWriter, samples []int64, bitDepth int) {
+	constant := true
+	for _, v := range samples[1:] {
+		constant = constant && v == samples[0]
+	}
+	if constant {
+		bw.write(0b00000000, 8)
+		bw.writeSigned(samples[0], bitDepth)
+		return
+	}
+
+	// Find the fixed predictor with the smallest residuals.
+	verbatim := len(samples) * bitDepth
+	best, bestCost, bestParams := -1, verbatim, []int(nil)
+	var residuals, bestResiduals []int64
+	for order := 0; order <= 4 && order < len(samples); order++ {
+		residuals = fixedResiduals(residuals[:0], samples, order)
+		params, cost := riceParams(residuals, len(samples), order)
+		cost += order * bitDepth
+		if params != nil && cost < bestCost {
+			best, bestCost, bestParams = order, cost, params
+			residuals, bestResiduals = bestResiduals, residuals
+		}
+	}
+
+	if best < 0 {
+		bw.write(0b00000010, 8)
+		for _, v := range samples {
+			bw.writeSigned(v, bitDepth)
+		}
+		return
+	}
+	bw.write(uint64(0b00010000|best<<1), 8)
+	for _, v := range samples[:best] {
+		bw.writeSigned(v, bitDepth) // Warm-up samples.
+	}
+	bw.write(0, 2) // Rice coding with 4-bit parameters.
+	bw.write(uint64(bits.Len(uint(len(bestParams)))-1), 4)
+	partition := len(samples) / len(bestParams)
+	start := 0
+	for i, k := range bestParams {
+		end := (i+1)*partition - best // Residuals start after the warm-up samples.
+		bw.write(uint64(k), 4)
+		for _, r := range bestResiduals[start:end] {
+			u := zigzag(r)
+			bw.writeUnary(u >> k)
+			bw.write(u&(1<<k-1), k)
+		}
+		start = end
+	}
+}
+
+// fixedResiduals appends the residuals of the fixed predictor of the given order to dst.
+func fixedResiduals(dst, s []int64, order int) []int64 {
+	for i := order; i < len(s); i++ {
+		var r int64
+		switch order {
+		case 0:
+			r = s[i]
+		case 1:
+			r = s[i] - s[i-1]
+		case 2:
+			r = s[i] - 2*s[i-1] + s[i-2]
+		case 3:
+			r = s[i] - 3*s[i-1] + 3*s[i-2] - s[i-3]
+		case 4:
+			r = s[i] - 4*s[i-1] + 6*s[i-2] - 4*s[i-3] + s[i-4]
+		}
+		dst = append(dst, r)
+	}
+	return dst
+}
+
+// riceParams returns the partitioning of the residuals (as the Rice parameter of each partition)
+// with the smallest encoding, and the size of the encoding in bits.
+func riceParams(residuals []int64, size, order int) (params []int, cost int) {
+	cost = -1
+	for partitionOrder := 0; partitionOrder <= 8; partitionOrder++ {
+		partitions := 1 << partitionOrder
+		if size%partitions != 0 || size/partitions <= order {
+			break
+		}
+		orderParams := make([]int, partitions)
+		orderCost, start := 6, 0
+		for i := range orderParams {
+			end := (i+1)*size/partitions - order
+			k, c := riceParam(residuals[start:end])
+			orderParams[i], orderCost = k, orderCost+4+c
+			start = end
+		}
+		if cost < 0 || orderCost < cost {
+			params, cost = orderParams, orderCost
+		}
+	}
+	return params, cost
+}
+
+// riceParam returns the Rice parameter with the smallest encoding of the residuals, and its size in bits.
+func riceParam(residuals []int64) (k, cost int) {
+	cost = -1
+	for p := 0; p <= 14; p++ {
+		c := len(residuals) * (p + 1)
+		for _, r := range residuals {
+			c += int(zigzag(r) >> p)
+		}
+		if cost < 0 || c < cost {
+			k, cost = p, c
+		}
+	}
+	return k, cost
+}
+
+// zigzag maps signed integers to unsigned ones (0, -1, 1, -2... to 0, 1, 2, 3...).
+func zigzag(v int64) uint64 { return uint64(v<<1) ^ uint64(v>>63) }
+
+// appendUTF8 appends v with the UTF-8 like variable length coding of FLAC frame numbers.
+func appendUTF8(b []byte, v uint64) []byte {
+	if v < 0x80 {
+		return append(b, byte(v))
+	}
+	n := 2
+	for v >= 1<<(5*n+1) {
+		n++
+	}
+	b = append(b, byte(0xFF<<(8-n))|byte(v>>(6*(n-1))))
+	for i := n - 2; i >= 0; i-- {
+		b = append(b, 0x80|byte(v>>(6*i))&0x3F)
+	}
+	return b
+}
+
+// bitWriter appends bits (most significant first) to a buffer.
+type bitWriter struct {
+	buf []byte
+	acc uint64 // Bits not yet appended.
+	n   int    // Number of bits in acc.
+}
+
+func (bw *bitWriter) write(v uint64, n int) {
+	for n > 0 {
+		take := min(n, 56-bw.n)
+		n -= take
+		bw.acc = bw.acc<<take | (v>>n)&(1<<take-1)
+		bw.n += take
+		for bw.n >= 8 {
+			bw.n -= 8
+			bw.buf = append(bw.buf, byte(bw.acc>>bw.n))
+		}
+	}
+}
+
+func (bw *bitWriter) writeSigned(v int64, n int) { bw.write(uint64(v)&(1<<n-1), n) }
+
+// writeUnary writes v zeros followed by a one.
+func (bw *bitWriter) writeUnary(v uint64) {
+	for ; v >= 32; v -= 32 {
+		bw.write(0, 32)
+	}
+	bw.write(1, int(v)+1)
+}
+
+// align pads the last byte with zeros.
+func (bw *bitWriter) align() {
+	if bw.n > 0 {
+		bw.write(0, 8-bw.n)
+	}
+}
+
+// crc8 returns the CRC-8 of b (polynomial x^8 + x^2 + x + 1), as used in FLAC frame headers.
+func crc8(b []byte) byte {
+	var crc byte
+	for _, v := range b {
+		crc ^= v
+		for range 8 {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// crc16 returns the CRC-16 of b (polynomial x^16 + x^15 + x^2 + 1), as used in FLAC frame footers.
+func crc16(b []byte) uint16 {
+	var crc uint16
+	for _, v := range b {
+		crc ^= uint16(v) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,154 @@
+package encode
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Format describes how each sample is encoded in raw PCM data.
+type Format struct {
+	BitDepth  int    // 8, 16, 24 or 32 for integers, 32 or 64 for floats.
+	Float     bool   // IEEE floating point instead of integers.
+	Unsigned  bool   // Unsigned integers (offset by half the range) instead of signed ones.
+	BigEndian bool   // Most significant byte first.
+	Dither    Dither // Dither applied before quantizing to integers, by Encode, NewReader and WriteWAV.
+}
+
+// Common formats, named after ffmpeg's raw formats.
+var (
+	F64BE = Format{BitDepth: 64, Float: true, BigEndian: true}
+	F64LE = Format{BitDepth: 64, Float: true}
+	F32LE = Format{BitDepth: 32, Float: true}
+	S32LE = Format{BitDepth: 32}
+	S24LE = Format{BitDepth: 24}
+	S16LE = Format{BitDepth: 16}
+	S16BE = Format{BitDepth: 16, BigEndian: true}
+	U8    = Format{BitDepth: 8, Unsigned: true}
+)
+
+// ParseFormat parses a format name as used by ffmpeg (for example "s16le", "f32be" or "u8").
+func ParseFormat(name string) (f Format, err error) {
+	s := strings.ToLower(name)
+	switch {
+	case strings.HasPrefix(s, "f"):
+		f.Float = true
+	case strings.HasPrefix(s, "u"):
+		f.Unsigned = true
+	case !strings.HasPrefix(s, "s"):
+		return f, fmt.Errorf("invalid format %q", name)
+	}
+	s = s[1:]
+	if strings.HasSuffix(s, "be") {
+		f.BigEndian = true
+	}
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "be"), "le")
+	f.BitDepth, err = strconv.Atoi(s)
+	if err != nil {
+		return f, fmt.Errorf("invalid format %q", name)
+	}
+	return f, f.Validate()
+}
+
+// String returns the ffmpeg name of the format.
+func (f Format) String() string {
+	kind := "s"
+	if f.Float {
+		kind = "f"
+	} else if f.Unsigned {
+		kind = "u"
+	}
+	if f.BitDepth == 8 {
+		return kind + "8"
+	}
+	endianness := "le"
+	if f.BigEndian {
+		endianness = "be"
+	}
+	return kind + strconv.Itoa(f.BitDepth) + endianness
+}
+
+// Validate returns an error if the format can't be encoded.
+func (f Format) Validate() error {
+	switch {
+	case f.Float && (f.BitDepth == 32 || f.BitDepth == 64) && !f.Unsigned:
+		return nil
+	case !f.Float && (f.BitDepth == 8 || f.BitDepth == 16 || f.BitDepth == 24 || f.BitDepth == 32):
+		return nil
+	}
+	return fmt.Errorf("unsupported format: %d-bit (float: %v, unsigned: %v)", f.BitDepth, f.Float, f.Unsigned)
+}
+
+// Size returns the size of a sample in bytes.
+func (f Format) Size() int { return f.BitDepth / 8 }
+
+// AppendSample appends the encoding of a single sample to b.
+// Integer samples are clamped to [-1, 1] before being quantized, without dither (which needs the previous samples).
+func (f Format) AppendSample(b []byte, pulse float64) []byte {
+	var bits uint64
+	switch {
+	case f.Float && f.BitDepth == 32:
+		bits = uint64(math.Float32bits(float32(pulse)))
+	case f.Float:
+		bits = math.Float64bits(pulse)
+	default:
+		bits = f.quantize(pulse)
+	}
+	n := f.Size()
+	for i := 0; i < n; i++ {
+		shift := 8 * i
+		if f.BigEndian {
+			shift = 8 * (n - 1 - i)
+		}
+		b = append(b, byte(bits>>shift))
+	}
+	return b
+}
+
+// quantize returns the integer value of a sample (as two's complement or offset binary).
+func (f Format) quantize(pulse float64) uint64 {
+	maxValue := float64(int64(1)<<(f.BitDepth-1) - 1)
+	v := int64(math.Round(clamp(pulse) * maxValue))
+	if f.Unsigned {
+		v += int64(1) << (f.BitDepth - 1)
+	}
+	return uint64(v)
+}
+
+// Encode encodes the frames (or interleaved samples) one after another.
+func (f Format) Encode(frames []float64) (b []byte) {
+	return f.Append(make([]byte, 0, len(frames)*f.Size()), frames)
+}
+
+// Append appends the encoding of the frames (or interleaved samples) to dst, like Encode, and returns the extended buffer.
+// Without dither, it doesn't allocate when dst has enough capacity, so a buffer can be reused for each block of a render.
+// With dither, each call starts a new dither sequence: use NewReader to encode a stream block by block.
+func (f Format) Append(dst []byte, frames []float64) []byte {
+	return appendSamples(f, dst, frames)
+}
+
+// AppendFloat32 is like Append with float32 samples (encoded exactly in 32-bit float formats, like F32LE).
+func (f Format) AppendFloat32(dst []byte, samples []float32) []byte {
+	return appendSamples(f, dst, samples)
+}
+
+func appendSamples[T float32 | float64](f Format, dst []byte, samples []T) []byte {
+	dst = slices.Grow(dst, len(samples)*f.Size())
+	if f.Float || f.Dither == NoDither {
+		for _, pulse := range samples {
+			dst = f.AppendSample(dst, float64(pulse))
+		}
+		return dst
+	}
+	e := f.newEncoder(1)
+	for _, pulse := range samples {
+		dst = e.append(dst, float64(pulse))
+	}
+	return dst
+}
+
+func clamp(v float64) float64 {
+	return math.Max(-1, math.Min(1, v))
+}
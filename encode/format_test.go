@@ -0,0 +1,18 @@
+package encode
+
+import "testing"
+
+// BenchmarkFormatAppend measures the encoding of blocks into a reused buffer, which must not allocate.
+func BenchmarkFormatAppend(b *testing.B) {
+	frames := make([]float64, blockSize)
+	for i := range frames {
+		frames[i] = float64(i%200)/100 - 1
+	}
+	dst := S24LE.Append(nil, frames) // Warm-up, growing the buffer.
+	b.ReportAllocs()
+	b.SetBytes(int64(len(dst)))
+	b.ResetTimer()
+	for range b.N {
+		dst = S24LE.Append(dst[:0], frames)
+	}
+}
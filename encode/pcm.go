@@ -0,0 +1,16 @@
+// Package encode turns audio frames into files that can be played on a speaker.
+package encode
+
+// PCM encodes each frame as a big-endian float64 (F64BE), one after another.
+// Multichannel audio is encoded the same way from interleaved samples (see synth.Interleave).
+//
+// Use Format.Encode for other sample formats.
+func PCM(frames []float64) (b []byte) {
+	return F64BE.Encode(frames)
+}
+
+// AppendPCM appends the encoding of the frames as in PCM to dst and returns the extended buffer,
+// without allocating when dst has enough capacity (see Format.Append).
+func AppendPCM(dst []byte, frames []float64) []byte {
+	return F64BE.Append(dst, frames)
+}
@@ -0,0 +1,120 @@
+package encode
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// FrameReader reads audio frames, like synth.Stream.
+// It returns io.EOF once all frames have been read.
+type FrameReader interface {
+	Read(frames []float64) (n int, err error)
+}
+
+// channelCount returns the number of interleaved channels read from r.
+func channelCount(r FrameReader) int {
+	if c, ok := r.(interface{ Channels() int }); ok {
+		return c.Channels()
+	}
+	return 1
+}
+
+// blockSize is the number of frames read at once when encoding a stream.
+const blockSize = 4096
+
+// sliceReader reads frames from a slice.
+type sliceReader []float64
+
+func (r *sliceReader) Read(frames []float64) (n int, err error) {
+	if len(*r) == 0 {
+		return 0, io.EOF
+	}
+	n = copy(frames, *r)
+	*r = (*r)[n:]
+	return n, nil
+}
+
+// interleaver reads interleaved samples from separate channels.
+type interleaver struct {
+	channels [][]float64
+	frame    int
+}
+
+func (r *interleaver) Channels() int { return len(r.channels) }
+
+func (r *interleaver) Read(samples []float64) (n int, err error) {
+	if len(r.channels) == 0 || r.frame >= len(r.channels[0]) {
+		return 0, io.EOF
+	}
+	for ; r.frame < len(r.channels[0]) && n+len(r.channels) <= len(samples); r.frame++ {
+		for _, ch := range r.channels {
+			samples[n] = ch[r.frame]
+			n++
+		}
+	}
+	return n, nil
+}
+
+// NewPCMReader returns a reader of the frames encoded as in PCM.
+// Multichannel streams (like synth.MultiStream) are encoded as interleaved samples.
+func NewPCMReader(r FrameReader) io.Reader {
+	return F64BE.NewReader(r)
+}
+
+// NewReader returns a reader of the frames encoded in the format.
+// Frames are read from r block by block as the returned reader is being read.
+func (f Format) NewReader(r FrameReader) io.Reader {
+	return &pcmReader{r: r, encoder: f.newEncoder(channelCount(r)), frames: make([]float64, blockSize)}
+}
+
+type pcmReader struct {
+	r       FrameReader
+	encoder *sampleEncoder
+	frames  []float64
+	encoded []byte // Encoding of the last block read.
+	buf     []byte // Encoded bytes not yet read.
+	err     error
+}
+
+func (pr *pcmReader) Read(b []byte) (n int, err error) {
+	for len(pr.buf) == 0 {
+		if pr.err != nil {
+			return 0, pr.err
+		}
+		var nf int
+		nf, pr.err = pr.r.Read(pr.frames)
+		pr.encoded = pr.encoded[:0]
+		for _, pulse := range pr.frames[:nf] {
+			pr.encoded = pr.encoder.append(pr.encoded, pulse)
+		}
+		pr.buf = pr.encoded
+	}
+	n = copy(b, pr.buf)
+	pr.buf = pr.buf[n:]
+	return n, nil
+}
+
+// blocks are buffers of blockSize frames reused between encodings, so encoding many files doesn't allocate them each time.
+var blocks = sync.Pool{New: func() any { b := make([]float64, blockSize); return &b }}
+
+// readBlocks calls fn with successive blocks of frames read from r until io.EOF.
+func readBlocks(r FrameReader, fn func(frames []float64) error) error {
+	block := blocks.Get().(*[]float64)
+	defer blocks.Put(block)
+	frames := *block
+	for {
+		n, err := r.Read(frames)
+		if n > 0 {
+			fnErr := fn(frames[:n])
+			if fnErr != nil {
+				return fnErr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
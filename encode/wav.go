@@ -0,0 +1,156 @@
+package encode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WAV format tags (as stored in the "fmt " chunk).
+const (
+	wavFormatPCM   = 1
+	wavFormatFloat = 3
+)
+
+// WAV returns the given mono frames as a complete WAV file.
+//
+// Supported bit depths are 16 and 24 (signed integer PCM) and 32 (IEEE float).
+func WAV(frames []float64, rate int, bitDepth int) (b []byte, err error) {
+	buf := &bytes.Buffer{}
+	err = WriteWAV(buf, frames, rate, bitDepth)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteWAV writes the given mono frames to w as a WAV file (RIFF header followed by the data chunk).
+func WriteWAV(w io.Writer, frames []float64, rate int, bitDepth int) (err error) {
+	r := sliceReader(frames)
+	return WriteWAVStream(w, &r, len(frames), rate, bitDepth)
+}
+
+// WriteMultiWAV writes the given channels to w as a multichannel WAV file.
+// All channels must have the same length.
+func WriteMultiWAV(w io.Writer, channels [][]float64, rate int, bitDepth int) (err error) {
+	n := 0
+	if len(channels) > 0 {
+		n = len(channels[0])
+	}
+	r := &interleaver{channels: channels}
+	return WriteWAVStream(w, r, n, rate, bitDepth)
+}
+
+// WriteWAVStream writes n frames read from r to w as a WAV file.
+// Frames are encoded block by block so long renders are never held in memory.
+//
+// If r has a Channels method (like synth.MultiStream), it is expected to return interleaved samples
+// of all channels, and n is the number of frames per channel.
+//
+// Since the header must contain the size of the data, n must be known upfront
+// (see synth.Stream.Len), it is an error for r to return a different number of frames.
+func WriteWAVStream(w io.Writer, r FrameReader, n int, rate int, bitDepth int) (err error) {
+	return wavSampleFormat(bitDepth).WriteWAV(w, r, n, rate)
+}
+
+// WriteWAV is like WriteWAVStream, encoding samples in the format (including its dither).
+// WAV files support 16 and 24-bit signed little-endian integers and 32-bit little-endian floats.
+//
+// If n is negative, the length is unknown (like for a live stream): the sizes in the header are set to their maximum
+// (as most players expect from streams) and frames are written until r returns io.EOF.
+func (f Format) WriteWAV(w io.Writer, r FrameReader, n int, rate int) (err error) {
+	return f.writeWAV(w, r, n, rate, nil)
+}
+
+// writeWAV writes a WAV file with the given chunks between the "fmt " and "data" chunks.
+func (f Format) writeWAV(w io.Writer, r FrameReader, n int, rate int, chunks []byte) (err error) {
+	bitDepth := f.BitDepth
+	format, err := wavFormat(bitDepth)
+	if err != nil {
+		return err
+	} else if f.Float != (bitDepth == 32) || f.Unsigned || f.BigEndian {
+		return fmt.Errorf("unsupported WAV format: %s", f)
+	}
+	channels := channelCount(r)
+	encoder := f.newEncoder(channels)
+	blockAlign := channels * bitDepth / 8
+	dataSize := n * blockAlign
+	padding := dataSize % 2
+	riffSize, dataChunkSize := uint32(36+len(chunks)+dataSize+padding), uint32(dataSize)
+	if n < 0 {
+		padding, riffSize, dataChunkSize = 0, math.MaxUint32, math.MaxUint32
+	}
+
+	header := appendWAVHeader(nil, format, channels, rate, bitDepth, chunks, riffSize, dataChunkSize)
+	_, err = w.Write(header)
+	if err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+
+	// Data chunk.
+	// Note: a padding byte is required for odd-sized chunks,
+	// this can only happen with 24-bit audio and an odd number of frames.
+	written := 0
+	var data []byte
+	err = readBlocks(r, func(frames []float64) error {
+		written += len(frames) / channels
+		if n >= 0 && written > n {
+			return fmt.Errorf("got more than %d frames", n)
+		}
+		data = data[:0]
+		for _, pulse := range frames {
+			data = encoder.append(data, pulse)
+		}
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("write data: %w", err)
+	} else if n >= 0 && written != n {
+		return fmt.Errorf("write data: got %d frames instead of %d", written, n)
+	}
+	if padding != 0 {
+		_, err = w.Write([]byte{0})
+		if err != nil {
+			return fmt.Errorf("write padding: %w", err)
+		}
+	}
+	return nil
+}
+
+// appendWAVHeader appends the RIFF header, the "fmt " chunk, the given chunks and the header of the "data" chunk to b.
+func appendWAVHeader(b []byte, format uint16, channels, rate, bitDepth int, chunks []byte, riffSize, dataSize uint32) []byte {
+	blockAlign := channels * bitDepth / 8
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, riffSize)
+	b = append(b, "WAVE"...)
+	b = append(b, "fmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, format)
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate*blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(bitDepth))
+	b = append(b, chunks...)
+	b = append(b, "data"...)
+	return binary.LittleEndian.AppendUint32(b, dataSize)
+}
+
+func wavFormat(bitDepth int) (format uint16, err error) {
+	switch bitDepth {
+	case 16, 24:
+		return wavFormatPCM, nil
+	case 32:
+		return wavFormatFloat, nil
+	default:
+		return 0, fmt.Errorf("unsupported bit depth: %d", bitDepth)
+	}
+}
+
+// wavSampleFormat returns the encoding of samples in WAV files for the given bit depth.
+func wavSampleFormat(bitDepth int) Format {
+	return Format{BitDepth: bitDepth, Float: bitDepth == 32}
+}
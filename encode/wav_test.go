@@ -0,0 +1,29 @@
+package encode
+
+import (
+	"io"
+	"testing"
+)
+
+// blockReader reads n blocks of silence, then io.EOF.
+type blockReader struct{ n int }
+
+func (r *blockReader) Read(frames []float64) (n int, err error) {
+	if r.n == 0 {
+		return 0, io.EOF
+	}
+	r.n--
+	clear(frames)
+	return len(frames), nil
+}
+
+// BenchmarkWriteWAVStream measures a WAV stream (of unknown length) of one block per iteration:
+// past the header and the buffers set up by the first block, writing blocks must not allocate.
+func BenchmarkWriteWAVStream(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(blockSize * 3)
+	err := S24LE.WriteWAV(io.Discard, &blockReader{n: b.N}, -1, 44100)
+	if err != nil {
+		b.Fatal(err)
+	}
+}
@@ -0,0 +1,143 @@
+package encode
+
+import (
+	"encoding/binary"
+	"io"
+	"sort"
+	"time"
+)
+
+// Cue is a named position in a WAV file (a marker), shown by audio editors and samplers.
+type Cue struct {
+	Name  string
+	Frame int
+}
+
+// Loop is a region of a WAV file that samplers play in a loop, from Start to End (excluded), in frames.
+type Loop struct {
+	Start, End int
+}
+
+// Broadcast is the provenance of a Broadcast Wave file (EBU Tech 3285), read by most DAWs.
+// Longer texts are truncated to the size of their fields.
+type Broadcast struct {
+	Description         string    // Up to 256 characters.
+	Originator          string    // Up to 32 characters, like the name of the software.
+	OriginatorReference string    // Up to 32 characters, a unique identifier of the file.
+	Origination         time.Time // Date and time of the creation of the file.
+	TimeReference       int       // Position of the first frame in the timeline (frames since midnight), for stems.
+	CodingHistory       string    // Lines like "A=PCM,F=44100,W=16,M=mono,T=synth", one per step of processing.
+}
+
+// WAVMetadata holds what is written in WAV files besides the audio.
+type WAVMetadata struct {
+	Cues      []Cue             // Written in the "cue " chunk, with their names in a "LIST" chunk of type "adtl".
+	Loops     []Loop            // Written in the "smpl" chunk.
+	Broadcast *Broadcast        // Written in the "bext" chunk if not nil.
+	Info      map[string]string // Tags of the "LIST" chunk of type "INFO" by ID, like "INAM" (title), "IART" (artist), "ICMT" (comment) or "ISFT" (software).
+	IXML      string            // XML document written as is in the "iXML" chunk if not empty.
+}
+
+// WriteWAVWith is like WriteWAV, also writing the metadata (in chunks before the audio data).
+func (f Format) WriteWAVWith(w io.Writer, r FrameReader, n int, rate int, meta WAVMetadata) error {
+	return f.writeWAV(w, r, n, rate, meta.chunks(rate))
+}
+
+// chunks returns the encoding of the chunks of the metadata.
+func (m WAVMetadata) chunks(rate int) (b []byte) {
+	le := binary.LittleEndian
+	if bw := m.Broadcast; bw != nil {
+		bext := appendText(nil, bw.Description, 256)
+		bext = appendText(bext, bw.Originator, 32)
+		bext = appendText(bext, bw.OriginatorReference, 32)
+		date, clock := "", ""
+		if !bw.Origination.IsZero() {
+			date, clock = bw.Origination.Format("2006-01-02"), bw.Origination.Format("15:04:05")
+		}
+		bext = appendText(bext, date, 10)
+		bext = appendText(bext, clock, 8)
+		bext = le.AppendUint64(bext, uint64(bw.TimeReference))
+		bext = le.AppendUint16(bext, 1)           // Version.
+		bext = append(bext, make([]byte, 254)...) // UMID and reserved bytes.
+		bext = append(bext, bw.CodingHistory...)
+		b = appendChunk(b, "bext", bext)
+	}
+	if len(m.Info) > 0 {
+		ids := make([]string, 0, len(m.Info))
+		for id := range m.Info {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		info := []byte("INFO")
+		for _, id := range ids {
+			info = appendChunk(info, id, append([]byte(m.Info[id]), 0))
+		}
+		b = appendChunk(b, "LIST", info)
+	}
+	if m.IXML != "" {
+		b = appendChunk(b, "iXML", []byte(m.IXML))
+	}
+	if len(m.Cues) > 0 {
+		cue := le.AppendUint32(nil, uint32(len(m.Cues)))
+		var labels []byte
+		for i, c := range m.Cues {
+			id := uint32(i + 1)
+			cue = le.AppendUint32(cue, id)
+			cue = le.AppendUint32(cue, uint32(c.Frame)) // Position in the playlist (there is none).
+			cue = append(cue, "data"...)
+			cue = le.AppendUint32(cue, 0) // Chunk and block starts, 0 for uncompressed data.
+			cue = le.AppendUint32(cue, 0)
+			cue = le.AppendUint32(cue, uint32(c.Frame))
+			if c.Name != "" {
+				labl := le.AppendUint32(nil, id)
+				labl = append(append(labl, c.Name...), 0)
+				labels = appendChunk(labels, "labl", labl)
+			}
+		}
+		b = appendChunk(b, "cue ", cue)
+		if len(labels) > 0 {
+			b = appendChunk(b, "LIST", append([]byte("adtl"), labels...))
+		}
+	}
+	if len(m.Loops) > 0 {
+		smpl := le.AppendUint32(nil, 0)                          // Manufacturer.
+		smpl = le.AppendUint32(smpl, 0)                          // Product.
+		smpl = le.AppendUint32(smpl, uint32(1_000_000_000/rate)) // Sample period, in nanoseconds.
+		smpl = le.AppendUint32(smpl, 60)                         // MIDI unity note (middle C).
+		smpl = le.AppendUint32(smpl, 0)                          // Pitch fraction.
+		smpl = le.AppendUint32(smpl, 0)                          // SMPTE format.
+		smpl = le.AppendUint32(smpl, 0)                          // SMPTE offset.
+		smpl = le.AppendUint32(smpl, uint32(len(m.Loops)))
+		smpl = le.AppendUint32(smpl, 0) // Sampler data.
+		for i, l := range m.Loops {
+			smpl = le.AppendUint32(smpl, uint32(i+1)) // Identifier.
+			smpl = le.AppendUint32(smpl, 0)           // Forward loop.
+			smpl = le.AppendUint32(smpl, uint32(l.Start))
+			smpl = le.AppendUint32(smpl, uint32(max(l.End-1, l.Start))) // The end is the last frame played.
+			smpl = le.AppendUint32(smpl, 0)                             // Fraction.
+			smpl = le.AppendUint32(smpl, 0)                             // Play count, 0 loops forever.
+		}
+		b = appendChunk(b, "smpl", smpl)
+	}
+	return b
+}
+
+// appendChunk appends a RIFF chunk (padded to an even size) to b.
+func appendChunk(b []byte, id string, data []byte) []byte {
+	b = append(b, id...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+	b = append(b, data...)
+	if len(data)%2 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// appendText appends s to b, truncated or padded with zeros to n bytes.
+func appendText(b []byte, s string, n int) []byte {
+	if len(s) > n {
+		s = s[:n]
+	}
+	b = append(b, s...)
+	return append(b, make([]byte, n-len(s))...)
+}
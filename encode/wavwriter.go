@@ -0,0 +1,128 @@
+package encode
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// WAVWriter writes a WAV file of unknown length (like the recording of a live performance), growing as frames are written.
+//
+// Until it is closed, the sizes in the header are set to their maximum like for streams,
+// so the file can already be read (and survives a crash). Close sets the actual sizes.
+type WAVWriter struct {
+	w        io.WriteSeeker
+	bw       *bufio.Writer
+	encoder  *sampleEncoder
+	channels int
+	header   int // Size of the header, in bytes.
+	data     int // Size of the written data, in bytes.
+	buf      []byte
+	closed   bool
+}
+
+// CreateWAV creates a WAV file with the given number of channels at the path, for a WAVWriter.
+// WAV files support 16 and 24-bit signed little-endian integers and 32-bit little-endian floats (see Format.WriteWAV).
+func (f Format) CreateWAV(path string, channels, rate int) (*WAVWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	ww, err := f.NewWAVWriter(file, channels, rate)
+	if err != nil {
+		return nil, errors.Join(err, file.Close(), os.Remove(path))
+	}
+	return ww, nil
+}
+
+// NewWAVWriter writes the header of a WAV file with the given number of channels to w and returns a writer of its frames.
+// Closing the writer closes w if it is an io.Closer.
+func (f Format) NewWAVWriter(w io.WriteSeeker, channels, rate int) (*WAVWriter, error) {
+	format, err := wavFormat(f.BitDepth)
+	if err != nil {
+		return nil, err
+	} else if f.Float != (f.BitDepth == 32) || f.Unsigned || f.BigEndian {
+		return nil, fmt.Errorf("unsupported WAV format: %s", f)
+	}
+	channels = max(channels, 1)
+	header := appendWAVHeader(nil, format, channels, rate, f.BitDepth, nil, math.MaxUint32, math.MaxUint32)
+	ww := &WAVWriter{w: w, bw: bufio.NewWriter(w), encoder: f.newEncoder(channels), channels: channels, header: len(header)}
+	_, err = ww.bw.Write(header)
+	if err != nil {
+		return nil, fmt.Errorf("write header: %w", err)
+	}
+	return ww, nil
+}
+
+// Channels returns the number of channels of the file.
+func (ww *WAVWriter) Channels() int { return ww.channels }
+
+// Write appends frames to the file (with the samples of all channels interleaved).
+func (ww *WAVWriter) Write(frames []float64) error {
+	if ww.closed {
+		return errors.New("write to closed WAV writer")
+	}
+	ww.buf = ww.buf[:0]
+	for _, pulse := range frames {
+		ww.buf = ww.encoder.append(ww.buf, pulse)
+	}
+	n, err := ww.bw.Write(ww.buf)
+	ww.data += n
+	if err != nil {
+		return fmt.Errorf("write data: %w", err)
+	}
+	return nil
+}
+
+// Frames returns the number of frames written so far.
+func (ww *WAVWriter) Frames() int {
+	return ww.data / (ww.channels * ww.encoder.format.Size())
+}
+
+// Close finishes the file: it pads the data chunk, sets the sizes in the header and closes the underlying writer.
+func (ww *WAVWriter) Close() error {
+	if ww.closed {
+		return nil
+	}
+	ww.closed = true
+	err := ww.finish()
+	if c, ok := ww.w.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+func (ww *WAVWriter) finish() error {
+	padding := ww.data % 2
+	if padding != 0 {
+		err := ww.bw.WriteByte(0)
+		if err != nil {
+			return fmt.Errorf("write padding: %w", err)
+		}
+	}
+	err := ww.bw.Flush()
+	if err != nil {
+		return fmt.Errorf("write data: %w", err)
+	}
+
+	// The RIFF size is at the 4th byte and the data size in the last 4 bytes of the header.
+	sizes := []struct {
+		offset int64
+		size   int
+	}{{4, ww.header - 8 + ww.data + padding}, {int64(ww.header - 4), ww.data}}
+	for _, s := range sizes {
+		_, err = ww.w.Seek(s.offset, io.SeekStart)
+		if err == nil {
+			_, err = ww.w.Write(binary.LittleEndian.AppendUint32(nil, uint32(min(s.size, math.MaxUint32))))
+		}
+		if err != nil {
+			return fmt.Errorf("write header: %w", err)
+		}
+	}
+	_, err = ww.w.Seek(0, io.SeekEnd)
+	return err
+}
@@ -0,0 +1,137 @@
+// Package fm implements frequency modulation synthesis (as popularized by Yamaha's DX synthesizers):
+// sine operators modulate the phase of one another to produce rich, evolving timbres.
+package fm
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Operator is a sine oscillator with its own frequency ratio, level and envelope.
+type Operator struct {
+	Ratio    float64      // Frequency relative to the voice frequency.
+	Detune   float64      // Added to the frequency (in Hertz).
+	Level    float64      // Output level, for a modulator it acts as the modulation index.
+	Envelope synth.Signal // Multiplies the output level, always 1 if nil.
+	Feedback float64      // Amount of its own output modulating its phase.
+}
+
+// Algorithm describes how operators are wired together.
+type Algorithm struct {
+	Modulators [][]int // Modulators[i] lists the operators modulating operator i.
+	Carriers   []int   // Operators whose outputs are summed into the voice output.
+}
+
+// Common 4-operator algorithms, operators are numbered from 0.
+var (
+	// Stack chains all operators: 3 → 2 → 1 → 0.
+	Stack = Algorithm{Modulators: [][]int{{1}, {2}, {3}, nil}, Carriers: []int{0}}
+	// Y uses two modulators on the same operator: (2 + 3) → 1 → 0.
+	Y = Algorithm{Modulators: [][]int{{1}, {2, 3}, nil, nil}, Carriers: []int{0}}
+	// TwoStacks plays two pairs side by side: 1 → 0 and 3 → 2.
+	TwoStacks = Algorithm{Modulators: [][]int{{1}, nil, {3}, nil}, Carriers: []int{0, 2}}
+	// OneToThree has a single modulator shared by three carriers: 3 → (0, 1, 2).
+	OneToThree = Algorithm{Modulators: [][]int{{3}, {3}, {3}, nil}, Carriers: []int{0, 1, 2}}
+	// StackAndSine chains three operators next to a pure sine: 3 → 2 → 1, and 0.
+	StackAndSine = Algorithm{Modulators: [][]int{nil, {2}, {3}, nil}, Carriers: []int{0, 1}}
+	// Additive sums all operators without any modulation.
+	Additive = Algorithm{Modulators: [][]int{nil, nil, nil, nil}, Carriers: []int{0, 1, 2, 3}}
+)
+
+// Validate returns an error if the algorithm doesn't match the number of operators
+// or if operators modulate each other in a loop (use Operator.Feedback for self-modulation).
+func (alg Algorithm) Validate(n int) error {
+	if len(alg.Modulators) != n {
+		return fmt.Errorf("algorithm has %d operators instead of %d", len(alg.Modulators), n)
+	}
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, n)
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("operator %d modulates itself through other operators", i)
+		case done:
+			return nil
+		}
+		state[i] = visiting
+		for _, m := range alg.Modulators[i] {
+			if m < 0 || m >= n {
+				return fmt.Errorf("operator %d: invalid modulator %d", i, m)
+			}
+			err := visit(m)
+			if err != nil {
+				return err
+			}
+		}
+		state[i] = done
+		return nil
+	}
+	for i := range alg.Modulators {
+		err := visit(i)
+		if err != nil {
+			return err
+		}
+	}
+	for _, c := range alg.Carriers {
+		if c < 0 || c >= n {
+			return fmt.Errorf("invalid carrier %d", c)
+		}
+	}
+	return nil
+}
+
+// Voice returns the output of the operators wired with the given algorithm, playing at the given frequency (in Hertz).
+// It panics if the algorithm is invalid (see Algorithm.Validate).
+//
+// Modulation is applied to the phase of operators (like on DX synthesizers):
+// a modulator with a level of 1 shifts the phase of its carrier by up to one radian.
+func Voice(freq synth.Signal, ops []Operator, alg Algorithm) synth.Signal {
+	err := alg.Validate(len(ops))
+	if err != nil {
+		panic("fm: " + err.Error())
+	}
+	outputs := make([]synth.Signal, len(ops))
+	var output func(i int) synth.Signal
+	output = func(i int) synth.Signal {
+		if outputs[i] == nil {
+			var mods []synth.Signal
+			for _, m := range alg.Modulators[i] {
+				mods = append(mods, output(m))
+			}
+			outputs[i] = operator(freq, ops[i], synth.Add(mods...))
+		}
+		return outputs[i]
+	}
+	carriers := make([]synth.Signal, len(alg.Carriers))
+	for i, c := range alg.Carriers {
+		carriers[i] = output(c)
+	}
+	return synth.Add(carriers...)
+}
+
+// operator returns the output of an operator, its phase being modulated by mod (in radians).
+func operator(freq synth.Signal, op Operator, mod synth.Signal) synth.Signal {
+	phase := synth.Phase(func(x time.Duration) float64 { return freq(x)*op.Ratio + op.Detune })
+	env := op.Envelope
+	if env == nil {
+		env = synth.Constant(1)
+	}
+	return synth.Stateful(func() func(x time.Duration, dt float64) float64 {
+		// Feedback uses the average of the two previous outputs, which keeps it from oscillating at Nyquist.
+		var prev1, prev2 float64
+		return func(x time.Duration, dt float64) float64 {
+			fb := op.Feedback * (prev1 + prev2) / 2
+			y := op.Level * env(x) * math.Sin(2*math.Pi*phase(x)+mod(x)+fb)
+			prev2, prev1 = prev1, y
+			return y
+		}
+	})
+}
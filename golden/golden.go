@@ -0,0 +1,219 @@
+// Package golden checks that patches still render the same sound, so DSP refactors can be validated:
+// renders of named patches are compared against stored golden hashes (bit-for-bit),
+// or against stored golden renders within a tolerance.
+//
+// A suite is described by a JSON file listing its cases, patch paths are relative to it:
+//
+//	{"cases": [
+//		{"name": "pluck", "patch": "pluck.json", "hash": "0f3c..."},
+//		{"name": "pad", "patch": "pad.json", "duration": "2s", "tolerance": 1e-6}
+//	]}
+//
+// Cases with a tolerance also keep their golden render next to the suite, as raw F64LE frames in a file named after the case
+// (like "pad.f64"), so it has the exact precision of the render.
+// Hashes may differ between CPU architectures (which can fuse floating-point operations differently),
+// suites checked on several architectures need a tolerance.
+package golden
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/encode"
+	"github.com/ejuju/poc-go-audio-synthesis/patch"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Case is a patch with its expected render.
+type Case struct {
+	Name      string         `json:"name"`
+	Patch     string         `json:"patch"`               // Path of the patch file, relative to the suite.
+	Duration  patch.Duration `json:"duration,omitempty"`  // Length of the render, the duration of the patch if zero.
+	Hash      string         `json:"hash,omitempty"`      // Of the golden render (see Hash), set by Update.
+	Tolerance float64        `json:"tolerance,omitempty"` // Largest difference allowed from the golden render, 0 for bit-for-bit renders.
+}
+
+// Suite is a set of cases.
+type Suite struct {
+	Cases []Case `json:"cases"`
+	path  string
+}
+
+// Load reads a suite from a JSON file.
+func Load(path string) (*Suite, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Suite{path: path}
+	err = json.Unmarshal(b, s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, c := range s.Cases {
+		if c.Name == "" || c.Patch == "" {
+			return nil, fmt.Errorf("%s: case %d must have a name and a patch", path, i)
+		}
+	}
+	return s, nil
+}
+
+// Save writes the suite back to its file.
+func (s *Suite) Save() error {
+	b, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, append(b, '\n'), 0o644)
+}
+
+// Result is the outcome of the verification of a case.
+type Result struct {
+	Case    string
+	Frames  int
+	Hash    string  // Of the new render.
+	Exact   bool    // Whether the new render is bit-for-bit the golden one.
+	MaxDiff float64 // Largest difference from the golden render, if it is compared to it.
+	Err     error   // Why the case failed, nil if it passed.
+}
+
+// Verify renders the case with the given name (every case if empty) and compares it to its golden render.
+func (s *Suite) Verify(name string) ([]Result, error) {
+	var results []Result
+	for _, c := range s.Cases {
+		if name == "" || c.Name == name {
+			results = append(results, s.verify(c))
+		}
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no case named %q", name)
+	}
+	return results, nil
+}
+
+func (s *Suite) verify(c Case) Result {
+	r := Result{Case: c.Name}
+	frames, err := s.render(c)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Frames, r.Hash = len(frames), Hash(frames)
+	if c.Hash == "" {
+		r.Err = errors.New("no golden render (update the suite first)")
+		return r
+	} else if r.Hash == c.Hash {
+		r.Exact = true
+		return r
+	} else if c.Tolerance <= 0 {
+		r.Err = errors.New("the render changed")
+		return r
+	}
+
+	want, err := readReference(s.reference(c))
+	if err != nil {
+		r.Err = fmt.Errorf("load golden render: %w", err)
+		return r
+	}
+	if len(want) != len(frames) {
+		r.Err = fmt.Errorf("the render has %d frames instead of %d", len(frames), len(want))
+		return r
+	}
+	for i, v := range frames {
+		r.MaxDiff = math.Max(r.MaxDiff, math.Abs(v-want[i]))
+	}
+	if math.IsNaN(r.MaxDiff) || r.MaxDiff > c.Tolerance {
+		r.Err = fmt.Errorf("the render differs by up to %g (tolerance %g)", r.MaxDiff, c.Tolerance)
+	}
+	return r
+}
+
+// Update renders the case with the given name (every case if empty) and stores the render as the golden one,
+// then saves the suite.
+func (s *Suite) Update(name string) error {
+	found := false
+	for i, c := range s.Cases {
+		if name != "" && c.Name != name {
+			continue
+		}
+		found = true
+		frames, err := s.render(c)
+		if err != nil {
+			return fmt.Errorf("case %q: %w", c.Name, err)
+		}
+		s.Cases[i].Hash = Hash(frames)
+		if c.Tolerance > 0 {
+			err = os.WriteFile(s.reference(c), encode.F64LE.Encode(frames), 0o644)
+			if err != nil {
+				return fmt.Errorf("case %q: %w", c.Name, err)
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("no case named %q", name)
+	}
+	return s.Save()
+}
+
+// readReference reads a golden render (raw F64LE frames).
+func readReference(path string) ([]float64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	} else if len(b)%8 != 0 {
+		return nil, fmt.Errorf("%s: truncated frame", path)
+	}
+	frames := make([]float64, len(b)/8)
+	for i := range frames {
+		frames[i] = math.Float64frombits(binary.LittleEndian.Uint64(b[8*i:]))
+	}
+	return frames, nil
+}
+
+// reference returns the path of the golden render of a case.
+func (s *Suite) reference(c Case) string {
+	return filepath.Join(filepath.Dir(s.path), c.Name+".f64")
+}
+
+// render renders the patch of a case.
+func (s *Suite) render(c Case) ([]float64, error) {
+	path := c.Patch
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(s.path), path)
+	}
+	p, err := patch.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	length := time.Duration(c.Duration)
+	if length <= 0 {
+		length = time.Duration(p.Duration)
+	}
+	if length <= 0 {
+		return nil, errors.New("the case or its patch must have a duration")
+	}
+	signal, err := p.Build()
+	if err != nil {
+		return nil, err
+	}
+	return synth.Sample(signal, p.Rate, 0, length), nil
+}
+
+// Hash returns the SHA-256 hash of frames (of their exact bits, as little-endian float64 values), in hexadecimal.
+func Hash(frames []float64) string {
+	h := sha256.New()
+	b := make([]byte, 8)
+	for _, v := range frames {
+		binary.LittleEndian.PutUint64(b, math.Float64bits(v))
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,87 @@
+package graph
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Description is a serializable view of a graph.
+type Description struct {
+	Nodes       []NodeDescription `json:"nodes"`
+	Connections []Connection      `json:"connections"`
+	Order       []string          `json:"order,omitempty"` // Schedule, if the graph compiles.
+}
+
+// NodeDescription describes a node and the value of its unconnected inputs.
+type NodeDescription struct {
+	Name    string             `json:"name"`
+	Type    string             `json:"type"`
+	Inputs  []string           `json:"inputs"`
+	Outputs []string           `json:"outputs"`
+	Values  map[string]float64 `json:"values,omitempty"`
+}
+
+// MarshalText encodes the port as "node.port".
+func (p Port) MarshalText() ([]byte, error) { return []byte(p.String()), nil }
+
+// UnmarshalText parses "node.port".
+func (p *Port) UnmarshalText(b []byte) (err error) {
+	*p, err = ParsePort(string(b))
+	return err
+}
+
+// Describe returns the nodes (sorted by name) and connections of the graph.
+func (g *Graph) Describe() Description {
+	d := Description{Connections: slices.Clone(g.connections)}
+	for _, n := range g.sortedNodes() {
+		typ := fmt.Sprintf("%T", n.p)
+		if t, ok := n.p.(Typed); ok {
+			typ = t.Type()
+		}
+		nd := NodeDescription{Name: n.name, Type: typ, Inputs: n.p.Inputs(), Outputs: n.p.Outputs()}
+		for input, i := range n.inputs {
+			if n.values[i] != 0 {
+				if nd.Values == nil {
+					nd.Values = map[string]float64{}
+				}
+				nd.Values[input] = n.values[i]
+			}
+		}
+		d.Nodes = append(d.Nodes, nd)
+	}
+	slices.SortStableFunc(d.Connections, func(a, b Connection) int {
+		return cmp.Or(cmp.Compare(a.To.String(), b.To.String()), cmp.Compare(a.From.String(), b.From.String()))
+	})
+	if g.Compile() == nil {
+		for _, n := range g.order {
+			d.Order = append(d.Order, n.name)
+		}
+	}
+	return d
+}
+
+// MarshalJSON encodes the description of the graph.
+func (g *Graph) MarshalJSON() ([]byte, error) { return json.Marshal(g.Describe()) }
+
+// WriteDot writes the graph in the DOT language of Graphviz, to visualize it (with "dot -Tsvg").
+func (g *Graph) WriteDot(w io.Writer) error {
+	d := g.Describe()
+	_, err := fmt.Fprintln(w, "digraph {\n\trankdir=LR;")
+	for _, n := range d.Nodes {
+		if err == nil {
+			_, err = fmt.Fprintf(w, "\t%q [label=%q shape=box];\n", n.Name, n.Name+"\n"+n.Type)
+		}
+	}
+	for _, c := range d.Connections {
+		if err == nil {
+			_, err = fmt.Fprintf(w, "\t%q -> %q [taillabel=%q headlabel=%q];\n", c.From.Node, c.To.Node, c.From.Name, c.To.Name)
+		}
+	}
+	if err == nil {
+		_, err = fmt.Fprintln(w, "}")
+	}
+	return err
+}
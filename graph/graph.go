@@ -0,0 +1,336 @@
+// Package graph is an explicit alternative to composing signals as closures:
+// processing nodes with named inputs and outputs are connected into a graph that can be inspected and serialized,
+// and that is scheduled in topological order, one frame at a time.
+//
+// Feedback loops are allowed only through nodes that delay their input (see Delayer).
+package graph
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Processor computes the outputs of a node from its inputs, one frame at a time.
+type Processor interface {
+	Inputs() []string
+	Outputs() []string
+	// Process computes the outputs of a frame from the inputs (in the order of Inputs and Outputs),
+	// with x the time of the frame and dt the duration of a frame (in seconds).
+	Process(x time.Duration, dt float64, in, out []float64)
+}
+
+// Delayer is a processor whose outputs only depend on the inputs of previous frames (like a delay line),
+// edges to delayers don't count as dependencies so they can close feedback loops.
+//
+// Read is called first with the outputs of the frame, then Process with its inputs (and the same outputs).
+type Delayer interface {
+	Processor
+	Read(out []float64)
+}
+
+// Port refers to an input or an output of a node, written as "node.port".
+type Port struct {
+	Node, Name string
+}
+
+// ParsePort parses a port reference ("node.port").
+func ParsePort(s string) (Port, error) {
+	node, name, ok := strings.Cut(s, ".")
+	if !ok || node == "" || name == "" {
+		return Port{}, fmt.Errorf("invalid port %q (expected node.port)", s)
+	}
+	return Port{Node: node, Name: name}, nil
+}
+
+func (p Port) String() string { return p.Node + "." + p.Name }
+
+// Connection connects an output to an input, inputs with several connections receive their sum.
+type Connection struct {
+	From Port `json:"from"`
+	To   Port `json:"to"`
+}
+
+// Graph is a set of nodes and connections between them.
+// A graph is not safe for concurrent use.
+type Graph struct {
+	rate        int
+	nodes       map[string]*node
+	connections []Connection
+	order       []*node // Schedule, nil until compiled.
+	frame       int
+}
+
+type node struct {
+	name    string
+	p       Processor
+	inputs  map[string]int // Index of each input, by name.
+	outputs map[string]int // Index of each output, by name.
+	values  []float64      // Value of unconnected inputs (0 by default).
+	in, out []float64
+	sources [][]*float64 // Outputs connected to each input.
+}
+
+// New returns an empty graph running at the given sample rate.
+func New(rate int) *Graph {
+	return &Graph{rate: rate, nodes: map[string]*node{}}
+}
+
+// Add adds a node.
+func (g *Graph) Add(name string, p Processor) error {
+	if _, ok := g.nodes[name]; ok {
+		return fmt.Errorf("duplicate node %q", name)
+	} else if name == "" || strings.Contains(name, ".") {
+		return fmt.Errorf("invalid node name %q", name)
+	}
+	n := &node{name: name, p: p, inputs: map[string]int{}, outputs: map[string]int{}}
+	for i, input := range p.Inputs() {
+		n.inputs[input] = i
+	}
+	for i, output := range p.Outputs() {
+		n.outputs[output] = i
+	}
+	n.values = make([]float64, len(n.inputs))
+	n.in, n.out = make([]float64, len(n.inputs)), make([]float64, len(n.outputs))
+	n.sources = make([][]*float64, len(n.inputs))
+	g.nodes[name] = n
+	g.order = nil
+	return nil
+}
+
+// Connect connects an output ("node.output") to an input ("node.input").
+func (g *Graph) Connect(from, to string) error {
+	src, err := g.port(from, false)
+	if err != nil {
+		return err
+	}
+	dst, err := g.port(to, true)
+	if err != nil {
+		return err
+	}
+	g.connections = append(g.connections, Connection{From: src, To: dst})
+	g.order = nil
+	return nil
+}
+
+// Set sets the value of an input ("node.input") that isn't connected.
+func (g *Graph) Set(input string, v float64) error {
+	p, err := g.port(input, true)
+	if err != nil {
+		return err
+	}
+	n := g.nodes[p.Node]
+	n.values[n.inputs[p.Name]] = v
+	return nil
+}
+
+// port parses a reference to an existing port.
+func (g *Graph) port(ref string, input bool) (Port, error) {
+	p, err := ParsePort(ref)
+	if err != nil {
+		return p, err
+	}
+	n, ok := g.nodes[p.Node]
+	if !ok {
+		return p, fmt.Errorf("unknown node %q", p.Node)
+	}
+	ports, kind := n.outputs, "output"
+	if input {
+		ports, kind = n.inputs, "input"
+	}
+	if _, ok := ports[p.Name]; !ok {
+		return p, fmt.Errorf("node %q has no %s %q", p.Node, kind, p.Name)
+	}
+	return p, nil
+}
+
+// Compile schedules the nodes so that each node is processed after the nodes it depends on.
+// It returns an error if the graph has a cycle that doesn't go through a Delayer.
+// Compile is called by Step if the graph changed.
+func (g *Graph) Compile() error {
+	dependents := map[*node][]*node{}
+	pending := map[*node]int{} // Number of dependencies not yet scheduled.
+	for _, n := range g.nodes {
+		clear(n.sources)
+	}
+	for _, c := range g.connections {
+		from, to := g.nodes[c.From.Node], g.nodes[c.To.Node]
+		i := to.inputs[c.To.Name]
+		to.sources[i] = append(to.sources[i], &from.out[from.outputs[c.From.Name]])
+		if _, ok := to.p.(Delayer); !ok {
+			dependents[from] = append(dependents[from], to)
+			pending[to]++
+		}
+	}
+
+	// Kahn's algorithm, ready nodes being sorted by name so the schedule is deterministic.
+	// Delayers are processed last, once all their inputs are known (their outputs are read first).
+	var order, delayers, ready []*node
+	for _, n := range g.nodes {
+		if pending[n] == 0 {
+			ready = append(ready, n)
+		}
+	}
+	for len(ready) > 0 {
+		slices.SortFunc(ready, func(a, b *node) int { return cmp.Compare(b.name, a.name) })
+		n := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		if _, ok := n.p.(Delayer); ok {
+			delayers = append(delayers, n)
+		} else {
+			order = append(order, n)
+		}
+		for _, d := range dependents[n] {
+			pending[d]--
+			if pending[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+	if len(order)+len(delayers) < len(g.nodes) {
+		return fmt.Errorf("cycle without delay: %s", g.cycle(dependents, pending))
+	}
+	g.order = append(order, delayers...)
+	return nil
+}
+
+// cycle returns the names of the nodes of a cycle among the nodes that couldn't be scheduled.
+func (g *Graph) cycle(dependents map[*node][]*node, pending map[*node]int) string {
+	dependencies := map[*node][]*node{}
+	for n, ds := range dependents {
+		for _, d := range ds {
+			dependencies[d] = append(dependencies[d], n)
+		}
+	}
+	var start *node
+	for _, n := range g.sortedNodes() {
+		if pending[n] > 0 {
+			start = n
+			break
+		}
+	}
+	// Unscheduled nodes all have an unscheduled dependency, follow them until a node is seen twice.
+	seen := map[*node]int{}
+	var path []string
+	for n := start; ; {
+		if i, ok := seen[n]; ok {
+			cycle := append(path[i:], n.name)
+			slices.Reverse(cycle)
+			return strings.Join(cycle, " -> ")
+		}
+		seen[n] = len(path)
+		path = append(path, n.name)
+		for _, d := range dependencies[n] {
+			if pending[d] > 0 {
+				n = d
+				break
+			}
+		}
+	}
+}
+
+func (g *Graph) sortedNodes() []*node {
+	nodes := make([]*node, 0, len(g.nodes))
+	for _, n := range g.nodes {
+		nodes = append(nodes, n)
+	}
+	slices.SortFunc(nodes, func(a, b *node) int { return cmp.Compare(a.name, b.name) })
+	return nodes
+}
+
+// Step processes the next frame.
+func (g *Graph) Step() error {
+	if g.order == nil {
+		err := g.Compile()
+		if err != nil {
+			return err
+		}
+	}
+	x, dt := synth.AtFrame(g.frame, g.rate), 1/float64(g.rate)
+	for _, n := range g.order {
+		if d, ok := n.p.(Delayer); ok {
+			d.Read(n.out)
+		}
+	}
+	for _, n := range g.order {
+		for i, sources := range n.sources {
+			v := n.values[i]
+			if len(sources) > 0 {
+				v = 0
+			}
+			for _, src := range sources {
+				v += *src
+			}
+			n.in[i] = v
+		}
+		n.p.Process(x, dt, n.in, n.out)
+	}
+	g.frame++
+	return nil
+}
+
+// Value returns the value of an output ("node.output") at the last frame.
+func (g *Graph) Value(output string) (float64, error) {
+	p, err := g.port(output, false)
+	if err != nil {
+		return 0, err
+	}
+	n := g.nodes[p.Node]
+	return n.out[n.outputs[p.Name]], nil
+}
+
+// Render processes n frames and returns the values of an output ("node.output").
+func (g *Graph) Render(output string, n int) ([]float64, error) {
+	p, err := g.port(output, false)
+	if err != nil {
+		return nil, err
+	}
+	out := &g.nodes[p.Node].out[g.nodes[p.Node].outputs[p.Name]]
+	frames := make([]float64, n)
+	for i := range frames {
+		err := g.Step()
+		if err != nil {
+			return nil, err
+		}
+		frames[i] = *out
+	}
+	return frames, nil
+}
+
+// Signal returns an output ("node.output") as a signal, processing frames as time passes.
+// The graph can't go back in time: earlier times return the value of the last frame.
+func (g *Graph) Signal(output string) (synth.Signal, error) {
+	p, err := g.port(output, false)
+	if err != nil {
+		return nil, err
+	}
+	if g.order == nil {
+		err = g.Compile()
+		if err != nil {
+			return nil, err
+		}
+	}
+	n := g.nodes[p.Node]
+	out := &n.out[n.outputs[p.Name]]
+	return func(x time.Duration) float64 {
+		for target := synth.FrameAt(x, g.rate); g.frame <= target; {
+			if g.Step() != nil {
+				return 0
+			}
+		}
+		return *out
+	}, nil
+}
+
+// Node returns the processor of a node.
+func (g *Graph) Node(name string) (Processor, error) {
+	n, ok := g.nodes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown node %q", name)
+	}
+	return n.p, nil
+}
@@ -0,0 +1,99 @@
+package graph
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Typed processors have a type name, used when describing graphs (the Go type is used otherwise).
+type Typed interface {
+	Type() string
+}
+
+type funcNode struct {
+	typ    string
+	inputs []string
+	values []float64 // Inputs of the current frame.
+	s      synth.Signal
+}
+
+// Func returns a node computing a signal built from its inputs, with a single output named "out".
+// Inputs are given to build as signals returning the value of the input at the current frame,
+// so any function of package synth can be used as a node:
+//
+//	graph.Func("lowpass", []string{"in", "cutoff", "q"}, func(in []synth.Signal) synth.Signal {
+//		return synth.LowPass(in[0], in[1], in[2])
+//	})
+func Func(typ string, inputs []string, build func(in []synth.Signal) synth.Signal) Processor {
+	f := &funcNode{typ: typ, inputs: inputs, values: make([]float64, len(inputs))}
+	signals := make([]synth.Signal, len(inputs))
+	for i := range signals {
+		signals[i] = func(time.Duration) float64 { return f.values[i] }
+	}
+	f.s = build(signals)
+	return f
+}
+
+func (f *funcNode) Type() string      { return f.typ }
+func (f *funcNode) Inputs() []string  { return f.inputs }
+func (f *funcNode) Outputs() []string { return []string{"out"} }
+
+func (f *funcNode) Process(x time.Duration, dt float64, in, out []float64) {
+	copy(f.values, in)
+	out[0] = f.s(x)
+}
+
+// Sine returns a sine oscillator node with input "freq".
+func Sine() Processor {
+	return Func("sine", []string{"freq"}, func(in []synth.Signal) synth.Signal { return synth.Sine(in[0]) })
+}
+
+// Saw returns a saw oscillator node with input "freq".
+func Saw() Processor {
+	return Func("saw", []string{"freq"}, func(in []synth.Signal) synth.Signal { return synth.Saw(in[0]) })
+}
+
+// Mul returns a node multiplying its inputs "a" and "b" (like a VCA, with a signal and an envelope).
+func Mul() Processor {
+	return Func("mul", []string{"a", "b"}, func(in []synth.Signal) synth.Signal { return synth.Mul(in[0], in[1]) })
+}
+
+// LowPass returns a low-pass filter node with inputs "in", "cutoff" (in Hertz) and "q".
+func LowPass() Processor {
+	return Func("lowpass", []string{"in", "cutoff", "q"}, func(in []synth.Signal) synth.Signal {
+		return synth.LowPass(in[0], in[1], in[2])
+	})
+}
+
+type delay struct {
+	buf     []float64
+	pos     int     // Index of the next write.
+	samples float64 // Delay time of the last frame, in samples.
+}
+
+// Delay returns a delay line of up to max, with inputs "in" and "time" (in seconds) and output "out".
+// Since its output only depends on past inputs, it can be used in feedback loops (for echoes, combs, etc.),
+// delays are at least one frame long.
+func Delay(max time.Duration, rate int) Processor {
+	return &delay{buf: make([]float64, synth.FrameAt(max, rate)+2)}
+}
+
+func (d *delay) Type() string      { return "delay" }
+func (d *delay) Inputs() []string  { return []string{"in", "time"} }
+func (d *delay) Outputs() []string { return []string{"out"} }
+
+// Read interpolates the input between the two frames around the delay time.
+func (d *delay) Read(out []float64) {
+	samples := math.Max(1, math.Min(d.samples, float64(len(d.buf)-1)))
+	i, frac := math.Modf(samples)
+	at := func(back int) float64 { return d.buf[(d.pos-back+len(d.buf))%len(d.buf)] }
+	out[0] = at(int(i))*(1-frac) + at(int(i)+1)*frac
+}
+
+func (d *delay) Process(x time.Duration, dt float64, in, out []float64) {
+	d.buf[d.pos] = in[0]
+	d.pos = (d.pos + 1) % len(d.buf)
+	d.samples = in[1] / dt
+}
@@ -0,0 +1,29 @@
+// Package dsp holds helpers shared by the signal processing packages.
+package dsp
+
+import (
+	"math"
+	"time"
+)
+
+// RateMeter estimates the sample rate of a stateful signal from the times it is evaluated at,
+// for signals that depend on it (like filters).
+//
+// Since durations are rounded to the nanosecond, consecutive samples aren't exactly evenly spaced,
+// so the rate is averaged since the first sample and rounded to the nearest Hertz.
+type RateMeter struct {
+	start time.Duration
+	n     int
+}
+
+// Tick records a new sample at x and returns the estimated rate (in Hertz), 0 if it is unknown yet.
+func (m *RateMeter) Tick(x time.Duration) (rate float64) {
+	if m.n == 0 {
+		m.start = x
+	}
+	m.n++
+	if x == m.start {
+		return 0
+	}
+	return math.Round(float64(m.n-1) / (x - m.start).Seconds())
+}
@@ -0,0 +1,60 @@
+// Package fft implements the fast Fourier transform for power-of-two sizes.
+package fft
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+)
+
+// Forward computes the discrete Fourier transform of x in place.
+// It panics if the length of x is not a power of two.
+func Forward(x []complex128) { transform(x, -1) }
+
+// Inverse computes the inverse discrete Fourier transform of x in place (including the 1/n scaling).
+func Inverse(x []complex128) {
+	transform(x, 1)
+	scale := complex(1/float64(len(x)), 0)
+	for i := range x {
+		x[i] *= scale
+	}
+}
+
+// NextPow2 returns the smallest power of two greater than or equal to n.
+func NextPow2(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
+
+// transform is an iterative radix-2 Cooley-Tukey FFT, sign being the sign of the exponent.
+func transform(x []complex128, sign float64) {
+	n := len(x)
+	if n <= 1 {
+		return
+	} else if n&(n-1) != 0 {
+		panic("fft: length is not a power of two")
+	}
+
+	// Bit-reversal permutation.
+	shift := 64 - bits.Len(uint(n-1))
+	for i := range x {
+		j := int(bits.Reverse64(uint64(i)) >> shift)
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size *= 2 {
+		step := cmplx.Exp(complex(0, sign*2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], w*x[start+k+size/2]
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+}
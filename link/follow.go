@@ -0,0 +1,64 @@
+package link
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/transport"
+)
+
+// followPeriod is the interval between the checks of a followed transport.
+const followPeriod = 20 * time.Millisecond
+
+// phaseTolerance is the phase difference (in beats) under which a followed transport isn't moved,
+// since its recorded positions jitter by the blocks computed ahead by the player.
+const phaseTolerance = 1.0 / 32
+
+// Follow keeps a transport in sync with the session until it is closed:
+// tempo changes of the session are applied to the transport and tempo changes of the transport to the session,
+// and the transport is moved when its phase in the given quantum (in beats, like 4 for a bar) drifts from the session.
+// The latency is how long after the transport computes a position it is heard (the latency of the player),
+// so the sound lines up with the peers rather than the computation.
+func (s *Session) Follow(t *transport.Transport, quantum float64, latency time.Duration) {
+	if quantum <= 0 {
+		quantum = 1
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(followPeriod)
+		defer ticker.Stop()
+		last := t.BPM()
+		var located time.Time
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+			if bpm := t.BPM(); bpm != last {
+				s.SetTempo(bpm)
+				last = bpm
+			} else if bpm := s.Tempo(); math.Abs(bpm-last) > 1e-6 {
+				t.SetBPM(bpm)
+				last = bpm
+			}
+
+			beat, at := t.LastPosition()
+			if !t.Playing() || !at.After(located) {
+				continue // Stopped, or not computed since it was last moved.
+			}
+			diff := math.Mod(beat-s.Beat(at.Add(latency)), quantum)
+			if diff > quantum/2 {
+				diff -= quantum
+			} else if diff < -quantum/2 {
+				diff += quantum
+			}
+			if math.Abs(diff) > phaseTolerance {
+				now := beat + time.Since(at).Seconds()*last/60
+				t.Locate(now - diff)
+				located = time.Now()
+			}
+		}
+	}()
+}
@@ -0,0 +1,340 @@
+// Package link synchronizes the tempo and the beat phase with other applications of the local network
+// (like Ableton Live or TidalCycles) with the Ableton Link protocol.
+//
+// It implements the discovery of peers, sessions, timelines (tempo and beats) and the measurement of the offset
+// between the clocks of peers from version 1 of the protocol, over IPv4.
+// Start and stop synchronization isn't supported.
+package link
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ttl is how long (in seconds) peers consider a peer alive without news from it.
+const ttl = 5
+
+// broadcastPeriod is the interval between discovery messages.
+const broadcastPeriod = 250 * time.Millisecond
+
+// sessionEpsilon is the difference of clocks (in microseconds) under which two sessions are considered as old
+// as each other, when deciding which one to join.
+const sessionEpsilon = 500_000
+
+// remeasure is how long a session that wasn't joined is left alone before measuring it again.
+const remeasure = 30 * time.Second
+
+// Session is a peer of a Link session: peers share a timeline, so they play at the same tempo and phase.
+// A new peer founds its own session, and joins the session that has been running the longest when it finds others.
+type Session struct {
+	id    nodeID
+	start time.Time // Origin of the host clock.
+
+	multicast *net.UDPConn // Receives discovery messages.
+	conn      *net.UDPConn // Sends discovery messages and exchanges measurements of clocks with peers.
+	group     *net.UDPAddr
+	endpoint  *net.UDPAddr
+
+	mu        sync.Mutex
+	session   nodeID
+	offset    int64 // Ghost time (the clock of the session) minus host time, in microseconds.
+	timeline  timeline
+	peers     map[nodeID]peer
+	measured  map[nodeID]time.Time        // Last measurement of other sessions.
+	measuring map[string]chan measurement // By endpoint of the peer being measured.
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+type peer struct {
+	discovery
+	expires time.Time
+}
+
+// Join starts a session at the given tempo (in beats per minute) and looks for peers on the network,
+// until Close is called.
+func Join(bpm float64) (*Session, error) {
+	group, err := net.ResolveUDPAddr("udp4", multicastAddr)
+	if err != nil {
+		return nil, err
+	}
+	multicast, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return nil, fmt.Errorf("join the Link multicast group: %w", err)
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		multicast.Close()
+		return nil, err
+	}
+
+	s := &Session{
+		start:     time.Now(),
+		multicast: multicast,
+		conn:      conn,
+		group:     group,
+		endpoint:  &net.UDPAddr{IP: localIP(), Port: conn.LocalAddr().(*net.UDPAddr).Port},
+		timeline:  timeline{tempo: tempo(bpm)},
+		peers:     map[nodeID]peer{},
+		measured:  map[nodeID]time.Time{},
+		measuring: map[string]chan measurement{},
+		done:      make(chan struct{}),
+	}
+	_, err = rand.Read(s.id[:])
+	if err != nil {
+		return nil, errors.Join(err, multicast.Close(), conn.Close())
+	}
+	s.session = s.id
+	s.wg.Add(3)
+	go s.receive(multicast)
+	go s.receive(conn)
+	go s.broadcast()
+	return s, nil
+}
+
+// localIP returns the address of the interface used to reach the multicast group, 0.0.0.0 if it is unknown
+// (peers then use the source address of the messages).
+func localIP() net.IP {
+	c, err := net.Dial("udp4", multicastAddr)
+	if err != nil {
+		return net.IPv4zero
+	}
+	defer c.Close()
+	return c.LocalAddr().(*net.UDPAddr).IP
+}
+
+// tempo converts beats per minute to microseconds per beat.
+func tempo(bpm float64) int64 { return int64(math.Round(60e6 / bpm)) }
+
+// Close leaves the session, telling the peers.
+func (s *Session) Close() error {
+	s.send(msgByeBye, s.group)
+	close(s.done)
+	err := errors.Join(s.multicast.Close(), s.conn.Close())
+	s.wg.Wait()
+	return err
+}
+
+// host returns the time of the host clock, in microseconds.
+func (s *Session) host() int64 { return time.Since(s.start).Microseconds() }
+
+// Tempo returns the tempo of the session (in beats per minute).
+func (s *Session) Tempo() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.timeline.bpm()
+}
+
+// SetTempo changes the tempo of the session (in beats per minute) for all peers, keeping the current beat.
+func (s *Session) SetTempo(bpm float64) {
+	s.mu.Lock()
+	ghost := s.host() + s.offset
+	beat := s.timeline.beats(ghost)
+	s.timeline = timeline{tempo: tempo(bpm), beatOrigin: int64(math.Round(beat * 1e6)), timeOrigin: ghost}
+	s.mu.Unlock()
+	s.send(msgAlive, s.group)
+}
+
+// Beat returns the beat of the session at the given time. All peers agree on it.
+func (s *Session) Beat(at time.Time) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.timeline.beats(at.Sub(s.start).Microseconds() + s.offset)
+}
+
+// Peers returns the number of other peers in the session.
+func (s *Session) Peers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, p := range s.peers {
+		if p.session == s.session {
+			n++
+		}
+	}
+	return n
+}
+
+// send sends the state of the peer in a discovery message.
+func (s *Session) send(kind byte, to *net.UDPAddr) {
+	s.mu.Lock()
+	d := discovery{kind: kind, ttl: ttl, peer: s.id, session: s.session, timeline: s.timeline, endpoint: s.endpoint}
+	s.mu.Unlock()
+	_, _ = s.conn.WriteToUDP(d.encode(), to) // Lost messages are sent again at the next period.
+}
+
+// broadcast advertises the peer and forgets the peers that went silent, until the session is closed.
+func (s *Session) broadcast() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(broadcastPeriod)
+	defer ticker.Stop()
+	for {
+		s.send(msgAlive, s.group)
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for id, p := range s.peers {
+				if now.After(p.expires) {
+					delete(s.peers, id)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// receive handles the messages of a connection until it is closed.
+func (s *Session) receive(conn *net.UDPConn) {
+	defer s.wg.Done()
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+				continue // Like a message too long for the buffer.
+			}
+		}
+		b := slices.Clone(buf[:n])
+		if m, err := decodeMeasurement(b); err == nil {
+			s.handleMeasurement(m, from)
+		} else if d, err := decodeDiscovery(b, from); err == nil && d.peer != s.id {
+			s.handleDiscovery(d, from)
+		}
+	}
+}
+
+func (s *Session) handleMeasurement(m measurement, from *net.UDPAddr) {
+	s.mu.Lock()
+	session, ghost := s.session, s.host()+s.offset
+	ch := s.measuring[from.String()]
+	s.mu.Unlock()
+	switch {
+	case m.kind == msgPing:
+		_, _ = s.conn.WriteToUDP(pong(session, ghost, m), from)
+	case m.kind == msgPong && ch != nil:
+		select {
+		case ch <- m:
+		default: // Late pong of a ping that timed out.
+		}
+	}
+}
+
+func (s *Session) handleDiscovery(d discovery, from *net.UDPAddr) {
+	if d.kind == msgAlive {
+		defer s.send(msgResponse, from) // Once the lock is released.
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d.kind == msgByeBye {
+		delete(s.peers, d.peer)
+		return
+	}
+	if d.endpoint == nil {
+		d.endpoint = from
+	}
+	s.peers[d.peer] = peer{discovery: d, expires: time.Now().Add(time.Duration(d.ttl) * time.Second)}
+
+	if d.session == s.session {
+		// The most recent change of the timeline wins.
+		if d.timeline.timeOrigin > s.timeline.timeOrigin {
+			s.timeline = d.timeline
+		}
+		return
+	}
+	if t, ok := s.measured[d.session]; ok && time.Since(t) < remeasure {
+		return
+	} else if s.measuring[d.endpoint.String()] != nil {
+		return
+	}
+	s.measured[d.session] = time.Now()
+	ch := make(chan measurement, 1)
+	s.measuring[d.endpoint.String()] = ch
+	s.wg.Add(1)
+	go s.measure(d, ch)
+}
+
+// Measurements are repeated until measurePoints offsets are known, pings time out after measureTimeout
+// and the measurement fails after measureRetries pings in a row time out.
+const (
+	measurePoints  = 100
+	measureTimeout = 50 * time.Millisecond
+	measureRetries = 5
+)
+
+// measure estimates the offset between the host clock and the ghost time of the session of a peer,
+// and joins the session if it has been running longer.
+func (s *Session) measure(d discovery, pongs chan measurement) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.measuring, d.endpoint.String())
+		s.mu.Unlock()
+	}()
+
+	var offsets []float64
+	var prevGhost int64
+	timer := time.NewTimer(measureTimeout)
+	defer timer.Stop()
+	for retries := 0; len(offsets) < measurePoints && retries < measureRetries; {
+		_, err := s.conn.WriteToUDP(ping(s.host(), prevGhost), d.endpoint)
+		if err != nil {
+			return
+		}
+		timer.Reset(measureTimeout)
+		select {
+		case <-s.done:
+			return
+		case <-timer.C:
+			retries++
+		case m := <-pongs:
+			host, sent := s.host(), int64Entry(m.entries, "__ht")
+			ghost, prev := int64Entry(m.entries, "__gt"), int64Entry(m.entries, "_pgt")
+			if m.session != d.session || sent == 0 || ghost == 0 {
+				return
+			}
+			// The ghost time is compared to the host time halfway through the round trip,
+			// and the host time of the ping to the ghost time halfway between the pongs around it.
+			offsets = append(offsets, float64(ghost)-float64(host+sent)/2)
+			if prev != 0 {
+				offsets = append(offsets, float64(ghost+prev)/2-float64(sent))
+			}
+			prevGhost, retries = ghost, 0
+		}
+	}
+	if len(offsets) < measurePoints/10 {
+		return
+	}
+	slices.Sort(offsets)
+	offset := int64(math.Round(offsets[len(offsets)/2]))
+
+	// The session that has been running the longest (with the largest ghost time) wins, or the one with the
+	// smallest ID when they started around the same time.
+	s.mu.Lock()
+	diff := offset - s.offset
+	joined := d.session != s.session && (diff > sessionEpsilon || diff > -sessionEpsilon && bytes.Compare(d.session[:], s.session[:]) < 0)
+	if joined {
+		s.session, s.offset, s.timeline = d.session, offset, d.timeline
+		for _, p := range s.peers {
+			if p.session == d.session && p.timeline.timeOrigin > s.timeline.timeOrigin {
+				s.timeline = p.timeline
+			}
+		}
+	}
+	s.mu.Unlock()
+	if joined {
+		s.send(msgAlive, s.group)
+	}
+}
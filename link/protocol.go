@@ -0,0 +1,196 @@
+package link
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// The wire format of Link version 1: discovery messages are multicast to every peer of the network,
+// measurement messages are sent between two peers to estimate the offset between their clocks.
+const (
+	multicastAddr = "224.76.78.75:20808"
+
+	discoveryHeader   = "_asdp_v\x01"
+	measurementHeader = "_link_v\x01"
+
+	msgAlive    = 1
+	msgResponse = 2
+	msgByeBye   = 3
+
+	msgPing = 1
+	msgPong = 2
+)
+
+// nodeID identifies a peer or a session (the ID of the peer that founded it).
+type nodeID [8]byte
+
+// timeline maps the ghost time (the shared clock of a session, in microseconds) to beats.
+type timeline struct {
+	tempo      int64 // Microseconds per beat.
+	beatOrigin int64 // In microbeats.
+	timeOrigin int64 // Ghost time of the beat origin.
+}
+
+// beats returns the beat at a ghost time.
+func (tl timeline) beats(ghost int64) float64 {
+	return float64(tl.beatOrigin)/1e6 + float64(ghost-tl.timeOrigin)/float64(tl.tempo)
+}
+
+// bpm returns the tempo in beats per minute.
+func (tl timeline) bpm() float64 { return 60e6 / float64(tl.tempo) }
+
+func (tl timeline) encode() []byte {
+	b := make([]byte, 24)
+	binary.BigEndian.PutUint64(b[0:], uint64(tl.tempo))
+	binary.BigEndian.PutUint64(b[8:], uint64(tl.beatOrigin))
+	binary.BigEndian.PutUint64(b[16:], uint64(tl.timeOrigin))
+	return b
+}
+
+func decodeTimeline(b []byte) (timeline, bool) {
+	if len(b) != 24 {
+		return timeline{}, false
+	}
+	tl := timeline{
+		tempo:      int64(binary.BigEndian.Uint64(b[0:])),
+		beatOrigin: int64(binary.BigEndian.Uint64(b[8:])),
+		timeOrigin: int64(binary.BigEndian.Uint64(b[16:])),
+	}
+	return tl, tl.tempo > 0
+}
+
+// appendEntry appends a payload entry: a key made of 4 characters, the size of the value and the value.
+func appendEntry(dst []byte, key string, value []byte) []byte {
+	dst = append(dst, key...)
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(value)))
+	return append(dst, value...)
+}
+
+func appendInt64(dst []byte, key string, v int64) []byte {
+	return appendEntry(dst, key, binary.BigEndian.AppendUint64(nil, uint64(v)))
+}
+
+// parseEntries returns the values of a payload by key.
+func parseEntries(b []byte) (map[string][]byte, error) {
+	entries := map[string][]byte{}
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return nil, errors.New("truncated payload entry")
+		}
+		key, size := string(b[:4]), int(binary.BigEndian.Uint32(b[4:8]))
+		if 8+size > len(b) {
+			return nil, errors.New("truncated payload entry")
+		}
+		entries[key] = b[8 : 8+size]
+		b = b[8+size:]
+	}
+	return entries, nil
+}
+
+// int64Entry returns an integer value of a payload, 0 if it is missing.
+func int64Entry(entries map[string][]byte, key string) int64 {
+	if v := entries[key]; len(v) == 8 {
+		return int64(binary.BigEndian.Uint64(v))
+	}
+	return 0
+}
+
+// discovery is a discovery message: the state of a peer.
+type discovery struct {
+	kind     byte
+	ttl      byte // In seconds.
+	peer     nodeID
+	session  nodeID
+	timeline timeline
+	endpoint *net.UDPAddr // For measurements.
+}
+
+func (d discovery) encode() []byte {
+	b := append([]byte(discoveryHeader), d.kind, d.ttl, 0, 0) // Group 0.
+	b = append(b, d.peer[:]...)
+	if d.kind == msgByeBye {
+		return b
+	}
+	b = appendEntry(b, "tmln", d.timeline.encode())
+	b = appendEntry(b, "sess", d.session[:])
+	if ip := d.endpoint.IP.To4(); ip != nil {
+		b = appendEntry(b, "mep4", binary.BigEndian.AppendUint16(append([]byte(nil), ip...), uint16(d.endpoint.Port)))
+	}
+	return b
+}
+
+// decodeDiscovery reads a discovery message sent from the given address.
+func decodeDiscovery(b []byte, from *net.UDPAddr) (discovery, error) {
+	const size = len(discoveryHeader) + 12
+	if len(b) < size || string(b[:len(discoveryHeader)]) != discoveryHeader {
+		return discovery{}, errors.New("not a Link discovery message")
+	}
+	h := b[len(discoveryHeader):]
+	d := discovery{kind: h[0], ttl: h[1]}
+	copy(d.peer[:], h[4:12])
+	if d.kind == msgByeBye {
+		return d, nil
+	}
+	entries, err := parseEntries(b[size:])
+	if err != nil {
+		return discovery{}, err
+	}
+	var ok bool
+	d.timeline, ok = decodeTimeline(entries["tmln"])
+	if !ok || len(entries["sess"]) != 8 {
+		return discovery{}, errors.New("missing peer state")
+	}
+	copy(d.session[:], entries["sess"])
+	if ep := entries["mep4"]; len(ep) == 6 {
+		d.endpoint = &net.UDPAddr{IP: net.IP(append([]byte(nil), ep[:4]...)), Port: int(binary.BigEndian.Uint16(ep[4:]))}
+		if d.endpoint.IP.IsUnspecified() && from != nil {
+			d.endpoint.IP = from.IP
+		}
+	}
+	return d, nil
+}
+
+// measurement is a ping or a pong.
+type measurement struct {
+	kind    byte
+	session nodeID // Of pongs.
+	entries map[string][]byte
+}
+
+func decodeMeasurement(b []byte) (measurement, error) {
+	if len(b) < len(measurementHeader)+1 || string(b[:len(measurementHeader)]) != measurementHeader {
+		return measurement{}, errors.New("not a Link measurement message")
+	}
+	m := measurement{kind: b[len(measurementHeader)]}
+	var err error
+	m.entries, err = parseEntries(b[len(measurementHeader)+1:])
+	if err != nil {
+		return measurement{}, err
+	}
+	copy(m.session[:], m.entries["sess"])
+	return m, nil
+}
+
+// ping returns a ping with the host time when it is sent and the ghost time of the previous pong (0 for the first).
+func ping(host, prevGhost int64) []byte {
+	b := append([]byte(measurementHeader), msgPing)
+	b = appendInt64(b, "__ht", host)
+	if prevGhost != 0 {
+		b = appendInt64(b, "_pgt", prevGhost)
+	}
+	return b
+}
+
+// pong answers a ping with the session and the ghost time when it was received, echoing the payload of the ping.
+func pong(session nodeID, ghost int64, p measurement) []byte {
+	b := append([]byte(measurementHeader), msgPong)
+	b = appendEntry(b, "sess", session[:])
+	b = appendInt64(b, "__gt", ghost)
+	for _, key := range []string{"__ht", "_pgt"} {
+		if v, ok := p.entries[key]; ok {
+			b = appendEntry(b, key, v)
+		}
+	}
+	return b
+}
@@ -0,0 +1,33 @@
+package live
+
+import (
+	"io"
+
+	"github.com/ejuju/poc-go-audio-synthesis/midi"
+	"github.com/ejuju/poc-go-audio-synthesis/param"
+)
+
+// CC maps a MIDI control change to a parameter, the controller value (0 to 127) being scaled between Min and Max.
+type CC struct {
+	Param    *param.Param
+	Min, Max float64
+}
+
+// ListenMIDI plays the instrument from MIDI messages read from r (like a raw MIDI device),
+// and updates the parameters mapped to control changes (by controller number), until r returns an error.
+func ListenMIDI(r io.Reader, p *Poly, ccs map[int]CC) error {
+	return midi.ReadMessages(r, func(m midi.Message) {
+		switch {
+		case m.IsNoteOn():
+			p.NoteOn(int(m.Data1), float64(m.Data2)/127)
+		case m.IsNoteOff():
+			p.NoteOff(int(m.Data1))
+		case m.Kind() == midi.ControlChange && m.Data1 == 123: // All notes off.
+			p.AllNotesOff()
+		case m.Kind() == midi.ControlChange:
+			if cc, ok := ccs[int(m.Data1)]; ok {
+				cc.Param.Set(cc.Min + (cc.Max-cc.Min)*float64(m.Data2)/127)
+			}
+		}
+	})
+}
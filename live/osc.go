@@ -0,0 +1,39 @@
+package live
+
+import (
+	"net"
+
+	"github.com/ejuju/poc-go-audio-synthesis/osc"
+	"github.com/ejuju/poc-go-audio-synthesis/param"
+)
+
+// ListenOSC updates the parameters of the registry from OSC messages received on conn, until conn returns an error.
+// Parameters are addressed by name (like "/filter/cutoff" for "filter/cutoff").
+// The first argument of a message is the new value, messages sent to a pattern (like "/mixer/*/gain")
+// update all matching parameters.
+//
+// If p is not nil, "/note/on pitch velocity" and "/note/off pitch" messages play the instrument.
+func ListenOSC(conn net.PacketConn, p *Poly, params *param.Registry) error {
+	return osc.Listen(conn, func(m osc.Message) {
+		v, ok := m.Float(0)
+		if !ok {
+			return
+		}
+		switch {
+		case p != nil && m.Address == "/note/on":
+			velocity, ok := m.Float(1)
+			if !ok {
+				velocity = 1
+			}
+			p.NoteOn(int(v), velocity)
+		case p != nil && m.Address == "/note/off":
+			p.NoteOff(int(v))
+		default:
+			for _, name := range params.Names() {
+				if osc.Match(m.Address, "/"+name) {
+					params.Set(name, v)
+				}
+			}
+		}
+	})
+}
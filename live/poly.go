@@ -0,0 +1,124 @@
+// Package live plays the synthesizer in real time from incoming note and controller events.
+package live
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/param"
+	"github.com/ejuju/poc-go-audio-synthesis/seq"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Poly is a polyphonic instrument played by note events, which may come from any goroutine
+// while its output is being played.
+type Poly struct {
+	Tuning seq.Tuning // Nil means seq.TwelveTone, it should be set before playing.
+
+	mu     sync.Mutex
+	voices []*voice
+	clock  int // Incremented on each note, to find the oldest voices.
+	output synth.Signal
+}
+
+type voice struct {
+	pitch    int // MIDI note number, -1 if the voice is free.
+	started  int // Value of the clock when the note started.
+	freq     *param.Param
+	velocity *param.Param
+	gate     atomic.Int64 // Positive while open, each new note gets a new value so envelopes restart.
+}
+
+// NewPoly returns an instrument of n voices built with the given function.
+// When all voices are busy, the oldest note is stolen.
+func NewPoly(n int, fn seq.VoiceFunc) *Poly {
+	p := &Poly{}
+	var outputs []synth.Signal
+	for i := 0; i < max(1, n); i++ {
+		v := &voice{pitch: -1, freq: param.New(440), velocity: param.New(0)}
+		p.voices = append(p.voices, v)
+		outputs = append(outputs, synth.Mul(v.velocity.Signal(), fn(v.freq.Signal(), v.gateSignal())))
+	}
+	p.output = synth.Add(outputs...)
+	return p
+}
+
+// gateSignal returns the gate of the voice, which closes for one sample when a new note restarts a busy voice.
+func (v *voice) gateSignal() synth.Signal {
+	return synth.Stateful(func() func(x time.Duration, dt float64) float64 {
+		var last int64
+		return func(x time.Duration, dt float64) float64 {
+			g := v.gate.Load()
+			retriggered := g > 0 && last > 0 && g != last
+			last = g
+			if g <= 0 || retriggered {
+				return 0
+			}
+			return 1
+		}
+	})
+}
+
+// Signal returns the sum of all voices.
+func (p *Poly) Signal() synth.Signal { return p.output }
+
+// NoteOn starts a note (MIDI note number) with the given velocity (between 0 and 1).
+func (p *Poly) NoteOn(pitch int, velocity float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clock++
+	v := p.pick(pitch)
+	v.pitch, v.started = pitch, p.clock
+	tuning := p.Tuning
+	if tuning == nil {
+		tuning = seq.TwelveTone
+	}
+	v.freq.Set(tuning.Freq(float64(pitch)))
+	v.velocity.Set(velocity)
+	v.gate.Store(int64(p.clock))
+}
+
+// NoteOff releases a note.
+func (p *Poly) NoteOff(pitch int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, v := range p.voices {
+		if v.pitch == pitch {
+			v.pitch = -1
+			v.gate.Store(-v.gate.Load())
+		}
+	}
+}
+
+// AllNotesOff releases all notes.
+func (p *Poly) AllNotesOff() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, v := range p.voices {
+		if v.pitch >= 0 {
+			v.pitch = -1
+			v.gate.Store(-v.gate.Load())
+		}
+	}
+}
+
+// pick returns the voice playing the same pitch, or the free voice released the longest ago,
+// or the oldest note.
+func (p *Poly) pick(pitch int) *voice {
+	var free, oldest *voice
+	for _, v := range p.voices {
+		switch {
+		case v.pitch == pitch:
+			return v
+		case v.pitch < 0 && (free == nil || v.started < free.started):
+			free = v
+		case v.pitch >= 0 && (oldest == nil || v.started < oldest.started):
+			oldest = v
+		}
+	}
+	if free != nil {
+		return free
+	}
+	return oldest
+}
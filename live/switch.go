@@ -0,0 +1,43 @@
+package live
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Switch plays a signal that can be replaced from another goroutine while playing (like a patch being edited),
+// crossfading from the old signal to the new one so the audio never stops or clicks.
+type Switch struct {
+	fade    time.Duration
+	pending atomic.Pointer[synth.Signal] // Set by Set, picked up by the output.
+
+	// Only used by the output.
+	prev, cur synth.Signal
+	at        time.Duration // Time of the last switch.
+}
+
+// NewSwitch returns a switch playing s, fading over the given duration when the signal is replaced.
+func NewSwitch(s synth.Signal, fade time.Duration) *Switch {
+	return &Switch{fade: fade, cur: s}
+}
+
+// Set replaces the signal, which starts from its time 0 at the next sample played.
+func (sw *Switch) Set(s synth.Signal) { sw.pending.Store(&s) }
+
+// Signal returns the output of the switch, which must be played by a single goroutine.
+func (sw *Switch) Signal() synth.Signal {
+	return func(x time.Duration) float64 {
+		if s := sw.pending.Swap(nil); s != nil {
+			sw.prev, sw.cur, sw.at = sw.cur, synth.Shift(*s, x), x
+		}
+		if sw.prev == nil || x >= sw.at+sw.fade {
+			sw.prev = nil // Done fading.
+			return sw.cur(x)
+		}
+		t := float64(x-sw.at) / float64(sw.fade) * math.Pi / 2
+		return math.Cos(t)*sw.prev(x) + math.Sin(t)*sw.cur(x)
+	}
+}
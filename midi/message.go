@@ -0,0 +1,51 @@
+// Package midi reads and writes Standard MIDI Files, converting them from and to note events of the seq package,
+// and reads and sends live MIDI messages.
+package midi
+
+// Channel message kinds (the high nibble of the status byte).
+const (
+	NoteOff         = 0x80
+	NoteOn          = 0x90
+	PolyAftertouch  = 0xA0
+	ControlChange   = 0xB0
+	ProgramChange   = 0xC0
+	ChannelPressure = 0xD0
+	PitchBend       = 0xE0
+)
+
+// Message is a MIDI channel message.
+type Message struct {
+	Status       byte // Kind and channel.
+	Data1, Data2 byte
+}
+
+// Kind returns the kind of message (NoteOn, ControlChange, etc.).
+func (m Message) Kind() byte { return m.Status & 0xF0 }
+
+// Channel returns the channel of the message (between 0 and 15).
+func (m Message) Channel() int { return int(m.Status & 0x0F) }
+
+// IsNoteOn reports whether the message starts a note (a note-on with a velocity of 0 is a note-off).
+func (m Message) IsNoteOn() bool { return m.Kind() == NoteOn && m.Data2 > 0 }
+
+// IsNoteOff reports whether the message ends a note.
+func (m Message) IsNoteOff() bool {
+	return m.Kind() == NoteOff || (m.Kind() == NoteOn && m.Data2 == 0)
+}
+
+// Bend returns the pitch bend value between -1 and 1 (for PitchBend messages).
+func (m Message) Bend() float64 {
+	v := int(m.Data1) | int(m.Data2)<<7
+	return float64(v-8192) / 8192
+}
+
+// dataLen returns the number of data bytes following a channel message status, or -1 for non-channel messages.
+func dataLen(status byte) int {
+	switch status & 0xF0 {
+	case NoteOff, NoteOn, PolyAftertouch, ControlChange, PitchBend:
+		return 2
+	case ProgramChange, ChannelPressure:
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,105 @@
+package midi
+
+import (
+	"slices"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/seq"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Render plays every note of the song with its own instance of the voice, scaled by the note velocity.
+// Pitch bends are applied to the frequency of the notes on the same channel.
+//
+// The tail is how long a voice keeps sounding after its note ends (for example the release of its envelope),
+// voices are only evaluated while they can be heard so long songs stay cheap to render.
+func Render(song *Song, voice seq.VoiceFunc, tail time.Duration) synth.Signal {
+	return RenderTuned(song, voice, tail, seq.TwelveTone)
+}
+
+// RenderTuned is like Render with another tuning for the MIDI note numbers.
+func RenderTuned(song *Song, voice seq.VoiceFunc, tail time.Duration, tuning seq.Tuning) synth.Signal {
+	return render(song, func(Program, float64) seq.VoiceFunc { return voice }, tail, tuning)
+}
+
+// Instruments returns the voice playing a note, from the program of its channel when it starts and its velocity
+// (between 0 and 1), like the sounds of a SoundFont.
+type Instruments func(program Program, velocity float64) seq.VoiceFunc
+
+// RenderInstruments is like Render, with the voice of each note chosen by instruments.
+// Channels play program 0 of bank 0 until their first program change.
+func RenderInstruments(song *Song, instruments Instruments, tail time.Duration) synth.Signal {
+	return render(song, instruments, tail, seq.TwelveTone)
+}
+
+// program returns the program of a channel at x.
+func (song *Song) program(channel int, x time.Duration) Program {
+	p := Program{Channel: channel}
+	for _, c := range song.Programs {
+		if c.Time > x {
+			break
+		} else if c.Channel == channel {
+			p = c
+		}
+	}
+	return p
+}
+
+func render(song *Song, instruments Instruments, tail time.Duration, tuning seq.Tuning) synth.Signal {
+	bends := map[int][]Bend{}
+	for _, b := range song.Bends {
+		bends[b.Channel] = append(bends[b.Channel], b)
+	}
+	bend := func(channel int, x time.Duration) float64 {
+		bs := bends[channel]
+		i, _ := slices.BinarySearchFunc(bs, x, func(b Bend, x time.Duration) int {
+			if b.Time <= x {
+				return -1
+			}
+			return 1
+		})
+		if i == 0 {
+			return 0
+		}
+		return bs[i-1].Semitones
+	}
+
+	type playing struct {
+		start, end time.Duration // Time range where the voice can be heard.
+		signal     synth.Signal
+	}
+	voices := make([]playing, len(song.Notes))
+	var longest time.Duration
+	for i, n := range song.Notes {
+		start := time.Duration(n.Start * float64(time.Second))
+		length := time.Duration(n.Duration * float64(time.Second))
+		freq := func(x time.Duration) float64 { return tuning.Freq(n.Pitch + bend(n.Channel, x)) }
+		voice := instruments(song.program(n.Channel, start), n.Velocity)
+		v := voice(freq, synth.Gate(start, length))
+		voices[i] = playing{
+			start:  start,
+			end:    start + length + tail,
+			signal: synth.Mul(synth.Constant(n.Velocity), v),
+		}
+		longest = max(longest, length+tail)
+	}
+
+	// Notes are sorted by start, so the voices that can be heard at x started between x-longest and x.
+	search := func(x time.Duration) int {
+		i, _ := slices.BinarySearchFunc(voices, x, func(v playing, x time.Duration) int {
+			if v.start <= x {
+				return -1
+			}
+			return 1
+		})
+		return i
+	}
+	return func(x time.Duration) (y float64) {
+		for _, v := range voices[search(x-longest):search(x)] {
+			if x < v.end {
+				y += v.signal(x)
+			}
+		}
+		return y
+	}
+}
@@ -0,0 +1,267 @@
+package midi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/seq"
+)
+
+// DefaultBendRange is the pitch bend range (in semitones) assumed when reading files.
+const DefaultBendRange = 2
+
+// Song is the content of a MIDI file, converted to the seq package's note model.
+//
+// Since MIDI files may change tempo along the way, notes are timed in seconds:
+// one beat is one second, so they must be sequenced at 60 BPM.
+type Song struct {
+	Notes    []seq.Note
+	Bends    []Bend
+	Programs []Program
+	Duration time.Duration // Time of the last event.
+}
+
+// Program is a program change on a channel (the instrument of the channel, like a General MIDI sound).
+type Program struct {
+	Time    time.Duration
+	Channel int
+	Program int
+	Bank    int // From the bank select MSB (controller 0) before it, the LSB (controller 32) is ignored like in SoundFont players.
+}
+
+// Bend is a pitch bend change on a channel.
+type Bend struct {
+	Time      time.Duration
+	Channel   int
+	Semitones float64
+}
+
+// event is a channel message or a tempo change, at the given tick.
+type event struct {
+	tick  int
+	track int
+	msg   Message
+	tempo int // Microseconds per quarter note, for tempo changes.
+}
+
+// Load reads a Standard MIDI File from disk.
+func Load(path string) (*Song, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(bufio.NewReader(f))
+}
+
+// Parse reads a Standard MIDI File (format 0 or 1).
+func Parse(r io.Reader) (*Song, error) {
+	id, header, err := readChunk(r)
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	} else if id != "MThd" || len(header) < 6 {
+		return nil, errors.New("not a MIDI file")
+	}
+	format := binary.BigEndian.Uint16(header[0:2])
+	ntracks := int(binary.BigEndian.Uint16(header[2:4]))
+	division := binary.BigEndian.Uint16(header[4:6])
+	if format > 1 {
+		return nil, fmt.Errorf("unsupported MIDI file format: %d", format)
+	} else if division == 0 || division&0x8000 != 0 && division&0xFF == 0 { // No ticks per beat or per SMPTE frame.
+		return nil, fmt.Errorf("invalid time division: %#x", division)
+	}
+
+	var events []event
+	for track := 0; track < ntracks; {
+		id, data, err := readChunk(r)
+		if err != nil {
+			return nil, fmt.Errorf("read track %d: %w", track, err)
+		} else if id != "MTrk" {
+			continue // Unknown chunks must be ignored.
+		}
+		trackEvents, err := parseTrack(data, track)
+		if err != nil {
+			return nil, fmt.Errorf("parse track %d: %w", track, err)
+		}
+		events = append(events, trackEvents...)
+		track++
+	}
+	slices.SortStableFunc(events, func(a, b event) int { return a.tick - b.tick })
+	return newSong(events, division), nil
+}
+
+func readChunk(r io.Reader) (id string, data []byte, err error) {
+	var header [8]byte
+	_, err = io.ReadFull(r, header[:])
+	if err != nil {
+		return "", nil, err
+	}
+	// The chunk is read as it comes rather than allocated from its length, which may be corrupt.
+	n := int64(binary.BigEndian.Uint32(header[4:]))
+	var buf bytes.Buffer
+	_, err = io.CopyN(&buf, r, n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return string(header[:4]), buf.Bytes(), err
+}
+
+func parseTrack(data []byte, track int) (events []event, err error) {
+	r := bytes.NewReader(data)
+	tick := 0
+	var status byte // For running status.
+	for r.Len() > 0 {
+		delta, err := readVarLen(r)
+		if err != nil {
+			return nil, err
+		}
+		tick += delta
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case b == 0xFF: // Meta event.
+			kind, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			payload, err := readPayload(r)
+			if err != nil {
+				return nil, err
+			}
+			if kind == 0x51 && len(payload) == 3 {
+				tempo := int(payload[0])<<16 | int(payload[1])<<8 | int(payload[2])
+				events = append(events, event{tick: tick, track: track, tempo: tempo})
+			} else if kind == 0x2F {
+				return events, nil // End of track.
+			}
+		case b == 0xF0 || b == 0xF7: // System exclusive.
+			_, err = readPayload(r)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			var data1 byte
+			if b&0x80 != 0 {
+				status = b
+				data1, err = r.ReadByte()
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				data1 = b // Running status: b is the first data byte.
+			}
+			n := dataLen(status)
+			if n < 0 {
+				return nil, fmt.Errorf("unexpected status byte: %#x", status)
+			}
+			msg := Message{Status: status, Data1: data1}
+			if n == 2 {
+				msg.Data2, err = r.ReadByte()
+				if err != nil {
+					return nil, err
+				}
+			}
+			events = append(events, event{tick: tick, track: track, msg: msg})
+		}
+	}
+	return events, nil
+}
+
+func readPayload(r *bytes.Reader) ([]byte, error) {
+	n, err := readVarLen(r)
+	if err != nil {
+		return nil, err
+	} else if n > r.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	payload := make([]byte, n)
+	_, err = io.ReadFull(r, payload)
+	return payload, err
+}
+
+// readVarLen reads a variable-length quantity (7 bits per byte, most significant first).
+func readVarLen(r io.ByteReader) (v int, err error) {
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<7 | int(b&0x7F)
+		if b&0x80 == 0 {
+			return v, nil
+		}
+	}
+	return 0, errors.New("variable-length quantity too long")
+}
+
+// newSong converts events sorted by tick into notes and pitch bends.
+func newSong(events []event, division uint16) *Song {
+	// Convert ticks to time following tempo changes.
+	tempo := 500000 // 120 BPM until told otherwise.
+	ticksDuration := func(ticks int) time.Duration {
+		if division&0x8000 != 0 { // SMPTE: frames per second and ticks per frame.
+			fps := -int(int8(division >> 8))
+			return time.Duration(ticks) * time.Second / time.Duration(fps*int(division&0xFF))
+		}
+		return time.Duration(ticks*tempo) * time.Microsecond / time.Duration(division)
+	}
+	var last int
+	var now time.Duration
+
+	song := &Song{}
+	type key struct{ channel, pitch int }
+	held := map[key]seq.Note{}
+	banks := map[int]int{}
+	end := func(k key) {
+		n, ok := held[k]
+		if !ok {
+			return
+		}
+		n.Duration = now.Seconds() - n.Start
+		song.Notes = append(song.Notes, n)
+		delete(held, k)
+	}
+	for _, e := range events {
+		now += ticksDuration(e.tick - last)
+		last = e.tick
+		if e.tempo > 0 {
+			tempo = e.tempo
+			continue
+		}
+		k := key{e.msg.Channel(), int(e.msg.Data1)}
+		switch {
+		case e.msg.IsNoteOn():
+			end(k) // Retriggering a held note ends it.
+			held[k] = seq.Note{
+				Start:    now.Seconds(),
+				Pitch:    float64(e.msg.Data1),
+				Velocity: float64(e.msg.Data2) / 127,
+				Channel:  e.msg.Channel(),
+			}
+		case e.msg.IsNoteOff():
+			end(k)
+		case e.msg.Kind() == ControlChange && e.msg.Data1 == 0:
+			banks[e.msg.Channel()] = int(e.msg.Data2)
+		case e.msg.Kind() == ProgramChange:
+			song.Programs = append(song.Programs, Program{Time: now, Channel: e.msg.Channel(), Program: int(e.msg.Data1), Bank: banks[e.msg.Channel()]})
+		case e.msg.Kind() == PitchBend:
+			song.Bends = append(song.Bends, Bend{Time: now, Channel: e.msg.Channel(), Semitones: e.msg.Bend() * DefaultBendRange})
+		}
+	}
+	for k := range held {
+		end(k) // Notes still held end with the song.
+	}
+	seq.SortNotes(song.Notes)
+	song.Duration = now
+	return song
+}
@@ -0,0 +1,53 @@
+package midi
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// ReadMessages reads a live stream of MIDI bytes (like a raw MIDI device such as /dev/snd/midiC1D0)
+// and calls fn with each channel message, until r returns an error.
+// System messages (clock, sysex, etc.) are skipped.
+func ReadMessages(r io.Reader, fn func(Message)) error {
+	br := bufio.NewReader(r)
+	var status byte
+	var data []byte
+	inSysex := false
+	for {
+		b, err := br.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch {
+		case b >= 0xF8: // Real-time messages can appear anywhere, even between data bytes.
+			continue
+		case b == 0xF0:
+			inSysex = true
+			continue
+		case b == 0xF7:
+			inSysex = false
+			continue
+		case b >= 0xF0: // Other system common messages cancel the running status.
+			status, data = 0, data[:0]
+			continue
+		case b&0x80 != 0:
+			inSysex = false
+			status, data = b, data[:0]
+			continue
+		case inSysex || status == 0:
+			continue
+		}
+		data = append(data, b)
+		if len(data) == dataLen(status) {
+			msg := Message{Status: status, Data1: data[0]}
+			if len(data) == 2 {
+				msg.Data2 = data[1]
+			}
+			fn(msg)
+			data = data[:0] // Keep the running status.
+		}
+	}
+}
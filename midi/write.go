@@ -0,0 +1,197 @@
+package midi
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/seq"
+)
+
+// Append appends the bytes of the message to dst.
+func (m Message) Append(dst []byte) []byte {
+	switch dataLen(m.Status) {
+	case 1:
+		return append(dst, m.Status, m.Data1)
+	case 2:
+		return append(dst, m.Status, m.Data1, m.Data2)
+	}
+	return append(dst, m.Status)
+}
+
+// Event is a message sent at a given time.
+type Event struct {
+	Time    time.Duration
+	Message Message
+}
+
+// beatEvent is a message at a given beat.
+type beatEvent struct {
+	beat float64
+	msg  Message
+}
+
+// noteEvents returns the note-on and note-off messages of notes (from the sequencer, an Arpeggiator or a Pattern),
+// sorted by beat with note-offs first so repeated notes on the same pitch aren't cut.
+// Notes without a duration are silent and skipped (their note-off would come first and leave them held).
+// Pitches are rounded to the closest MIDI note and channels are wrapped to the 16 MIDI channels.
+func noteEvents(notes []seq.Note) []beatEvent {
+	events := make([]beatEvent, 0, 2*len(notes))
+	for _, n := range notes {
+		if n.Duration <= 0 {
+			continue
+		}
+		channel := byte(n.Channel & 0x0F)
+		pitch := byte(max(0, min(int(math.Round(n.Pitch)), 127)))
+		velocity := byte(max(1, min(int(math.Round(n.Velocity*127)), 127))) // 0 would be a note-off.
+		events = append(events,
+			beatEvent{n.Start, Message{Status: NoteOn | channel, Data1: pitch, Data2: velocity}},
+			beatEvent{n.End(), Message{Status: NoteOff | channel, Data1: pitch}},
+		)
+	}
+	slices.SortStableFunc(events, func(a, b beatEvent) int {
+		if c := cmp.Compare(a.beat, b.beat); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.msg.Kind(), b.msg.Kind()) // NoteOff < NoteOn.
+	})
+	return events
+}
+
+// Events returns the MIDI messages playing notes at the given tempo, sorted by time.
+// Pitches are rounded to the closest MIDI note number and the channels of the notes are used as MIDI channels.
+func Events(notes []seq.Note, bpm float64) []Event {
+	events := make([]Event, 0, 2*len(notes))
+	for _, e := range noteEvents(notes) {
+		events = append(events, Event{Time: seq.BeatsToDuration(e.beat, bpm), Message: e.msg})
+	}
+	return events
+}
+
+// ticksPerBeat is the resolution of written MIDI files.
+const ticksPerBeat = 480
+
+// WriteSMF writes notes as a Standard MIDI File (format 0) at the given tempo,
+// so patterns made with the seq package can be played by other synthesizers or imported in a DAW.
+// Pitches are rounded to the closest MIDI note number and the channels of the notes are used as MIDI channels.
+func WriteSMF(w io.Writer, notes []seq.Note, bpm float64) error {
+	var track []byte
+	tempo := int(math.Round(60e6 / bpm)) // Microseconds per beat.
+	track = append(track, 0, 0xFF, 0x51, 3, byte(tempo>>16), byte(tempo>>8), byte(tempo))
+	last := 0
+	var status byte
+	for _, e := range noteEvents(notes) {
+		tick := int(math.Round(e.beat * ticksPerBeat))
+		track = appendVarLen(track, tick-last)
+		last = tick
+		b := e.msg.Append(nil)
+		if b[0] == status {
+			b = b[1:] // Running status.
+		}
+		status = e.msg.Status
+		track = append(track, b...)
+	}
+	track = append(track, 0, 0xFF, 0x2F, 0) // End of track.
+
+	var header [14]byte
+	copy(header[:], "MThd")
+	binary.BigEndian.PutUint32(header[4:], 6)
+	binary.BigEndian.PutUint16(header[8:], 0)  // Format.
+	binary.BigEndian.PutUint16(header[10:], 1) // Tracks.
+	binary.BigEndian.PutUint16(header[12:], ticksPerBeat)
+	var chunk [8]byte
+	copy(chunk[:], "MTrk")
+	binary.BigEndian.PutUint32(chunk[4:], uint32(len(track)))
+	for _, b := range [][]byte{header[:], chunk[:], track} {
+		_, err := w.Write(b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveSMF writes notes to a Standard MIDI File on disk (see WriteSMF).
+func SaveSMF(path string, notes []seq.Note, bpm float64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = WriteSMF(w, notes, bpm)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// appendVarLen appends a variable-length quantity (7 bits per byte, most significant first).
+func appendVarLen(dst []byte, v int) []byte {
+	var buf [4]byte
+	i := len(buf) - 1
+	buf[i] = byte(v & 0x7F)
+	for v >>= 7; v > 0 && i > 0; v >>= 7 {
+		i--
+		buf[i] = byte(v&0x7F) | 0x80
+	}
+	return append(dst, buf[i:]...)
+}
+
+// Send writes the events to a live MIDI output (like a raw MIDI device such as /dev/snd/midiC1D0, or a virtual port)
+// in real time, from now, to drive an external synthesizer. Events must be sorted by time.
+// When the context is canceled, it stops with an "all notes off" on the channels it used and returns the error
+// of the context.
+func Send(ctx context.Context, w io.Writer, events []Event) error {
+	start := time.Now()
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	var used [16]bool
+	var buf []byte
+	for i := 0; i < len(events); {
+		if wait := time.Until(start.Add(events[i].Time)); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				return notesOff(w, used, ctx.Err())
+			case <-timer.C:
+			}
+		} else if ctx.Err() != nil {
+			return notesOff(w, used, ctx.Err())
+		}
+
+		// Events due at the same time are written at once.
+		buf = buf[:0]
+		for due := start.Add(events[i].Time); i < len(events) && !start.Add(events[i].Time).After(due); i++ {
+			buf = events[i].Message.Append(buf)
+			used[events[i].Message.Channel()] = true
+		}
+		_, err := w.Write(buf)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notesOff sends "all notes off" (controller 123) on the used channels, and returns err.
+func notesOff(w io.Writer, used [16]bool, err error) error {
+	var buf []byte
+	for channel, ok := range used {
+		if ok {
+			buf = Message{Status: ControlChange | byte(channel), Data1: 123}.Append(buf)
+		}
+	}
+	if len(buf) > 0 {
+		_, _ = w.Write(buf) // The error of the context matters more.
+	}
+	return err
+}
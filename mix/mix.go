@@ -0,0 +1,192 @@
+// Package mix mixes signals like a mixing desk: named channels with gain, pan, mute and solo,
+// auxiliary sends to shared effects (like a reverb bus) and a master bus with a limiter.
+package mix
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// muteFade is the duration of the fades when a channel is muted or unmuted, so it doesn't click.
+const muteFade = 5 * time.Millisecond
+
+// Mixer mixes mono channels into a stereo output.
+//
+// Channels and returns must be added before building the output,
+// but they can be muted and soloed from any goroutine while it plays.
+type Mixer struct {
+	Gain    synth.Signal // Master gain, in decibels (0 if nil).
+	Ceiling float64      // Of the master limiter (linked between the sides), in decibels.
+
+	channels []*Channel
+	returns  []*Return
+}
+
+// New returns an empty mixer, with a master limiter at -0.3 dB.
+func New() *Mixer {
+	return &Mixer{Ceiling: -0.3}
+}
+
+// Channel is a mono input of a mixer.
+type Channel struct {
+	Name string
+	In   synth.Signal
+	Gain synth.Signal // In decibels (0 if nil).
+	Pan  synth.Signal // From -1 (left) to 1 (right), centered if nil.
+
+	sends      []send
+	mute, solo atomic.Bool
+}
+
+type send struct {
+	to    *Return
+	level synth.Signal
+}
+
+// Return is an effect shared by channels (like a reverb), fed by their sends.
+// The effect processes each side of the stereo send bus, and only its output goes to the master bus.
+type Return struct {
+	Name   string
+	Effect func(in synth.Signal) synth.Signal // The send bus is returned as is if nil.
+	Gain   synth.Signal                       // In decibels (0 if nil).
+}
+
+// Add adds a channel playing the input, centered at 0 dB.
+func (m *Mixer) Add(name string, in synth.Signal) *Channel {
+	c := &Channel{Name: name, In: in}
+	m.channels = append(m.channels, c)
+	return c
+}
+
+// AddReturn adds an effect return, fed by the sends of channels.
+func (m *Mixer) AddReturn(name string, effect func(in synth.Signal) synth.Signal) *Return {
+	r := &Return{Name: name, Effect: effect}
+	m.returns = append(m.returns, r)
+	return r
+}
+
+// Channel returns the channel with the given name, nil if there is none.
+func (m *Mixer) Channel(name string) *Channel {
+	for _, c := range m.channels {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Send sends the channel to a return, after its gain and pan (post-fader),
+// at the given level (from 0 to 1).
+func (c *Channel) Send(to *Return, level synth.Signal) {
+	c.sends = append(c.sends, send{to: to, level: level})
+}
+
+// SetMute mutes or unmutes the channel (and its sends).
+func (c *Channel) SetMute(muted bool) { c.mute.Store(muted) }
+
+// SetSolo solos the channel: while any channel is soloed, the others are muted.
+func (c *Channel) SetSolo(soloed bool) { c.solo.Store(soloed) }
+
+// Muted reports whether the channel is muted.
+func (c *Channel) Muted() bool { return c.mute.Load() }
+
+// Soloed reports whether the channel is soloed.
+func (c *Channel) Soloed() bool { return c.solo.Load() }
+
+// Output returns the stereo mix of the channels and returns, through the master gain and limiter.
+// Each frame is computed once for both sides, so the output must be played by a single goroutine.
+func (m *Mixer) Output() synth.MultiSignal {
+	orZero := func(s synth.Signal) synth.Signal {
+		if s == nil {
+			return synth.Constant(0)
+		}
+		return s
+	}
+
+	// The send buses are read by the effects of the returns, once the channels of the frame are mixed.
+	buses := make([][2]float64, len(m.returns))
+	index := make(map[*Return]int, len(m.returns))
+	returns := make([][2]synth.Signal, len(m.returns))
+	for i, r := range m.returns {
+		index[r] = i
+		for side := range returns[i] {
+			var bus synth.Signal = func(time.Duration) float64 { return buses[i][side] }
+			if r.Effect != nil {
+				bus = r.Effect(bus)
+			}
+			returns[i][side] = bus
+		}
+	}
+
+	levels := make([]float64, len(m.channels)) // Mute fades.
+	var last time.Duration
+	var frame [2]float64
+	var began bool
+	mixFrame := func(x time.Duration) [2]float64 {
+		if began && x == last {
+			return frame
+		}
+		dt := 0.0
+		if began {
+			dt = (x - last).Seconds()
+		}
+		began, last = true, x
+
+		soloing := false
+		for _, c := range m.channels {
+			soloing = soloing || c.Soloed()
+		}
+		for i := range buses {
+			buses[i] = [2]float64{}
+		}
+		var out [2]float64
+		for i, c := range m.channels {
+			target := 1.0
+			if c.Muted() || soloing && !c.Soloed() {
+				target = 0
+			}
+			step := muteFade.Seconds()
+			if dt <= 0 || dt > step {
+				levels[i] = target
+			} else {
+				levels[i] += math.Max(-dt/step, math.Min(target-levels[i], dt/step))
+			}
+			v := c.In(x) // Even when muted, so stateful signals keep up.
+			v *= levels[i] * synth.DBToAmp(orZero(c.Gain)(x))
+			left, right := pan(orZero(c.Pan)(x))
+			side := [2]float64{v * left, v * right}
+			out[0], out[1] = out[0]+side[0], out[1]+side[1]
+			for _, s := range c.sends {
+				i, ok := index[s.to]
+				if !ok {
+					continue // Return of another mixer.
+				}
+				level := s.level(x)
+				buses[i][0] += level * side[0]
+				buses[i][1] += level * side[1]
+			}
+		}
+		for i, r := range m.returns {
+			gain := synth.DBToAmp(orZero(r.Gain)(x))
+			out[0] += gain * returns[i][0](x)
+			out[1] += gain * returns[i][1](x)
+		}
+		master := synth.DBToAmp(orZero(m.Gain)(x))
+		frame = [2]float64{out[0] * master, out[1] * master}
+		return frame
+	}
+	// The limiter is linked, so a peak on one side lowers both and the stereo image stays put.
+	return synth.LimitLinked(synth.Stereo(
+		func(x time.Duration) float64 { return mixFrame(x)[0] },
+		func(x time.Duration) float64 { return mixFrame(x)[1] },
+	), m.Ceiling, 0, 50*time.Millisecond)
+}
+
+// pan returns the gains of the sides at a position, with equal-power panning like synth.Pan.
+func pan(pos float64) (left, right float64) {
+	angle := (math.Max(-1, math.Min(pos, 1)) + 1) * math.Pi / 4
+	return math.Cos(angle), math.Sin(angle)
+}
@@ -0,0 +1,73 @@
+package osc
+
+import "strings"
+
+// Match reports whether an address matches an OSC address pattern, in which:
+//   - "?" matches any character but "/",
+//   - "*" matches any sequence of characters without "/",
+//   - "[abc]" and "[a-z]" match one of the characters ("[!abc]" any other one),
+//   - "{foo,bar}" matches one of the strings.
+//
+// Messages sent to a pattern (like "/mixer/*/gain") are meant for all matching addresses.
+func Match(pattern, address string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			rest := strings.TrimLeft(pattern, "*")
+			for i := 0; ; i++ {
+				if Match(rest, address[i:]) {
+					return true
+				} else if i == len(address) || address[i] == '/' {
+					return false
+				}
+			}
+		case '?':
+			if len(address) == 0 || address[0] == '/' {
+				return false
+			}
+			pattern, address = pattern[1:], address[1:]
+		case '[':
+			end := strings.IndexByte(pattern, ']')
+			if end < 0 || len(address) == 0 || !matchClass(pattern[1:end], address[0]) {
+				return false
+			}
+			pattern, address = pattern[end+1:], address[1:]
+		case '{':
+			end := strings.IndexByte(pattern, '}')
+			if end < 0 {
+				return false
+			}
+			for _, choice := range strings.Split(pattern[1:end], ",") {
+				if strings.HasPrefix(address, choice) && Match(pattern[end+1:], address[len(choice):]) {
+					return true
+				}
+			}
+			return false
+		default:
+			if len(address) == 0 || pattern[0] != address[0] {
+				return false
+			}
+			pattern, address = pattern[1:], address[1:]
+		}
+	}
+	return len(address) == 0
+}
+
+// matchClass reports whether c is in the character class (the inside of brackets).
+func matchClass(class string, c byte) bool {
+	negate := strings.HasPrefix(class, "!")
+	if negate {
+		class = class[1:]
+	}
+	for i := 0; i < len(class); i++ {
+		if i+2 < len(class) && class[i+1] == '-' {
+			if class[i] <= c && c <= class[i+2] {
+				return !negate
+			}
+			i += 2
+		} else if class[i] == c {
+			return !negate
+		}
+	}
+	return negate
+}
@@ -0,0 +1,199 @@
+// Package osc reads and writes Open Sound Control messages,
+// the protocol used by controllers like TouchOSC and environments like SuperCollider or Max.
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+)
+
+// Message is an OSC message: an address (like "/filter/cutoff") and arguments.
+//
+// Arguments are int32, int64, float32, float64, string, []byte (blobs), bool or nil values.
+type Message struct {
+	Address string
+	Args    []any
+}
+
+// Float returns the i-th argument as a float64, if it is a number (or a boolean).
+func (m Message) Float(i int) (v float64, ok bool) {
+	if i >= len(m.Args) {
+		return 0, false
+	}
+	switch arg := m.Args[i].(type) {
+	case int32:
+		return float64(arg), true
+	case int64:
+		return float64(arg), true
+	case float32:
+		return float64(arg), true
+	case float64:
+		return arg, true
+	case bool:
+		if arg {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// MarshalBinary encodes the message as an OSC packet.
+func (m Message) MarshalBinary() ([]byte, error) {
+	tags := []byte{','}
+	var args []byte
+	for _, arg := range m.Args {
+		switch arg := arg.(type) {
+		case int32:
+			tags = append(tags, 'i')
+			args = binary.BigEndian.AppendUint32(args, uint32(arg))
+		case int64:
+			tags = append(tags, 'h')
+			args = binary.BigEndian.AppendUint64(args, uint64(arg))
+		case float32:
+			tags = append(tags, 'f')
+			args = binary.BigEndian.AppendUint32(args, math.Float32bits(arg))
+		case float64:
+			tags = append(tags, 'd')
+			args = binary.BigEndian.AppendUint64(args, math.Float64bits(arg))
+		case string:
+			tags = append(tags, 's')
+			args = appendString(args, arg)
+		case []byte:
+			tags = append(tags, 'b')
+			args = binary.BigEndian.AppendUint32(args, uint32(len(arg)))
+			args = append(args, arg...)
+			args = append(args, make([]byte, pad(len(arg)))...)
+		case bool:
+			tags = append(tags, map[bool]byte{true: 'T', false: 'F'}[arg])
+		case nil:
+			tags = append(tags, 'N')
+		default:
+			return nil, fmt.Errorf("unsupported OSC argument type %T", arg)
+		}
+	}
+	b := appendString(nil, m.Address)
+	b = appendString(b, string(tags))
+	return append(b, args...), nil
+}
+
+// Parse decodes an OSC packet, which is either a message or a bundle of packets.
+// Messages of bundles are returned in order (their time tags are ignored).
+func Parse(b []byte) ([]Message, error) {
+	if bytes.HasPrefix(b, []byte("#bundle\x00")) {
+		if len(b) < 16 {
+			return nil, errors.New("invalid OSC bundle")
+		}
+		var msgs []Message
+		for b = b[16:]; len(b) > 0; {
+			if len(b) < 4 {
+				return nil, errors.New("invalid OSC bundle element")
+			}
+			size := int(binary.BigEndian.Uint32(b))
+			if size > len(b)-4 {
+				return nil, errors.New("invalid OSC bundle element size")
+			}
+			elem, err := Parse(b[4 : 4+size])
+			if err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, elem...)
+			b = b[4+size:]
+		}
+		return msgs, nil
+	}
+
+	address, b, err := readString(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OSC address: %w", err)
+	} else if !strings.HasPrefix(address, "/") {
+		return nil, fmt.Errorf("invalid OSC address %q", address)
+	}
+	m := Message{Address: address}
+	if len(b) == 0 {
+		return []Message{m}, nil // Old implementations may omit the type tags.
+	}
+	tags, b, err := readString(b)
+	if err != nil || !strings.HasPrefix(tags, ",") {
+		return nil, errors.New("invalid OSC type tags")
+	}
+	for _, tag := range tags[1:] {
+		need := map[rune]int{'i': 4, 'f': 4, 'h': 8, 'd': 8, 'b': 4}[tag]
+		if len(b) < need {
+			return nil, fmt.Errorf("missing OSC argument %q", tag)
+		}
+		var arg any
+		switch tag {
+		case 'i':
+			arg, b = int32(binary.BigEndian.Uint32(b)), b[4:]
+		case 'f':
+			arg, b = math.Float32frombits(binary.BigEndian.Uint32(b)), b[4:]
+		case 'h':
+			arg, b = int64(binary.BigEndian.Uint64(b)), b[8:]
+		case 'd':
+			arg, b = math.Float64frombits(binary.BigEndian.Uint64(b)), b[8:]
+		case 's', 'S':
+			arg, b, err = readString(b)
+			if err != nil {
+				return nil, fmt.Errorf("invalid OSC string: %w", err)
+			}
+		case 'b':
+			size := int(binary.BigEndian.Uint32(b))
+			if size > len(b)-4 {
+				return nil, errors.New("invalid OSC blob size")
+			}
+			arg = bytes.Clone(b[4 : 4+size])
+			b = b[min(len(b), 4+size+pad(size)):]
+		case 'T', 'F':
+			arg = tag == 'T'
+		case 'N', 'I': // Nil and "infinitum", which carry no data.
+		default:
+			return nil, fmt.Errorf("unsupported OSC type tag %q", tag)
+		}
+		m.Args = append(m.Args, arg)
+	}
+	return []Message{m}, nil
+}
+
+// Listen reads OSC packets from conn (like a UDP socket opened with net.ListenPacket)
+// and calls fn with each message, until conn returns an error.
+// Invalid packets are ignored.
+func Listen(conn net.PacketConn, fn func(Message)) error {
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		msgs, err := Parse(buf[:n])
+		if err != nil {
+			continue
+		}
+		for _, m := range msgs {
+			fn(m)
+		}
+	}
+}
+
+// pad returns the number of zero bytes needed to align n bytes on 4 bytes.
+func pad(n int) int { return (4 - n%4) % 4 }
+
+// appendString appends an OSC string: null terminated and padded with zeros to a multiple of 4 bytes.
+func appendString(b []byte, s string) []byte {
+	b = append(b, s...)
+	return append(b, make([]byte, 4-len(s)%4)...)
+}
+
+func readString(b []byte) (s string, rest []byte, err error) {
+	end := bytes.IndexByte(b, 0)
+	if end < 0 {
+		return "", nil, errors.New("missing null terminator")
+	}
+	size := min(len(b), end+1+pad(end+1))
+	return string(b[:end]), b[size:], nil
+}
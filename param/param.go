@@ -0,0 +1,49 @@
+// Package param holds parameters that can be changed from another goroutine while a patch is playing
+// (from the command line, a MIDI controller, etc.).
+package param
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Param is a float64 value safe for concurrent use.
+type Param struct {
+	bits     atomic.Uint64
+	observer atomic.Pointer[func(v float64)] // Called on each change, by the recorder of the registry.
+}
+
+// New returns a parameter with the given initial value.
+func New(v float64) *Param {
+	p := &Param{}
+	p.Set(v)
+	return p
+}
+
+// Get returns the current value.
+func (p *Param) Get() float64 { return math.Float64frombits(p.bits.Load()) }
+
+// Set changes the value.
+func (p *Param) Set(v float64) {
+	p.bits.Store(math.Float64bits(v))
+	if observe := p.observer.Load(); observe != nil {
+		(*observe)(v)
+	}
+}
+
+// Signal returns a signal reading the current value.
+func (p *Param) Signal() synth.Signal {
+	return func(x time.Duration) float64 { return p.Get() }
+}
+
+// DefaultSmoothing is the time constant (in seconds) of Smooth for parameters of patches and the command line.
+const DefaultSmoothing = 0.02
+
+// Smooth returns a signal gliding to the current value with a one-pole filter of the given time constant (in seconds),
+// so that sudden changes (like the steps of a MIDI controller) don't click.
+func (p *Param) Smooth(seconds float64) synth.Signal {
+	return synth.Glide(p.Signal(), synth.Constant(seconds))
+}
@@ -0,0 +1,190 @@
+package param
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Event is a change of a parameter, at the time of the clock of the recording.
+type Event struct {
+	At    time.Duration
+	Name  string
+	Value float64
+}
+
+// event is the JSON form of an event, with its time in seconds.
+type event struct {
+	At    float64 `json:"at"`
+	Name  string  `json:"param"`
+	Value float64 `json:"value"`
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(event{At: e.At.Seconds(), Name: e.Name, Value: e.Value})
+}
+
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var v event
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+	*e = Event{At: time.Duration(math.Round(v.At * float64(time.Second))), Name: v.Name, Value: v.Value}
+	return nil
+}
+
+// Recording is the changes of the parameters of a registry (see Registry.RecordAt), in chronological order.
+// It starts with the values of the parameters when the recording started.
+type Recording struct {
+	Events []Event `json:"events"`
+}
+
+// LoadRecording reads a recording from a JSON file (as written by Save).
+func LoadRecording(path string) (*Recording, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rec := &Recording{}
+	err = json.Unmarshal(b, rec)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	slices.SortStableFunc(rec.Events, func(a, b Event) int { return cmp.Compare(a.At, b.At) })
+	return rec, nil
+}
+
+// Save writes the recording to a JSON file.
+func (rec *Recording) Save(path string) error {
+	b, err := json.MarshalIndent(rec, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// Names returns the names of the recorded parameters, sorted.
+func (rec *Recording) Names() []string {
+	var names []string
+	for _, e := range rec.Events {
+		if !slices.Contains(names, e.Name) {
+			names = append(names, e.Name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Points returns the changes of a parameter as the points of an automation curve (see synth.Automation),
+// holding each value until the next change, or nil if the parameter wasn't recorded.
+func (rec *Recording) Points(name string) []synth.Point {
+	var points []synth.Point
+	for _, e := range rec.Events {
+		if e.Name == name {
+			points = append(points, synth.Point{At: e.At, Value: e.Value, Ramp: synth.Hold})
+		}
+	}
+	return points
+}
+
+// Signal returns the recorded values of a parameter over time (steps, like the parameter itself),
+// to render the changes of a performance offline.
+func (rec *Recording) Signal(name string) synth.Signal {
+	return synth.Automation(rec.Points(name)...)
+}
+
+// Recorder records the changes of the parameters of a registry, with the time of its clock.
+type Recorder struct {
+	registry *Registry
+	clock    func() time.Duration
+	params   []*Param // Watched parameters, guarded by the lock of the registry.
+
+	mu     sync.Mutex
+	events []Event
+}
+
+// Record starts recording the changes of the parameters with the time since the recording started (see RecordAt).
+func (r *Registry) Record() *Recorder {
+	start := time.Now()
+	return r.RecordAt(func() time.Duration { return time.Since(start) })
+}
+
+// RecordAt starts recording the changes of the parameters (including the parameters added later),
+// from whichever controller (OSC, MIDI, etc.) they come, to play them back or render them offline.
+// Changes are stamped with the time returned by the clock, like the position of the player reading the parameters,
+// so they are played back against the same time (see Recording.Signal).
+// Only one recording is made at a time: a previous recording is stopped.
+func (r *Registry) RecordAt(clock func() time.Duration) *Recorder {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.recorder != nil {
+		r.recorder.unwatch()
+	}
+	rec := &Recorder{registry: r, clock: clock}
+	names := make([]string, 0, len(r.params))
+	for name := range r.params {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		rec.watch(name, r.params[name])
+	}
+	for i := range rec.events {
+		rec.events[i].At = 0 // The values of the parameters when the recording started.
+	}
+	r.recorder = rec
+	return rec
+}
+
+// watch records the current value of the parameter and its changes, with the lock of the registry held.
+func (rec *Recorder) watch(name string, p *Param) {
+	rec.add(name, p.Get())
+	observe := func(v float64) { rec.add(name, v) }
+	p.observer.Store(&observe)
+	rec.params = append(rec.params, p)
+}
+
+// unwatch stops recording the changes of the parameters, with the lock of the registry held.
+func (rec *Recorder) unwatch() {
+	for _, p := range rec.params {
+		p.observer.Store(nil)
+	}
+	rec.params = nil
+}
+
+func (rec *Recorder) add(name string, v float64) {
+	at := rec.clock()
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.events = append(rec.events, Event{At: at, Name: name, Value: v})
+}
+
+// Recording returns the events recorded so far.
+func (rec *Recorder) Recording() *Recording {
+	rec.mu.Lock()
+	events := slices.Clone(rec.events)
+	rec.mu.Unlock()
+	// Concurrent changes may have been added slightly out of order.
+	slices.SortStableFunc(events, func(a, b Event) int { return cmp.Compare(a.At, b.At) })
+	return &Recording{Events: events}
+}
+
+// Stop stops the recording and returns it.
+func (rec *Recorder) Stop() *Recording {
+	r := rec.registry
+	r.mu.Lock()
+	if r.recorder == rec {
+		rec.unwatch()
+		r.recorder = nil
+	}
+	r.mu.Unlock()
+	return rec.Recording()
+}
@@ -0,0 +1,65 @@
+package param
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Registry holds named parameters (like "filter/cutoff"), so they can be found by controllers (OSC, MIDI, etc.).
+// It is safe for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	params   map[string]*Param
+	recorder *Recorder // Recording the changes of the parameters, if not nil.
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{params: map[string]*Param{}}
+}
+
+// Add returns the parameter with the given name, creating it with the given initial value if it doesn't exist.
+func (r *Registry) Add(name string, v float64) *Param {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.params[name]
+	if !ok {
+		p = New(v)
+		r.params[name] = p
+		if r.recorder != nil {
+			r.recorder.watch(name, p)
+		}
+	}
+	return p
+}
+
+// Get returns the parameter with the given name.
+func (r *Registry) Get(name string) (p *Param, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok = r.params[name]
+	return p, ok
+}
+
+// Set changes the value of the parameter with the given name.
+func (r *Registry) Set(name string, v float64) error {
+	p, ok := r.Get(name)
+	if !ok {
+		return fmt.Errorf("unknown parameter %q", name)
+	}
+	p.Set(v)
+	return nil
+}
+
+// Names returns the names of the parameters, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.params))
+	for name := range r.params {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
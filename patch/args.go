@@ -0,0 +1,138 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Args gives access to the parameters of a module being built.
+//
+// Accessors return the default value of missing parameters.
+// The first invalid parameter is remembered and reported once the module is built,
+// so build functions don't need to check errors after each parameter.
+type Args struct {
+	module string
+	params Module
+	b      *builder
+	err    error
+}
+
+func (a *Args) fail(key string, err error) {
+	if a.err == nil {
+		a.err = fmt.Errorf("parameter %q: %w", key, err)
+	}
+}
+
+// Has reports whether the parameter is set.
+func (a *Args) Has(key string) bool {
+	_, ok := a.params[key]
+	return ok
+}
+
+// Float returns a numeric parameter.
+func (a *Args) Float(key string, def float64) float64 {
+	raw, ok := a.params[key]
+	if !ok {
+		return def
+	}
+	var v float64
+	err := json.Unmarshal(raw, &v)
+	if err != nil {
+		a.fail(key, err)
+	}
+	return v
+}
+
+// Int returns an integer parameter.
+func (a *Args) Int(key string, def int) int {
+	return int(a.Float(key, float64(def)))
+}
+
+// String returns a string parameter.
+func (a *Args) String(key, def string) string {
+	raw, ok := a.params[key]
+	if !ok {
+		return def
+	}
+	var v string
+	err := json.Unmarshal(raw, &v)
+	if err != nil {
+		a.fail(key, err)
+	}
+	return v
+}
+
+// Duration returns a duration parameter (a string like "10ms" or a number of seconds).
+func (a *Args) Duration(key string, def time.Duration) time.Duration {
+	raw, ok := a.params[key]
+	if !ok {
+		return def
+	}
+	var v Duration
+	err := json.Unmarshal(raw, &v)
+	if err != nil {
+		a.fail(key, err)
+	}
+	return time.Duration(v)
+}
+
+// Decode decodes a parameter into v (for structured parameters like lists of notes).
+func (a *Args) Decode(key string, v any) {
+	raw, ok := a.params[key]
+	if !ok {
+		return
+	}
+	err := json.Unmarshal(raw, v)
+	if err != nil {
+		a.fail(key, err)
+	}
+}
+
+// Signal returns a modulatable parameter: either a number (a constant signal) or a reference to another module.
+func (a *Args) Signal(key string, def float64) synth.Signal {
+	raw, ok := a.params[key]
+	if !ok {
+		return synth.Constant(def)
+	}
+	return a.parseSignal(key, raw)
+}
+
+// Signals returns a list of modulatable parameters.
+func (a *Args) Signals(key string) (signals []synth.Signal) {
+	raw, ok := a.params[key]
+	if !ok {
+		return nil
+	}
+	var items []json.RawMessage
+	err := json.Unmarshal(raw, &items)
+	if err != nil {
+		a.fail(key, err)
+		return nil
+	}
+	for _, item := range items {
+		signals = append(signals, a.parseSignal(key, item))
+	}
+	return signals
+}
+
+func (a *Args) parseSignal(key string, raw json.RawMessage) synth.Signal {
+	var v float64
+	if json.Unmarshal(raw, &v) == nil {
+		return synth.Constant(v)
+	}
+	var ref string
+	err := json.Unmarshal(raw, &ref)
+	if err != nil {
+		a.fail(key, fmt.Errorf("expected a number or a module name, got %s", raw))
+		return synth.Constant(0)
+	}
+	s, err := a.b.signal(ref)
+	if err != nil {
+		a.fail(key, err)
+		return synth.Constant(0)
+	}
+	return s
+}
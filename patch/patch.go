@@ -0,0 +1,243 @@
+// Package patch loads sounds described declaratively in JSON files,
+// so sounds can be designed without writing (and compiling) Go code.
+//
+// A patch is a set of named modules (oscillators, envelopes, filters, effects...) and the name of the output module.
+// Module parameters are either numbers (constant values) or the names of other modules (modulation routings),
+// modules with several outputs are referenced as "name.output". For example:
+//
+//	{
+//		"duration": "3s",
+//		"modules": {
+//			"gate": {"type": "gate", "length": "1s"},
+//			"env": {"type": "adsr", "gate": "gate", "attack": "10ms", "decay": "300ms", "sustain": 0.4, "release": "1s"},
+//			"vibrato": {"type": "lfo", "shape": "sine", "rate": 5, "depth": 3, "offset": 220},
+//			"osc": {"type": "saw", "freq": "vibrato"},
+//			"filter": {"type": "lowpass", "in": "osc", "cutoff": 1500, "q": 2},
+//			"out": {"type": "mul", "inputs": ["filter", "env"]}
+//		},
+//		"output": "out"
+//	}
+//
+// See Types for the available modules and their parameters.
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/param"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Patch is the description of a sound.
+type Patch struct {
+	Rate     int               `json:"rate,omitempty"`     // Sample rate, in frames per second (44100 if zero).
+	Duration Duration          `json:"duration,omitempty"` // Length of the render.
+	Modules  map[string]Module `json:"modules"`
+	Output   string            `json:"output"`          // Name of the module (or module output) to render.
+	Title    string            `json:"title,omitempty"` // Written in the metadata of WAV renders, with the artist and the comment.
+	Artist   string            `json:"artist,omitempty"`
+	Comment  string            `json:"comment,omitempty"`
+	Stems    map[string]string `json:"stems,omitempty"` // Module (or module output) of each stem, by name (see BuildStems).
+	Cues     []Cue             `json:"cues,omitempty"`
+	Loops    []Loop            `json:"loops,omitempty"`
+}
+
+// Cue is a named marker in the render (written in WAV files).
+type Cue struct {
+	Name string   `json:"name"`
+	At   Duration `json:"at"`
+}
+
+// Loop is a region of the render that samplers play in a loop (written in WAV files).
+type Loop struct {
+	Start Duration `json:"start"`
+	End   Duration `json:"end"`
+}
+
+// Module holds the type and the parameters of a module.
+type Module map[string]json.RawMessage
+
+// Duration is a time.Duration encoded as a string in JSON (like "150ms").
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string (or a number of seconds).
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var seconds float64
+	if json.Unmarshal(b, &seconds) == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	*d = Duration(v)
+	return err
+}
+
+// MarshalJSON encodes the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Load reads a patch from a JSON file.
+func Load(path string) (*Patch, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Parse reads a patch from JSON.
+func Parse(b []byte) (*Patch, error) {
+	p := &Patch{}
+	err := json.Unmarshal(b, p)
+	if err != nil {
+		return nil, err
+	}
+	if p.Rate == 0 {
+		p.Rate = 44100
+	}
+	return p, nil
+}
+
+// Build returns the output signal of the patch.
+func (p *Patch) Build() (synth.Signal, error) {
+	return p.BuildWith(BuildOptions{})
+}
+
+// BuildDebug is like Build but wraps the outputs of every module with a probe of the debugger (if not nil),
+// named after the module (and output), to find which module produces bad values.
+func (p *Patch) BuildDebug(d *synth.Debugger) (synth.Signal, error) {
+	return p.BuildWith(BuildOptions{Debugger: d})
+}
+
+// BuildOptions are the options of BuildWith.
+type BuildOptions struct {
+	Debugger *synth.Debugger // See BuildDebug.
+	Profiler *synth.Profiler // Measures the time spent in every module (named like the probes of the debugger) if not nil.
+	Params   *param.Registry // Registry of the "param" modules, so they can be changed while playing.
+
+	// Beat is a position in beats (like transport.Transport.Position, read once per frame by the player)
+	// followed by the sequencer modules instead of their own tempo, so patches can follow a shared clock
+	// (like an Ableton Link session). Sequencers follow the tempo of their "bpm" if nil.
+	Beat synth.Signal
+
+	// Automation replaces the "param" modules it recorded (by name) with their recorded changes,
+	// to render a performance offline (see param.Registry.Record).
+	Automation *param.Recording
+}
+
+// BuildWith returns the output signal of the patch built with the given options.
+func (p *Patch) BuildWith(opts BuildOptions) (synth.Signal, error) {
+	return p.newBuilder(opts).signal(p.Output)
+}
+
+// BuildStems returns the signals of the stems of the patch (the tracks of the mix, to export them on their own), by name.
+// The stems are built together, so the modules they share are built once and must be rendered in one pass
+// (with synth.SampleStreams).
+func (p *Patch) BuildStems(opts BuildOptions) (map[string]synth.Signal, error) {
+	b := p.newBuilder(opts)
+	stems := make(map[string]synth.Signal, len(p.Stems))
+	for name, ref := range p.Stems {
+		s, err := b.signal(ref)
+		if err != nil {
+			return nil, fmt.Errorf("stem %q: %w", name, err)
+		}
+		stems[name] = s
+	}
+	return stems, nil
+}
+
+func (p *Patch) newBuilder(opts BuildOptions) *builder {
+	params := opts.Params
+	if params == nil {
+		params = param.NewRegistry()
+	}
+	return &builder{
+		patch: p, built: map[string]Outputs{}, building: map[string]bool{},
+		debugger: opts.Debugger, profiler: opts.Profiler, params: params, automation: opts.Automation, beat: opts.Beat,
+	}
+}
+
+// Outputs are the signals produced by a module, the main output having an empty name.
+type Outputs map[string]synth.Signal
+
+// BuildFunc builds a module from its arguments.
+type BuildFunc func(args *Args) (Outputs, error)
+
+// builder builds modules on demand, following references between them.
+type builder struct {
+	patch      *Patch
+	built      map[string]Outputs
+	building   map[string]bool // To detect cycles.
+	debugger   *synth.Debugger
+	profiler   *synth.Profiler
+	params     *param.Registry
+	automation *param.Recording
+	beat       synth.Signal
+}
+
+// signal returns the signal referenced as "module" or "module.output".
+func (b *builder) signal(ref string) (synth.Signal, error) {
+	name, output, _ := strings.Cut(ref, ".")
+	outputs, err := b.module(name)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := outputs[output]
+	if !ok {
+		return nil, fmt.Errorf("module %q has no output %q", name, output)
+	}
+	return s, nil
+}
+
+func (b *builder) module(name string) (Outputs, error) {
+	if outputs, ok := b.built[name]; ok {
+		return outputs, nil
+	} else if b.building[name] {
+		return nil, fmt.Errorf("module %q depends on itself", name)
+	}
+	m, ok := b.patch.Modules[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown module %q", name)
+	}
+	b.building[name] = true
+	defer delete(b.building, name)
+
+	args := &Args{module: name, params: m, b: b}
+	typ := args.String("type", "")
+	build, ok := Types[typ]
+	if !ok {
+		return nil, fmt.Errorf("module %q: unknown type %q", name, typ)
+	}
+	outputs, err := build(args)
+	if err == nil {
+		err = args.err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("module %q: %w", name, err)
+	}
+	for output, s := range outputs {
+		probe := name
+		if output != "" {
+			probe += "." + output
+		}
+		if b.debugger != nil {
+			s = b.debugger.Wrap(probe, s)
+		}
+		if b.profiler != nil {
+			s = b.profiler.Wrap(probe, s)
+		}
+		outputs[output] = s
+	}
+	b.built[name] = outputs
+	return outputs, nil
+}
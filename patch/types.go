@@ -0,0 +1,484 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode"
+
+	"github.com/ejuju/poc-go-audio-synthesis/drum"
+	"github.com/ejuju/poc-go-audio-synthesis/param"
+	"github.com/ejuju/poc-go-audio-synthesis/seq"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Types are the available module types, new types can be added before loading patches.
+var Types map[string]BuildFunc
+
+// Types is set in init since building modules refers to it.
+// Parameters are listed with their default values, signal parameters accept numbers or module references.
+func init() {
+	Types = map[string]BuildFunc{
+		// Sources.
+		"constant": buildConstant,              // value (0)
+		"param":    buildParam,                 // value (0), name (module name), smooth (0.02), see BuildOptions.Params and Automation
+		"sine":     oscillator(synth.Sine),     // freq (440)
+		"saw":      oscillator(synth.Saw),      // freq (440)
+		"square":   oscillator(synth.Square),   // freq (440)
+		"triangle": oscillator(synth.Triangle), // freq (440)
+		"pulse":    buildPulse,                 // freq (440), width (0.5)
+		"noise":    buildNoise,                 // color ("white"), seed (1)
+		"lfo":      buildLFO,                   // shape ("sine"), rate (1), depth (1), offset (0), seed (1)
+		"pluck":    buildPluck,                 // freq (440), gate (1), damping (0.3), stretch (0.5), seed (1)
+
+		// Drums: gate (1), decay (default of the drum, see package drum), seed (1).
+		"kick":  drumVoice(func(a *Args) voice { return drum.Kick{Decay: a.Duration("decay", 0)} }),
+		"snare": drumVoice(func(a *Args) voice { return drum.Snare{Decay: a.Duration("decay", 0), Seed: int64(a.Int("seed", 1))} }),
+		"hihat": drumVoice(func(a *Args) voice { return drum.HiHat{Decay: a.Duration("decay", 0), Seed: int64(a.Int("seed", 1))} }),
+		"clap":  drumVoice(func(a *Args) voice { return drum.Clap{Decay: a.Duration("decay", 0), Seed: int64(a.Int("seed", 1))} }),
+
+		// Envelopes and sequencing.
+		"gate":       buildGate,       // at (0s), length (0s)
+		"adsr":       buildADSR,       // gate (1), attack (0s), decay (0s), sustain (1), release (0s)
+		"automation": buildAutomation, // points ([{"at": "1s", "value": 1, "ramp": "linear"}]), ramps: linear, exp, smooth, hold
+		"sequence":   buildSequence,   // notes, bpm (120, see BuildOptions.Beat), outputs: freq, gate, velocity
+		"steps":      buildSteps,      // pattern ("x..."), bpm (120), loops (1), swing (0), pitch (60), outputs: freq, gate, velocity
+		"euclid":     buildEuclid,     // pulses (4), steps (16), rotation (0), then like steps
+		"mini":       buildMini,       // pattern in mini-notation ("x ~ x x"), bpm (120), loops (1), cycle (4 beats), pitch (60), outputs: freq, gate, velocity
+
+		// Combinators.
+		"add":    buildAdd,    // inputs
+		"mul":    buildMul,    // inputs
+		"gain":   buildGain,   // in (0), db (0)
+		"offset": buildOffset, // in (0), value (0)
+		"clamp":  buildClamp,  // in (0), min (-1), max (1)
+		"glide":  buildGlide,  // in (0), time (0.05)
+		"hold":   buildHold,   // in (0), trigger (0)
+
+		// Filters: in (0), cutoff (1000), q (0.707), gain (0).
+		"lowpass":   filter(synth.LowPassBiquad),
+		"highpass":  filter(synth.HighPassBiquad),
+		"bandpass":  filter(synth.BandPassBiquad),
+		"notch":     filter(synth.NotchBiquad),
+		"peak":      filter(synth.PeakBiquad),
+		"lowshelf":  filter(synth.LowShelfBiquad),
+		"highshelf": filter(synth.HighShelfBiquad),
+		"dcblock":   buildDCBlock, // in (0)
+		"eq":        buildEQ,      // in (0), bands ([{"type": "lowshelf", "freq": 100, "q": 0.707, "gain": 3}]), types are filter types (peak by default)
+
+		// Effects.
+		"delay":     buildDelay,     // in (0), time (0.25), feedback (0.3), mix (0.5)
+		"reverb":    buildReverb,    // in (0), room (0.5), damping (0.5), mix (0.3)
+		"compress":  buildCompress,  // in (0), key (in), threshold (-20), ratio (4), attack (5ms), release (100ms)
+		"multiband": buildMultiband, // in (0), crossovers ([200, 2000]), bands (list of settings like compress, and gain (0))
+		"noisegate": buildNoiseGate, // in (0), key (in), threshold (-40), hysteresis (6), attack (1ms), hold (50ms), release (100ms), range (80), ratio (0, expands above 1)
+		"follow":    buildFollow,    // in (0), attack (5ms), release (100ms)
+		"limit":     buildLimit,     // in (0), ceiling (-0.3)
+		"shape":     buildShape,     // in (0), curve ("soft", "hard", "fold" or "crush"), bits (8), drive (0), output (0), oversample (1)
+		"decimate":  buildDecimate,  // in (0), rate (8000)
+		"vocoder":   buildVocoder,   // in (carrier, 0), modulator (0), bands (16), low (100), high (8000)
+		"formant":   buildFormant,   // in (0), position (0, morphing between the vowels), vowels ("aeiou")
+		"comb":      buildComb,      // in (0), freq (440), feedback (0.9, feedback comb) or gain (feedforward comb)
+		"resonator": buildResonator, // in (0), freq (440), modes ("bell", "bar", "tube" or a list like [{"ratio": 1, "gain": 0, "decay": "1s"}])
+		"pitch":     buildPitch,     // in (0), semitones (0)
+		"tape":      buildTape,      // in (0), intensity (0.5), and wow, flutter, drive, rolloff, hiss, seed to override the settings of the intensity
+		"freeze":    buildFreeze,    // in (0), length (duration of the patch), rendered once when the patch is built
+
+		// Ready-made modulations: in (0), rate (5, in Hertz) or beats (a note length like 0.5) and bpm (120).
+		"tremolo": buildTremolo, // depth (6, in decibels)
+		"vibrato": buildVibrato, // depth (20, in cents)
+		"autopan": buildAutoPan, // depth (1), outputs: left, right
+
+		// Modulation effects: in (0), rate (0.5), depth (0.5), feedback (0), mix (0.5).
+		"chorus":  modulation(synth.Chorus),
+		"flanger": modulation(synth.Flanger),
+		"phaser":  modulation(synth.Phaser),
+	}
+}
+
+func single(s synth.Signal) (Outputs, error) { return Outputs{"": s}, nil }
+
+func buildConstant(a *Args) (Outputs, error) { return single(synth.Constant(a.Float("value", 0))) }
+
+func buildParam(a *Args) (Outputs, error) {
+	name, smooth := a.String("name", a.module), a.Float("smooth", param.DefaultSmoothing)
+	p := a.b.params.Add(name, a.Float("value", 0))
+	if rec := a.b.automation; rec != nil && rec.Points(name) != nil {
+		return single(synth.Glide(rec.Signal(name), synth.Constant(smooth)))
+	}
+	return single(p.Smooth(smooth))
+}
+
+func oscillator(osc func(freq synth.Signal) synth.Signal) BuildFunc {
+	return func(a *Args) (Outputs, error) { return single(osc(a.Signal("freq", 440))) }
+}
+
+func buildPulse(a *Args) (Outputs, error) {
+	return single(synth.Pulse(a.Signal("freq", 440), a.Signal("width", 0.5)))
+}
+
+func buildPluck(a *Args) (Outputs, error) {
+	return single(synth.PluckGate(a.Signal("freq", 440), a.Signal("gate", 1), a.Float("damping", 0.3),
+		a.Float("stretch", 0.5), int64(a.Int("seed", 1))))
+}
+
+// voice is an instrument played by a gate.
+type voice interface {
+	Play(gate synth.Signal) synth.Signal
+}
+
+func drumVoice(build func(a *Args) voice) BuildFunc {
+	return func(a *Args) (Outputs, error) { return single(build(a).Play(a.Signal("gate", 1))) }
+}
+
+func buildGate(a *Args) (Outputs, error) {
+	return single(synth.Gate(a.Duration("at", 0), a.Duration("length", 0)))
+}
+
+func buildAdd(a *Args) (Outputs, error) { return single(synth.Add(a.Signals("inputs")...)) }
+
+func buildMul(a *Args) (Outputs, error) { return single(synth.Mul(a.Signals("inputs")...)) }
+
+func buildGain(a *Args) (Outputs, error) {
+	return single(synth.Gain(a.Signal("in", 0), a.Float("db", 0)))
+}
+
+func buildOffset(a *Args) (Outputs, error) {
+	return single(synth.Offset(a.Signal("in", 0), a.Float("value", 0)))
+}
+
+func buildClamp(a *Args) (Outputs, error) {
+	return single(synth.Clamp(a.Signal("in", 0), a.Float("min", -1), a.Float("max", 1)))
+}
+
+func buildGlide(a *Args) (Outputs, error) {
+	return single(synth.Glide(a.Signal("in", 0), a.Signal("time", 0.05)))
+}
+
+func buildHold(a *Args) (Outputs, error) {
+	return single(synth.SampleHold(a.Signal("in", 0), a.Signal("trigger", 0)))
+}
+
+func buildDelay(a *Args) (Outputs, error) {
+	return single(synth.Delay(a.Signal("in", 0), a.Signal("time", 0.25), a.Signal("feedback", 0.3), a.Signal("mix", 0.5)))
+}
+
+func buildReverb(a *Args) (Outputs, error) {
+	return single(synth.Reverb(a.Signal("in", 0), a.Float("room", 0.5), a.Float("damping", 0.5), a.Float("mix", 0.3)))
+}
+
+func buildCompress(a *Args) (Outputs, error) {
+	in := a.Signal("in", 0)
+	key := in
+	if a.Has("key") {
+		key = a.Signal("key", 0)
+	}
+	return single(synth.CompressSidechain(in, key, a.Float("threshold", -20), a.Float("ratio", 4),
+		a.Duration("attack", 5*time.Millisecond), a.Duration("release", 100*time.Millisecond)))
+}
+
+func buildNoiseGate(a *Args) (Outputs, error) {
+	in := a.Signal("in", 0)
+	key := in
+	if a.Has("key") {
+		key = a.Signal("key", 0)
+	}
+	g := synth.NoiseGate{
+		Threshold:  a.Float("threshold", -40),
+		Hysteresis: a.Float("hysteresis", 6),
+		Attack:     a.Duration("attack", time.Millisecond),
+		Hold:       a.Duration("hold", 50*time.Millisecond),
+		Release:    a.Duration("release", 100*time.Millisecond),
+		Range:      a.Float("range", 80),
+		Ratio:      a.Float("ratio", 0),
+	}
+	return single(g.ApplySidechain(in, key))
+}
+
+func buildMultiband(a *Args) (Outputs, error) {
+	crossovers := []float64{200, 2000}
+	a.Decode("crossovers", &crossovers)
+	var raw []json.RawMessage
+	a.Decode("bands", &raw)
+	bands := make([]synth.CompressorBand, len(raw))
+	for i, r := range raw {
+		b := struct {
+			Threshold float64  `json:"threshold"`
+			Ratio     float64  `json:"ratio"`
+			Attack    Duration `json:"attack"`
+			Release   Duration `json:"release"`
+			Gain      float64  `json:"gain"`
+		}{Threshold: -20, Ratio: 4, Attack: Duration(5 * time.Millisecond), Release: Duration(100 * time.Millisecond)}
+		err := json.Unmarshal(r, &b)
+		if err != nil {
+			return nil, fmt.Errorf("band %d: %w", i, err)
+		}
+		bands[i] = synth.CompressorBand{Threshold: b.Threshold, Ratio: b.Ratio,
+			Attack: time.Duration(b.Attack), Release: time.Duration(b.Release), Gain: b.Gain}
+	}
+	return single(synth.MultibandCompress(a.Signal("in", 0), crossovers, bands...))
+}
+
+func buildFollow(a *Args) (Outputs, error) {
+	return single(synth.EnvelopeFollower(a.Signal("in", 0),
+		a.Duration("attack", 5*time.Millisecond), a.Duration("release", 100*time.Millisecond)))
+}
+
+func buildVocoder(a *Args) (Outputs, error) {
+	return single(synth.Vocoder(a.Signal("in", 0), a.Signal("modulator", 0),
+		a.Int("bands", 16), a.Float("low", 100), a.Float("high", 8000)))
+}
+
+func buildFormant(a *Args) (Outputs, error) {
+	letters := map[rune]synth.Vowel{'a': synth.VowelA, 'e': synth.VowelE, 'i': synth.VowelI, 'o': synth.VowelO, 'u': synth.VowelU}
+	var vowels []synth.Vowel
+	for _, r := range a.String("vowels", "aeiou") {
+		v, ok := letters[unicode.ToLower(r)]
+		if !ok {
+			return nil, fmt.Errorf("unknown vowel %q (expected a, e, i, o or u)", r)
+		}
+		vowels = append(vowels, v)
+	}
+	return single(synth.FormantFilter(a.Signal("in", 0), a.Signal("position", 0), vowels...))
+}
+
+func buildComb(a *Args) (Outputs, error) {
+	if a.Has("gain") {
+		return single(synth.FeedforwardComb(a.Signal("in", 0), a.Signal("freq", 440), a.Signal("gain", 1)))
+	}
+	return single(synth.FeedbackComb(a.Signal("in", 0), a.Signal("freq", 440), a.Signal("feedback", 0.9)))
+}
+
+var modePresets = map[string][]synth.Mode{"bell": synth.BellModes, "bar": synth.BarModes, "tube": synth.TubeModes}
+
+func buildResonator(a *Args) (Outputs, error) {
+	modes := synth.BellModes
+	if raw := a.params["modes"]; len(raw) > 0 && raw[0] == '"' {
+		name := a.String("modes", "")
+		var ok bool
+		modes, ok = modePresets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown modes %q", name)
+		}
+	} else if a.Has("modes") {
+		var list []struct {
+			Ratio float64  `json:"ratio"`
+			Gain  float64  `json:"gain"`
+			Decay Duration `json:"decay"`
+		}
+		a.Decode("modes", &list)
+		modes = nil
+		for _, m := range list {
+			modes = append(modes, synth.Mode{Ratio: m.Ratio, Gain: m.Gain, Decay: time.Duration(m.Decay)})
+		}
+	}
+	return single(synth.Resonator(a.Signal("in", 0), a.Signal("freq", 440), modes...))
+}
+
+func buildPitch(a *Args) (Outputs, error) {
+	return single(synth.PitchShift(a.Signal("in", 0), a.Signal("semitones", 0)))
+}
+
+func buildTape(a *Args) (Outputs, error) {
+	t := synth.NewTape(a.Float("intensity", 0.5))
+	t.Wow, t.Flutter, t.Drive = a.Float("wow", t.Wow), a.Float("flutter", t.Flutter), a.Float("drive", t.Drive)
+	t.Rolloff, t.Hiss, t.Seed = a.Float("rolloff", t.Rolloff), a.Float("hiss", t.Hiss), int64(a.Int("seed", int(t.Seed)))
+	return single(t.Apply(a.Signal("in", 0)))
+}
+
+func buildFreeze(a *Args) (Outputs, error) {
+	length := a.Duration("length", time.Duration(a.b.patch.Duration))
+	if length <= 0 {
+		return nil, fmt.Errorf("invalid length %v (the patch has no duration)", length)
+	}
+	return single(synth.Freeze(a.Signal("in", 0), a.b.patch.Rate, length))
+}
+
+// syncedRate returns the rate parameter, or the rate of the note length of the beats parameter at the tempo.
+func syncedRate(a *Args) synth.Signal {
+	if a.Has("beats") {
+		return synth.Sync(a.Float("bpm", 120), a.Float("beats", synth.Quarter))
+	}
+	return a.Signal("rate", 5)
+}
+
+func buildTremolo(a *Args) (Outputs, error) {
+	return single(synth.Tremolo(a.Signal("in", 0), syncedRate(a), a.Float("depth", 6)))
+}
+
+func buildVibrato(a *Args) (Outputs, error) {
+	return single(synth.Vibrato(a.Signal("in", 0), syncedRate(a), a.Float("depth", 20)))
+}
+
+func buildAutoPan(a *Args) (Outputs, error) {
+	ms := synth.AutoPan(a.Signal("in", 0), syncedRate(a), a.Float("depth", 1))
+	return Outputs{"left": ms[0], "right": ms[1]}, nil
+}
+
+func buildLimit(a *Args) (Outputs, error) {
+	return single(synth.Limit(a.Signal("in", 0), a.Float("ceiling", -0.3)))
+}
+
+func filter(design synth.BiquadDesign) BuildFunc {
+	return func(a *Args) (Outputs, error) {
+		return single(synth.Filter(a.Signal("in", 0), design, a.Signal("cutoff", 1000), a.Signal("q", 0.707), a.Signal("gain", 0)))
+	}
+}
+
+// designs are the filter types of EQ bands.
+var designs = map[string]synth.BiquadDesign{
+	"lowpass":   synth.LowPassBiquad,
+	"highpass":  synth.HighPassBiquad,
+	"bandpass":  synth.BandPassBiquad,
+	"notch":     synth.NotchBiquad,
+	"peak":      synth.PeakBiquad,
+	"lowshelf":  synth.LowShelfBiquad,
+	"highshelf": synth.HighShelfBiquad,
+}
+
+func buildEQ(a *Args) (Outputs, error) {
+	var bands []struct {
+		Type string  `json:"type"`
+		Freq float64 `json:"freq"`
+		Q    float64 `json:"q"`
+		Gain float64 `json:"gain"`
+	}
+	a.Decode("bands", &bands)
+	var eq synth.EQ
+	for _, b := range bands {
+		design, ok := designs[b.Type]
+		if b.Type == "" {
+			design, ok = synth.PeakBiquad, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown band type %q", b.Type)
+		}
+		eq = append(eq, synth.EQBand{Design: design, Freq: b.Freq, Q: b.Q, Gain: b.Gain})
+	}
+	return single(eq.Apply(a.Signal("in", 0)))
+}
+
+func buildDCBlock(a *Args) (Outputs, error) { return single(synth.DCBlock(a.Signal("in", 0))) }
+
+func buildShape(a *Args) (Outputs, error) {
+	var curve synth.Curve
+	switch name := a.String("curve", "soft"); name {
+	case "soft":
+		curve = synth.SoftClip
+	case "hard":
+		curve = synth.HardClip
+	case "fold":
+		curve = synth.Foldback
+	case "crush":
+		curve = synth.Bitcrush(a.Int("bits", 8))
+	default:
+		return nil, fmt.Errorf("unknown curve %q", name)
+	}
+	return single(synth.ShapeOversampled(a.Signal("in", 0), curve, a.Float("drive", 0), a.Float("output", 0), a.Int("oversample", 1)))
+}
+
+func buildDecimate(a *Args) (Outputs, error) {
+	return single(synth.Decimate(a.Signal("in", 0), a.Signal("rate", 8000)))
+}
+
+func modulation(effect func(in, rate, depth, feedback, mix synth.Signal) synth.Signal) BuildFunc {
+	return func(a *Args) (Outputs, error) {
+		return single(effect(a.Signal("in", 0), a.Signal("rate", 0.5), a.Signal("depth", 0.5), a.Signal("feedback", 0), a.Signal("mix", 0.5)))
+	}
+}
+
+func buildNoise(a *Args) (Outputs, error) {
+	seed := int64(a.Int("seed", 1))
+	switch color := a.String("color", "white"); color {
+	case "white":
+		return single(synth.WhiteNoise(seed))
+	case "pink":
+		return single(synth.PinkNoise(seed))
+	case "brown":
+		return single(synth.BrownNoise(seed))
+	default:
+		return nil, fmt.Errorf("unknown noise color %q", color)
+	}
+}
+
+func buildLFO(a *Args) (Outputs, error) {
+	rate, depth, offset := a.Signal("rate", 1), a.Float("depth", 1), a.Float("offset", 0)
+	switch shape := a.String("shape", "sine"); shape {
+	case "sine":
+		return single(synth.LFOSine(rate, depth, offset))
+	case "triangle":
+		return single(synth.LFOTriangle(rate, depth, offset))
+	case "saw":
+		return single(synth.LFOSaw(rate, depth, offset))
+	case "square":
+		return single(synth.LFOSquare(rate, depth, offset))
+	case "random":
+		return single(synth.LFOSampleHold(rate, depth, offset, int64(a.Int("seed", 1))))
+	case "walk":
+		return single(synth.RandomWalk(rate, depth, offset, int64(a.Int("seed", 1))))
+	default:
+		return nil, fmt.Errorf("unknown LFO shape %q", shape)
+	}
+}
+
+func buildADSR(a *Args) (Outputs, error) {
+	return single(synth.ADSR(a.Signal("gate", 1), a.Duration("attack", 0), a.Duration("decay", 0),
+		a.Float("sustain", 1), a.Duration("release", 0)))
+}
+
+func buildSequence(a *Args) (Outputs, error) {
+	var notes []seq.Note
+	a.Decode("notes", &notes)
+	v := a.b.sequence(notes, a.Float("bpm", 120))
+	return Outputs{"freq": v.Freq, "gate": v.Gate, "velocity": v.Velocity}, nil
+}
+
+func buildSteps(a *Args) (Outputs, error) { return pattern(a, seq.Steps(a.String("pattern", "x..."))) }
+
+func buildEuclid(a *Args) (Outputs, error) {
+	return pattern(a, seq.Euclid(a.Int("pulses", 4), a.Int("steps", 16), a.Int("rotation", 0)))
+}
+
+func buildMini(a *Args) (Outputs, error) {
+	m, err := seq.ParseMini(a.String("pattern", "x"))
+	if err != nil {
+		return nil, err
+	}
+	m.Cycle, m.Pitch = a.Float("cycle", 4), a.Float("pitch", 60)
+	v := a.b.sequence(m.Notes(a.Int("loops", 1)), a.Float("bpm", 120))
+	return Outputs{"freq": v.Freq, "gate": v.Gate, "velocity": v.Velocity}, nil
+}
+
+// sequence returns a voice playing the notes at the given tempo, or following the beat of the options if set.
+func (b *builder) sequence(notes []seq.Note, bpm float64) seq.Voice {
+	if b.beat != nil {
+		return seq.SequenceAt(notes, b.beat, seq.TwelveTone)
+	}
+	return seq.Sequence(notes, bpm)
+}
+
+func pattern(a *Args, steps []seq.Step) (Outputs, error) {
+	p := seq.Pattern{Steps: steps, Swing: a.Float("swing", 0), Pitch: a.Float("pitch", 60)}
+	v := a.b.sequence(p.Notes(a.Int("loops", 1)), a.Float("bpm", 120))
+	return Outputs{"freq": v.Freq, "gate": v.Gate, "velocity": v.Velocity}, nil
+}
+
+var ramps = map[string]synth.Ramp{"": synth.LinearRamp, "linear": synth.LinearRamp, "exp": synth.ExpRamp, "smooth": synth.SmoothRamp, "hold": synth.Hold}
+
+func buildAutomation(a *Args) (Outputs, error) {
+	var points []struct {
+		At    Duration `json:"at"`
+		Value float64  `json:"value"`
+		Ramp  string   `json:"ramp"`
+	}
+	a.Decode("points", &points)
+	var automation []synth.Point
+	for _, p := range points {
+		r, ok := ramps[p.Ramp]
+		if !ok {
+			return nil, fmt.Errorf("unknown ramp %q", p.Ramp)
+		}
+		automation = append(automation, synth.Point{At: time.Duration(p.At), Value: p.Value, Ramp: r})
+	}
+	return single(synth.Automation(automation...))
+}
@@ -0,0 +1,219 @@
+package playback
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// InputBackend opens audio inputs (like a microphone or line-in).
+//
+// The returned reader produces mono frames encoded as 16-bit signed little-endian integers,
+// closing it stops the capture. The device is backend-specific, the empty string is the default input.
+type InputBackend interface {
+	OpenInput(device string, rate int) (io.ReadCloser, error)
+}
+
+// Recorder is an input backend reading audio frames from the standard output of an external recorder.
+type Recorder struct {
+	Name string
+	Args func(device string, rate int) []string
+}
+
+// Recorders are the external recorders known to the default input backend, in order of preference.
+var Recorders = []Recorder{
+	{Name: "arecord", Args: func(device string, rate int) []string {
+		args := []string{"-q", "-t", "raw", "-f", "S16_LE", "-r", strconv.Itoa(rate), "-c", "1"}
+		if device != "" {
+			args = append(args, "-D", device)
+		}
+		return args
+	}},
+	{Name: "parec", Args: func(device string, rate int) []string {
+		args := []string{"--format=s16le", "--rate=" + strconv.Itoa(rate), "--channels=1"}
+		if device != "" {
+			args = append(args, "--device="+device)
+		}
+		return args
+	}},
+	{Name: "ffmpeg", Args: func(device string, rate int) []string {
+		if device == "" {
+			device = "default"
+		}
+		return []string{"-loglevel", "quiet", "-f", "alsa", "-i", device, "-f", "s16le", "-ar", strconv.Itoa(rate), "-ac", "1", "-"}
+	}},
+}
+
+// ErrNoInputBackend is returned when none of the known recorders is installed.
+var ErrNoInputBackend = errors.New("no audio recorder found (install arecord, parec or ffmpeg)")
+
+// DefaultInputBackend returns the first of the known recorders found in the PATH.
+func DefaultInputBackend() (InputBackend, error) {
+	for _, r := range Recorders {
+		_, err := exec.LookPath(r.Name)
+		if err == nil {
+			return r, nil
+		}
+	}
+	return nil, ErrNoInputBackend
+}
+
+// OpenInput starts the recorder process.
+func (r Recorder) OpenInput(device string, rate int) (io.ReadCloser, error) {
+	cmd := exec.Command(r.Name, r.Args(device, rate)...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("start %s: %w", r.Name, err)
+	}
+	return &recording{cmd: cmd, ReadCloser: stdout}, nil
+}
+
+// recording wraps the standard output of a running recorder, closing it kills the recorder.
+type recording struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (r *recording) Close() error {
+	r.cmd.Process.Kill()
+	r.ReadCloser.Close()
+	err := r.cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil // Killed on purpose.
+	}
+	return err
+}
+
+// DefaultInputBufferSize is the number of frames buffered between the input and the signal
+// when the input doesn't specify a buffer size.
+const DefaultInputBufferSize = 4096
+
+// Input captures audio from an input backend.
+//
+// Captured frames wait in a ring buffer until read by the signal: a larger buffer survives longer hiccups
+// of the recorder or player, but can hold more latency (frames are dropped when it is full).
+type Input struct {
+	Backend    InputBackend // If nil, the default input backend is used.
+	Device     string       // If empty, the default input device is used.
+	BufferSize int          // In frames, DefaultInputBufferSize is used if zero.
+}
+
+// Capture captures audio on the default input device.
+func Capture(rate int) (*InputStream, error) {
+	return Input{}.Capture(rate)
+}
+
+// Capture starts capturing audio from the input's device, until stopped.
+func (in Input) Capture(rate int) (*InputStream, error) {
+	backend := in.Backend
+	if backend == nil {
+		var err error
+		backend, err = DefaultInputBackend()
+		if err != nil {
+			return nil, err
+		}
+	}
+	size := in.BufferSize
+	if size <= 0 {
+		size = DefaultInputBufferSize
+	}
+	r, err := backend.OpenInput(in.Device, rate)
+	if err != nil {
+		return nil, fmt.Errorf("open input: %w", err)
+	}
+	c := &InputStream{in: r, ring: make([]float64, size), stop: make(chan struct{}), done: make(chan struct{})}
+	go c.run()
+	return c, nil
+}
+
+// InputStream is a running audio capture, played as a signal.
+type InputStream struct {
+	in       io.ReadCloser
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	err      error // Error that interrupted the capture, if any.
+
+	// Ring buffer with a single writer (the capture goroutine) and a single reader (the signal).
+	ring          []float64
+	written, read atomic.Uint64 // Frames written to and read from the ring.
+	dropped       atomic.Uint64
+
+	// Only used by the signal.
+	last  time.Duration
+	value float64
+	began bool
+}
+
+func (c *InputStream) run() {
+	defer close(c.done)
+	r := bufio.NewReader(c.in)
+	var b [2]byte
+	for {
+		_, err := io.ReadFull(r, b[:])
+		if err != nil {
+			select {
+			case <-c.stop: // Input closed by Stop.
+			default:
+				c.err = fmt.Errorf("read input: %w", err)
+			}
+			return
+		}
+		w := c.written.Load()
+		if w-c.read.Load() == uint64(len(c.ring)) {
+			c.dropped.Add(1) // The signal is behind.
+			continue
+		}
+		c.ring[w%uint64(len(c.ring))] = float64(int16(binary.LittleEndian.Uint16(b[:]))) / 32768
+		c.written.Store(w + 1)
+	}
+}
+
+// Signal returns the captured audio, reading the next frame from the buffer each time the signal
+// is sampled at a new time (so it must be sampled once per frame, in order, by a single goroutine).
+// It is silent when no frames are buffered (before the input starts or when it can't keep up).
+func (c *InputStream) Signal() synth.Signal {
+	return func(x time.Duration) float64 {
+		if c.began && x == c.last {
+			return c.value
+		}
+		c.began, c.last = true, x
+		r := c.read.Load()
+		if r == c.written.Load() {
+			c.value = 0 // Underrun.
+			return 0
+		}
+		c.value = c.ring[r%uint64(len(c.ring))]
+		c.read.Store(r + 1)
+		return c.value
+	}
+}
+
+// Dropped returns the number of captured frames dropped because the buffer was full.
+func (c *InputStream) Dropped() int { return int(c.dropped.Load()) }
+
+// Stop stops the capture and closes the input.
+// It returns the error that interrupted the capture, if any.
+func (c *InputStream) Stop() (err error) {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		closeErr := c.in.Close()
+		<-c.done
+		err = errors.Join(c.err, closeErr)
+	})
+	return err
+}
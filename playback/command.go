@@ -0,0 +1,77 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+)
+
+// Command is a backend that pipes audio frames to an external player through its standard input.
+type Command struct {
+	Name string
+	Args func(rate int) []string
+}
+
+// Players are the external players known to the default backend, in order of preference.
+var Players = []Command{
+	{Name: "ffplay", Args: func(rate int) []string {
+		return []string{"-nodisp", "-loglevel", "quiet", "-f", "s16le", "-ar", strconv.Itoa(rate), "-ac", "1", "-"}
+	}},
+	{Name: "aplay", Args: func(rate int) []string {
+		return []string{"-q", "-t", "raw", "-f", "S16_LE", "-r", strconv.Itoa(rate), "-c", "1", "-"}
+	}},
+	{Name: "pacat", Args: func(rate int) []string {
+		return []string{"--playback", "--format=s16le", "--rate=" + strconv.Itoa(rate), "--channels=1"}
+	}},
+	{Name: "play", Args: func(rate int) []string {
+		return []string{"-q", "-t", "raw", "-e", "signed", "-b", "16", "-L", "-r", strconv.Itoa(rate), "-c", "1", "-"}
+	}},
+}
+
+// ErrNoBackend is returned when none of the known players is installed.
+var ErrNoBackend = errors.New("no audio player found (install ffplay, aplay, pacat or sox)")
+
+// DefaultBackend returns the first of the known players found in the PATH.
+func DefaultBackend() (Backend, error) {
+	for _, p := range Players {
+		_, err := exec.LookPath(p.Name)
+		if err == nil {
+			return p, nil
+		}
+	}
+	return nil, ErrNoBackend
+}
+
+// Open starts the player process.
+func (c Command) Open(rate int) (io.WriteCloser, error) {
+	cmd := exec.Command(c.Name, c.Args(rate)...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("start %s: %w", c.Name, err)
+	}
+	return &process{cmd: cmd, WriteCloser: stdin}, nil
+}
+
+// process wraps the standard input of a running player,
+// closing it kills the player so that buffered audio isn't played after a stop.
+type process struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (p *process) Close() error {
+	p.WriteCloser.Close()
+	p.cmd.Process.Kill()
+	err := p.cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil // Killed on purpose.
+	}
+	return err
+}
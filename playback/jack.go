@@ -0,0 +1,48 @@
+package playback
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/ejuju/poc-go-audio-synthesis/encode"
+)
+
+// JACK is a backend playing through JACK, so the synth can be patched into other audio applications.
+//
+// It isn't a native JACK client (which would need cgo and libjack): the client is run by GStreamer
+// (gst-launch-1.0 with the jackaudiosink plugin, from gst-plugins-good), fed with 32-bit float frames.
+// GStreamer buffers two blocks of the player (see Player.BufferSize) on top of the period of the JACK server,
+// and resamples the audio if the server runs at another rate.
+type JACK struct {
+	Client   string // Name of the client.
+	Connect  bool   // Whether the ports are connected to the physical outputs.
+	Channels int    // Number of output ports (each one playing the signal), 1 if zero.
+}
+
+// Open starts the client, for blocks of DefaultBufferSize frames.
+func (j JACK) Open(rate int) (io.WriteCloser, error) {
+	out, _, _, err := j.OpenStream(rate, DefaultBufferSize)
+	return out, err
+}
+
+// OpenStream starts the client, with GStreamer buffering as little as the blocks allow.
+func (j JACK) OpenStream(rate, bufferSize int) (out io.WriteCloser, format encode.Format, channels int, err error) {
+	channels = max(j.Channels, 1)
+	mode := "none"
+	if j.Connect {
+		mode = "auto"
+	}
+	latency := int64(bufferSize) * 1_000_000 / int64(rate) // In microseconds.
+	c := Command{Name: "gst-launch-1.0", Args: func(rate int) []string {
+		return []string{
+			"-q", "fdsrc", "fd=0", "blocksize=" + strconv.Itoa(bufferSize*channels*encode.F32LE.Size()),
+			"!", "rawaudioparse", "use-sink-caps=false", "format=pcm", "pcm-format=f32le",
+			"sample-rate=" + strconv.Itoa(rate), "num-channels=" + strconv.Itoa(channels),
+			"!", "audioconvert", "!", "audioresample",
+			"!", "jackaudiosink", "client-name=" + j.Client, "connect=" + mode,
+			"latency-time=" + strconv.FormatInt(latency, 10), "buffer-time=" + strconv.FormatInt(2*latency, 10),
+		}
+	}}
+	out, err = c.Open(rate)
+	return out, encode.F32LE, channels, err
+}
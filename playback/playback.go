@@ -0,0 +1,165 @@
+// Package playback plays signals in real time on the default audio output device,
+// and captures audio from input devices.
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ejuju/poc-go-audio-synthesis/encode"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Backend opens audio outputs.
+//
+// The returned writer receives mono frames encoded as 16-bit signed little-endian integers,
+// closing it stops the playback immediately.
+type Backend interface {
+	Open(rate int) (io.WriteCloser, error)
+}
+
+// StreamBackend is a backend choosing the format of its frames, or depending on the buffer size of the player
+// (to keep its own buffering as small). Players open it with OpenStream instead of Open.
+type StreamBackend interface {
+	Backend
+
+	// OpenStream opens an output receiving blocks of bufferSize frames encoded in the returned format,
+	// with each frame copied to each channel (interleaved).
+	OpenStream(rate, bufferSize int) (out io.WriteCloser, format encode.Format, channels int, err error)
+}
+
+// Stopper stops a running playback.
+type Stopper interface {
+	Stop() error
+}
+
+// ErrRecord is wrapped by the errors of the recordings of playbacks (see Player.Record).
+var ErrRecord = errors.New("record")
+
+// DefaultBufferSize is the number of frames rendered and sent to the output at once
+// when the player doesn't specify a buffer size.
+const DefaultBufferSize = 1024
+
+// Player plays signals on a backend.
+//
+// Smaller buffers reduce latency, larger buffers make playback more robust to slow signals.
+type Player struct {
+	Backend    Backend // If nil, the default backend is used.
+	BufferSize int     // In frames, DefaultBufferSize is used if zero.
+
+	// Record receives the played frames too if not nil (like a file created by encode.Format.CreateWAV),
+	// so live sessions are kept. It is closed when the playback stops (finishing the file).
+	// Errors when recording don't interrupt the playback, they are returned by Stop.
+	Record *encode.WAVWriter
+}
+
+// Play plays the signal on the default backend until stopped.
+func Play(s synth.Signal, rate int) (Stopper, error) {
+	return Player{}.Play(s, rate)
+}
+
+// Play streams the signal to the player's backend until stopped.
+func (p Player) Play(s synth.Signal, rate int) (Stopper, error) {
+	backend := p.Backend
+	if backend == nil {
+		var err error
+		backend, err = DefaultBackend()
+		if err != nil {
+			return nil, err
+		}
+	}
+	size := p.BufferSize
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	var out io.WriteCloser
+	var err error
+	format, channels := encode.S16LE, 1
+	if sb, ok := backend.(StreamBackend); ok {
+		out, format, channels, err = sb.OpenStream(rate, size)
+	} else {
+		out, err = backend.Open(rate)
+	}
+	if err != nil {
+		if p.Record != nil {
+			err = errors.Join(err, p.Record.Close())
+		}
+		return nil, fmt.Errorf("open output: %w", err)
+	}
+
+	pb := &playback{out: out, format: format, channels: channels, record: p.Record, stop: make(chan struct{}), done: make(chan struct{})}
+	go pb.run(s, rate, size)
+	return pb, nil
+}
+
+type playback struct {
+	out       io.WriteCloser
+	format    encode.Format
+	channels  int
+	record    *encode.WAVWriter
+	stop      chan struct{}
+	done      chan struct{}
+	stopOnce  sync.Once
+	err       error // Error that interrupted the playback, if any.
+	recordErr error // Error that interrupted the recording, if any.
+}
+
+func (pb *playback) run(s synth.Signal, rate, size int) {
+	defer close(pb.done)
+	buf := make([]byte, 0, size*pb.channels*pb.format.Size())
+	block := make([]float64, size)
+	samples := block // Interleaved copies of the frames, for outputs with several channels.
+	if pb.channels > 1 {
+		samples = make([]float64, size*pb.channels)
+	}
+	for frame := 0; ; frame += size {
+		select {
+		case <-pb.stop:
+			return
+		default:
+		}
+		for i := range block {
+			block[i] = s(synth.AtFrame(frame+i, rate))
+		}
+		if pb.channels > 1 {
+			for i, v := range block {
+				for c := range pb.channels {
+					samples[i*pb.channels+c] = v
+				}
+			}
+		}
+		buf = pb.format.Append(buf[:0], samples)
+		if pb.record != nil && pb.recordErr == nil {
+			pb.recordErr = pb.record.Write(block)
+		}
+		_, err := pb.out.Write(buf)
+		if err != nil {
+			select {
+			case <-pb.stop: // Output closed by Stop.
+			default:
+				pb.err = err
+			}
+			return
+		}
+	}
+}
+
+// Stop stops the playback and closes the output (and the recording).
+// It returns the error that interrupted the playback or the recording, if any.
+func (pb *playback) Stop() (err error) {
+	pb.stopOnce.Do(func() {
+		close(pb.stop)
+		closeErr := pb.out.Close() // Unblocks a pending write.
+		<-pb.done
+		err = errors.Join(pb.err, closeErr)
+		if pb.record != nil {
+			recordErr := errors.Join(pb.recordErr, pb.record.Close())
+			if recordErr != nil {
+				err = errors.Join(err, fmt.Errorf("%w: %w", ErrRecord, recordErr))
+			}
+		}
+	})
+	return err
+}
@@ -0,0 +1,174 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/ejuju/poc-go-audio-synthesis/encode"
+)
+
+// Command is a processor running an external program as an effect: frames are piped to its standard input
+// and read back from its standard output, as raw mono 32-bit little-endian floats
+// (like the "f32le" format of ffmpeg or "-t f32" of sox).
+//
+// The program must write as many frames as it reads. Programs buffering their output (most tools do, by blocks
+// of a few thousand frames) need a Latency at least as large as their buffer, or processing waits for them forever.
+//
+// A command must be closed once done. If the program fails, the following blocks are silent and Err returns why.
+type Command struct {
+	Name    string
+	Args    func(rate int) []string // Arguments of the program for the sample rate, if not nil.
+	Latency int                     // Frames of silence fed to the program ahead of the audio, delaying it.
+
+	rate   int
+	cmd    *exec.Cmd
+	stdout *bufio.Reader
+	writes chan []byte // Blocks to write to the standard input, by a goroutine so the program is always fed.
+	wrote  chan struct{}
+	stderr *bytes.Buffer
+	buf    []byte
+
+	mu  sync.Mutex
+	err error
+}
+
+// pendingWrites is the number of blocks waiting to be written to the program.
+const pendingWrites = 64
+
+// Prepare starts the program.
+func (c *Command) Prepare(rate, maxBlock int) error {
+	c.Close()
+	c.rate = rate
+	c.mu.Lock()
+	c.err = nil
+	c.mu.Unlock()
+	var args []string
+	if c.Args != nil {
+		args = c.Args(rate)
+	}
+	cmd := exec.Command(c.Name, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	c.stderr = &bytes.Buffer{}
+	cmd.Stderr = c.stderr
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("start %s: %w", c.Name, err)
+	}
+	c.cmd, c.stdout = cmd, bufio.NewReader(stdout)
+	c.writes, c.wrote = make(chan []byte, pendingWrites), make(chan struct{})
+	go c.write(stdin, c.writes)
+	if c.Latency > 0 {
+		c.writes <- make([]byte, 4*c.Latency)
+	}
+	return nil
+}
+
+// write writes blocks to the standard input of the program until the channel is closed.
+func (c *Command) write(stdin io.WriteCloser, writes <-chan []byte) {
+	defer close(c.wrote)
+	defer stdin.Close()
+	failed := false
+	for b := range writes {
+		if failed {
+			continue // Drained so Process doesn't block.
+		}
+		_, err := stdin.Write(b)
+		if err != nil {
+			c.fail(fmt.Errorf("write: %w", err))
+			failed = true
+		}
+	}
+}
+
+// Process pipes the block through the program.
+func (c *Command) Process(in, out []float64) {
+	if c.cmd == nil || c.Err() != nil {
+		clear(out)
+		return
+	}
+	b := make([]byte, 0, 4*len(in))
+	c.writes <- encode.F32LE.Append(b, in)
+
+	c.buf = grow(c.buf, 4*len(out))
+	_, err := io.ReadFull(c.stdout, c.buf)
+	if err != nil {
+		c.fail(fmt.Errorf("read: %w", err))
+		clear(out)
+		return
+	}
+	for i := range out {
+		out[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(c.buf[4*i:])))
+	}
+}
+
+// Reset restarts the program, the only way to clear the state of an external effect.
+func (c *Command) Reset() {
+	if c.cmd == nil {
+		return
+	}
+	err := c.Prepare(c.rate, 0)
+	if err != nil {
+		c.fail(err)
+	}
+}
+
+// Err returns the error that made the program fail, if any.
+func (c *Command) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Command) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = fmt.Errorf("%s: %w", c.Name, err)
+	}
+}
+
+// Close stops the program. It returns the error that made the program fail, if any.
+func (c *Command) Close() error {
+	if c.cmd == nil {
+		return c.Err()
+	}
+	close(c.writes)
+	c.cmd.Process.Kill() // Also unblocks a pending write.
+	<-c.wrote
+	err := c.cmd.Wait()
+	c.cmd = nil
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) { // Killed on purpose.
+		c.fail(err)
+	}
+	if err := c.Err(); err != nil {
+		if msg := strings.TrimSpace(c.stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg) // The program explains why it failed.
+		}
+		return err
+	}
+	return nil
+}
+
+// grow returns a slice of n bytes, reusing b if it is large enough.
+func grow(b []byte, n int) []byte {
+	if cap(b) < n {
+		return make([]byte, n)
+	}
+	return b[:n]
+}
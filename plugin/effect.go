@@ -0,0 +1,48 @@
+package plugin
+
+import (
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// effect runs a signal effect on blocks.
+type effect struct {
+	fn    func(in synth.Signal) synth.Signal
+	rate  int
+	out   synth.Signal
+	block []float64 // Input block being processed.
+	start int       // Index of the first frame of the block.
+}
+
+// Effect returns a processor applying a signal effect (like synth.Shape or synth.RingMod) to its blocks,
+// so the effects of the synth package can be chained and oversampled (see Oversample).
+// The effect is built again when the processor is prepared or reset, to start from a fresh state.
+func Effect(fn func(in synth.Signal) synth.Signal) Processor {
+	return &effect{fn: fn}
+}
+
+func (e *effect) Prepare(rate, maxBlock int) error {
+	e.rate = rate
+	e.Reset()
+	return nil
+}
+
+func (e *effect) Process(in, out []float64) {
+	e.block = in
+	for i := range out {
+		out[i] = e.out(synth.AtFrame(e.start+i, e.rate))
+	}
+	e.start += len(in)
+}
+
+func (e *effect) Reset() {
+	e.start = 0
+	e.out = e.fn(func(x time.Duration) float64 {
+		i := synth.FrameAt(x, e.rate) - e.start
+		if i < 0 || i >= len(e.block) {
+			return 0 // Outside of the block (effects only read the current frame).
+		}
+		return e.block[i]
+	})
+}
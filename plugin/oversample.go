@@ -0,0 +1,110 @@
+package plugin
+
+import (
+	"math"
+)
+
+// OversampleLatency is the delay of the output of Oversample (in frames at the original rate),
+// from its anti-aliasing filters.
+const OversampleLatency = 64
+
+// oversampler runs a processor at a multiple of the sample rate.
+type oversampler struct {
+	n      int
+	p      Processor
+	kernel []float64 // Low-pass filter at the high rate, for both interpolation and decimation.
+
+	// Frames of the previous blocks needed by the filters (at the original and the high rate),
+	// the current block is appended to them while it is processed.
+	low, high []float64
+	up, down  []float64 // Frames at the high rate, before and after the processor.
+}
+
+// Oversample returns a processor running p at n times the sample rate (like 2 or 4, 1 runs p as is):
+// blocks are upsampled, processed by p and downsampled back, with steep low-pass filters against aliasing.
+// Nonlinear stages (distortion, ring modulation) add harmonics beyond the Nyquist frequency,
+// which are folded back into the audible range as inharmonic aliases unless they are generated at a higher rate
+// and filtered out first.
+//
+// The filters are linear phase: the output is delayed by OversampleLatency frames.
+func Oversample(n int, p Processor) Processor {
+	if n <= 1 {
+		return p
+	}
+	return &oversampler{n: n, p: p, kernel: lowPassKernel(n)}
+}
+
+// lowPassKernel returns a windowed sinc filter (with a Blackman window) for the rate multiplied by n,
+// cutting just below the Nyquist frequency of the original rate.
+// It spans half of OversampleLatency on each side, which is its latency.
+func lowPassKernel(n int) []float64 {
+	half := OversampleLatency / 2 * n
+	cutoff := 0.46 / float64(n) // In cycles per frame at the high rate.
+	kernel := make([]float64, 2*half+1)
+	var sum float64
+	for i := range kernel {
+		t := float64(i - half)
+		sinc := 1.0
+		if t != 0 {
+			sinc = math.Sin(2*math.Pi*cutoff*t) / (2 * math.Pi * cutoff * t)
+		}
+		phase := 2 * math.Pi * float64(i) / float64(len(kernel)-1)
+		blackman := 0.42 - 0.5*math.Cos(phase) + 0.08*math.Cos(2*phase)
+		kernel[i] = sinc * blackman
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+	return kernel
+}
+
+func (o *oversampler) Prepare(rate, maxBlock int) error {
+	taps := len(o.kernel)
+	history := (taps + o.n - 1) / o.n // Of frames at the original rate, for the interpolation.
+	o.low = make([]float64, history-1, history-1+maxBlock)
+	o.high = make([]float64, taps-1, taps-1+o.n*maxBlock)
+	o.up, o.down = make([]float64, o.n*maxBlock), make([]float64, o.n*maxBlock)
+	return o.p.Prepare(rate*o.n, o.n*maxBlock)
+}
+
+func (o *oversampler) Process(in, out []float64) {
+	n, k := o.n, o.kernel
+	history := len(o.low)
+	low := append(o.low, in...)
+
+	// Interpolation: the input with n-1 zeros between frames, filtered (polyphase, skipping the zeros).
+	up := o.up[:n*len(in)]
+	for i := range in {
+		for phase := 0; phase < n; phase++ {
+			var v float64
+			for tap, j := phase, history+i; tap < len(k) && j >= 0; tap, j = tap+n, j-1 {
+				v += k[tap] * low[j]
+			}
+			up[i*n+phase] = float64(n) * v
+		}
+	}
+	o.low = append(o.low[:0], low[len(low)-history:]...)
+
+	down := o.down[:len(up)]
+	o.p.Process(up, down)
+
+	// Decimation: filtered, keeping one frame out of n.
+	highHistory := len(o.high)
+	high := append(o.high, down...)
+	for i := range out {
+		var v float64
+		at := highHistory + i*n
+		for tap := range k {
+			v += k[tap] * high[at-tap]
+		}
+		out[i] = v
+	}
+	o.high = append(o.high[:0], high[len(high)-highHistory:]...)
+}
+
+func (o *oversampler) Reset() {
+	clear(o.low)
+	clear(o.high)
+	o.p.Reset()
+}
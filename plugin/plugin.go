@@ -0,0 +1,105 @@
+// Package plugin defines a stable interface for effects processing audio block by block (like audio plugins),
+// and hosts effects running as external processes, so third-party tools can be inserted into a chain of effects.
+// Processors are used from Go only: patches have no module for them, since they are dropped without being closed.
+package plugin
+
+import (
+	"errors"
+
+	"github.com/ejuju/poc-go-audio-synthesis/block"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Processor is an effect processing mono audio block by block.
+//
+// Prepare is called once before processing, with the sample rate and the largest block that will be processed.
+// Process then fills out with the processed frames of in (both have the same length, at most the prepared size),
+// blocks following each other in time. Reset clears the state of the effect (like the tail of a reverb),
+// as if no frames had been processed since Prepare.
+type Processor interface {
+	Prepare(rate, maxBlock int) error
+	Process(in, out []float64)
+	Reset()
+}
+
+// chain processes blocks through processors in turn.
+type chain struct {
+	processors []Processor
+	buf        []float64
+}
+
+// Chain returns a processor running the given processors in series.
+func Chain(processors ...Processor) Processor {
+	return &chain{processors: processors}
+}
+
+func (c *chain) Prepare(rate, maxBlock int) error {
+	c.buf = make([]float64, maxBlock)
+	var errs []error
+	for _, p := range c.processors {
+		errs = append(errs, p.Prepare(rate, maxBlock))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *chain) Process(in, out []float64) {
+	if len(c.processors) == 0 {
+		copy(out, in)
+		return
+	}
+	tmp := c.buf[:len(in)]
+	copy(tmp, in)
+	for _, p := range c.processors {
+		p.Process(tmp, out)
+		copy(tmp, out)
+	}
+}
+
+func (c *chain) Reset() {
+	for _, p := range c.processors {
+		p.Reset()
+	}
+}
+
+// source processes the blocks of a source.
+type source struct {
+	p    Processor
+	in   block.Source
+	buf  []float64
+	next int // Frame expected in the next block.
+}
+
+// Source prepares the processor for the rate and blocks of the given size,
+// and returns a source of the frames of in processed by it.
+// The processor is reset when blocks aren't contiguous, like stateful sources.
+func Source(p Processor, in block.Source, rate, size int) (block.Source, error) {
+	err := p.Prepare(rate, size)
+	if err != nil {
+		return nil, err
+	}
+	return &source{p: p, in: in, buf: make([]float64, size)}, nil
+}
+
+func (s *source) Process(out []float64, start int) {
+	if start != s.next {
+		s.p.Reset()
+	}
+	for len(out) > 0 { // In blocks of at most the prepared size.
+		n := min(len(out), len(s.buf))
+		in := s.buf[:n]
+		s.in.Process(in, start)
+		s.p.Process(in, out[:n])
+		out, start = out[n:], start+n
+	}
+	s.next = start
+}
+
+// Apply returns the signal processed by the processor, in blocks of the given size at the given rate
+// (see block.ToSignal, the signal must be sampled frame after frame).
+func Apply(p Processor, in synth.Signal, rate, size int) (synth.Signal, error) {
+	src, err := Source(p, block.FromSignal(in, rate), rate, size)
+	if err != nil {
+		return nil, err
+	}
+	return block.ToSignal(src, rate, size), nil
+}
@@ -0,0 +1,93 @@
+// Package render renders several independent tracks concurrently and mixes them down.
+package render
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Track is an independent part of a song.
+//
+// Tracks are rendered concurrently, so they must not share stateful signals
+// (an oscillator or a filter used by two tracks must be built twice).
+type Track struct {
+	Name   string
+	Signal synth.Signal
+	Gain   float64 // In decibels, applied when mixing down.
+}
+
+// Options configures a render.
+type Options struct {
+	Rate     int           // In frames per second.
+	From, To time.Duration // Start (included) and end (excluded) of the render.
+	Workers  int           // Number of tracks rendered at once, the number of CPUs if zero.
+
+	// Progress, if set, is called regularly with the number of frames rendered so far (over all tracks)
+	// and the total number of frames to render. Calls never happen concurrently.
+	Progress func(done, total int)
+}
+
+// blockSize is the number of frames rendered between progress reports.
+const blockSize = 1 << 14
+
+// Render renders all tracks and returns their mix.
+func Render(tracks []Track, opts Options) (mix []float64) {
+	n := synth.FrameCount(0, opts.To-opts.From, opts.Rate)
+	mix = make([]float64, n)
+	for i, frames := range RenderTracks(tracks, opts) {
+		gain := synth.DBToAmp(tracks[i].Gain)
+		for j, v := range frames {
+			mix[j] += gain * v
+		}
+	}
+	return mix
+}
+
+// RenderTracks renders all tracks and returns the frames of each one of them (without applying their gain).
+func RenderTracks(tracks []Track, opts Options) (frames [][]float64) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	n := synth.FrameCount(0, opts.To-opts.From, opts.Rate)
+	total := n * len(tracks)
+
+	var mu sync.Mutex
+	done := 0
+	report := func(frames int) {
+		if opts.Progress == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		done += frames
+		opts.Progress(done, total)
+	}
+
+	frames = make([][]float64, len(tracks))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(tracks)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				frames[i] = make([]float64, n)
+				st := synth.SampleStream(tracks[i].Signal, opts.Rate, opts.From, opts.To-opts.From)
+				for start := 0; start < n; start += blockSize {
+					read, _ := st.Read(frames[i][start:min(start+blockSize, n)])
+					report(read)
+				}
+			}
+		}()
+	}
+	for i := range tracks {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return frames
+}
@@ -0,0 +1,79 @@
+// Package resample converts audio frames from a sample rate to another,
+// for example to play 48 kHz samples in a 44.1 kHz render or to export a mix at another rate.
+package resample
+
+import (
+	"math"
+
+	"github.com/ejuju/poc-go-audio-synthesis/decode"
+)
+
+// Quality chooses the interpolation used to compute frames between the original ones.
+type Quality int
+
+const (
+	Sinc   Quality = iota // Windowed sinc interpolation: slower, but without audible aliasing or dulling.
+	Linear                // Linear interpolation: fast, but aliases and slightly dulls high frequencies.
+)
+
+// zeroCrossings is the number of zero crossings of each side of the sinc kernel (at the lower of both rates).
+const zeroCrossings = 16
+
+// Frames returns the frames (sampled at the rate from) sampled at the rate to.
+func Frames(frames []float64, from, to int, q Quality) []float64 {
+	if from == to || len(frames) == 0 || from <= 0 || to <= 0 {
+		return append([]float64(nil), frames...)
+	}
+	n := int(math.Ceil(float64(len(frames)) * float64(to) / float64(from)))
+	out := make([]float64, n)
+	step := float64(from) / float64(to) // Input frames per output frame.
+	for j := range out {
+		t := float64(j) * step
+		if q == Linear {
+			out[j] = linear(frames, t)
+		} else {
+			out[j] = sinc(frames, t, math.Min(1, 1/step))
+		}
+	}
+	return out
+}
+
+// Audio returns the audio sampled at the given rate.
+func Audio(a *decode.Audio, rate int, q Quality) *decode.Audio {
+	channels := make([][]float64, len(a.Channels))
+	for c, ch := range a.Channels {
+		channels[c] = Frames(ch, a.Rate, rate, q)
+	}
+	return &decode.Audio{Rate: rate, Channels: channels}
+}
+
+// linear returns the frames interpolated linearly at position t.
+func linear(frames []float64, t float64) float64 {
+	i := int(t)
+	frac := t - float64(i)
+	a, b := frames[min(i, len(frames)-1)], frames[min(i+1, len(frames)-1)]
+	return a + (b-a)*frac
+}
+
+// sinc returns the frames interpolated at position t by a Blackman-windowed sinc,
+// low-passed at the given portion of the Nyquist frequency of the frames (below 1 when downsampling).
+func sinc(frames []float64, t, scale float64) float64 {
+	cutoff := 0.95 * scale
+	half := zeroCrossings / scale // Half width of the kernel, in input frames.
+	var sum float64
+	for i := max(0, int(math.Ceil(t-half))); i <= min(len(frames)-1, int(math.Floor(t+half))); i++ {
+		d := t - float64(i)
+		u := d / half
+		window := 0.42 + 0.5*math.Cos(math.Pi*u) + 0.08*math.Cos(2*math.Pi*u)
+		sum += frames[i] * cutoff * normalizedSinc(cutoff*d) * window
+	}
+	return sum
+}
+
+// normalizedSinc returns sin(πx)/(πx).
+func normalizedSinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
@@ -0,0 +1,122 @@
+package sampler
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/decode"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Granular plays recorded audio as a cloud of short, overlapping grains,
+// to stretch, freeze, scrub or blur the audio into textures.
+//
+// All parameters are signals, read when each grain starts (so a grain keeps its settings until it ends).
+// Nil signals use the default values.
+type Granular struct {
+	Audio *decode.Audio
+
+	Size     synth.Signal // Grain duration, in seconds (0.05 by default).
+	Density  synth.Signal // Number of grains started per second (20 by default).
+	Position synth.Signal // Where grains start in the audio, from 0 (start) to 1 (end) (0 by default).
+	Pitch    synth.Signal // Playback speed of grains: 1 (default) for the original pitch, 2 an octave higher.
+	Jitter   synth.Signal // Random offset of the start of grains, as a fraction of the audio (0 by default).
+	Shape    synth.Signal // Part of the grain faded in and out, from 0 (rectangular) to 1 (Hann window, the default).
+
+	Voices int   // Maximum number of grains playing at once (32 if zero), extra grains are skipped.
+	Seed   int64 // Seed of the jitter.
+}
+
+// NewGranular returns a granular player with the default parameters.
+func NewGranular(a *decode.Audio) *Granular {
+	return &Granular{Audio: a}
+}
+
+type grain struct {
+	pos     float64 // Position in the audio, in frames.
+	speed   float64 // In frames of the audio per second.
+	elapsed float64 // In seconds.
+	length  float64 // In seconds.
+	shape   float64
+}
+
+// Play returns the mix of the grains: the level of the output grows with the overlap (density × size),
+// so dense clouds need less gain.
+func (g *Granular) Play() synth.Signal {
+	frames := g.Audio.Mono()
+	rate := float64(g.Audio.Rate)
+	orDefault := func(s synth.Signal, v float64) synth.Signal {
+		if s == nil {
+			return synth.Constant(v)
+		}
+		return s
+	}
+	size, density := orDefault(g.Size, 0.05), orDefault(g.Density, 20)
+	position, pitch := orDefault(g.Position, 0), orDefault(g.Pitch, 1)
+	jitter, shape := orDefault(g.Jitter, 0), orDefault(g.Shape, 1)
+	voices := g.Voices
+	if voices <= 0 {
+		voices = 32
+	}
+
+	return synth.Stateful(func() func(x time.Duration, dt float64) float64 {
+		rng := rand.New(rand.NewSource(g.Seed))
+		grains := make([]grain, 0, voices)
+		next := 1.0 // Grains to start (a grain starts at the first frame).
+		return func(x time.Duration, dt float64) float64 {
+			next += dt * math.Max(0, density(x))
+			for ; next >= 1; next-- {
+				if len(grains) == voices {
+					continue
+				}
+				start := position(x) + jitter(x)*(2*rng.Float64()-1)
+				grains = append(grains, grain{
+					pos:    start * float64(len(frames)),
+					speed:  pitch(x) * rate,
+					length: math.Max(size(x), 1e-3),
+					shape:  math.Max(0, math.Min(shape(x), 1)),
+				})
+			}
+
+			var out float64
+			for i := 0; i < len(grains); i++ {
+				gr := &grains[i]
+				if gr.elapsed >= gr.length {
+					grains[i] = grains[len(grains)-1]
+					grains = grains[:len(grains)-1]
+					i--
+					continue
+				}
+				out += grainEnvelope(gr.elapsed/gr.length, gr.shape) * interpolate(frames, gr.pos)
+				gr.pos += gr.speed * dt
+				gr.elapsed += dt
+			}
+			return out
+		}
+	})
+}
+
+// grainEnvelope returns the level at t (from 0 to 1) of a grain with half-cosine fades over the given part of it.
+func grainEnvelope(t, shape float64) float64 {
+	fade := shape / 2
+	switch {
+	case fade == 0:
+		return 1
+	case t < fade:
+		return 0.5 - 0.5*math.Cos(math.Pi*t/fade)
+	case t > 1-fade:
+		return 0.5 - 0.5*math.Cos(math.Pi*(1-t)/fade)
+	}
+	return 1
+}
+
+// interpolate returns the frame at a fractional position, 0 outside the audio.
+func interpolate(frames []float64, pos float64) float64 {
+	if pos < 0 || pos > float64(len(frames)-1) {
+		return 0
+	}
+	i := int(pos)
+	t := pos - float64(i)
+	return (1-t)*frames[i] + t*frames[min(i+1, len(frames)-1)]
+}
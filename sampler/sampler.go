@@ -0,0 +1,111 @@
+// Package sampler plays recorded audio (like drum hits or instrument notes) as signals.
+package sampler
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/decode"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Sampler plays a region of recorded audio, optionally looping part of it.
+type Sampler struct {
+	Audio *decode.Audio
+
+	// Speed is the playback rate: 1 plays at the original pitch, 2 an octave higher, etc.
+	// It is always 1 if nil.
+	Speed synth.Signal
+
+	// Start and End delimit the region that is played (End is the end of the audio if zero).
+	Start, End time.Duration
+
+	// While the gate is open, playback loops between LoopStart and LoopEnd (the end of the region if zero).
+	Loop               bool
+	LoopStart, LoopEnd time.Duration
+}
+
+// New returns a sampler playing the whole audio at its original speed.
+func New(a *decode.Audio) *Sampler {
+	return &Sampler{Audio: a}
+}
+
+// LoadWAV returns a sampler playing the given WAV file.
+func LoadWAV(path string) (*Sampler, error) {
+	a, err := decode.LoadWAV(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(a), nil
+}
+
+// Play returns the mono mix of the sample, played from the start of the region each time the gate opens.
+// The sample plays until the end of the region, even if the gate closes before (like a drum hit),
+// except for the loop that only repeats while the gate is open.
+func (s *Sampler) Play(gate synth.Signal) synth.Signal {
+	mono := &decode.Audio{Rate: s.Audio.Rate, Channels: [][]float64{s.Audio.Mono()}}
+	return s.read(mono, 0, s.position(gate))
+}
+
+// PlayChannels is like Play for each channel of the audio.
+func (s *Sampler) PlayChannels(gate synth.Signal) synth.MultiSignal {
+	pos := s.position(gate)
+	ms := make(synth.MultiSignal, len(s.Audio.Channels))
+	for c := range ms {
+		ms[c] = s.read(s.Audio, c, pos)
+	}
+	return ms
+}
+
+// read returns the given channel at the playback position (in frames, negative when stopped).
+func (s *Sampler) read(a *decode.Audio, c int, pos synth.Signal) synth.Signal {
+	frames := a.Channels[c]
+	return func(x time.Duration) float64 {
+		p := pos(x)
+		if p < 0 || len(frames) == 0 {
+			return 0
+		}
+		i := int(p)
+		t := p - float64(i)
+		next := frames[min(i+1, len(frames)-1)]
+		return (1-t)*frames[min(i, len(frames)-1)] + t*next
+	}
+}
+
+// position returns the playback position (in frames of the audio, negative when stopped).
+func (s *Sampler) position(gate synth.Signal) synth.Signal {
+	toFrames := func(d time.Duration) float64 { return d.Seconds() * float64(s.Audio.Rate) }
+	length := float64(s.Audio.Len())
+	start, end := toFrames(s.Start), length
+	if s.End > 0 {
+		end = math.Min(length, toFrames(s.End))
+	}
+	loopStart, loopEnd := toFrames(s.LoopStart), end
+	if s.LoopEnd > 0 {
+		loopEnd = math.Min(end, toFrames(s.LoopEnd))
+	}
+	speed := s.Speed
+	if speed == nil {
+		speed = synth.Constant(1)
+	}
+
+	return synth.Stateful(func() func(x time.Duration, dt float64) float64 {
+		pos, wasOpen := -1.0, false
+		return func(x time.Duration, dt float64) float64 {
+			open := gate(x) > 0
+			if open && !wasOpen {
+				pos = start
+			} else if pos >= 0 {
+				pos += dt * float64(s.Audio.Rate) * speed(x)
+				if open && s.Loop && pos >= loopEnd && loopEnd > loopStart {
+					pos = loopStart + math.Mod(pos-loopStart, loopEnd-loopStart)
+				}
+				if pos >= end || pos < 0 {
+					pos = -1
+				}
+			}
+			wasOpen = open
+			return pos
+		}
+	})
+}
@@ -0,0 +1,336 @@
+package sampler
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/decode"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Instrument is a multi-sampled instrument: samples mapped to ranges of keys, each played at the pitch of the notes
+// relative to its root key. Its Voice method plays it with the sequencer or MIDI input (see seq.VoiceFunc).
+type Instrument struct {
+	Regions []Region
+}
+
+// Region is a sample of an instrument and the keys it plays.
+type Region struct {
+	Audio           *decode.Audio
+	LowKey, HighKey int     // MIDI note numbers, included.
+	RootKey         float64 // MIDI note number at which the sample plays at its original speed.
+	Tune            float64 // In cents, positive values raising the pitch.
+	Volume          float64 // In decibels.
+	Offset          int     // First frame played.
+	Loop            LoopMode
+	LoopStart       int           // In frames.
+	LoopEnd         int           // In frames, included (the last frame if zero).
+	Release         time.Duration // Fade out when the gate closes (except for one-shot regions).
+
+	mono []float64 // Mix of the channels of the audio, computed once when parsed.
+}
+
+// LoopMode sets how a region plays, after the SFZ loop_mode opcode.
+type LoopMode int
+
+const (
+	NoLoop         LoopMode = iota // Plays once, faded out when the gate closes.
+	LoopContinuous                 // Loops until the end of the release.
+	LoopSustain                    // Loops while the gate is open, then plays to the end.
+	OneShot                        // Plays to the end whatever the gate.
+)
+
+// LoadSFZ loads an instrument from an SFZ file, loading its samples (WAV files) relative to it.
+//
+// Only a subset of SFZ is supported: the <control>, <global>, <group> and <region> headers
+// and the sample, lokey, hikey, key, pitch_keycenter, tune, transpose, volume, offset, loop_mode, loop_start, loop_end
+// and ampeg_release opcodes (other opcodes, like velocity ranges, are ignored).
+func LoadSFZ(path string) (*Instrument, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	in, err := ParseSFZ(string(b), filepath.Dir(path), decode.LoadWAV)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return in, nil
+}
+
+// ParseSFZ parses an SFZ instrument (see LoadSFZ), loading the samples with load from their path joined to the directory.
+func ParseSFZ(text, dir string, load func(path string) (*decode.Audio, error)) (*Instrument, error) {
+	var global, group, region map[string]string
+	var control map[string]string
+	current := &control
+	in := &Instrument{}
+	loaded := map[string]*decode.Audio{}
+	mono := map[string][]float64{}
+	flush := func() error {
+		if region == nil {
+			return nil
+		}
+		opcodes := map[string]string{}
+		for _, m := range []map[string]string{global, group, region} {
+			for k, v := range m {
+				opcodes[k] = v
+			}
+		}
+		region = nil
+		r, err := parseRegion(opcodes)
+		if err != nil {
+			return err
+		}
+		sample := strings.ReplaceAll(opcodes["sample"], `\`, "/")
+		if sample == "" {
+			return fmt.Errorf("region without sample")
+		}
+		path := filepath.Join(dir, control["default_path"], sample)
+		if loaded[path] == nil {
+			loaded[path], err = load(path)
+			if err != nil {
+				return err
+			}
+			mono[path] = loaded[path].Mono()
+		}
+		r.Audio, r.mono = loaded[path], mono[path]
+		in.Regions = append(in.Regions, r)
+		return nil
+	}
+
+	for _, token := range tokenizeSFZ(text) {
+		if strings.HasPrefix(token, "<") {
+			err := flush()
+			if err != nil {
+				return nil, err
+			}
+			switch token {
+			case "<control>":
+				current = &control
+			case "<global>":
+				global, group, current = nil, nil, &global
+			case "<group>", "<master>":
+				group, current = nil, &group
+			case "<region>":
+				current = &region
+			default:
+				current = nil // Unsupported header, its opcodes are ignored.
+				continue
+			}
+			*current = map[string]string{}
+			continue
+		}
+		if current == nil || *current == nil {
+			continue
+		}
+		k, v, _ := strings.Cut(token, "=")
+		(*current)[k] = v
+	}
+	err := flush()
+	if err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// tokenizeSFZ returns the headers and the opcodes (as "opcode=value") of an SFZ file, without comments.
+// Values run until the next opcode or header, so sample paths can contain spaces.
+func tokenizeSFZ(text string) (tokens []string) {
+	for {
+		start, end := strings.Index(text, "/*"), 0
+		if start < 0 {
+			break
+		}
+		end = strings.Index(text[start:], "*/")
+		if end < 0 {
+			text = text[:start]
+			break
+		}
+		text = text[:start] + " " + text[start+end+2:]
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		for _, word := range strings.Fields(strings.NewReplacer("<", " <", ">", "> ").Replace(line)) {
+			switch {
+			case strings.HasPrefix(word, "<"):
+				tokens = append(tokens, word)
+			case strings.Contains(word, "=") || len(tokens) == 0 || strings.HasPrefix(tokens[len(tokens)-1], "<"):
+				tokens = append(tokens, word)
+			default:
+				tokens[len(tokens)-1] += " " + word // Continuation of a value with spaces.
+			}
+		}
+	}
+	return tokens
+}
+
+// parseRegion reads the opcodes of a region.
+func parseRegion(opcodes map[string]string) (r Region, err error) {
+	r.LowKey, r.HighKey = 0, 127
+	number := func(k string, v *float64) {
+		if s, ok := opcodes[k]; ok && err == nil {
+			*v, err = strconv.ParseFloat(s, 64)
+			if err != nil {
+				err = fmt.Errorf("opcode %s: %w", k, err)
+			}
+		}
+	}
+	key := func(k string, v *int) {
+		if s, ok := opcodes[k]; ok && err == nil {
+			*v, err = parseKey(s)
+			if err != nil {
+				err = fmt.Errorf("opcode %s: %w", k, err)
+			}
+		}
+	}
+	root := 60
+	if _, ok := opcodes["key"]; ok {
+		key("key", &root) // Shorthand for a single key played at its original pitch.
+		r.LowKey, r.HighKey = root, root
+	}
+	key("lokey", &r.LowKey)
+	key("hikey", &r.HighKey)
+	key("pitch_keycenter", &root)
+	r.RootKey = float64(root)
+
+	var transpose, offset, loopStart, loopEnd, release float64
+	number("tune", &r.Tune)
+	number("transpose", &transpose)
+	number("volume", &r.Volume)
+	number("offset", &offset)
+	number("loop_start", &loopStart)
+	number("loopstart", &loopStart)
+	number("loop_end", &loopEnd)
+	number("loopend", &loopEnd)
+	number("ampeg_release", &release)
+	if err != nil {
+		return r, err
+	}
+	r.RootKey -= transpose
+	r.Offset, r.LoopStart, r.LoopEnd = int(offset), int(loopStart), int(loopEnd)
+	r.Release = time.Duration(release * float64(time.Second))
+
+	mode := opcodes["loop_mode"]
+	if mode == "" {
+		mode = opcodes["loopmode"]
+	}
+	switch mode {
+	case "", "no_loop":
+		r.Loop = NoLoop
+	case "loop_continuous":
+		r.Loop = LoopContinuous
+	case "loop_sustain":
+		r.Loop = LoopSustain
+	case "one_shot":
+		r.Loop = OneShot
+	default:
+		return r, fmt.Errorf("unknown loop_mode %q", mode)
+	}
+	return r, nil
+}
+
+// parseKey parses a MIDI note number or a note name like "c#4" (c4 being 60).
+func parseKey(s string) (int, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, nil
+	}
+	s = strings.ToLower(s)
+	semitones := map[byte]int{'c': 0, 'd': 2, 'e': 4, 'f': 5, 'g': 7, 'a': 9, 'b': 11}
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid key %q", s)
+	}
+	n, ok := semitones[s[0]]
+	if !ok {
+		return 0, fmt.Errorf("invalid key %q", s)
+	}
+	rest := s[1:]
+	switch rest[0] {
+	case '#':
+		n, rest = n+1, rest[1:]
+	case 'b':
+		n, rest = n-1, rest[1:]
+	}
+	octave, err := strconv.Atoi(rest)
+	if err != nil {
+		return 0, fmt.Errorf("invalid key %q", s)
+	}
+	return n + 12*(octave+1), nil
+}
+
+// Region returns the region playing the key (a MIDI note number), nil if there is none.
+// Among overlapping regions, the first one is used.
+func (in *Instrument) Region(key int) *Region {
+	for i := range in.Regions {
+		if r := &in.Regions[i]; key >= r.LowKey && key <= r.HighKey {
+			return r
+		}
+	}
+	return nil
+}
+
+// Voice plays the instrument at the given frequency (in Hertz) while the gate is open.
+// The region is chosen from the frequency (as a key in 12-tone equal temperament) each time the gate opens.
+func (in *Instrument) Voice(freq, gate synth.Signal) synth.Signal {
+	mono := make(map[*Region][]float64, len(in.Regions))
+	for i := range in.Regions {
+		if r := &in.Regions[i]; r.mono != nil {
+			mono[r] = r.mono
+		} else {
+			mono[r] = r.Audio.Mono() // Regions made by hand rather than parsed.
+		}
+	}
+	return synth.Stateful(func() func(x time.Duration, dt float64) float64 {
+		var r *Region
+		var frames []float64
+		var pos, level float64
+		wasOpen := false
+		return func(x time.Duration, dt float64) float64 {
+			f, open := freq(x), gate(x) > 0
+			if open && !wasOpen && f > 0 {
+				key := 69 + 12*math.Log2(f/440)
+				r = in.Region(int(math.Round(key)))
+				if r != nil {
+					frames, pos, level = mono[r], float64(r.Offset), 1
+				}
+				if r != nil && r.Offset >= len(frames) {
+					r = nil
+				}
+			} else if r != nil && dt > 0 {
+				speed := f / 440 * math.Exp2((69-r.RootKey)/12+r.Tune/1200)
+				pos += dt * float64(r.Audio.Rate) * speed
+				loopEnd := float64(r.LoopEnd) + 1
+				if r.LoopEnd == 0 {
+					loopEnd = float64(len(frames))
+				}
+				looping := r.Loop == LoopContinuous || r.Loop == LoopSustain && open
+				if looping && pos >= loopEnd && loopEnd > float64(r.LoopStart) {
+					pos = float64(r.LoopStart) + math.Mod(pos-float64(r.LoopStart), loopEnd-float64(r.LoopStart))
+				}
+				if !open && r.Loop != OneShot {
+					if r.Release <= 0 {
+						level = 0
+					} else {
+						level -= dt / r.Release.Seconds()
+					}
+				}
+				if pos >= float64(len(frames)) || level <= 0 {
+					r = nil
+				}
+			}
+			wasOpen = open
+			if r == nil {
+				return 0
+			}
+			i := int(pos)
+			t := pos - float64(i)
+			v := (1-t)*frames[i] + t*frames[min(i+1, len(frames)-1)]
+			return v * level * synth.DBToAmp(r.Volume)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package sampler
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/analysis"
+	"github.com/ejuju/poc-go-audio-synthesis/decode"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Slice is a region of recorded audio.
+type Slice struct {
+	Start, End time.Duration
+}
+
+// Slicer cuts recorded audio (like a drum break) into slices that are triggered on their own,
+// so they can be rearranged by the sequencer: each slice is played by a key, from RootKey up.
+type Slicer struct {
+	Audio   *decode.Audio
+	Slices  []Slice
+	RootKey int           // MIDI note playing the first slice, 0 means 36 (C2, the first pad of drum machines).
+	Fade    time.Duration // Fade out at the end of slices so they don't click, 0 means 2 ms.
+}
+
+// minSlice is the shortest slice made from the audio before the first onset.
+const minSlice = 10 * time.Millisecond
+
+// NewSlicer cuts the audio at its onsets (see analysis.Onsets).
+func NewSlicer(a *decode.Audio) *Slicer {
+	toDuration := func(frame int) time.Duration { return synth.AtFrame(frame, a.Rate) }
+	end := toDuration(a.Len())
+	var starts []time.Duration
+	for _, onset := range analysis.Onsets(a.Mono(), a.Rate) {
+		starts = append(starts, toDuration(onset))
+	}
+	if len(starts) == 0 || starts[0] >= minSlice {
+		starts = append([]time.Duration{0}, starts...)
+	} else {
+		starts[0] = 0
+	}
+	s := &Slicer{Audio: a}
+	for i, start := range starts {
+		next := end
+		if i+1 < len(starts) {
+			next = starts[i+1]
+		}
+		s.Slices = append(s.Slices, Slice{Start: start, End: next})
+	}
+	return s
+}
+
+// SliceEvenly cuts the audio in n slices of the same length, like the steps of a loop in time.
+func SliceEvenly(a *decode.Audio, n int) *Slicer {
+	s := &Slicer{Audio: a}
+	length := synth.AtFrame(a.Len(), a.Rate)
+	for i := 0; i < n; i++ {
+		s.Slices = append(s.Slices, Slice{Start: length * time.Duration(i) / time.Duration(n), End: length * time.Duration(i+1) / time.Duration(n)})
+	}
+	return s
+}
+
+// Sampler returns a sampler playing the i-th slice.
+func (s *Slicer) Sampler(i int) *Sampler {
+	return &Sampler{Audio: s.Audio, Start: s.Slices[i].Start, End: s.Slices[i].End}
+}
+
+// Key returns the MIDI note playing the i-th slice with Voice, for the pitch of sequenced notes.
+func (s *Slicer) Key(i int) float64 {
+	root := s.RootKey
+	if root == 0 {
+		root = 36
+	}
+	return float64(root + i)
+}
+
+// Voice plays the slice of the key of the frequency (in 12-tone equal temperament) each time the gate opens,
+// until its end (like a drum hit), at its original speed. It is a voice for the sequencer or the live input.
+func (s *Slicer) Voice(freq, gate synth.Signal) synth.Signal {
+	frames := s.Audio.Mono()
+	rate := float64(s.Audio.Rate)
+	fade := s.Fade
+	if fade <= 0 {
+		fade = 2 * time.Millisecond
+	}
+	return synth.Stateful(func() func(x time.Duration, dt float64) float64 {
+		var pos, end float64
+		playing, wasOpen := false, false
+		return func(x time.Duration, dt float64) float64 {
+			f, open := freq(x), gate(x) > 0
+			if open && !wasOpen && f > 0 {
+				i := int(math.Round(69+12*math.Log2(f/440))) - int(s.Key(0))
+				playing = i >= 0 && i < len(s.Slices)
+				if playing {
+					pos = s.Slices[i].Start.Seconds() * rate
+					end = math.Min(s.Slices[i].End.Seconds()*rate, float64(len(frames)))
+				}
+			} else if playing {
+				pos += dt * rate
+			}
+			wasOpen = open
+			if !playing || pos >= end-1 {
+				playing = false
+				return 0
+			}
+			level := math.Min((end-pos)/(fade.Seconds()*rate), 1)
+			j := int(pos)
+			t := pos - float64(j)
+			return level * ((1-t)*frames[j] + t*frames[j+1])
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package seq
+
+import (
+	"cmp"
+	"math/rand"
+	"slices"
+)
+
+// ArpMode is the order in which an arpeggiator plays the held notes.
+type ArpMode int
+
+const (
+	ArpUp     ArpMode = iota // From the lowest note to the highest.
+	ArpDown                  // From the highest note to the lowest.
+	ArpUpDown                // Up then down, without repeating the highest and lowest notes.
+	ArpRandom                // Randomly.
+	ArpPlayed                // In the order the notes started.
+)
+
+// Arpeggiator turns held notes (like chords) into a sequence of single notes on a clock.
+type Arpeggiator struct {
+	Mode    ArpMode
+	Octaves int     // Number of octaves covered by the arpeggio, 0 means 1.
+	Step    float64 // Time between notes, in beats, 0 means a sixteenth note (0.25).
+	Gate    float64 // Portion of the step during which a note is held, 0 means 0.5.
+	Seed    int64   // Seed of the random source of ArpRandom.
+}
+
+// Notes returns the arpeggio played while the given notes are held.
+// A note is played at each step of the clock (multiples of Step from beat 0) where at least one note is held,
+// with the velocity of the held note it comes from. The result can be played by a Sequence or a Poly.
+func (a Arpeggiator) Notes(held []Note) []Note {
+	step, gate := or(a.Step, 0.25), or(a.Gate, 0.5)
+	octaves := max(a.Octaves, 1)
+	rng := rand.New(rand.NewSource(a.Seed))
+	held = slices.Clone(held)
+	SortNotes(held)
+
+	var notes []Note
+	count := 0 // Number of notes played since the chord started.
+	end := Length(held)
+	for i := 0; float64(i)*step < end; i++ {
+		beat := float64(i) * step
+		var chord []Note
+		for _, n := range held {
+			if n.Start <= beat && beat < n.End() {
+				chord = append(chord, n)
+			}
+		}
+		if len(chord) == 0 {
+			count = 0
+			continue
+		}
+		order := a.order(chord, octaves)
+		var n Note
+		if a.Mode == ArpRandom {
+			n = order[rng.Intn(len(order))]
+		} else {
+			n = order[count%len(order)]
+		}
+		notes = append(notes, Note{Start: beat, Duration: gate * step, Pitch: n.Pitch, Velocity: n.Velocity, Channel: n.Channel})
+		count++
+	}
+	return notes
+}
+
+// order returns the held notes extended over the octaves, in the order of one cycle of the arpeggio.
+func (a Arpeggiator) order(chord []Note, octaves int) []Note {
+	if a.Mode != ArpPlayed {
+		slices.SortStableFunc(chord, func(x, y Note) int { return cmp.Compare(x.Pitch, y.Pitch) })
+	}
+	var order []Note
+	for o := 0; o < octaves; o++ {
+		for _, n := range chord {
+			n.Pitch += float64(12 * o)
+			order = append(order, n)
+		}
+	}
+	switch a.Mode {
+	case ArpDown:
+		slices.Reverse(order)
+	case ArpUpDown:
+		for i := len(order) - 2; i > 0; i-- {
+			order = append(order, order[i])
+		}
+	}
+	return order
+}
@@ -0,0 +1,36 @@
+package seq
+
+// Euclid returns a pattern of steps spreading the pulses as evenly as possible over the steps
+// (using Bjorklund's algorithm), rotated left by the given number of steps.
+// Many traditional rhythms are Euclidean: Euclid(3, 8, 0) is the tresillo "x..x..x.".
+func Euclid(pulses, steps, rotation int) []Step {
+	if steps <= 0 {
+		return nil
+	}
+	pulses = max(0, min(pulses, steps))
+
+	// Start with pulses groups [x] and steps-pulses groups [.], then repeatedly append the remainder groups
+	// to the others until at most one remainder group is left.
+	groups := make([][]bool, 0, steps)
+	for i := 0; i < steps; i++ {
+		groups = append(groups, []bool{i < pulses})
+	}
+	head, tail := pulses, steps-pulses // Number of leading groups and trailing (remainder) groups.
+	for tail > 1 && head > 0 {
+		n := min(head, tail)
+		for i := 0; i < n; i++ {
+			groups[i] = append(groups[i], groups[len(groups)-n+i]...)
+		}
+		groups = groups[:len(groups)-n]
+		head, tail = n, len(groups)-n
+	}
+
+	pattern := make([]Step, 0, steps)
+	for _, g := range groups {
+		for _, on := range g {
+			pattern = append(pattern, Step{On: on})
+		}
+	}
+	r := ((rotation % steps) + steps) % steps
+	return append(pattern[r:], pattern[:r]...)
+}
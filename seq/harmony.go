@@ -0,0 +1,222 @@
+package seq
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Voicing is how the chords of a progression are arranged.
+type Voicing int
+
+const (
+	VoiceRoot    Voicing = iota // Every chord in root position above its root.
+	VoiceLeading                // Inversions and octaves moving each voice as little as possible from the previous chord.
+	VoiceDrop2                  // Like VoiceLeading, with the second highest note dropped an octave (an open voicing).
+)
+
+// Harmony plays a chord progression in a key, for a Poly.
+type Harmony struct {
+	Key         float64 // Root of the key (a MIDI note number), 0 means 60 (C4).
+	Scale       Scale   // Scale of the key, giving the roots of the degrees, nil means MajorScale.
+	Progression string  // Chords in Roman numerals (see ParseProgression).
+	Beats       float64 // Length of each chord, 0 means 4 (a bar).
+	Rhythm      string  // Mini-notation pattern over each chord (like "x ~ x x"), the chords are held if empty.
+	Voicing     Voicing
+	Bass        bool    // Adds the root of the chords in the octave below them.
+	Velocity    float64 // Between 0 and 1, 0 means 0.8 (scaled by the velocities of the rhythm).
+	Channel     int
+}
+
+// Notes returns the notes of the progression played the given number of times.
+func (h Harmony) Notes(loops int) ([]Note, error) {
+	chords, err := ParseProgression(or(h.Key, 60), h.Scale, h.Progression)
+	if err != nil {
+		return nil, err
+	}
+	voiced := VoiceChords(chords, h.Voicing)
+	beats, velocity := or(h.Beats, 4), or(h.Velocity, 0.8)
+	var rhythm *Mini
+	if h.Rhythm != "" {
+		m, err := ParseMini(h.Rhythm)
+		if err != nil {
+			return nil, err
+		}
+		m.Cycle, m.Gate = beats, 0.9
+		rhythm = &m
+	}
+	var hits []Note // Of the rhythm, with one cycle per chord.
+	if rhythm != nil {
+		hits = rhythm.Notes(loops * len(voiced))
+	}
+
+	var notes []Note
+	for i := 0; i < loops*len(voiced); i++ {
+		chord, root := voiced[i%len(voiced)], chords[i%len(chords)][0]
+		start := float64(i) * beats
+		pitches := slices.Clone(chord)
+		if h.Bass {
+			pitches = append(pitches, chord[0]-12+math.Mod(math.Mod(root-chord[0], 12)+12, 12))
+		}
+		play := func(start, duration, v float64) {
+			for _, n := range Notes(pitches, start, duration, v) {
+				n.Channel = h.Channel
+				notes = append(notes, n)
+			}
+		}
+		if rhythm == nil {
+			play(start, beats, velocity)
+			continue
+		}
+		for _, hit := range hits {
+			if hit.Start >= start && hit.Start < start+beats {
+				play(hit.Start, hit.Duration, velocity*hit.Velocity)
+			}
+		}
+	}
+	SortNotes(notes)
+	return notes, nil
+}
+
+// numerals are the degrees written in Roman numerals, longest first so "IV" isn't read as "I".
+var numerals = []struct {
+	text   string
+	degree int
+}{{"VII", 6}, {"III", 2}, {"IV", 3}, {"VI", 5}, {"II", 1}, {"V", 4}, {"I", 0}}
+
+// chordSuffixes give the chord of a numeral, for upper case (major) and lower case (minor) numerals,
+// longest first so "maj7" isn't read as "7".
+var chordSuffixes = []struct {
+	text         string
+	major, minor Chord
+}{
+	{"maj7", Major7, Chord{0, 3, 7, 11}},
+	{"sus2", Sus2, Sus2},
+	{"sus4", Sus4, Sus4},
+	{"o7", Diminished7, Diminished7},
+	{"ø7", HalfDiminished7, HalfDiminished7},
+	{"7", Dominant7, Minor7},
+	{"o", DiminishedTriad, DiminishedTriad},
+	{"°", DiminishedTriad, DiminishedTriad},
+	{"ø", HalfDiminished7, HalfDiminished7},
+	{"+", AugmentedTriad, AugmentedTriad},
+	{"", MajorTriad, MinorTriad},
+}
+
+// ParseProgression returns the pitches of the chords of a progression written in Roman numerals
+// (like "ii-V-I" or "I vi IV V"), in root position from the degrees of the scale (MajorScale if nil) on the key.
+// Upper case numerals are major chords and lower case ones minor chords, with an optional suffix:
+// "7" (dominant or minor seventh), "maj7", "o" or "°" (diminished), "o7", "ø" (half-diminished seventh), "+" (augmented),
+// "sus2" and "sus4". A "b" or "#" before a numeral lowers or raises its root by a semitone (like "bVII").
+// Chords are separated by spaces, "-", "|" or ",".
+func ParseProgression(key float64, scale Scale, spec string) ([][]float64, error) {
+	if scale == nil {
+		scale = MajorScale
+	}
+	var chords [][]float64
+	fields := strings.FieldsFunc(spec, func(r rune) bool { return r == ' ' || r == '-' || r == '|' || r == ',' || r == '\t' || r == '\n' })
+	for _, f := range fields {
+		s, shift := f, 0
+		for strings.HasPrefix(s, "b") || strings.HasPrefix(s, "#") {
+			if s[0] == 'b' {
+				shift--
+			} else {
+				shift++
+			}
+			s = s[1:]
+		}
+		degree, upper := -1, false
+		for _, n := range numerals {
+			if strings.HasPrefix(s, n.text) {
+				degree, upper, s = n.degree, true, s[len(n.text):]
+				break
+			} else if strings.HasPrefix(s, strings.ToLower(n.text)) {
+				degree, s = n.degree, s[len(n.text):]
+				break
+			}
+		}
+		if degree < 0 {
+			return nil, fmt.Errorf("invalid chord %q: missing Roman numeral", f)
+		}
+		var chord Chord
+		for _, suffix := range chordSuffixes {
+			if s == suffix.text {
+				chord = suffix.minor
+				if upper {
+					chord = suffix.major
+				}
+				break
+			}
+		}
+		if chord == nil {
+			return nil, fmt.Errorf("invalid chord %q: unknown suffix %q", f, s)
+		}
+		chords = append(chords, chord.Pitches(scale.Degree(key, degree)+float64(shift)))
+	}
+	if len(chords) == 0 {
+		return nil, fmt.Errorf("empty chord progression")
+	}
+	return chords, nil
+}
+
+// VoiceChords arranges chords (pitches in root position, like ParseProgression returns) with a voicing.
+// The first chord keeps its position, so it sets the register of the progression.
+func VoiceChords(chords [][]float64, voicing Voicing) [][]float64 {
+	voiced := make([][]float64, len(chords))
+	for i, chord := range chords {
+		chord = slices.Clone(chord)
+		slices.Sort(chord)
+		if voicing != VoiceRoot && i > 0 {
+			chord = lead(voiced[i-1], chord, mean(voiced[0]))
+		}
+		voiced[i] = chord
+	}
+	if voicing == VoiceDrop2 {
+		for i, chord := range voiced {
+			chord = slices.Clone(chord)
+			if len(chord) >= 3 {
+				chord[len(chord)-2] -= 12
+			}
+			slices.Sort(chord)
+			voiced[i] = chord
+		}
+	}
+	return voiced
+}
+
+// lead returns the inversion of the chord (in any octave) closest to the previous chord: with the least total
+// movement of its voices, then the closest to the center of the progression so it doesn't drift away.
+func lead(prev, chord []float64, center float64) []float64 {
+	var best []float64
+	bestCost := math.Inf(1)
+	for inversion := 0; inversion < len(chord); inversion++ {
+		candidate := slices.Clone(chord)
+		for i := 0; i < inversion; i++ {
+			candidate[i] += 12
+		}
+		slices.Sort(candidate)
+		for octave := -2; octave <= 2; octave++ {
+			shifted := make([]float64, len(candidate))
+			for i, p := range candidate {
+				shifted[i] = p + float64(12*octave)
+			}
+			cost := 0.01 * math.Abs(mean(shifted)-center)
+			for i, p := range shifted {
+				cost += math.Abs(p - prev[min(i*len(prev)/len(shifted), len(prev)-1)])
+			}
+			if cost < bestCost {
+				best, bestCost = shifted, cost
+			}
+		}
+	}
+	return best
+}
+
+func mean(pitches []float64) float64 {
+	var sum float64
+	for _, p := range pitches {
+		sum += p
+	}
+	return sum / float64(len(pitches))
+}
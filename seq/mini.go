@@ -0,0 +1,356 @@
+package seq
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Mini is a pattern written in mini-notation, a compact text syntax for rhythms and melodies
+// (after the mini-notation of TidalCycles). Each cycle of the pattern is divided between its steps:
+//
+//	c3 e3 g3 c4      four notes (names like ParseNote, or MIDI note numbers like 60)
+//	x ~ x x          "x" plays the pitch of the pattern (with a velocity of 0.7, "X" is accented), "~" is a rest
+//	c3 [e3 g3]       brackets divide a step into a group of steps
+//	[c3, e3, g3]     commas play groups at the same time (chords)
+//	c3 <e3 g3>       angle brackets play one of their steps per cycle, in turn
+//	x*4              repeats a step within its length
+//	c3!3 e3  c3 ! e3 replicates a step (a lone "!" replicates the previous one)
+//	c3@3 e3  c3 _ _  makes a step longer (by a weight, or by "_" steps)
+//	x?               plays the step half of the time
+//	x(3,8)           spreads a step over a Euclidean rhythm (pulses, steps and an optional rotation, see Euclid)
+type Mini struct {
+	Cycle float64 // Length of a cycle in beats, 0 means a bar of 4 beats.
+	Gate  float64 // Portion of its step during which a note is held, 0 means 0.5.
+	Pitch float64 // Pitch of "x" steps, 0 means 60 (C4).
+	Seed  int64   // Seed of the random source deciding whether steps with "?" play.
+
+	root miniNode
+}
+
+type miniKind int
+
+const (
+	miniRest miniKind = iota
+	miniValue
+	miniHit // "x" and "X", at the pitch of the pattern.
+	miniGroup
+	miniStack
+	miniAlternation
+)
+
+// miniNode is a step of a pattern.
+type miniNode struct {
+	kind     miniKind
+	pitch    float64
+	velocity float64
+	children []miniNode // Steps of groups and alternations, layers of stacks.
+
+	weight  float64 // Relative length in its group.
+	repeat  int     // Number of repetitions within its length.
+	degrade bool
+	euclid  []Step
+}
+
+// ParseMini parses a pattern written in mini-notation (see Mini).
+func ParseMini(pattern string) (Mini, error) {
+	p := &miniParser{s: pattern}
+	root, err := p.stack(0)
+	if err != nil {
+		return Mini{}, fmt.Errorf("mini-notation %q: %w", pattern, err)
+	} else if p.i < len(p.s) {
+		return Mini{}, fmt.Errorf("mini-notation %q: unexpected %q at %d", pattern, p.s[p.i], p.i)
+	}
+	return Mini{root: root}, nil
+}
+
+// MustParseMini is like ParseMini but panics if the pattern is invalid.
+func MustParseMini(pattern string) Mini {
+	m, err := ParseMini(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Notes returns the notes played over the given number of cycles.
+func (m Mini) Notes(cycles int) []Note {
+	r := miniRenderer{Mini: m, rng: rand.New(rand.NewSource(m.Seed))}
+	cycle := or(m.Cycle, 4)
+	for c := 0; c < cycles; c++ {
+		r.render(m.root, float64(c)*cycle, cycle, c)
+	}
+	SortNotes(r.notes)
+	return r.notes
+}
+
+type miniRenderer struct {
+	Mini
+	rng   *rand.Rand
+	notes []Note
+}
+
+// render adds the notes of a step lasting length beats from start, in the given cycle (of its alternations).
+func (r *miniRenderer) render(n miniNode, start, length float64, cycle int) {
+	if n.repeat > 1 {
+		length /= float64(n.repeat)
+		for i := 0; i < n.repeat; i++ {
+			m := n
+			m.repeat = 1
+			r.render(m, start+float64(i)*length, length, cycle*n.repeat+i)
+		}
+		return
+	}
+	if n.euclid != nil {
+		length /= float64(len(n.euclid))
+		for i, s := range n.euclid {
+			if s.On {
+				m := n
+				m.euclid = nil
+				r.render(m, start+float64(i)*length, length, cycle)
+			}
+		}
+		return
+	}
+	if n.degrade && r.rng.Float64() < 0.5 {
+		return
+	}
+
+	switch n.kind {
+	case miniValue, miniHit:
+		pitch := n.pitch
+		if n.kind == miniHit {
+			pitch = or(r.Pitch, 60)
+		}
+		r.notes = append(r.notes, Note{Start: start, Duration: or(r.Gate, 0.5) * length, Pitch: pitch, Velocity: n.velocity})
+	case miniGroup:
+		var total float64
+		for _, c := range n.children {
+			total += c.weight
+		}
+		for _, c := range n.children {
+			l := length * c.weight / total
+			r.render(c, start, l, cycle)
+			start += l
+		}
+	case miniStack:
+		for _, c := range n.children {
+			r.render(c, start, length, cycle)
+		}
+	case miniAlternation:
+		if len(n.children) > 0 {
+			r.render(n.children[cycle%len(n.children)], start, length, cycle/len(n.children))
+		}
+	}
+}
+
+type miniParser struct {
+	s string
+	i int
+}
+
+func (p *miniParser) skipSpaces() {
+	for p.i < len(p.s) && unicode.IsSpace(rune(p.s[p.i])) {
+		p.i++
+	}
+}
+
+// peek returns the next byte, 0 at the end.
+func (p *miniParser) peek() byte {
+	if p.i < len(p.s) {
+		return p.s[p.i]
+	}
+	return 0
+}
+
+// stack parses groups separated by commas, until the closing bracket (or the end with 0).
+func (p *miniParser) stack(closing byte) (miniNode, error) {
+	var layers []miniNode
+	for {
+		g, err := p.group(closing)
+		if err != nil {
+			return miniNode{}, err
+		}
+		layers = append(layers, g)
+		if p.peek() != ',' {
+			break
+		}
+		p.i++
+	}
+	if len(layers) == 1 {
+		return layers[0], nil
+	}
+	return miniNode{kind: miniStack, children: layers, weight: 1}, nil
+}
+
+// group parses steps separated by spaces, until a comma or the closing bracket (or the end with 0).
+func (p *miniParser) group(closing byte) (miniNode, error) {
+	g := miniNode{kind: miniGroup, weight: 1}
+	for {
+		p.skipSpaces()
+		switch c := p.peek(); {
+		case c == 0 && closing != 0:
+			return miniNode{}, fmt.Errorf("missing %q", closing)
+		case c == 0 || c == ',' || c == closing:
+			if len(g.children) == 0 {
+				g.children = append(g.children, miniNode{kind: miniRest, weight: 1})
+			}
+			return g, nil
+		case c == '_' || c == '!':
+			p.i++
+			if len(g.children) == 0 {
+				return miniNode{}, fmt.Errorf("%q at %d has no step before it", c, p.i-1)
+			}
+			last := &g.children[len(g.children)-1]
+			if c == '_' {
+				last.weight++
+			} else {
+				g.children = append(g.children, *last)
+			}
+		default:
+			steps, err := p.step()
+			if err != nil {
+				return miniNode{}, err
+			}
+			g.children = append(g.children, steps...)
+		}
+	}
+}
+
+// step parses a step with its modifiers, several with "!n".
+func (p *miniParser) step() ([]miniNode, error) {
+	var n miniNode
+	switch c := p.peek(); c {
+	case '[', '<':
+		p.i++
+		closing := byte(']')
+		if c == '<' {
+			closing = '>'
+		}
+		inner, err := p.stack(closing)
+		if err != nil {
+			return nil, err
+		}
+		p.i++
+		n = inner
+		if c == '<' {
+			if inner.kind != miniGroup {
+				return nil, fmt.Errorf("commas aren't allowed in alternations")
+			}
+			n = miniNode{kind: miniAlternation, children: inner.children}
+		}
+	case ']', '>', ')', '*', '@', '?', '(':
+		return nil, fmt.Errorf("unexpected %q at %d", c, p.i)
+	default:
+		start := p.i
+		for p.i < len(p.s) && !unicode.IsSpace(rune(p.s[p.i])) && !strings.ContainsRune("[]<>,*@!?()_", rune(p.s[p.i])) {
+			p.i++
+		}
+		var err error
+		n, err = miniWord(p.s[start:p.i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	n.weight, n.repeat = 1, 1
+	copies := 1
+	for {
+		switch p.peek() {
+		case '*':
+			p.i++
+			v, err := p.number()
+			if err != nil {
+				return nil, err
+			}
+			n.repeat *= max(1, int(v))
+		case '@':
+			p.i++
+			v, err := p.number()
+			if err != nil {
+				return nil, err
+			}
+			n.weight = v
+		case '!':
+			p.i++
+			if c := p.peek(); c < '0' || c > '9' {
+				copies++ // Like a lone "!".
+				continue
+			}
+			v, err := p.number()
+			if err != nil {
+				return nil, err
+			}
+			copies = max(1, int(v))
+		case '?':
+			p.i++
+			n.degrade = true
+		case '(':
+			p.i++
+			var args []int
+			for {
+				p.skipSpaces()
+				v, err := p.number()
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, int(v))
+				p.skipSpaces()
+				if p.peek() == ',' {
+					p.i++
+					continue
+				} else if p.peek() != ')' {
+					return nil, fmt.Errorf("missing %q at %d", ')', p.i)
+				}
+				p.i++
+				break
+			}
+			if len(args) < 2 || len(args) > 3 {
+				return nil, fmt.Errorf("euclidean rhythms take 2 or 3 numbers, not %d", len(args))
+			}
+			args = append(args, 0)
+			n.euclid = Euclid(args[0], args[1], args[2])
+		default:
+			steps := make([]miniNode, copies)
+			for i := range steps {
+				steps[i] = n
+			}
+			return steps, nil
+		}
+	}
+}
+
+// number parses a positive number.
+func (p *miniParser) number() (float64, error) {
+	start := p.i
+	for p.i < len(p.s) && (p.s[p.i] >= '0' && p.s[p.i] <= '9' || p.s[p.i] == '.') {
+		p.i++
+	}
+	v, err := strconv.ParseFloat(p.s[start:p.i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number at %d", start)
+	}
+	return v, nil
+}
+
+// miniWord parses a value: a rest, a hit, a note name or a MIDI note number.
+func miniWord(w string) (miniNode, error) {
+	switch w {
+	case "~":
+		return miniNode{kind: miniRest}, nil
+	case "x":
+		return miniNode{kind: miniHit, velocity: 0.7}, nil
+	case "X":
+		return miniNode{kind: miniHit, velocity: 1}, nil
+	}
+	if v, err := strconv.ParseFloat(w, 64); err == nil {
+		return miniNode{kind: miniValue, pitch: v, velocity: 1}, nil
+	}
+	pitch, err := ParseNote(w)
+	if err != nil {
+		return miniNode{}, fmt.Errorf("invalid step %q", w)
+	}
+	return miniNode{kind: miniValue, pitch: pitch, velocity: 1}, nil
+}
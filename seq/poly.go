@@ -0,0 +1,100 @@
+package seq
+
+import (
+	"slices"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// StealPolicy chooses which voice plays a new note when all voices are busy.
+type StealPolicy int
+
+const (
+	StealOldest   StealPolicy = iota // The voice playing the note that started first.
+	StealQuietest                    // The voice playing the note with the lowest velocity.
+)
+
+// Poly allocates notes to a fixed number of voices, so chords and overlapping notes can be played.
+type Poly struct {
+	Voices int
+	Voice  VoiceFunc
+	Steal  StealPolicy
+	Tuning Tuning // Nil means TwelveTone.
+}
+
+// NewPoly returns an allocator of n voices built with the given function, stealing the oldest notes.
+func NewPoly(n int, voice VoiceFunc) *Poly {
+	return &Poly{Voices: n, Voice: voice, Steal: StealOldest}
+}
+
+// Sequence plays the notes at the given tempo (in beats per minute) and returns the sum of all voices,
+// each scaled by the velocity of its current note.
+//
+// A new note goes to the voice that has been free the longest (so release tails ring as long as possible).
+// When all voices are busy, a note is stolen according to the steal policy:
+// it ends when the new one starts and the voice glides to the new note without closing its gate.
+func (p *Poly) Sequence(notes []Note, bpm float64) synth.Signal {
+	perVoice := p.Allocate(notes)
+	signals := make([]synth.Signal, len(perVoice))
+	for i, notes := range perVoice {
+		v := SequenceTuned(notes, bpm, p.tuning())
+		signals[i] = synth.Mul(v.Velocity, p.Voice(v.Freq, v.Gate))
+	}
+	return synth.Add(signals...)
+}
+
+// Allocate returns the notes played by each voice.
+func (p *Poly) Allocate(notes []Note) (perVoice [][]Note) {
+	notes = slices.Clone(notes)
+	SortNotes(notes)
+	perVoice = make([][]Note, max(1, p.Voices))
+	for _, n := range notes {
+		i := p.pick(perVoice, n.Start)
+		if last := len(perVoice[i]) - 1; last >= 0 && perVoice[i][last].End() > n.Start {
+			perVoice[i][last].Duration = n.Start - perVoice[i][last].Start // Stolen.
+		}
+		perVoice[i] = append(perVoice[i], n)
+	}
+	return perVoice
+}
+
+// pick returns the voice that should play a note starting at the given beat.
+func (p *Poly) pick(perVoice [][]Note, start float64) int {
+	free, busy := -1, -1
+	var freeSince float64
+	for i, notes := range perVoice {
+		if len(notes) == 0 {
+			return i // Never used.
+		}
+		last := notes[len(notes)-1]
+		if last.End() <= start {
+			if free < 0 || last.End() < freeSince {
+				free, freeSince = i, last.End()
+			}
+			continue
+		}
+		if busy < 0 || p.steals(last, perVoice[busy][len(perVoice[busy])-1]) {
+			busy = i
+		}
+	}
+	if free >= 0 {
+		return free
+	}
+	return busy
+}
+
+// steals reports whether a should be stolen rather than b.
+func (p *Poly) steals(a, b Note) bool {
+	if p.Steal == StealQuietest && a.Velocity != b.Velocity {
+		return a.Velocity < b.Velocity
+	}
+	return a.Start < b.Start
+}
+
+// tuning returns the tuning of the notes.
+func (p *Poly) tuning() Tuning {
+	if p.Tuning == nil {
+		return TwelveTone
+	}
+	return p.Tuning
+}
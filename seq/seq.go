@@ -0,0 +1,128 @@
+// Package seq turns musical events (notes with a pitch, a velocity and a duration) into signals
+// that drive the oscillators and envelopes of the synth package.
+package seq
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Note is a note event, its timing is expressed in beats so it follows the tempo.
+type Note struct {
+	Start    float64 // In beats, from the start of the sequence.
+	Duration float64 // In beats.
+	Pitch    float64 // MIDI note number, 69 being A4 (440 Hz). Fractional values are allowed.
+	Velocity float64 // Between 0 and 1.
+	Channel  int     // Optional, used to route notes to instruments (like MIDI channels).
+}
+
+// End returns the beat at which the note ends.
+func (n Note) End() float64 { return n.Start + n.Duration }
+
+// Voice holds the signals driving a monophonic voice.
+type Voice struct {
+	Freq     synth.Signal // Frequency of the current (or last) note, in Hertz.
+	Gate     synth.Signal // Open while a note is held.
+	Velocity synth.Signal // Velocity of the current (or last) note.
+}
+
+// VoiceFunc builds a voice playing at the given frequency (in Hertz) while the gate is open.
+type VoiceFunc func(freq, gate synth.Signal) synth.Signal
+
+// MIDIToFreq returns the frequency (in Hertz) of a MIDI note number in 12-tone equal temperament.
+func MIDIToFreq(note float64) float64 {
+	return TwelveTone.Freq(note)
+}
+
+// Beats returns the number of beats elapsed at x for the given tempo (in beats per minute).
+func Beats(x time.Duration, bpm float64) float64 {
+	return x.Seconds() * bpm / 60
+}
+
+// BeatsToDuration returns the duration of the given number of beats for the given tempo (in beats per minute).
+func BeatsToDuration(beats, bpm float64) time.Duration {
+	return time.Duration(beats * 60 / bpm * float64(time.Second))
+}
+
+// Sequence plays the notes one after another at the given tempo (in beats per minute).
+//
+// The sequence is monophonic: when notes overlap, the last one started wins.
+// The frequency and velocity keep the value of the last note after it ends, so release tails keep their pitch.
+// Notes that touch (or overlap) are played legato: the gate stays open between them.
+func Sequence(notes []Note, bpm float64) Voice {
+	return SequenceTuned(notes, bpm, TwelveTone)
+}
+
+// SequenceTuned is like Sequence with another tuning for the pitch of the notes.
+func SequenceTuned(notes []Note, bpm float64, tuning Tuning) Voice {
+	return SequenceAt(notes, func(x time.Duration) float64 { return Beats(x, bpm) }, tuning)
+}
+
+// SequenceAt is like SequenceTuned but the position in beats is given by a signal,
+// so the notes can follow a tempo that changes or a transport that stops and loops.
+func SequenceAt(notes []Note, position synth.Signal, tuning Tuning) Voice {
+	notes = slices.Clone(notes)
+	SortNotes(notes)
+
+	// current returns the last note started at x and whether it is still held.
+	current := func(x time.Duration) (n Note, held, ok bool) {
+		beat := position(x)
+		i, _ := slices.BinarySearchFunc(notes, beat, func(n Note, beat float64) int {
+			if n.Start <= beat {
+				return -1
+			}
+			return 1
+		})
+		if i == 0 {
+			return Note{}, false, false
+		}
+		n = notes[i-1]
+		return n, beat < n.End(), true
+	}
+
+	return Voice{
+		Freq: func(x time.Duration) float64 {
+			n, _, ok := current(x)
+			if !ok && len(notes) > 0 {
+				n = notes[0] // Avoid an initial glide from 0 Hz.
+			}
+			return tuning.Freq(n.Pitch)
+		},
+		Gate: func(x time.Duration) float64 {
+			if _, held, _ := current(x); held {
+				return 1
+			}
+			return 0
+		},
+		Velocity: func(x time.Duration) float64 {
+			n, _, _ := current(x)
+			return n.Velocity
+		},
+	}
+}
+
+// Length returns the beat at which the last of the notes ends.
+func Length(notes []Note) (beats float64) {
+	for _, n := range notes {
+		beats = max(beats, n.End())
+	}
+	return beats
+}
+
+// Duration returns how long the notes last at the given tempo (in beats per minute).
+func Duration(notes []Note, bpm float64) time.Duration {
+	return BeatsToDuration(Length(notes), bpm)
+}
+
+// SortNotes sorts notes by start (and by pitch for notes starting together).
+func SortNotes(notes []Note) {
+	slices.SortStableFunc(notes, func(a, b Note) int {
+		if a.Start != b.Start {
+			return cmp.Compare(a.Start, b.Start)
+		}
+		return cmp.Compare(a.Pitch, b.Pitch)
+	})
+}
@@ -0,0 +1,86 @@
+package seq
+
+import (
+	"math/rand"
+)
+
+// Step is a step of a pattern.
+type Step struct {
+	On          bool
+	Velocity    float64 // Between 0 and 1, 0 means 1.
+	Pitch       float64 // MIDI note number, 0 means the pitch of the pattern.
+	Probability float64 // Chance of the step playing (between 0 and 1), 0 means it always plays.
+	Ratchet     int     // Number of times the step is repeated within its length, 0 means once.
+}
+
+// Steps parses a pattern written as text, one character per step:
+// "x" plays a step, "X" plays an accented step (velocity 1, the others having 0.7),
+// any other character (like "." or "-") is a rest. Spaces and "|" are ignored, so bars can be separated.
+//
+//	seq.Steps("x... x... x... x...") // Four on the floor.
+func Steps(s string) []Step {
+	var steps []Step
+	for _, c := range s {
+		switch c {
+		case ' ', '|':
+		case 'x':
+			steps = append(steps, Step{On: true, Velocity: 0.7})
+		case 'X':
+			steps = append(steps, Step{On: true, Velocity: 1})
+		default:
+			steps = append(steps, Step{})
+		}
+	}
+	return steps
+}
+
+// Pattern is a step sequencer pattern (usually 16 or 32 steps) that loops.
+type Pattern struct {
+	Steps []Step
+	Pitch float64 // Default pitch of the steps, 0 means 60 (C4).
+	Step  float64 // Length of a step in beats, 0 means a sixteenth note (0.25).
+	Gate  float64 // Portion of the step (or ratchet) during which the note is held, 0 means 0.5.
+	Swing float64 // Delay of every other step, as a portion of a step (1/3 gives a triplet shuffle).
+	Seed  int64   // Seed of the random source deciding whether steps with a probability play.
+}
+
+// Notes returns the notes played when looping the pattern the given number of times.
+// They can be played like any other notes, for example with Sequence (using the gate to hit a drum).
+func (p Pattern) Notes(loops int) []Note {
+	step, gate, pitch := or(p.Step, 0.25), or(p.Gate, 0.5), or(p.Pitch, 60)
+	rng := rand.New(rand.NewSource(p.Seed))
+	var notes []Note
+	for loop := 0; loop < loops; loop++ {
+		for i, s := range p.Steps {
+			if !s.On || (s.Probability > 0 && rng.Float64() >= s.Probability) {
+				continue
+			}
+			start := float64(loop*len(p.Steps)+i) * step
+			if i%2 == 1 {
+				start += p.Swing * step
+			}
+			ratchet := max(s.Ratchet, 1)
+			length := step / float64(ratchet)
+			for r := 0; r < ratchet; r++ {
+				notes = append(notes, Note{
+					Start:    start + float64(r)*length,
+					Duration: gate * length,
+					Pitch:    or(s.Pitch, pitch),
+					Velocity: or(s.Velocity, 1),
+				})
+			}
+		}
+	}
+	return notes
+}
+
+// Length returns the length of the pattern in beats.
+func (p Pattern) Length() float64 { return float64(len(p.Steps)) * or(p.Step, 0.25) }
+
+// or returns v, or def if v is zero.
+func or(v, def float64) float64 {
+	if v == 0 {
+		return def
+	}
+	return v
+}
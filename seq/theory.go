@@ -0,0 +1,182 @@
+package seq
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MIDIToFreqAt is like MIDIToFreq with another reference pitch for A4 (like 432 Hz).
+func MIDIToFreqAt(note, a4 float64) float64 {
+	return EqualTemperament{Divisions: 12, RefNote: 69, RefFreq: a4}.Freq(note)
+}
+
+// noteClasses are the pitch classes of the natural notes (C being 0).
+var noteClasses = map[byte]int{'C': 0, 'D': 2, 'E': 4, 'F': 5, 'G': 7, 'A': 9, 'B': 11}
+
+// ParseNote returns the MIDI note number of a note name in scientific pitch notation,
+// like "A4" (69), "C#3", "Bb2" or "C-1" (0). Sharps are written "#" and flats "b".
+func ParseNote(name string) (float64, error) {
+	if name == "" {
+		return 0, fmt.Errorf("empty note name")
+	}
+	class, ok := noteClasses[strings.ToUpper(name[:1])[0]]
+	if !ok {
+		return 0, fmt.Errorf("invalid note name %q", name)
+	}
+	rest := name[1:]
+	for len(rest) > 0 && (rest[0] == '#' || rest[0] == 'b') {
+		if rest[0] == '#' {
+			class++
+		} else {
+			class--
+		}
+		rest = rest[1:]
+	}
+	octave, err := strconv.Atoi(rest)
+	if err != nil {
+		return 0, fmt.Errorf("invalid octave in note name %q", name)
+	}
+	return float64((octave+1)*12 + class), nil
+}
+
+// MustParseNote is like ParseNote but panics if the name is invalid.
+func MustParseNote(name string) float64 {
+	note, err := ParseNote(name)
+	if err != nil {
+		panic(err)
+	}
+	return note
+}
+
+// noteNames are the names of the pitch classes, using sharps.
+var noteNames = [12]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// NoteName returns the name of a MIDI note number (rounded to the nearest semitone), using sharps (like "C#4").
+func NoteName(note float64) string {
+	n := int(math.Round(note))
+	class, octave := n%12, n/12-1
+	if class < 0 {
+		class, octave = class+12, octave-1
+	}
+	return noteNames[class] + strconv.Itoa(octave)
+}
+
+// NoteToFreq returns the frequency (in Hertz) of a note name (like "A4") for the given reference pitch of A4.
+func NoteToFreq(name string, a4 float64) (float64, error) {
+	note, err := ParseNote(name)
+	if err != nil {
+		return 0, err
+	}
+	return MIDIToFreqAt(note, a4), nil
+}
+
+// Scale is a set of intervals (in semitones) from the root of the scale, within an octave.
+type Scale []int
+
+// Common scales.
+var (
+	MajorScale         = Scale{0, 2, 4, 5, 7, 9, 11}
+	MinorScale         = Scale{0, 2, 3, 5, 7, 8, 10} // Natural minor.
+	HarmonicMinorScale = Scale{0, 2, 3, 5, 7, 8, 11}
+	MelodicMinorScale  = Scale{0, 2, 3, 5, 7, 9, 11}
+	MajorPentatonic    = Scale{0, 2, 4, 7, 9}
+	MinorPentatonic    = Scale{0, 3, 5, 7, 10}
+	BluesScale         = Scale{0, 3, 5, 6, 7, 10}
+	ChromaticScale     = Scale{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	WholeToneScale     = Scale{0, 2, 4, 6, 8, 10}
+	Ionian             = MajorScale
+	Dorian             = MajorScale.Mode(2)
+	Phrygian           = MajorScale.Mode(3)
+	Lydian             = MajorScale.Mode(4)
+	Mixolydian         = MajorScale.Mode(5)
+	Aeolian            = MinorScale
+	Locrian            = MajorScale.Mode(7)
+)
+
+// Mode returns the scale starting from the given degree (1 being the root), like Dorian from the major scale.
+func (s Scale) Mode(degree int) Scale {
+	start := s.Degree(0, degree-1)
+	mode := make(Scale, len(s))
+	for i := range mode {
+		mode[i] = int(s.Degree(0, degree-1+i) - start)
+	}
+	return mode
+}
+
+// Degree returns the pitch of a degree of the scale built on root (a MIDI note number), 0 being the root.
+// Degrees beyond the scale continue in the next octaves (and negative degrees in the previous ones).
+func (s Scale) Degree(root float64, degree int) float64 {
+	n := len(s)
+	octave, i := degree/n, degree%n
+	if i < 0 {
+		octave, i = octave-1, i+n
+	}
+	return root + float64(12*octave+s[i])
+}
+
+// Pitches returns the pitches of the scale built on root over the given number of octaves,
+// ending with the root of the next octave.
+func (s Scale) Pitches(root float64, octaves int) []float64 {
+	pitches := make([]float64, 0, len(s)*octaves+1)
+	for degree := 0; degree <= len(s)*octaves; degree++ {
+		pitches = append(pitches, s.Degree(root, degree))
+	}
+	return pitches
+}
+
+// Chord returns the chord of the given size built by stacking thirds from a degree of the scale
+// (size 3 for triads, 4 for seventh chords), such as the chords of a progression in the key.
+func (s Scale) Chord(root float64, degree, size int) []float64 {
+	pitches := make([]float64, size)
+	for i := range pitches {
+		pitches[i] = s.Degree(root, degree+2*i)
+	}
+	return pitches
+}
+
+// Chord is a set of intervals (in semitones) from the root of the chord.
+type Chord []int
+
+// Common chords.
+var (
+	MajorTriad      = Chord{0, 4, 7}
+	MinorTriad      = Chord{0, 3, 7}
+	DiminishedTriad = Chord{0, 3, 6}
+	AugmentedTriad  = Chord{0, 4, 8}
+	Sus2            = Chord{0, 2, 7}
+	Sus4            = Chord{0, 5, 7}
+	Major7          = Chord{0, 4, 7, 11}
+	Minor7          = Chord{0, 3, 7, 10}
+	Dominant7       = Chord{0, 4, 7, 10}
+	HalfDiminished7 = Chord{0, 3, 6, 10}
+	Diminished7     = Chord{0, 3, 6, 9}
+)
+
+// Pitches returns the pitches of the chord built on root (a MIDI note number).
+func (c Chord) Pitches(root float64) []float64 {
+	pitches := make([]float64, len(c))
+	for i, interval := range c {
+		pitches[i] = root + float64(interval)
+	}
+	return pitches
+}
+
+// Inversion returns the chord with its n lowest notes moved up an octave.
+func (c Chord) Inversion(n int) Chord {
+	inv := append(Chord(nil), c...)
+	for i := 0; i < n; i++ {
+		inv = append(inv[1:], inv[0]+12)
+	}
+	return inv
+}
+
+// Notes returns notes playing the pitches together, for the sequencer.
+func Notes(pitches []float64, start, duration, velocity float64) []Note {
+	notes := make([]Note, len(pitches))
+	for i, p := range pitches {
+		notes[i] = Note{Start: start, Duration: duration, Pitch: p, Velocity: velocity}
+	}
+	return notes
+}
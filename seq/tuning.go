@@ -0,0 +1,150 @@
+package seq
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Tuning maps note numbers to frequencies (in Hertz).
+type Tuning interface {
+	Freq(note float64) float64
+}
+
+// EqualTemperament divides the octave in equal steps, a note number being a number of steps.
+type EqualTemperament struct {
+	Divisions int     // Steps per octave.
+	RefNote   float64 // Note number of the reference pitch.
+	RefFreq   float64 // Frequency of the reference pitch (in Hertz).
+}
+
+// TwelveTone is the standard tuning of MIDI note numbers, A4 (69) being 440 Hz.
+var TwelveTone = EqualTemperament{Divisions: 12, RefNote: 69, RefFreq: 440}
+
+// EDO returns the equal division of the octave in n steps (like 19 or 31), note 69 being 440 Hz.
+func EDO(n int) EqualTemperament {
+	return EqualTemperament{Divisions: n, RefNote: 69, RefFreq: 440}
+}
+
+func (t EqualTemperament) Freq(note float64) float64 {
+	return t.RefFreq * math.Pow(2, (note-t.RefNote)/float64(t.Divisions))
+}
+
+// Ratios is a tuning defined by the frequency ratios of the steps of a scale from its root,
+// like just intonation or Scala files. The last ratio is the period of the scale (usually 2, the octave),
+// and the scale repeats every period. Fractional notes are interpolated (in cents) between steps.
+type Ratios struct {
+	Steps   []float64 // Ratios of notes RefNote+1, RefNote+2, etc. to RefNote, ending with the period.
+	RefNote float64   // Note number of the root of the scale.
+	RefFreq float64   // Frequency of the root (in Hertz).
+}
+
+// JustIntonation is a 5-limit just intonation of the 12 semitones, with the same C4 (note 60) as TwelveTone.
+var JustIntonation = Ratios{
+	Steps:   []float64{16. / 15, 9. / 8, 6. / 5, 5. / 4, 4. / 3, 45. / 32, 3. / 2, 8. / 5, 5. / 3, 9. / 5, 15. / 8, 2},
+	RefNote: 60,
+	RefFreq: TwelveTone.Freq(60),
+}
+
+func (t Ratios) Freq(note float64) float64 {
+	n := len(t.Steps)
+	if n == 0 {
+		return t.RefFreq
+	}
+	// ratio returns the ratio of a step from the root.
+	ratio := func(step int) float64 {
+		period, i := step/n, step%n
+		if i < 0 {
+			period, i = period-1, i+n
+		}
+		r := math.Pow(t.Steps[n-1], float64(period))
+		if i > 0 {
+			r *= t.Steps[i-1]
+		}
+		return r
+	}
+	step := math.Floor(note - t.RefNote)
+	frac := note - t.RefNote - step
+	lo, hi := ratio(int(step)), ratio(int(step)+1)
+	return t.RefFreq * lo * math.Pow(hi/lo, frac)
+}
+
+// LoadScala loads a Scala scale file (.scl), with its root at the given note number and frequency.
+func LoadScala(path string, refNote, refFreq float64) (Ratios, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Ratios{}, err
+	}
+	defer f.Close()
+	return ParseScala(f, refNote, refFreq)
+}
+
+// ParseScala parses a Scala scale file (.scl), with its root at the given note number and frequency.
+// Pitches are either ratios (like "3/2" or "2") or cents (containing a dot, like "701.955").
+func ParseScala(r io.Reader, refNote, refFreq float64) (Ratios, error) {
+	t := Ratios{RefNote: refNote, RefFreq: refFreq}
+	scanner := bufio.NewScanner(r)
+	line, count := 0, -1
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(text, "!") {
+			continue
+		}
+		line++
+		fields := strings.Fields(text)
+		switch {
+		case line == 1: // Description.
+			continue
+		case line == 2:
+			if len(fields) == 0 {
+				return t, fmt.Errorf("scala: missing number of notes")
+			}
+			n, err := strconv.Atoi(fields[0])
+			if err != nil || n < 0 {
+				return t, fmt.Errorf("scala: invalid number of notes %q", fields[0])
+			}
+			count = n
+			continue
+		case len(t.Steps) == count:
+			continue
+		case len(fields) == 0:
+			return t, fmt.Errorf("scala: line %d: missing pitch", line)
+		}
+		ratio, err := parseScalaPitch(fields[0])
+		if err != nil {
+			return t, fmt.Errorf("scala: line %d: %w", line, err)
+		}
+		t.Steps = append(t.Steps, ratio)
+	}
+	if err := scanner.Err(); err != nil {
+		return t, fmt.Errorf("scala: %w", err)
+	} else if count < 0 || len(t.Steps) != count {
+		return t, fmt.Errorf("scala: expected %d pitches, got %d", max(count, 0), len(t.Steps))
+	}
+	return t, nil
+}
+
+// parseScalaPitch returns the ratio of a pitch written in cents or as a ratio.
+func parseScalaPitch(s string) (float64, error) {
+	if strings.Contains(s, ".") {
+		cents, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid cents %q", s)
+		}
+		return math.Pow(2, cents/1200), nil
+	}
+	num, den, found := strings.Cut(s, "/")
+	n, err := strconv.Atoi(num)
+	d := 1
+	if err == nil && found {
+		d, err = strconv.Atoi(den)
+	}
+	if err != nil || n <= 0 || d <= 0 {
+		return 0, fmt.Errorf("invalid ratio %q", s)
+	}
+	return float64(n) / float64(d), nil
+}
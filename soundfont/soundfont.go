@@ -0,0 +1,271 @@
+// Package soundfont plays SoundFont 2 (SF2) files: banks of sampled instruments,
+// like the General MIDI sounds used to render MIDI files.
+//
+// Samples are played with their key and velocity zones, tuning, loops, volume envelope and low-pass filter.
+// Modulators, LFOs, the modulation envelope, effects (chorus, reverb) and panning aren't supported:
+// stereo samples are mixed to mono.
+package soundfont
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SoundFont is a loaded SF2 file.
+type SoundFont struct {
+	Name    string
+	Presets []*Preset
+
+	samples []float64 // All sample data, between -1 and 1.
+	headers []sampleHeader
+}
+
+// Preset is an instrument of a SoundFont, selected by its bank and program number.
+type Preset struct {
+	Name          string
+	Bank, Program int
+
+	font  *SoundFont
+	zones []zone
+}
+
+// zone is a key and velocity range with its generators.
+// Preset zones point to an instrument, instrument zones to a sample.
+type zone struct {
+	gens       map[uint16]int16
+	keys, vels [2]int
+	instrument *instrument // Of preset zones.
+	sample     int         // Of instrument zones, -1 for none.
+}
+
+type instrument struct {
+	name  string
+	zones []zone
+}
+
+type sampleHeader struct {
+	name                           string
+	start, end, loopStart, loopEnd int
+	rate                           int
+	pitch                          int // MIDI key of the recording.
+	correction                     int // In cents.
+}
+
+// Generators (see the SoundFont 2.04 specification, section 8.1.2).
+const (
+	genStartOffset       = 0
+	genEndOffset         = 1
+	genLoopStartOffset   = 2
+	genLoopEndOffset     = 3
+	genStartCoarseOffset = 4
+	genFilterFc          = 8
+	genFilterQ           = 9
+	genEndCoarseOffset   = 12
+	genDelayVolEnv       = 33
+	genAttackVolEnv      = 34
+	genHoldVolEnv        = 35
+	genDecayVolEnv       = 36
+	genSustainVolEnv     = 37
+	genReleaseVolEnv     = 38
+	genInstrument        = 41
+	genKeyRange          = 43
+	genVelRange          = 44
+	genLoopStartCoarse   = 45
+	genAttenuation       = 48
+	genLoopEndCoarse     = 50
+	genCoarseTune        = 51
+	genFineTune          = 52
+	genSampleID          = 53
+	genSampleModes       = 54
+	genScaleTuning       = 56
+	genOverridingRootKey = 58
+)
+
+// Load reads a SoundFont from an SF2 file.
+func Load(path string) (*SoundFont, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sf, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sf, nil
+}
+
+// Parse reads a SoundFont from the content of an SF2 file.
+func Parse(b []byte) (*SoundFont, error) {
+	if len(b) < 12 || string(b[:4]) != "RIFF" || string(b[8:12]) != "sfbk" {
+		return nil, errors.New("not a SoundFont 2 file")
+	}
+	chunks := map[string][]byte{}
+	err := readChunks(b[12:], func(id string, data []byte) error {
+		if id != "LIST" || len(data) < 4 {
+			return nil
+		}
+		return readChunks(data[4:], func(id string, data []byte) error {
+			chunks[id] = data
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range []string{"smpl", "phdr", "pbag", "pgen", "inst", "ibag", "igen", "shdr"} {
+		if _, ok := chunks[id]; !ok {
+			return nil, fmt.Errorf("missing %q chunk", id)
+		}
+	}
+
+	sf := &SoundFont{Name: text(chunks["INAM"])}
+	smpl := chunks["smpl"]
+	sf.samples = make([]float64, len(smpl)/2)
+	for i := range sf.samples {
+		sf.samples[i] = float64(int16(binary.LittleEndian.Uint16(smpl[2*i:]))) / 32768
+	}
+	shdr := chunks["shdr"]
+	for i := 0; i+46 <= len(shdr); i += 46 {
+		h := shdr[i : i+46]
+		u32 := func(at int) int { return int(binary.LittleEndian.Uint32(h[at:])) }
+		sf.headers = append(sf.headers, sampleHeader{
+			name: text(h[:20]), start: u32(20), end: u32(24), loopStart: u32(28), loopEnd: u32(32), rate: u32(36),
+			pitch: int(h[40]), correction: int(int8(h[41])),
+		})
+	}
+
+	igens, err := generators(chunks["igen"])
+	if err != nil {
+		return nil, err
+	}
+	insts, err := records(chunks["inst"], 22, 20, chunks["ibag"], igens, func(name string, zones []zone) *instrument {
+		return &instrument{name: name, zones: zones}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, in := range insts {
+		for i := range in.zones {
+			z := &in.zones[i]
+			if id, ok := z.gens[genSampleID]; ok && int(uint16(id)) < len(sf.headers) {
+				z.sample = int(uint16(id))
+			}
+		}
+	}
+
+	pgens, err := generators(chunks["pgen"])
+	if err != nil {
+		return nil, err
+	}
+	phdr := chunks["phdr"]
+	presets, err := records(phdr, 38, 24, chunks["pbag"], pgens, func(name string, zones []zone) *Preset {
+		return &Preset{Name: name, font: sf, zones: zones}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range presets {
+		p.Program = int(binary.LittleEndian.Uint16(phdr[38*i+20:]))
+		p.Bank = int(binary.LittleEndian.Uint16(phdr[38*i+22:]))
+		for j := range p.zones {
+			z := &p.zones[j]
+			if id, ok := z.gens[genInstrument]; ok && int(uint16(id)) < len(insts) {
+				z.instrument = insts[uint16(id)]
+			}
+		}
+	}
+	sf.Presets = presets
+	return sf, nil
+}
+
+// readChunks calls fn with each RIFF chunk of b.
+func readChunks(b []byte, fn func(id string, data []byte) error) error {
+	for len(b) >= 8 {
+		id, size := string(b[:4]), int(binary.LittleEndian.Uint32(b[4:8]))
+		if 8+size > len(b) {
+			return fmt.Errorf("truncated %q chunk", id)
+		}
+		err := fn(id, b[8:8+size])
+		if err != nil {
+			return err
+		}
+		b = b[min(8+size+size%2, len(b)):]
+	}
+	return nil
+}
+
+// text returns a null-terminated string.
+func text(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimSpace(string(b))
+}
+
+type generator struct {
+	oper   uint16
+	amount int16
+}
+
+// generators reads a "pgen" or "igen" chunk.
+func generators(b []byte) ([]generator, error) {
+	if len(b)%4 != 0 {
+		return nil, errors.New("invalid generator chunk")
+	}
+	gens := make([]generator, len(b)/4)
+	for i := range gens {
+		gens[i] = generator{binary.LittleEndian.Uint16(b[4*i:]), int16(binary.LittleEndian.Uint16(b[4*i+2:]))}
+	}
+	return gens, nil
+}
+
+// records reads the presets or instruments of a header chunk (records of the given size starting with a name,
+// with the index of their first bag at the given offset), with their zones from the bags and generators.
+// The last record only marks the end of the previous one.
+func records[T any](headers []byte, size, bagAt int, bags []byte, gens []generator, build func(name string, zones []zone) T) ([]T, error) {
+	n := len(headers)/size - 1
+	bag := func(i int) int { return int(binary.LittleEndian.Uint16(headers[size*i+bagAt:])) }
+	genIndex := func(b int) int { return int(binary.LittleEndian.Uint16(bags[4*b:])) }
+	var out []T
+	for i := 0; i < n; i++ {
+		first, last := bag(i), bag(i+1)
+		if first > last || 4*last+4 > len(bags) {
+			return nil, errors.New("invalid zone indexes")
+		}
+		var zones []zone
+		for b := first; b < last; b++ {
+			from, to := genIndex(b), genIndex(b+1)
+			if from > to || to > len(gens) {
+				return nil, errors.New("invalid generator indexes")
+			}
+			z := zone{gens: map[uint16]int16{}, keys: [2]int{0, 127}, vels: [2]int{0, 127}, sample: -1}
+			for _, g := range gens[from:to] {
+				switch g.oper {
+				case genKeyRange:
+					z.keys = [2]int{int(uint16(g.amount) & 0xFF), int(uint16(g.amount) >> 8)}
+				case genVelRange:
+					z.vels = [2]int{int(uint16(g.amount) & 0xFF), int(uint16(g.amount) >> 8)}
+				default:
+					z.gens[g.oper] = g.amount
+				}
+			}
+			zones = append(zones, z)
+		}
+		out = append(out, build(text(headers[size*i:size*i+20]), zones))
+	}
+	return out, nil
+}
+
+// Preset returns the preset of the bank and program, nil if there is none.
+func (sf *SoundFont) Preset(bank, program int) *Preset {
+	for _, p := range sf.Presets {
+		if p.Bank == bank && p.Program == program {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package soundfont
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/midi"
+	"github.com/ejuju/poc-go-audio-synthesis/seq"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// defaults are the values of the generators missing from instrument zones.
+var defaults = map[uint16]int16{
+	genFilterFc:          13500,
+	genDelayVolEnv:       -12000,
+	genAttackVolEnv:      -12000,
+	genHoldVolEnv:        -12000,
+	genDecayVolEnv:       -12000,
+	genReleaseVolEnv:     -12000,
+	genScaleTuning:       100,
+	genOverridingRootKey: -1,
+}
+
+// layer is a sample played by a note, with the generators of its instrument zone (and the offsets of its preset zone).
+type layer struct {
+	header sampleHeader
+	data   []float64 // All the sample data of the SoundFont.
+	gens   map[uint16]int
+}
+
+func (l layer) gen(oper uint16) int { return l.gens[oper] }
+
+// seconds converts timecents to seconds.
+func (l layer) seconds(oper uint16) float64 { return math.Exp2(float64(l.gen(oper)) / 1200) }
+
+// layers returns the samples played by a key at a velocity (MIDI values from 0 to 127).
+func (p *Preset) layers(key, vel int) []layer {
+	var layers []layer
+	in := func(z zone) bool { return key >= z.keys[0] && key <= z.keys[1] && vel >= z.vels[0] && vel <= z.vels[1] }
+	var presetGlobal *zone
+	for i, pz := range p.zones {
+		if pz.instrument == nil {
+			if i == 0 {
+				presetGlobal = &p.zones[0]
+			}
+			continue
+		} else if !in(pz) {
+			continue
+		}
+		var instGlobal *zone
+		for j, iz := range pz.instrument.zones {
+			if iz.sample < 0 {
+				if j == 0 {
+					instGlobal = &pz.instrument.zones[0]
+				}
+				continue
+			} else if !in(iz) {
+				continue
+			}
+			gens := map[uint16]int{}
+			for oper, v := range defaults {
+				gens[oper] = int(v)
+			}
+			for _, z := range []*zone{instGlobal, &iz} {
+				if z != nil {
+					for oper, v := range z.gens {
+						gens[oper] = int(v)
+					}
+				}
+			}
+			offsets := map[uint16]int16{}
+			for _, z := range []*zone{presetGlobal, &pz} {
+				if z != nil {
+					for oper, v := range z.gens {
+						offsets[oper] = v
+					}
+				}
+			}
+			for oper, v := range offsets {
+				switch oper {
+				case genInstrument, genSampleID, genSampleModes, genOverridingRootKey,
+					genStartOffset, genEndOffset, genLoopStartOffset, genLoopEndOffset,
+					genStartCoarseOffset, genEndCoarseOffset, genLoopStartCoarse, genLoopEndCoarse:
+					// Only valid in instrument zones.
+				default:
+					gens[oper] += int(v)
+				}
+			}
+			layers = append(layers, layer{header: p.font.headers[iz.sample], data: p.font.samples, gens: gens})
+		}
+	}
+	return layers
+}
+
+// playing is the state of a layer playing a note.
+type playing struct {
+	layer
+	pos                float64 // In frames of the sample data.
+	start, end         float64
+	loopStart, loopEnd float64
+	loop               int // Sample mode: 1 loops, 3 loops until the release.
+	time               float64
+	level              float64 // Of the volume envelope, in decibels of attenuation.
+	released           float64 // Time of the release, -1 before it.
+	releaseFrom        float64 // Attenuation when the release started.
+	filter             synth.Biquad
+	filtered           bool
+	x1, x2, y1, y2     float64
+}
+
+// Voice returns a voice playing the preset at the given velocity (between 0 and 1), for the sequencer or MIDI files.
+// The zones played are chosen from the frequency (as a key in 12-tone equal temperament) each time the gate opens,
+// and the output is scaled by the velocity like the other voices (see seq.Poly).
+func (p *Preset) Voice(velocity float64) seq.VoiceFunc {
+	vel := int(math.Round(math.Max(0, math.Min(velocity, 1)) * 127))
+	return func(freq, gate synth.Signal) synth.Signal {
+		return synth.Stateful(func() func(x time.Duration, dt float64) float64 {
+			var notes []playing
+			wasOpen := false
+			return func(x time.Duration, dt float64) float64 {
+				f, open := freq(x), gate(x) > 0
+				if f <= 0 {
+					wasOpen = open
+					return 0
+				}
+				key := 69 + 12*math.Log2(f/440)
+				if open && !wasOpen {
+					notes = notes[:0]
+					for _, l := range p.layers(int(math.Round(key)), vel) {
+						notes = append(notes, newPlaying(l))
+					}
+				}
+				wasOpen = open
+				if dt <= 0 {
+					return 0
+				}
+
+				var out float64
+				alive := notes[:0]
+				for _, n := range notes {
+					v, ok := n.next(key, open, dt)
+					out += v
+					if ok {
+						alive = append(alive, n)
+					}
+				}
+				notes = alive
+				return out
+			}
+		})
+	}
+}
+
+func newPlaying(l layer) playing {
+	h := l.header
+	n := playing{layer: l, released: -1, loop: l.gen(genSampleModes) & 3}
+	n.start = float64(h.start + l.gen(genStartOffset) + 32768*l.gen(genStartCoarseOffset))
+	n.end = float64(h.end + l.gen(genEndOffset) + 32768*l.gen(genEndCoarseOffset))
+	n.loopStart = float64(h.loopStart + l.gen(genLoopStartOffset) + 32768*l.gen(genLoopStartCoarse))
+	n.loopEnd = float64(h.loopEnd + l.gen(genLoopEndOffset) + 32768*l.gen(genLoopEndCoarse))
+	n.pos = n.start
+	n.level = maxAttenuation
+	return n
+}
+
+// maxAttenuation is the attenuation (in decibels) at which a voice is silent.
+const maxAttenuation = 96
+
+// next returns the next frame of the layer and whether it is still playing.
+func (n *playing) next(key float64, open bool, dt float64) (float64, bool) {
+	h := n.header
+	root := float64(n.gen(genOverridingRootKey))
+	if root < 0 {
+		root = float64(h.pitch)
+		if h.pitch > 127 {
+			root = 60
+		}
+	}
+	cents := (key-root)*float64(n.gen(genScaleTuning)) + float64(100*n.gen(genCoarseTune)+n.gen(genFineTune)+h.correction)
+	n.pos += dt * float64(h.rate) * math.Exp2(cents/1200)
+	looping := n.loop == 1 || n.loop == 3 && open
+	if looping && n.loopEnd > n.loopStart && n.pos >= n.loopEnd {
+		n.pos = n.loopStart + math.Mod(n.pos-n.loopStart, n.loopEnd-n.loopStart)
+	}
+	if n.pos >= n.end-1 || int(n.pos)+1 >= len(n.data) {
+		return 0, false
+	}
+
+	// Volume envelope: delay, linear attack and hold, then the decay to the sustain level and the release
+	// go linearly in decibels (their times are for the full 96 dB).
+	n.time += dt
+	if !open && n.released < 0 {
+		n.released, n.releaseFrom = n.time, n.level
+	}
+	delay, attack, hold := n.seconds(genDelayVolEnv), n.seconds(genAttackVolEnv), n.seconds(genHoldVolEnv)
+	var amp float64
+	switch t := n.time; {
+	case n.released >= 0:
+		n.level = n.releaseFrom + (t-n.released)/n.seconds(genReleaseVolEnv)*maxAttenuation
+		if n.level >= maxAttenuation {
+			return 0, false
+		}
+		amp = synth.DBToAmp(-n.level)
+	case t < delay:
+		n.level = maxAttenuation
+	case t < delay+attack:
+		amp = (t - delay) / attack
+		n.level = math.Min(-synth.AmpToDB(amp), maxAttenuation)
+	case t < delay+attack+hold:
+		n.level, amp = 0, 1
+	default:
+		sustain := float64(n.gen(genSustainVolEnv)) / 10
+		n.level = math.Min((t-delay-attack-hold)/n.seconds(genDecayVolEnv)*maxAttenuation, sustain)
+		if n.level >= maxAttenuation {
+			return 0, false
+		}
+		amp = synth.DBToAmp(-n.level)
+	}
+
+	i := int(n.pos)
+	t := n.pos - float64(i)
+	v := (1-t)*n.data[i] + t*n.data[i+1]
+	v *= amp * synth.DBToAmp(-float64(n.gen(genAttenuation))/10)
+
+	if fc := n.gen(genFilterFc); fc < 13500 {
+		if !n.filtered {
+			cutoff := math.Min(8.176*math.Exp2(float64(fc)/1200), 0.45/dt)
+			q := synth.DBToAmp(float64(n.gen(genFilterQ))/10) * math.Sqrt2 / 2
+			n.filter, n.filtered = synth.LowPassBiquad(1/dt, cutoff, q, 0), true
+		}
+		b := n.filter
+		y := b.B0*v + b.B1*n.x1 + b.B2*n.x2 - b.A1*n.y1 - b.A2*n.y2
+		n.x2, n.x1, n.y2, n.y1 = n.x1, v, n.y1, y
+		v = y
+	}
+	return v, true
+}
+
+// Instruments returns the presets of the SoundFont as the instruments of a MIDI file:
+// each channel plays the preset of its bank and program, channel 10 the drum kits of bank 128 (General MIDI).
+// Missing presets fall back to the program of bank 0, then to the first preset.
+func (sf *SoundFont) Instruments() midi.Instruments {
+	silent := func(freq, gate synth.Signal) synth.Signal { return synth.Constant(0) }
+	return func(program midi.Program, velocity float64) seq.VoiceFunc {
+		bank := program.Bank
+		if program.Channel == 9 {
+			bank = 128
+		}
+		p := sf.Preset(bank, program.Program)
+		if p == nil {
+			p = sf.Preset(bank, 0)
+		}
+		if p == nil && bank != 128 {
+			p = sf.Preset(0, program.Program)
+		}
+		if p == nil && len(sf.Presets) > 0 {
+			p = sf.Presets[0]
+		}
+		if p == nil {
+			return silent
+		}
+		return p.Voice(velocity)
+	}
+}
@@ -0,0 +1,62 @@
+package spatial
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Distance returns the distance between the origin and a position (x and y, in meters).
+func Distance(x, y synth.Signal) synth.Signal {
+	return func(t time.Duration) float64 { return math.Hypot(x(t), y(t)) }
+}
+
+// Azimuth returns the direction (in degrees, see HRIRSet) of a position (x to the right and y to the front,
+// in meters) for a listener at the origin facing the front.
+func Azimuth(x, y synth.Signal) synth.Signal {
+	return func(t time.Duration) float64 { return math.Atan2(x(t), y(t)) * 180 / math.Pi }
+}
+
+// Doppler delays the input by the time the sound takes to travel the distance (in meters),
+// which shifts its pitch up when the distance decreases and down when it increases (the Doppler effect),
+// and attenuates it by the inverse of the distance (-6 dB each time it doubles, from 1 meter).
+// Distances beyond max (in meters) are clamped.
+func Doppler(in, distance synth.Signal, max float64) synth.Signal {
+	return synth.Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var buf []float64
+		var pos int // Index of the next write.
+		return func(x time.Duration, dt float64) float64 {
+			v, d := in(x), math.Max(0, math.Min(distance(x), max))
+			rate := meter.Tick(x)
+			if rate == 0 {
+				return 0 // The sample rate isn't known until the second sample.
+			}
+			if buf == nil {
+				buf = make([]float64, int(max/speedOfSound*rate)+4)
+			}
+			buf[pos] = v
+			pos = (pos + 1) % len(buf)
+
+			delay := math.Min(d/speedOfSound*rate, float64(len(buf)-3)) // In frames.
+			i, t := math.Modf(delay)
+			at := func(back int) float64 { return buf[(pos-1-back+2*len(buf))%len(buf)] }
+			delayed := (1-t)*at(int(i)) + t*at(int(i)+1)
+			return delayed / math.Max(d, 1)
+		}
+	})
+}
+
+// Move plays a source moving around a listener at the origin facing the front,
+// at a position given in meters (x to the right, y to the front), up to 100 meters away:
+// its level follows the distance, its pitch the speed (with the Doppler effect) and its panning the direction.
+// For headphones, Binaural can place the source instead of panning it:
+//
+//	spatial.Binaural(spatial.Doppler(in, spatial.Distance(x, y), 100), spatial.Azimuth(x, y), synth.Constant(0), set)
+func Move(in, x, y synth.Signal) synth.MultiSignal {
+	azimuth := Azimuth(x, y)
+	pos := func(t time.Duration) float64 { return math.Sin(azimuth(t) * math.Pi / 180) } // Behind is mirrored to the front.
+	return synth.Pan(Doppler(in, Distance(x, y), 100), pos)
+}
@@ -0,0 +1,232 @@
+// Package spatial places sounds in space: binaural rendering for headphones with head-related transfer functions,
+// and moving sources with distance attenuation and Doppler shift.
+package spatial
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/decode"
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// HRIR is a pair of head-related impulse responses: how a click from a direction sounds at each ear.
+type HRIR struct {
+	Left, Right []float64
+}
+
+// HRIRSet gives the impulse responses of directions, in degrees:
+// the azimuth goes clockwise from the front (90 is right, -90 is left)
+// and the elevation from -90 (below) to 90 (above).
+type HRIRSet interface {
+	HRIR(azimuth, elevation float64, rate int) HRIR
+}
+
+// SphericalHead is a built-in HRIR set modeling the head as a sphere (after Brown and Duda, 1998):
+// the delay between ears, the shadow of the head on the far ear and a few echoes of the pinna for elevation.
+// It doesn't sound as real as measured responses, but it needs no data and works at any sample rate.
+type SphericalHead struct {
+	Radius float64 // In meters, 0.0875 if zero.
+}
+
+// speedOfSound is in meters per second.
+const speedOfSound = 343.0
+
+// HRIR computes the impulse responses of the direction.
+func (h SphericalHead) HRIR(azimuth, elevation float64, rate int) HRIR {
+	radius := h.Radius
+	if radius <= 0 {
+		radius = 0.0875
+	}
+	az, el := azimuth*math.Pi/180, elevation*math.Pi/180
+	right := math.Cos(el) * math.Sin(az) // Cosine of the angle between the source and the right ear.
+	return HRIR{
+		Left:  sphericalEar(math.Acos(-right), -azimuth, elevation, radius, float64(rate)),
+		Right: sphericalEar(math.Acos(right), azimuth, elevation, radius, float64(rate)),
+	}
+}
+
+// sphericalEar returns the impulse response of an ear at the given angle (in radians) from the source.
+func sphericalEar(angle, azimuth, elevation, radius, rate float64) []float64 {
+	ir := make([]float64, int(math.Ceil(rate*0.003))) // Long enough for the delay and the echoes.
+	at := radius / speedOfSound
+
+	// Woodworth's formula for the delay of the sound going around the head (0 at the closest point).
+	delay := at * (1 - math.Cos(angle))
+	if angle > math.Pi/2 {
+		delay = at * (1 + angle - math.Pi/2)
+	}
+	addFractional(ir, delay*rate, 1)
+
+	// Head shadow: a shelf boosting the highs in front of the ear and cutting them behind.
+	const minAlpha, minAngle = 0.1, 150 * math.Pi / 180
+	alpha := (1 + minAlpha/2) + (1-minAlpha/2)*math.Cos(angle/minAngle*math.Pi)
+	w := 2 * speedOfSound / radius / rate // Twice the corner frequency of the shelf, in radians per sample.
+	b0, b1, a1 := (w+2*alpha)/(w+2), (w-2*alpha)/(w+2), (w-2)/(w+2)
+	var x1, y1 float64
+	for i, v := range ir {
+		y := b0*v + b1*x1 - a1*y1
+		x1, y1, ir[i] = v, y, y
+	}
+
+	// Pinna echoes, whose delays change with elevation (in samples at 44.1 kHz).
+	direct := append([]float64(nil), ir...)
+	reflections := [...]struct{ gain, a, b, d float64 }{{0.5, 1, 2, 1}, {-1, 5, 4, 0.5}, {0.5, 5, 7, 0.5}, {-0.25, 5, 11, 0.5}, {0.25, 5, 13, 0.5}}
+	az := math.Max(-90, math.Min(azimuth, 90)) * math.Pi / 180
+	for _, r := range reflections {
+		samples := r.a*math.Cos(az/2)*math.Sin(r.d*(math.Pi/2-elevation*math.Pi/180)) + r.b
+		shift := samples * rate / 44100
+		for i, v := range direct {
+			addFractional(ir, float64(i)+shift, v*r.gain*0.5)
+		}
+	}
+	return ir
+}
+
+// addFractional adds v at a fractional position of the frames (split between both frames around it).
+func addFractional(frames []float64, pos, v float64) {
+	i := int(math.Floor(pos))
+	t := pos - float64(i)
+	if i >= 0 && i < len(frames) {
+		frames[i] += v * (1 - t)
+	}
+	if i+1 >= 0 && i+1 < len(frames) {
+		frames[i+1] += v * t
+	}
+}
+
+// Measured is a set of measured impulse responses (for example exported from a SOFA file),
+// the closest direction is used.
+type Measured struct {
+	Rate       int
+	Directions []Direction
+}
+
+// Direction is a measured pair of impulse responses.
+type Direction struct {
+	Azimuth, Elevation float64
+	HRIR
+}
+
+// LoadHRIRWAV loads a pair of impulse responses from a stereo WAV file (left and right ears).
+func LoadHRIRWAV(path string) (HRIR, int, error) {
+	a, err := decode.LoadWAV(path)
+	if err != nil {
+		return HRIR{}, 0, err
+	} else if len(a.Channels) != 2 {
+		return HRIR{}, 0, fmt.Errorf("%s: %d channels instead of 2", path, len(a.Channels))
+	}
+	return HRIR{Left: a.Channels[0], Right: a.Channels[1]}, a.Rate, nil
+}
+
+// HRIR returns the measured responses closest to the direction.
+// Measurements at other sample rates are resampled linearly.
+func (m *Measured) HRIR(azimuth, elevation float64, rate int) HRIR {
+	best, distance := HRIR{}, math.Inf(1)
+	for _, d := range m.Directions {
+		if a := angle(azimuth, elevation, d.Azimuth, d.Elevation); a < distance {
+			best, distance = d.HRIR, a
+		}
+	}
+	if rate == m.Rate || m.Rate <= 0 {
+		return best
+	}
+	return HRIR{Left: resampleLinear(best.Left, float64(m.Rate)/float64(rate)), Right: resampleLinear(best.Right, float64(m.Rate)/float64(rate))}
+}
+
+// angle returns the angle (in radians) between two directions (in degrees).
+func angle(az1, el1, az2, el2 float64) float64 {
+	r := math.Pi / 180
+	cos := math.Sin(el1*r)*math.Sin(el2*r) + math.Cos(el1*r)*math.Cos(el2*r)*math.Cos((az1-az2)*r)
+	return math.Acos(math.Max(-1, math.Min(cos, 1)))
+}
+
+// resampleLinear returns the frames read with the given step, scaled to keep the energy of an impulse response.
+func resampleLinear(frames []float64, step float64) []float64 {
+	out := make([]float64, int(float64(len(frames))/step))
+	for i := range out {
+		pos := float64(i) * step
+		j := int(pos)
+		t := pos - float64(j)
+		v := frames[j] * (1 - t)
+		if j+1 < len(frames) {
+			v += frames[j+1] * t
+		}
+		out[i] = v * step
+	}
+	return out
+}
+
+// hrtfStep is the angle (in degrees) by which directions are rounded, so responses are only computed again when the
+// direction moves noticeably.
+const hrtfStep = 2
+
+// hrtfFade is the number of frames over which the output crossfades when the direction changes.
+const hrtfFade = 256
+
+// Binaural places a mono signal at a direction (azimuth and elevation in degrees, see HRIRSet) for headphones,
+// by convolving it with the head-related impulse responses of each ear.
+// The direction can move: the output crossfades between the responses of successive directions.
+func Binaural(in, azimuth, elevation synth.Signal, set HRIRSet) synth.MultiSignal {
+	var right float64 // Computed with the left side.
+	left := synth.Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var history []float64 // Latest input frames, the newest last.
+		var cur, prev HRIR
+		var az, el float64
+		fade := hrtfFade // Frames since the last change of direction.
+		return func(x time.Duration, dt float64) float64 {
+			v := in(x)
+			newAz, newEl := round(azimuth(x), hrtfStep), round(elevation(x), hrtfStep)
+			rate := meter.Tick(x)
+			if rate == 0 {
+				history = append(history, v)
+				right = 0
+				return 0 // The sample rate isn't known until the second sample.
+			}
+			if cur.Left == nil || newAz != az || newEl != el {
+				prev, cur, az, el = cur, set.HRIR(newAz, newEl, int(rate)), newAz, newEl
+				fade = 0
+				if prev.Left == nil {
+					fade = hrtfFade
+				}
+			}
+			history = append(history, v)
+			if n := max(len(cur.Left), len(cur.Right), len(prev.Left), len(prev.Right)); len(history) > 2*n+1 {
+				history = append(history[:0], history[len(history)-n:]...)
+			}
+
+			l, r := convolve(history, cur.Left), convolve(history, cur.Right)
+			if fade < hrtfFade {
+				t := float64(fade) / hrtfFade
+				l = t*l + (1-t)*convolve(history, prev.Left)
+				r = t*r + (1-t)*convolve(history, prev.Right)
+				fade++
+			}
+			right = r
+			return l
+		}
+	})
+	return synth.Stereo(left, func(x time.Duration) float64 {
+		left(x)
+		return right
+	})
+}
+
+// convolve returns the latest frame of the history convolved with the impulse response.
+func convolve(history, ir []float64) float64 {
+	var sum float64
+	for i, h := range ir {
+		j := len(history) - 1 - i
+		if j < 0 {
+			break
+		}
+		sum += h * history[j]
+	}
+	return sum
+}
+
+// round rounds v to a multiple of step.
+func round(v, step float64) float64 { return math.Round(v/step) * step }
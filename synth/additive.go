@@ -0,0 +1,74 @@
+package synth
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+)
+
+// Partial is a sine component of an additive sound.
+type Partial struct {
+	Ratio     float64 // Frequency relative to the fundamental (1, 2, 3... for harmonics).
+	Amplitude float64
+	Detune    float64 // In cents.
+	Envelope  Signal  // Level of the partial over time (like an ADSR), always 1 if nil.
+}
+
+// Harmonics returns the first n harmonics, with the amplitude of the k-th harmonic (starting at 1) given by amplitude.
+// For example, 1/k gives the spectrum of a saw wave.
+func Harmonics(n int, amplitude func(k int) float64) []Partial {
+	partials := make([]Partial, n)
+	for i := range partials {
+		partials[i] = Partial{Ratio: float64(i + 1), Amplitude: amplitude(i + 1)}
+	}
+	return partials
+}
+
+// Additive returns the sum of sine waves at the frequencies of the partials relative to freq (in Hertz).
+//
+// It is band-limited: partials above the Nyquist frequency (half the sample rate) are dropped,
+// and faded out as they approach it, so sweeping the frequency doesn't click.
+func Additive(freq Signal, partials []Partial) Signal {
+	detunes := make([]float64, len(partials))
+	for i, p := range partials {
+		detunes[i] = p.Ratio * math.Exp2(p.Detune/1200)
+	}
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		phases := make([]float64, len(partials))
+		return func(x time.Duration, dt float64) float64 {
+			rate := meter.Tick(x)
+			if rate == 0 {
+				return 0 // The sample rate isn't known until the second sample (and all phases are 0).
+			}
+			f, nyquist := freq(x), rate/2
+			var out float64
+			for i, p := range partials {
+				pf := f * detunes[i]
+				phases[i] = frac(phases[i] + pf/rate)
+				level := nyquistFade(math.Abs(pf), nyquist)
+				if level == 0 {
+					continue
+				}
+				if p.Envelope != nil {
+					level *= p.Envelope(x)
+				}
+				out += level * p.Amplitude * math.Sin(2*math.Pi*phases[i])
+			}
+			return out
+		}
+	})
+}
+
+// nyquistFade returns the level of a partial at the given frequency: 1 up to 90% of the Nyquist frequency,
+// fading to 0 at the Nyquist frequency.
+func nyquistFade(f, nyquist float64) float64 {
+	switch {
+	case f >= nyquist:
+		return 0
+	case f <= 0.9*nyquist:
+		return 1
+	}
+	return (nyquist - f) / (0.1 * nyquist)
+}
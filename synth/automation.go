@@ -0,0 +1,69 @@
+package synth
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+)
+
+// Ramp is the shape of an automation curve between two points.
+type Ramp int
+
+const (
+	LinearRamp Ramp = iota // Straight line.
+	ExpRamp                // Exponential (constant ratio per second), natural for frequencies and amplitudes.
+	SmoothRamp             // S-curve, starting and ending slowly.
+	Hold                   // Keeps the previous value until the point, then jumps.
+)
+
+// Point is a breakpoint of an automation curve.
+type Point struct {
+	At    time.Duration
+	Value float64
+	Ramp  Ramp // Shape of the curve from the previous point to this one.
+}
+
+// Automation returns a curve going through the points, like the automation lanes of a DAW,
+// to draw the evolution of a parameter (a filter cutoff, a volume, a pan position) over time.
+// The value of the first point is held before it and the value of the last point after it.
+//
+// Exponential ramps between values of different signs (or zero) are linear.
+func Automation(points ...Point) Signal {
+	points = slices.Clone(points)
+	slices.SortStableFunc(points, func(a, b Point) int { return cmp.Compare(a.At, b.At) })
+	return func(x time.Duration) float64 {
+		if len(points) == 0 {
+			return 0
+		}
+		i, _ := slices.BinarySearchFunc(points, x, func(p Point, x time.Duration) int {
+			if p.At <= x {
+				return -1
+			}
+			return 1
+		})
+		if i == 0 {
+			return points[0].Value
+		} else if i == len(points) {
+			return points[i-1].Value
+		}
+		from, to := points[i-1], points[i]
+		t := float64(x-from.At) / float64(to.At-from.At)
+		return ramp(from.Value, to.Value, t, to.Ramp)
+	}
+}
+
+// ramp returns the value at t (between 0 and 1) of a ramp from a to b.
+func ramp(a, b, t float64, shape Ramp) float64 {
+	switch shape {
+	case ExpRamp:
+		if a*b > 0 {
+			return a * math.Pow(b/a, t)
+		}
+	case SmoothRamp:
+		t = t * t * (3 - 2*t)
+	case Hold:
+		return a
+	}
+	return a + (b-a)*t
+}
@@ -0,0 +1,141 @@
+package synth
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+)
+
+// FeedforwardComb adds a copy of the input delayed by a period of the frequency (in Hertz), scaled by the gain
+// (between -1 and 1): the harmonics of the frequency are boosted (or cut with a negative gain) and the frequencies
+// between them are cut (or boosted), like the teeth of a comb.
+func FeedforwardComb(in, freq, gain Signal) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var line delayLine
+		return func(x time.Duration, dt float64) float64 {
+			v := in(x)
+			rate := meter.Tick(x)
+			var delayed float64
+			if rate > 0 {
+				delayed = line.read(combDelay(freq(x), rate))
+			}
+			line.write(v)
+			return v + gain(x)*delayed
+		}
+	})
+}
+
+// FeedbackComb sends its output back into its input after a period of the frequency (in Hertz), scaled by the feedback
+// (between -1 and 1): the harmonics of the frequency resonate (odd harmonics an octave lower with a negative feedback),
+// the closer the feedback to 1, the longer they ring. It is the resonator of the Karplus-Strong algorithm:
+// short bursts played through it sound like plucked strings or metallic hits.
+//
+// The resonances are much louder than the input when the feedback is high.
+func FeedbackComb(in, freq, feedback Signal) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var line delayLine
+		return func(x time.Duration, dt float64) float64 {
+			v := in(x)
+			rate := meter.Tick(x)
+			var delayed float64
+			if rate > 0 {
+				delayed = line.read(combDelay(freq(x), rate))
+			}
+			g := math.Max(-0.9999, math.Min(feedback(x), 0.9999)) // Stable.
+			y := v + g*delayed
+			line.write(y)
+			return y
+		}
+	})
+}
+
+// combDelay returns the period of the frequency in frames, within the supported delays.
+func combDelay(freq, rate float64) float64 {
+	return math.Max(1, math.Min(rate/math.Max(freq, 1), MaxDelay.Seconds()*rate))
+}
+
+// Mode is a resonance of a struck object, as a multiple of its fundamental.
+type Mode struct {
+	Ratio float64       // Frequency, relative to the fundamental.
+	Gain  float64       // In decibels.
+	Decay time.Duration // Time to fade by 60 dB.
+}
+
+// Modes of common struck objects.
+var (
+	// BarModes are the modes of a free bar, like the bars of a glockenspiel or a marimba.
+	BarModes = []Mode{{1, 0, 2 * time.Second}, {2.756, -6, 1200 * time.Millisecond}, {5.404, -12, 600 * time.Millisecond}, {8.933, -18, 300 * time.Millisecond}}
+	// BellModes are the partials of a church bell, named after the fundamental (the prime, 1):
+	// the hum, tierce, quint, nominal and upper partials.
+	BellModes = []Mode{
+		{0.5, -3, 6 * time.Second}, {1, 0, 4 * time.Second}, {1.183, -3, 3500 * time.Millisecond}, {1.506, -9, 2500 * time.Millisecond},
+		{2, -2, 2 * time.Second}, {2.514, -10, 1500 * time.Millisecond}, {2.662, -8, 1300 * time.Millisecond}, {3.011, -12, time.Second},
+		{4.166, -14, 700 * time.Millisecond}, {5.433, -18, 500 * time.Millisecond}, {6.796, -22, 350 * time.Millisecond},
+	}
+	// TubeModes are the modes of a tubular bell (a chime).
+	TubeModes = []Mode{{1, 0, 5 * time.Second}, {2.76, -4, 3 * time.Second}, {5.40, -8, 2 * time.Second}, {8.93, -14, time.Second}, {13.34, -20, 600 * time.Millisecond}}
+)
+
+// Resonator is a bank of resonant filters tuned to the modes of an object above the frequency (in Hertz),
+// each ringing for its own decay: an impulse (see Impulse) or a short burst of noise played through it
+// sounds like the object being struck (modal synthesis). An impulse makes each mode ring at its gain.
+//
+// Unlike FeedbackComb, whose resonances are all the harmonics of its frequency, each mode is a pure partial,
+// so inharmonic ratios give bells and metallic tones.
+// Modes above the Nyquist frequency are dropped.
+func Resonator(in, freq Signal, modes ...Mode) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		resonators := make([]resonator, len(modes))
+		var rate, f, first float64
+		return func(x time.Duration, dt float64) float64 {
+			v := in(x)
+			newRate, newF := meter.Tick(x), freq(x)
+			if newRate == 0 {
+				first = v // The sample rate isn't known until the second sample.
+				return 0
+			}
+			if newRate != rate || newF != f {
+				for i, m := range modes {
+					resonators[i].tune(newRate, newF*m.Ratio, m.Decay, DBToAmp(m.Gain))
+					if rate == 0 {
+						resonators[i].process(first) // So strikes on the first sample ring.
+					}
+				}
+				rate, f = newRate, newF
+			}
+			var out float64
+			for i := range resonators {
+				out += resonators[i].process(v)
+			}
+			return out
+		}
+	})
+}
+
+// resonator is a two-pole filter ringing at a frequency: y[n] = b0*x[n] + a1*y[n-1] - a2*y[n-2].
+type resonator struct {
+	b0, a1, a2 float64
+	y1, y2     float64
+}
+
+// tune sets the frequency, decay and gain of the resonator, keeping its state so retuning doesn't click.
+func (r *resonator) tune(rate, freq float64, decay time.Duration, gain float64) {
+	if freq <= 0 || freq >= 0.49*rate {
+		r.b0, r.a1, r.a2 = 0, 0, 0
+		return
+	}
+	w := 2 * math.Pi * freq / rate
+	radius := math.Exp(-math.Log(1000) / (math.Max(decay.Seconds(), 1e-3) * rate)) // -60 dB after the decay.
+	// The impulse response is radius^n sin((n+1)w) / sin(w) times b0, so this scales it to the gain.
+	r.b0, r.a1, r.a2 = gain*math.Sin(w), 2*radius*math.Cos(w), radius*radius
+}
+
+func (r *resonator) process(v float64) float64 {
+	y := r.b0*v + r.a1*r.y1 - r.a2*r.y2
+	r.y2, r.y1 = r.y1, y
+	return y
+}
@@ -0,0 +1,65 @@
+package synth
+
+import (
+	"math"
+	"time"
+)
+
+// Add returns the sum of the given signals.
+func Add(signals ...Signal) Signal {
+	return func(x time.Duration) (y float64) {
+		for _, s := range signals {
+			y += s(x)
+		}
+		return y
+	}
+}
+
+// Mul returns the product of the given signals.
+// This is typically used to apply an envelope to an oscillator.
+func Mul(signals ...Signal) Signal {
+	return func(x time.Duration) (y float64) {
+		y = 1
+		for _, s := range signals {
+			y *= s(x)
+		}
+		return y
+	}
+}
+
+// Mix returns the weighted sum of the given signals.
+// It panics if the number of weights and signals differ.
+func Mix(weights []float64, signals ...Signal) Signal {
+	if len(weights) != len(signals) {
+		panic("synth: mix weights and signals must have the same length")
+	}
+	return func(x time.Duration) (y float64) {
+		for i, s := range signals {
+			y += weights[i] * s(x)
+		}
+		return y
+	}
+}
+
+// Gain returns the signal amplified by the given gain (in decibels).
+func Gain(s Signal, db float64) Signal {
+	factor := DBToAmp(db)
+	return func(x time.Duration) float64 { return factor * s(x) }
+}
+
+// Offset returns the signal shifted by v.
+// For example, Offset(s, 1) turns a bipolar signal (between -1 and 1) into a unipolar one (between 0 and 2).
+func Offset(s Signal, v float64) Signal {
+	return func(x time.Duration) float64 { return s(x) + v }
+}
+
+// Clamp returns the signal limited to the range [min, max].
+func Clamp(s Signal, min, max float64) Signal {
+	return func(x time.Duration) float64 { return math.Max(min, math.Min(max, s(x))) }
+}
+
+// DBToAmp converts a gain in decibels to an amplitude factor.
+func DBToAmp(db float64) float64 { return math.Pow(10, db/20) }
+
+// AmpToDB converts an amplitude factor to a gain in decibels.
+func AmpToDB(amp float64) float64 { return 20 * math.Log10(amp) }
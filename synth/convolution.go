@@ -0,0 +1,97 @@
+package synth
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+	"github.com/ejuju/poc-go-audio-synthesis/internal/fft"
+)
+
+// convolutionBlock is the size of the partitions of the impulse response.
+const convolutionBlock = 256
+
+// ConvolutionReverb convolves the input with an impulse response (the recording of a room's echo
+// to a short click, usually loaded from a WAV file with decode.LoadWAV),
+// which makes the input sound as if it was played in that room.
+//
+// The impulse response must have the same sample rate as the render.
+// The mix goes from 0 (only the input) to 1 (only the reverb),
+// and the pre-delay separates the input from the start of the reverb.
+//
+// The first samples of the impulse response are convolved directly so there is no added latency,
+// the rest is convolved block by block in the frequency domain (uniformly partitioned convolution),
+// which keeps long impulse responses cheap to render.
+func ConvolutionReverb(in Signal, ir []float64, mix float64, preDelay time.Duration) Signal {
+	const b = convolutionBlock
+	head := ir[:min(b, len(ir))]
+
+	// Spectra of the other partitions, zero-padded to twice the block size.
+	var partitions [][]complex128
+	for start := b; start < len(ir); start += b {
+		p := make([]complex128, 2*b)
+		for i, v := range ir[start:min(start+b, len(ir))] {
+			p[i] = complex(v, 0)
+		}
+		fft.Forward(p)
+		partitions = append(partitions, p)
+	}
+
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var delay, history delayLine
+		var block, prev, tail [b]float64
+		pos := 0
+		spectra := make([][]complex128, len(partitions)) // Spectra of the latest input blocks, most recent first.
+		for i := range spectra {
+			spectra[i] = make([]complex128, 2*b)
+		}
+		acc := make([]complex128, 2*b)
+
+		return func(x time.Duration, dt float64) float64 {
+			v := in(x)
+			rate := meter.Tick(x)
+			send := v
+			if preDelay > 0 {
+				send = 0
+				if rate > 0 {
+					send = delay.read(math.Max(1, preDelay.Seconds()*rate))
+				}
+				delay.write(v)
+			}
+
+			// Direct convolution with the head of the impulse response.
+			history.write(send)
+			wet := tail[pos]
+			for i, h := range head {
+				wet += h * history.at(i+1)
+			}
+
+			// Convolution of the tail with the latest complete blocks, played during the next block.
+			block[pos] = send
+			pos++
+			if pos == b && len(partitions) > 0 {
+				last := spectra[len(spectra)-1]
+				copy(spectra[1:], spectra)
+				spectra[0] = last
+				for i := 0; i < b; i++ {
+					last[i], last[b+i] = complex(prev[i], 0), complex(block[i], 0)
+				}
+				fft.Forward(last)
+				clear(acc)
+				for k, p := range partitions {
+					for i, s := range spectra[k] {
+						acc[i] += s * p[i]
+					}
+				}
+				fft.Inverse(acc)
+				for i := range tail {
+					tail[i] = real(acc[b+i])
+				}
+				prev = block
+			}
+			pos %= b
+			return (1-mix)*v + mix*wet
+		}
+	})
+}
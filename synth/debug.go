@@ -0,0 +1,126 @@
+package synth
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Debugger collects statistics about the signals wrapped by its probes,
+// to find where NaN or infinite values (which silently propagate through the whole graph) first appear.
+type Debugger struct {
+	Limit float64 // Values beyond ±Limit are counted as out of range, 1 by default.
+
+	mu     sync.Mutex
+	probes []*Probe
+	bad    int // Number of bad values seen, to order probes by their first bad value.
+}
+
+// Probe holds the statistics of a signal wrapped by a debugger.
+type Probe struct {
+	Name           string
+	Samples        int
+	Min, Max       float64
+	NaN, Inf, Clip int           // Number of NaN, infinite and out of range values.
+	FirstBad       time.Duration // Time of the first NaN or infinite value.
+	FirstBadValue  float64
+	sumSquares     float64
+	order          int // Position of the first bad value among all bad values of the debugger (0 if none).
+}
+
+// RMS returns the root mean square of the finite values.
+func (p *Probe) RMS() float64 {
+	finite := p.Samples - p.NaN - p.Inf
+	if finite == 0 {
+		return 0
+	}
+	return math.Sqrt(p.sumSquares / float64(finite))
+}
+
+// DefaultDebugger is the debugger used by Debug.
+var DefaultDebugger = &Debugger{}
+
+// Debug wraps the signal with a probe of the default debugger, see Debugger.Wrap.
+func Debug(name string, in Signal) Signal { return DefaultDebugger.Wrap(name, in) }
+
+// Wrap returns the signal unchanged, recording statistics about its values under the given name.
+// Wrapping several nodes of a graph tells where bad values come from:
+// since inputs are evaluated before the signals using them, the first probe to see a bad value is the closest to its origin.
+func (d *Debugger) Wrap(name string, in Signal) Signal {
+	p := &Probe{Name: name, Min: math.Inf(1), Max: math.Inf(-1)}
+	d.mu.Lock()
+	d.probes = append(d.probes, p)
+	d.mu.Unlock()
+	limit := d.Limit
+	if limit <= 0 {
+		limit = 1
+	}
+	return func(x time.Duration) float64 {
+		v := in(x)
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		p.Samples++
+		switch {
+		case math.IsNaN(v), math.IsInf(v, 0):
+			if math.IsNaN(v) {
+				p.NaN++
+			} else {
+				p.Inf++
+			}
+			d.bad++
+			if p.order == 0 {
+				p.order, p.FirstBad, p.FirstBadValue = d.bad, x, v
+			}
+			return v
+		case math.Abs(v) > limit:
+			p.Clip++
+		}
+		p.Min, p.Max = math.Min(p.Min, v), math.Max(p.Max, v)
+		p.sumSquares += v * v
+		return v
+	}
+}
+
+// Probes returns a copy of the statistics of all probes.
+func (d *Debugger) Probes() []Probe {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	probes := make([]Probe, len(d.probes))
+	for i, p := range d.probes {
+		probes[i] = *p
+	}
+	return probes
+}
+
+// Origin returns the probe that saw the first bad value, and false if no probe saw any.
+func (d *Debugger) Origin() (Probe, bool) {
+	var origin Probe
+	found := false
+	for _, p := range d.Probes() {
+		if p.order > 0 && (!found || p.order < origin.order) {
+			origin, found = p, true
+		}
+	}
+	return origin, found
+}
+
+// Report returns a summary of the statistics of all probes and of where the first bad value appeared.
+func (d *Debugger) Report() string {
+	var b strings.Builder
+	for _, p := range d.Probes() {
+		fmt.Fprintf(&b, "%s: %d samples, min %.4g, max %.4g, rms %.4g", p.Name, p.Samples, p.Min, p.Max, p.RMS())
+		if p.Clip > 0 {
+			fmt.Fprintf(&b, ", %d out of range", p.Clip)
+		}
+		if p.NaN+p.Inf > 0 {
+			fmt.Fprintf(&b, ", %d NaN, %d Inf (first %v at %v)", p.NaN, p.Inf, p.FirstBadValue, p.FirstBad)
+		}
+		b.WriteByte('\n')
+	}
+	if origin, ok := d.Origin(); ok {
+		fmt.Fprintf(&b, "first bad value: %v in %q at %v\n", origin.FirstBadValue, origin.Name, origin.FirstBad)
+	}
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package synth
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+)
+
+// MaxDelay is the longest delay supported by delay-based effects.
+const MaxDelay = 10 * time.Second
+
+// delayLine is a circular buffer of past samples that grows as longer delays are requested.
+type delayLine struct {
+	buf []float64
+	pos int // Index where the next sample is written.
+}
+
+// write appends a new sample.
+func (d *delayLine) write(v float64) {
+	if len(d.buf) == 0 {
+		d.buf = make([]float64, 1024)
+	}
+	d.buf[d.pos] = v
+	d.pos = (d.pos + 1) % len(d.buf)
+}
+
+// read returns the sample written the given number of samples ago (at least 1),
+// interpolating linearly between samples for fractional delays.
+func (d *delayLine) read(delay float64) float64 {
+	delay = math.Max(1, delay)
+	d.grow(int(delay) + 2)
+	i := int(delay)
+	t := delay - float64(i)
+	return (1-t)*d.at(i) + t*d.at(i+1)
+}
+
+// at returns the sample written n samples ago.
+func (d *delayLine) at(n int) float64 {
+	return d.buf[((d.pos-n)%len(d.buf)+len(d.buf))%len(d.buf)]
+}
+
+// grow makes sure the buffer can hold at least n samples, keeping the samples already written.
+func (d *delayLine) grow(n int) {
+	if n <= len(d.buf) {
+		return
+	}
+	size := max(1024, len(d.buf))
+	for size < n {
+		size *= 2
+	}
+	buf := make([]float64, size)
+	for i := 1; i <= len(d.buf); i++ {
+		buf[size-i] = d.at(i)
+	}
+	d.buf, d.pos = buf, 0
+}
+
+// Delay returns the input mixed with delayed copies of itself (echoes).
+//
+// The delay time (in seconds, up to MaxDelay) is a signal so it can be modulated (for chorus or flanger effects).
+// The feedback (between 0 and 1) is the portion of the output sent back into the delay line,
+// it controls how long the echoes last. The mix goes from 0 (only the input) to 1 (only the echoes).
+func Delay(in, delay, feedback, mix Signal) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var line delayLine
+		return func(x time.Duration, dt float64) float64 {
+			v := in(x)
+			rate := meter.Tick(x)
+			d := math.Max(0, math.Min(delay(x), MaxDelay.Seconds()))
+			var delayed float64
+			if rate > 0 {
+				delayed = line.read(d * rate)
+			}
+			line.write(v + feedback(x)*delayed)
+			m := mix(x)
+			return (1-m)*v + m*delayed
+		}
+	})
+}
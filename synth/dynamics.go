@@ -0,0 +1,200 @@
+package synth
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+)
+
+// Compress reduces the level of the input above the threshold (in decibels) by the given ratio
+// (for example 4 means that 4 dB above the threshold come out as 1 dB above it).
+// The attack and release control how fast the compressor reacts when the level goes above or below the threshold.
+func Compress(in Signal, threshold, ratio float64, attack, release time.Duration) Signal {
+	return compress(in, in, threshold, ratio, attack, release)
+}
+
+// CompressSidechain is like Compress but the gain reduction follows the level of key instead of the input,
+// for example to duck a bass or a pad each time the kick plays.
+func CompressSidechain(in, key Signal, threshold, ratio float64, attack, release time.Duration) Signal {
+	return compress(in, key, threshold, ratio, attack, release)
+}
+
+// compress is a feed-forward compressor whose gain reduction is computed from the level of key.
+func compress(in, key Signal, threshold, ratio float64, attack, release time.Duration) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		c := compressor{threshold: threshold, ratio: ratio, attack: attack, release: release}
+		return func(x time.Duration, dt float64) float64 {
+			v, k := in(x), key(x)
+			return v * c.gain(k, meter.Tick(x))
+		}
+	})
+}
+
+// compressor computes the gain of a feed-forward compressor, sample by sample.
+type compressor struct {
+	threshold, ratio float64
+	attack, release  time.Duration
+	reduction        float64 // Current gain reduction, in decibels.
+}
+
+// gain returns the gain to apply, given the current sample of the key (0 is the unknown sample rate of the first sample).
+func (c *compressor) gain(key, rate float64) float64 {
+	level := AmpToDB(math.Max(math.Abs(key), 1e-9))
+	target := 0.0
+	if level > c.threshold {
+		target = (level - c.threshold) * (1 - 1/math.Max(c.ratio, 1))
+	}
+	if rate > 0 {
+		t := c.release
+		if target > c.reduction {
+			t = c.attack
+		}
+		c.reduction += (target - c.reduction) * smoothing(t, rate)
+	}
+	return DBToAmp(-c.reduction)
+}
+
+// EnvelopeFollower returns the amplitude of the input (between 0 and its peak level),
+// rising in about the attack time and falling in about the release time.
+// It can be used to control other signals with the level of an audio signal.
+func EnvelopeFollower(in Signal, attack, release time.Duration) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var env float64
+		return func(x time.Duration, dt float64) float64 {
+			v := math.Abs(in(x))
+			rate := meter.Tick(x)
+			if rate <= 0 {
+				return env
+			}
+			t := release
+			if v > env {
+				t = attack
+			}
+			env += (v - env) * smoothing(t, rate)
+			return env
+		}
+	})
+}
+
+// smoothing returns the coefficient of a one-pole smoother reaching about 63% of its target in the given time.
+func smoothing(t time.Duration, rate float64) float64 {
+	if t <= 0 {
+		return 1
+	}
+	return 1 - math.Exp(-1/(t.Seconds()*rate))
+}
+
+// Limit keeps the input below the ceiling (in decibels), whatever its level,
+// so that mixing many voices together doesn't clip.
+func Limit(in Signal, ceiling float64) Signal {
+	return LimitLookahead(in, ceiling, 0, 50*time.Millisecond)
+}
+
+// LimitLookahead is a brickwall limiter that looks at the input ahead of time to lower the gain smoothly
+// before peaks instead of reacting when they are already there.
+// The output is delayed by the lookahead, and the release controls how fast the gain comes back up after a peak.
+func LimitLookahead(in Signal, ceiling float64, lookahead, release time.Duration) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		l := newLimiter(ceiling, lookahead, release, 1)
+		frame := make([]float64, 1)
+		return func(x time.Duration, dt float64) float64 {
+			frame[0] = in(x)
+			l.process(x, frame)
+			return frame[0]
+		}
+	})
+}
+
+// LimitLinked is like LimitLookahead for all the channels of a signal at once: the gain is computed from the loudest
+// channel and applied to all of them, so a peak on one side doesn't shift the stereo image.
+// Each frame is computed once for all channels, so the output must be played by a single goroutine.
+func LimitLinked(in MultiSignal, ceiling float64, lookahead, release time.Duration) MultiSignal {
+	frame := make([]float64, len(in))
+	step := Stateful(func() func(x time.Duration, dt float64) float64 {
+		l := newLimiter(ceiling, lookahead, release, len(in))
+		return func(x time.Duration, dt float64) float64 {
+			for c, s := range in {
+				frame[c] = s(x)
+			}
+			l.process(x, frame)
+			return 0
+		}
+	})
+	out := make(MultiSignal, len(in))
+	for c := range out {
+		out[c] = func(x time.Duration) float64 {
+			step(x) // Computes the frame if it's a new one.
+			return frame[c]
+		}
+	}
+	return out
+}
+
+// limiter is the state of a lookahead limiter, shared by the channels it limits.
+type limiter struct {
+	ceiling            float64 // Linear.
+	lookahead, release time.Duration
+	meter              dsp.RateMeter
+	lines              []delayLine // One per channel.
+	window             slidingMin
+	gain               float64
+}
+
+func newLimiter(ceiling float64, lookahead, release time.Duration, channels int) *limiter {
+	return &limiter{ceiling: DBToAmp(ceiling), lookahead: lookahead, release: release, lines: make([]delayLine, channels), gain: 1}
+}
+
+// required returns the gain keeping a peak below the ceiling.
+func (l *limiter) required(peak float64) float64 { return math.Min(1, l.ceiling/math.Max(peak, 1e-12)) }
+
+// process limits a frame (a sample per channel) in place, delaying it by the lookahead.
+func (l *limiter) process(x time.Duration, frame []float64) {
+	rate := l.meter.Tick(x)
+	n := 0
+	if rate > 0 {
+		n = int(l.lookahead.Seconds() * rate)
+	}
+	var peak, delayedPeak float64
+	for c, v := range frame {
+		peak = math.Max(peak, math.Abs(v))
+		if n > 0 {
+			frame[c] = l.lines[c].read(float64(n))
+		}
+		l.lines[c].write(v)
+		delayedPeak = math.Max(delayedPeak, math.Abs(frame[c]))
+	}
+
+	target := l.window.push(l.required(peak), n+1)
+	if target < l.gain {
+		l.gain += (target - l.gain) * smoothing(l.lookahead/4, math.Max(rate, 1))
+	} else if rate > 0 {
+		l.gain += (target - l.gain) * smoothing(l.release, rate)
+	}
+	g := math.Min(l.gain, l.required(delayedPeak))
+	for c := range frame {
+		frame[c] *= g
+	}
+}
+
+// slidingMin keeps the minimum of the latest values using a monotonic queue.
+type slidingMin struct {
+	n      int // Number of values pushed so far.
+	values []float64
+	index  []int
+}
+
+// push adds a value and returns the minimum of the latest size values.
+func (m *slidingMin) push(v float64, size int) float64 {
+	for len(m.values) > 0 && m.values[len(m.values)-1] >= v {
+		m.values, m.index = m.values[:len(m.values)-1], m.index[:len(m.index)-1]
+	}
+	m.values, m.index = append(m.values, v), append(m.index, m.n)
+	for m.index[0] <= m.n-size {
+		m.values, m.index = m.values[1:], m.index[1:]
+	}
+	m.n++
+	return m.values[0]
+}
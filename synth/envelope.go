@@ -0,0 +1,82 @@
+package synth
+
+import "time"
+
+// A gate is a signal that tells when a note is held: it is open while its value is positive,
+// and closed otherwise. Gates drive envelopes (and later sequencers and voices).
+
+// Gate returns a gate that opens at the given time and stays open for the given length.
+func Gate(at, length time.Duration) Signal {
+	return func(x time.Duration) float64 {
+		if x >= at && x < at+length {
+			return 1
+		}
+		return 0
+	}
+}
+
+// isOpen reports whether the gate value means the note is held.
+func isOpen(gate float64) bool { return gate > 0 }
+
+// ADSR returns an envelope (between 0 and 1) following the given gate:
+//   - when the gate opens, the envelope rises to 1 during attack,
+//   - then it falls to the sustain level during decay and stays there while the gate is open,
+//   - when the gate closes, it falls back to 0 during release.
+//
+// The gate may open again before the release is over, in which case the attack starts from the current level.
+func ADSR(gate Signal, attack, decay time.Duration, sustain float64, release time.Duration) Signal {
+	const (
+		idle = iota
+		attacking
+		decaying
+		sustaining
+		releasing
+	)
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		stage := idle
+		var level, releaseFrom float64
+		return func(x time.Duration, dt float64) float64 {
+			open := isOpen(gate(x))
+			switch {
+			case open && (stage == idle || stage == releasing):
+				stage = attacking
+			case !open && stage != idle && stage != releasing:
+				stage, releaseFrom = releasing, level
+			}
+
+			switch stage {
+			case attacking:
+				level = advance(level, 1, 1, attack, dt)
+				if level >= 1 {
+					stage = decaying
+				}
+			case decaying:
+				level = advance(level, sustain, 1-sustain, decay, dt)
+				if level <= sustain {
+					stage = sustaining
+				}
+			case sustaining:
+				level = sustain
+			case releasing:
+				level = advance(level, 0, releaseFrom, release, dt)
+				if level <= 0 {
+					stage = idle
+				}
+			}
+			return level
+		}
+	})
+}
+
+// advance moves v linearly towards target, covering the given distance in the given duration,
+// and returns the new value after dt seconds (without overshooting the target).
+func advance(v, target, distance float64, d time.Duration, dt float64) float64 {
+	if d <= 0 {
+		return target
+	}
+	step := distance * dt / d.Seconds()
+	if v < target {
+		return min(v+step, target)
+	}
+	return max(v-step, target)
+}
@@ -0,0 +1,98 @@
+package synth
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/cmplx"
+	"strings"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+)
+
+// EQBand is a band of a parametric equalizer.
+type EQBand struct {
+	Design BiquadDesign // PeakBiquad if nil, usually LowShelfBiquad, HighShelfBiquad, or a pass filter to cut the ends.
+	Freq   float64      // Center or cutoff frequency, in Hertz.
+	Q      float64      // 0.707 if zero.
+	Gain   float64      // In decibels.
+}
+
+// EQ is a parametric equalizer with any number of bands (like a low shelf, a few peaks and a high shelf),
+// applied one after the other.
+type EQ []EQBand
+
+// biquads returns the filters of the bands at the given sample rate.
+func (eq EQ) biquads(rate float64) []Biquad {
+	filters := make([]Biquad, len(eq))
+	for i, b := range eq {
+		design, q := b.Design, b.Q
+		if design == nil {
+			design = PeakBiquad
+		}
+		if q == 0 {
+			q = 0.707
+		}
+		filters[i] = design(rate, b.Freq, q, b.Gain)
+	}
+	return filters
+}
+
+// Apply returns the input equalized.
+// The bands are fixed, use Filter for modulated bands.
+func (eq EQ) Apply(in Signal) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var filters []Biquad
+		states := make([]biquadState, len(eq))
+		return func(x time.Duration, dt float64) float64 {
+			v := in(x)
+			rate := meter.Tick(x)
+			if rate == 0 {
+				if len(states) > 0 {
+					states[0].x1 = v // The sample rate isn't known until the second sample.
+				}
+				return 0
+			}
+			if filters == nil {
+				filters = eq.biquads(rate)
+			}
+			for i, b := range filters {
+				v = states[i].process(b, v)
+			}
+			return v
+		}
+	})
+}
+
+// Response returns the gain of the equalizer (in decibels) at the given frequency (in Hertz).
+func (eq EQ) Response(rate, freq float64) float64 {
+	h := complex(1, 0)
+	for _, b := range eq.biquads(rate) {
+		h *= b.Response(rate, freq)
+	}
+	return AmpToDB(math.Max(cmplx.Abs(h), 1e-12))
+}
+
+// WriteResponse prints the response of the equalizer at third-octave frequencies from 20 Hz to 20 kHz
+// (or the Nyquist frequency), with a bar for each frequency from -24 to +24 dB, to check its settings.
+func (eq EQ) WriteResponse(w io.Writer, rate float64) error {
+	const scale = 2 // Characters per decibel.
+	for i := -17; ; i++ {
+		freq := 1000 * math.Pow(2, float64(i)/3)
+		if freq > math.Min(20000, 0.49*rate) {
+			return nil
+		}
+		db := eq.Response(rate, freq)
+		n := int(math.Round(math.Max(-24, math.Min(db, 24)) * scale))
+		bar := strings.Repeat(" ", 24*scale) + "|" + strings.Repeat("#", max(n, 0))
+		if n < 0 {
+			bar = strings.Repeat(" ", 24*scale+n) + strings.Repeat("#", -n) + "|"
+		}
+		_, err := fmt.Fprintf(w, "%7.0f Hz %+6.1f dB %s\n", freq, db, bar)
+		if err != nil {
+			return err
+		}
+	}
+}
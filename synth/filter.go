@@ -0,0 +1,194 @@
+package synth
+
+import (
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+)
+
+// Biquad holds the normalized coefficients of a second-order IIR filter:
+//
+//	y[n] = B0*x[n] + B1*x[n-1] + B2*x[n-2] - A1*y[n-1] - A2*y[n-2]
+//
+// The designs follow Robert Bristow-Johnson's "Audio EQ Cookbook".
+type Biquad struct {
+	B0, B1, B2, A1, A2 float64
+}
+
+// BiquadDesign computes the coefficients of a filter for the given sample rate,
+// cutoff (or center) frequency (in Hertz), resonance (Q) and gain (in decibels, for peak and shelving filters).
+type BiquadDesign func(rate, freq, q, gain float64) Biquad
+
+// normalize divides all coefficients by a0.
+func normalize(b0, b1, b2, a0, a1, a2 float64) Biquad {
+	return Biquad{B0: b0 / a0, B1: b1 / a0, B2: b2 / a0, A1: a1 / a0, A2: a2 / a0}
+}
+
+// rbj returns the intermediate values shared by the cookbook designs.
+// The frequency is kept below Nyquist and the resonance above zero so the filter stays stable.
+func rbj(rate, freq, q float64) (cos, alpha float64) {
+	freq = math.Max(1, math.Min(freq, 0.49*rate))
+	q = math.Max(q, 0.01)
+	w0 := 2 * math.Pi * freq / rate
+	return math.Cos(w0), math.Sin(w0) / (2 * q)
+}
+
+// LowPassBiquad designs a low-pass filter.
+func LowPassBiquad(rate, freq, q, _ float64) Biquad {
+	c, a := rbj(rate, freq, q)
+	return normalize((1-c)/2, 1-c, (1-c)/2, 1+a, -2*c, 1-a)
+}
+
+// HighPassBiquad designs a high-pass filter.
+func HighPassBiquad(rate, freq, q, _ float64) Biquad {
+	c, a := rbj(rate, freq, q)
+	return normalize((1+c)/2, -(1 + c), (1+c)/2, 1+a, -2*c, 1-a)
+}
+
+// BandPassBiquad designs a band-pass filter (with a gain of 0 dB at the center frequency).
+func BandPassBiquad(rate, freq, q, _ float64) Biquad {
+	c, a := rbj(rate, freq, q)
+	return normalize(a, 0, -a, 1+a, -2*c, 1-a)
+}
+
+// NotchBiquad designs a band-stop filter.
+func NotchBiquad(rate, freq, q, _ float64) Biquad {
+	c, a := rbj(rate, freq, q)
+	return normalize(1, -2*c, 1, 1+a, -2*c, 1-a)
+}
+
+// AllPassBiquad designs an all-pass filter (which only changes the phase).
+func AllPassBiquad(rate, freq, q, _ float64) Biquad {
+	c, a := rbj(rate, freq, q)
+	return normalize(1-a, -2*c, 1+a, 1+a, -2*c, 1-a)
+}
+
+// PeakBiquad designs a peaking filter, boosting or cutting around the center frequency.
+func PeakBiquad(rate, freq, q, gain float64) Biquad {
+	c, a := rbj(rate, freq, q)
+	A := math.Pow(10, gain/40)
+	return normalize(1+a*A, -2*c, 1-a*A, 1+a/A, -2*c, 1-a/A)
+}
+
+// LowShelfBiquad designs a low-shelf filter, boosting or cutting below the cutoff frequency.
+func LowShelfBiquad(rate, freq, q, gain float64) Biquad {
+	c, a := rbj(rate, freq, q)
+	A := math.Pow(10, gain/40)
+	s := 2 * math.Sqrt(A) * a
+	return normalize(
+		A*((A+1)-(A-1)*c+s), 2*A*((A-1)-(A+1)*c), A*((A+1)-(A-1)*c-s),
+		(A+1)+(A-1)*c+s, -2*((A-1)+(A+1)*c), (A+1)+(A-1)*c-s,
+	)
+}
+
+// HighShelfBiquad designs a high-shelf filter, boosting or cutting above the cutoff frequency.
+func HighShelfBiquad(rate, freq, q, gain float64) Biquad {
+	c, a := rbj(rate, freq, q)
+	A := math.Pow(10, gain/40)
+	s := 2 * math.Sqrt(A) * a
+	return normalize(
+		A*((A+1)+(A-1)*c+s), -2*A*((A-1)+(A+1)*c), A*((A+1)+(A-1)*c-s),
+		(A+1)-(A-1)*c+s, 2*((A-1)-(A+1)*c), (A+1)-(A-1)*c-s,
+	)
+}
+
+// Response returns the complex frequency response of the filter at the given frequency (in Hertz).
+// Its absolute value is the gain and its argument the phase shift.
+func (b Biquad) Response(rate, freq float64) complex128 {
+	z := cmplx.Exp(complex(0, -2*math.Pi*freq/rate)) // z^-1
+	num := complex(b.B0, 0) + complex(b.B1, 0)*z + complex(b.B2, 0)*z*z
+	den := 1 + complex(b.A1, 0)*z + complex(b.A2, 0)*z*z
+	return num / den
+}
+
+// Filter runs the input through a biquad filter designed on the fly from the given parameters,
+// which are signals so they can be modulated (for example a filter sweep driven by an envelope).
+// The coefficients are only computed again when a parameter changes.
+func Filter(in Signal, design BiquadDesign, freq, q, gain Signal) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var b Biquad
+		var rate, f, r, g float64
+		var x1, x2, y1, y2 float64
+		return func(x time.Duration, dt float64) float64 {
+			v := in(x)
+			newRate, newF, newR, newG := meter.Tick(x), freq(x), q(x), gain(x)
+			if newRate == 0 {
+				x1 = v // The sample rate isn't known until the second sample.
+				return 0
+			}
+			if newRate != rate || newF != f || newR != r || newG != g {
+				rate, f, r, g = newRate, newF, newR, newG
+				b = design(rate, f, r, g)
+			}
+			y := b.B0*v + b.B1*x1 + b.B2*x2 - b.A1*y1 - b.A2*y2
+			x2, x1 = x1, v
+			y2, y1 = y1, y
+			return y
+		}
+	})
+}
+
+// LowPass attenuates frequencies above the cutoff (in Hertz), q controls the resonance around the cutoff
+// (0.707 gives a flat response).
+func LowPass(in, cutoff, q Signal) Signal { return Filter(in, LowPassBiquad, cutoff, q, Constant(0)) }
+
+// HighPass attenuates frequencies below the cutoff (in Hertz).
+func HighPass(in, cutoff, q Signal) Signal { return Filter(in, HighPassBiquad, cutoff, q, Constant(0)) }
+
+// BandPass keeps frequencies around the center frequency (in Hertz), a higher q gives a narrower band.
+func BandPass(in, center, q Signal) Signal { return Filter(in, BandPassBiquad, center, q, Constant(0)) }
+
+// Notch removes frequencies around the center frequency (in Hertz).
+func Notch(in, center, q Signal) Signal { return Filter(in, NotchBiquad, center, q, Constant(0)) }
+
+// AllPass shifts the phase of frequencies around the center frequency (in Hertz) without changing their level.
+func AllPass(in, center, q Signal) Signal { return Filter(in, AllPassBiquad, center, q, Constant(0)) }
+
+// Peak boosts or cuts frequencies around the center frequency by the given gain (in decibels).
+func Peak(in, center, q, gain Signal) Signal { return Filter(in, PeakBiquad, center, q, gain) }
+
+// LowShelf boosts or cuts frequencies below the cutoff by the given gain (in decibels).
+func LowShelf(in, cutoff, q, gain Signal) Signal { return Filter(in, LowShelfBiquad, cutoff, q, gain) }
+
+// HighShelf boosts or cuts frequencies above the cutoff by the given gain (in decibels).
+func HighShelf(in, cutoff, q, gain Signal) Signal {
+	return Filter(in, HighShelfBiquad, cutoff, q, gain)
+}
+
+// DCBlock removes the DC offset (the average level) of the input with a one-pole high-pass filter at about 10 Hz.
+// Unipolar modulators, asymmetric waveshaping and AM easily create offsets,
+// which waste headroom and cause clicks when sounds start and stop.
+func DCBlock(in Signal) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var x1, y1 float64
+		return func(x time.Duration, dt float64) float64 {
+			v := in(x)
+			rate := meter.Tick(x)
+			if rate <= 0 {
+				x1 = v
+				return 0
+			}
+			r := math.Exp(-2 * math.Pi * 10 / rate)
+			y := v - x1 + r*y1
+			x1, y1 = v, y
+			return y
+		}
+	})
+}
+
+// biquadState holds the previous samples of a biquad filter with fixed coefficients.
+type biquadState struct {
+	x1, x2, y1, y2 float64
+}
+
+// process filters one sample.
+func (s *biquadState) process(b Biquad, v float64) float64 {
+	y := b.B0*v + b.B1*s.x1 + b.B2*s.x2 - b.A1*s.y1 - b.A2*s.y2
+	s.x2, s.x1 = s.x1, v
+	s.y2, s.y1 = s.y1, y
+	return y
+}
@@ -0,0 +1,25 @@
+package synth
+
+import "time"
+
+// Float is the type of the frames of buffers: float64 (the precision of signals)
+// or float32 (half the memory and bandwidth, and the format of most audio hardware and drivers).
+// Signals are always computed in float64, frames are converted as they are stored.
+type Float interface{ ~float32 | ~float64 }
+
+// SampleFloat32 is like Sample with float32 frames.
+func SampleFloat32(s Signal, rate int, from, length time.Duration) []float32 {
+	frames := make([]float32, FrameCount(0, length, rate))
+	SampleInto(frames, s, rate, from)
+	return frames
+}
+
+// Convert converts frames from one type to the other (or copies them), into dst, and returns the number of frames converted
+// (the minimum of both lengths, like copy).
+func Convert[D, S Float](dst []D, src []S) int {
+	n := min(len(dst), len(src))
+	for i, v := range src[:n] {
+		dst[i] = D(v)
+	}
+	return n
+}
@@ -0,0 +1,95 @@
+package synth
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+)
+
+// Formant is a resonance of the vocal tract, a peak in the spectrum of a vowel.
+type Formant struct {
+	Freq      float64 // Center frequency, in Hertz.
+	Bandwidth float64 // In Hertz.
+	Gain      float64 // In decibels.
+}
+
+// Vowel is the formants of a vowel, from the lowest.
+type Vowel []Formant
+
+// Vowels sung by a bass voice, with their first five formants (from the formant tables of Csound).
+var (
+	VowelA = Vowel{{600, 60, 0}, {1040, 70, -7}, {2250, 110, -9}, {2450, 120, -9}, {2750, 130, -20}}
+	VowelE = Vowel{{400, 40, 0}, {1620, 80, -12}, {2400, 100, -9}, {2800, 120, -12}, {3100, 120, -18}}
+	VowelI = Vowel{{250, 60, 0}, {1750, 90, -30}, {2600, 100, -16}, {3050, 120, -22}, {3340, 120, -28}}
+	VowelO = Vowel{{400, 40, 0}, {750, 80, -11}, {2400, 100, -21}, {2600, 120, -20}, {2900, 120, -40}}
+	VowelU = Vowel{{350, 40, 0}, {600, 80, -20}, {2400, 100, -32}, {2675, 120, -28}, {2950, 120, -36}}
+)
+
+// AEIOU are the vowels morphed by default by FormantFilter.
+var AEIOU = []Vowel{VowelA, VowelE, VowelI, VowelO, VowelU}
+
+// formantUpdate is the number of frames between the updates of the filters of a FormantFilter.
+const formantUpdate = 16
+
+// FormantFilter makes the input "talk": it is run through band-pass filters in parallel, tuned to the formants of a vowel.
+// The position morphs between the vowels (AEIOU if none are given): 0 is the first vowel, 1 the second and so on,
+// positions in between move the formants smoothly from a vowel to the next.
+//
+// Bright sources with a steady pitch (saws, pulses) work best, and the output is quieter than the input
+// since only the bands of the formants go through.
+func FormantFilter(in, position Signal, vowels ...Vowel) Signal {
+	if len(vowels) == 0 {
+		vowels = AEIOU
+	}
+	bands := len(vowels[0])
+	for _, v := range vowels {
+		bands = min(bands, len(v))
+	}
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		formants := make([]Formant, bands)
+		filters := make([]Biquad, bands)
+		gains := make([]float64, bands)
+		states := make([]biquadState, bands)
+		frame := 0
+		return func(x time.Duration, dt float64) float64 {
+			v, p := in(x), position(x)
+			rate := meter.Tick(x)
+			if rate == 0 {
+				return 0 // The sample rate isn't known until the second sample.
+			}
+			if frame%formantUpdate == 0 {
+				morphVowels(formants, vowels, p)
+				for i, f := range formants {
+					filters[i] = BandPassBiquad(rate, f.Freq, f.Freq/math.Max(f.Bandwidth, 1), 0)
+					gains[i] = DBToAmp(f.Gain)
+				}
+			}
+			frame++
+
+			var out float64
+			for i, b := range filters {
+				out += gains[i] * states[i].process(b, v)
+			}
+			return out
+		}
+	})
+}
+
+// morphVowels sets the formants to those at a position between vowels,
+// interpolating frequencies logarithmically (like pitches) and gains in decibels.
+func morphVowels(formants []Formant, vowels []Vowel, position float64) {
+	position = math.Max(0, math.Min(position, float64(len(vowels)-1)))
+	i := min(int(position), len(vowels)-1)
+	from, to := vowels[i], vowels[min(i+1, len(vowels)-1)]
+	t := position - float64(i)
+	for k := range formants {
+		a, b := from[k], to[k]
+		formants[k] = Formant{
+			Freq:      a.Freq * math.Pow(b.Freq/a.Freq, t),
+			Bandwidth: a.Bandwidth + (b.Bandwidth-a.Bandwidth)*t,
+			Gain:      a.Gain + (b.Gain-a.Gain)*t,
+		}
+	}
+}
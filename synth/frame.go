@@ -0,0 +1,52 @@
+package synth
+
+import "time"
+
+// FrameSignal is a signal evaluated at frame indexes rather than at arbitrary times,
+// for a given sample rate (in frames per second).
+//
+// Integer frame indexes don't accumulate rounding errors and make durations exact, a frame lasting exactly 1/rate.
+type FrameSignal func(frame, rate int) (y float64)
+
+// AtFrame returns the time of the given frame (rounded down to the nanosecond).
+func AtFrame(frame, rate int) time.Duration {
+	n, d := int64(frame)*int64(time.Second), int64(rate)
+	if n < 0 && n%d != 0 {
+		return time.Duration(n/d - 1) // Division truncates towards zero, which rounds negative times up.
+	}
+	return time.Duration(n / d)
+}
+
+// FrameAt returns the first frame at or after x, which is the inverse of AtFrame.
+// Negative times (like those of shifted signals) give negative frames.
+func FrameAt(x time.Duration, rate int) int {
+	n, d := int64(x)*int64(rate), int64(time.Second)
+	if n > 0 && n%d != 0 {
+		return int(n/d + 1)
+	}
+	return int(n / d) // Division truncates towards zero, which rounds negative frames up already.
+}
+
+// FrameCount returns the number of frames between from and to (from included, to excluded).
+func FrameCount(from, to time.Duration, rate int) int {
+	return max(0, FrameAt(to, rate)-FrameAt(from, rate))
+}
+
+// Frames returns the signal evaluated at the time of each frame.
+func (s Signal) Frames() FrameSignal {
+	return func(frame, rate int) float64 { return s(AtFrame(frame, rate)) }
+}
+
+// Signal returns the frame signal as a signal at the given rate, x being rounded up to the next frame.
+func (fs FrameSignal) Signal(rate int) Signal {
+	return func(x time.Duration) float64 { return fs(FrameAt(x, rate), rate) }
+}
+
+// SampleFrames returns exactly n frames of the signal at the given rate, starting at the given frame.
+func SampleFrames(fs FrameSignal, rate, from, n int) (frames []float64) {
+	frames = make([]float64, n)
+	for i := range frames {
+		frames[i] = fs(from+i, rate)
+	}
+	return frames
+}
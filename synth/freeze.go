@@ -0,0 +1,37 @@
+package synth
+
+import (
+	"math"
+	"time"
+)
+
+// Freeze renders the signal at the given sample rate from 0 to dur and returns a signal replaying the frames (0 after them),
+// so an expensive static layer (like a pad through a convolution reverb) is computed once
+// instead of on every pass of a live patch. The signal must not depend on anything changing while it plays.
+func Freeze(s Signal, rate int, dur time.Duration) Signal {
+	return Frozen(Sample(s, rate, 0, dur), rate)
+}
+
+// Frozen returns a signal replaying the frames rendered at the given sample rate (from 0, and 0 before), like Freeze.
+// Between frames (when played at another rate), the frames are interpolated linearly.
+func Frozen(frames []float64, rate int) Signal {
+	return func(x time.Duration) float64 {
+		i := FrameAt(x, rate)
+		if AtFrame(i, rate) == x {
+			if i < 0 || i >= len(frames) {
+				return 0
+			}
+			return frames[i]
+		}
+		pos := x.Seconds() * float64(rate)
+		j := int(math.Floor(pos))
+		if j < 0 || j >= len(frames) {
+			return 0
+		}
+		next := 0.0
+		if j+1 < len(frames) {
+			next = frames[j+1]
+		}
+		return frames[j] + (pos-float64(j))*(next-frames[j])
+	}
+}
@@ -0,0 +1,77 @@
+package synth
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+)
+
+// NoiseGate silences the input while it is quiet (like the hiss of a recording between phrases, or an effect tail),
+// or turns it down progressively below the threshold as a downward expander.
+// The zero value is a gate at 0 dB with instant transitions.
+type NoiseGate struct {
+	Threshold  float64       // In decibels, the gate opens above it.
+	Hysteresis float64       // In decibels, the gate closes below Threshold-Hysteresis so it doesn't chatter around the threshold.
+	Attack     time.Duration // Time to open.
+	Hold       time.Duration // Time the gate stays open once the level is below the closing threshold.
+	Release    time.Duration // Time to close.
+	Range      float64       // Attenuation when closed, in decibels (80 if zero).
+	Ratio      float64       // Expands instead of gating if above 1: each decibel below the threshold comes out Ratio decibels below it (down to Range).
+}
+
+// gateDetector is the release of the detection of the level (the peaks of the key, held between cycles of low notes).
+const gateDetector = 20 * time.Millisecond
+
+// Apply returns the input gated according to its own level.
+func (g NoiseGate) Apply(in Signal) Signal {
+	return g.ApplySidechain(in, in)
+}
+
+// ApplySidechain returns the input gated according to the level of key, for example to gate a noisy room microphone
+// with a close one, or a pad with a drum loop.
+func (g NoiseGate) ApplySidechain(in, key Signal) Signal {
+	rangeDB := g.Range
+	if rangeDB == 0 {
+		rangeDB = 80
+	}
+	rangeDB = math.Abs(rangeDB)
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var peak float64
+		reduction := rangeDB // Current attenuation, in decibels, closed at first.
+		open := false
+		var held float64 // Time (in seconds) since the level went below the closing threshold.
+		return func(x time.Duration, dt float64) float64 {
+			v, k := in(x), math.Abs(key(x))
+			rate := meter.Tick(x)
+			if rate == 0 {
+				peak = k
+				return v * DBToAmp(-reduction)
+			}
+			peak = math.Max(k, peak*(1-smoothing(gateDetector, rate)))
+			level := AmpToDB(math.Max(peak, 1e-9))
+
+			var target float64
+			if g.Ratio > 1 {
+				target = math.Min(rangeDB, math.Max(0, g.Threshold-level)*(g.Ratio-1))
+			} else {
+				if level > g.Threshold {
+					open, held = true, 0
+				} else if open && level < g.Threshold-math.Abs(g.Hysteresis) {
+					held += dt
+					open = held < g.Hold.Seconds()
+				}
+				if !open {
+					target = rangeDB
+				}
+			}
+			t := g.Release
+			if target < reduction {
+				t = g.Attack
+			}
+			reduction += (target - reduction) * smoothing(t, rate)
+			return v * DBToAmp(-reduction)
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package synth
+
+import (
+	"math"
+	"time"
+)
+
+// Glide smooths changes of the target (like the frequency of a sequence) with a one-pole filter,
+// so legato notes slide from one pitch to the next (portamento) instead of jumping.
+// Each change takes about the same time whatever the interval: seconds is the time constant,
+// after which about 63% of the way is covered. It starts at the first value of the target.
+func Glide(target, seconds Signal) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var v float64
+		return func(x time.Duration, dt float64) float64 {
+			t := target(x)
+			if dt == 0 {
+				v = t
+				return v
+			}
+			if tc := seconds(x); tc > 0 {
+				v += (t - v) * (1 - math.Exp(-dt/tc))
+			} else {
+				v = t
+			}
+			return v
+		}
+	})
+}
+
+// GlideRate slides the frequency (in Hertz) towards the target at a constant rate (in octaves per second),
+// so large intervals take longer than small ones. It starts at the first value of the target.
+func GlideRate(target, rate Signal) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var pitch float64 // In octaves (log2 of the frequency).
+		return func(x time.Duration, dt float64) float64 {
+			t := math.Log2(math.Max(target(x), 1e-9))
+			if dt == 0 {
+				pitch = t
+			} else if r := rate(x); r > 0 {
+				step := r * dt
+				pitch += math.Max(-step, math.Min(t-pitch, step))
+			} else {
+				pitch = t
+			}
+			return math.Exp2(pitch)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package synth
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Note lengths (in beats, a beat being a quarter note), to express tempo-synced rates.
+const (
+	Whole     = 4.0
+	Half      = 2.0
+	Quarter   = 1.0
+	Eighth    = 0.5
+	Sixteenth = 0.25
+)
+
+// Dotted returns the length of a dotted note (one and a half times as long).
+func Dotted(beats float64) float64 { return beats * 1.5 }
+
+// Triplet returns the length of a triplet note (three in the time of two).
+func Triplet(beats float64) float64 { return beats * 2 / 3 }
+
+// Hz returns a constant rate in Hertz (for readability when building LFOs).
+func Hz(freq float64) Signal { return Constant(freq) }
+
+// Sync returns the rate (in Hertz) of a cycle lasting the given number of beats at the given tempo (in beats per minute).
+// For example, Sync(120, Eighth) cycles twice per beat.
+//
+// Since oscillators start their phase from x*freq, synced LFOs stay aligned on the beat grid.
+func Sync(bpm, beats float64) Signal {
+	return Constant(bpm / 60 / beats)
+}
+
+// LFOs are low-frequency oscillators used to modulate other signals (vibrato, tremolo, filter sweeps).
+// They oscillate around the offset, between offset-depth and offset+depth.
+
+// LFOSine returns a sine LFO.
+func LFOSine(rate Signal, depth, offset float64) Signal {
+	return lfo(Sine(rate), depth, offset)
+}
+
+// LFOTriangle returns a triangle LFO.
+func LFOTriangle(rate Signal, depth, offset float64) Signal {
+	return lfo(NaiveTriangle(rate), depth, offset)
+}
+
+// LFOSaw returns a rising sawtooth LFO.
+func LFOSaw(rate Signal, depth, offset float64) Signal {
+	return lfo(NaiveSaw(rate), depth, offset)
+}
+
+// LFOSquare returns a square LFO.
+func LFOSquare(rate Signal, depth, offset float64) Signal {
+	return lfo(NaiveSquare(rate), depth, offset)
+}
+
+// LFOSampleHold returns an LFO that jumps to a new random value at each cycle.
+// The random values are drawn from the given seed, so renders are reproducible.
+func LFOSampleHold(rate Signal, depth, offset float64, seed int64) Signal {
+	phase := Phase(rate)
+	return lfo(Stateful(func() func(x time.Duration, dt float64) float64 {
+		rng := rand.New(rand.NewSource(seed))
+		value := 2*rng.Float64() - 1
+		last := math.Inf(1)
+		return func(x time.Duration, dt float64) float64 {
+			p := phase(x)
+			if p < last && dt > 0 {
+				value = 2*rng.Float64() - 1
+			}
+			last = p
+			return value
+		}
+	}), depth, offset)
+}
+
+func lfo(s Signal, depth, offset float64) Signal {
+	return func(x time.Duration) float64 { return offset + depth*s(x) }
+}
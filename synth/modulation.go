@@ -0,0 +1,85 @@
+package synth
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+)
+
+// Chorus thickens the input by mixing it with a copy delayed by 10 to 25 milliseconds,
+// the delay being swept by a sine LFO at the given rate (in Hertz).
+// The depth (between 0 and 1) scales the sweep, the feedback (between 0 and 1) is the portion of the delayed signal
+// sent back into the delay line and the mix goes from 0 (only the input) to 1 (only the delayed copy).
+func Chorus(in, rate, depth, feedback, mix Signal) Signal {
+	return modulatedDelay(in, rate, depth, feedback, mix, 0.010, 0.015)
+}
+
+// Flanger is like Chorus with a much shorter delay (0.5 to 5.5 milliseconds),
+// so the sweep produces the characteristic jet-like comb filter. Feedback makes it more resonant.
+func Flanger(in, rate, depth, feedback, mix Signal) Signal {
+	return modulatedDelay(in, rate, depth, feedback, mix, 0.0005, 0.005)
+}
+
+// modulatedDelay mixes the input with a copy delayed by base to base+sweep seconds (scaled by the depth),
+// modulated by a sine LFO.
+func modulatedDelay(in, rate, depth, feedback, mix Signal, base, sweep float64) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var line delayLine
+		var phase float64
+		return func(x time.Duration, dt float64) float64 {
+			v := in(x)
+			sr := meter.Tick(x)
+			var delayed float64
+			if sr > 0 {
+				lfo := 0.5 - 0.5*math.Cos(2*math.Pi*phase) // Between 0 and 1, starting at 0.
+				phase = frac(phase + rate(x)/sr)
+				d := base + sweep*math.Max(0, math.Min(depth(x), 1))*lfo
+				delayed = line.read(d * sr)
+			}
+			line.write(v + feedback(x)*delayed)
+			m := mix(x)
+			return (1-m)*v + m*delayed
+		}
+	})
+}
+
+// phaserStages is the number of all-pass stages of the phaser (producing half as many notches).
+const phaserStages = 6
+
+// Phaser mixes the input with a copy passed through a chain of all-pass filters
+// whose frequency is swept between 200 Hz and 6.4 kHz (scaled by the depth, between 0 and 1)
+// by a sine LFO at the given rate (in Hertz), producing moving notches.
+// The feedback (between -1 and 1) sends the output of the chain back to its input, making the notches sharper,
+// and the mix goes from 0 (only the input) to 1 (only the filtered copy), 0.5 giving the deepest notches.
+func Phaser(in, rate, depth, feedback, mix Signal) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var phase, last float64
+		var xs, ys [phaserStages]float64 // Previous input and output of each stage.
+		return func(x time.Duration, dt float64) float64 {
+			v := in(x)
+			sr := meter.Tick(x)
+			if sr <= 0 {
+				return v
+			}
+			lfo := 0.5 - 0.5*math.Cos(2*math.Pi*phase)
+			phase = frac(phase + rate(x)/sr)
+			freq := 200 * math.Pow(2, 5*math.Max(0, math.Min(depth(x), 1))*lfo)
+			t := math.Tan(math.Pi * math.Min(freq, 0.49*sr) / sr)
+			a := (t - 1) / (t + 1)
+
+			fb := math.Max(-0.99, math.Min(feedback(x), 0.99))
+			s := v + fb*last
+			for i := range xs {
+				y := a*s + xs[i] - a*ys[i]
+				xs[i], ys[i] = s, y
+				s = y
+			}
+			last = s
+			m := mix(x)
+			return (1-m)*v + m*s
+		}
+	})
+}
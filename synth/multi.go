@@ -0,0 +1,116 @@
+package synth
+
+import (
+	"io"
+	"math"
+	"time"
+)
+
+// MultiSignal holds one signal per audio channel (for example left and right for stereo).
+type MultiSignal []Signal
+
+// Stereo returns a two-channel signal.
+func Stereo(left, right Signal) MultiSignal {
+	return MultiSignal{left, right}
+}
+
+// Pan places a mono signal in the stereo field using equal-power panning,
+// so the perceived loudness stays the same across positions.
+// The position goes from -1 (left) to 1 (right), 0 being the center.
+func Pan(s, pos Signal) MultiSignal {
+	gain := func(x time.Duration) (left, right float64) {
+		p := math.Max(-1, math.Min(1, pos(x)))
+		angle := (p + 1) * math.Pi / 4
+		return math.Cos(angle), math.Sin(angle)
+	}
+	return Stereo(
+		func(x time.Duration) float64 { l, _ := gain(x); return l * s(x) },
+		func(x time.Duration) float64 { _, r := gain(x); return r * s(x) },
+	)
+}
+
+// SampleMulti measures each channel of the signal and returns one slice of frames per channel.
+func SampleMulti(ms MultiSignal, rate int, from, length time.Duration) (channels [][]float64) {
+	st := SampleMultiStream(ms, rate, from, length)
+	samples := make([]float64, st.Len()*len(ms))
+	st.Read(samples)
+	return Deinterleave(samples, len(ms))
+}
+
+// Interleave merges channels into a single slice of samples (one sample of each channel after another),
+// which is the layout expected by multichannel PCM and WAV files.
+// All channels must have the same length.
+func Interleave(channels [][]float64) (samples []float64) {
+	if len(channels) == 0 {
+		return nil
+	}
+	samples = make([]float64, 0, len(channels)*len(channels[0]))
+	for i := range channels[0] {
+		for _, ch := range channels {
+			samples = append(samples, ch[i])
+		}
+	}
+	return samples
+}
+
+// Deinterleave splits interleaved samples into n channels.
+func Deinterleave(samples []float64, n int) (channels [][]float64) {
+	channels = make([][]float64, n)
+	for c := range channels {
+		channels[c] = make([]float64, 0, len(samples)/n)
+	}
+	for i, v := range samples {
+		channels[i%n] = append(channels[i%n], v)
+	}
+	return channels
+}
+
+// MultiStream measures a multichannel signal block by block.
+// All channels are measured at a given time before moving on to the next frame.
+type MultiStream struct {
+	ms MultiSignal
+	st *Stream
+}
+
+// SampleMultiStream returns a stream of the interleaved frames of each channel.
+func SampleMultiStream(ms MultiSignal, rate int, from, length time.Duration) *MultiStream {
+	return &MultiStream{ms: ms, st: SampleStream(nil, rate, from, length)}
+}
+
+// Read fills samples with the next interleaved frames and returns the number of samples read,
+// which is always a multiple of the number of channels.
+// It returns io.EOF once all frames have been read.
+func (mst *MultiStream) Read(samples []float64) (n int, err error) {
+	return readMultiStream(mst, samples)
+}
+
+// ReadFloat32 is like Read with float32 samples.
+func (mst *MultiStream) ReadFloat32(samples []float32) (n int, err error) {
+	return readMultiStream(mst, samples)
+}
+
+func readMultiStream[T Float](mst *MultiStream, samples []T) (n int, err error) {
+	st := mst.st
+	if st.Len() == 0 {
+		return 0, io.EOF
+	}
+	frames := min(len(samples)/len(mst.ms), st.Len())
+	for i := 0; i < frames; i++ {
+		x := st.At(st.frame + i)
+		for c, s := range mst.ms {
+			samples[n+c] = T(s(x))
+		}
+		n += len(mst.ms)
+	}
+	st.frame += frames
+	return n, nil
+}
+
+// Len returns the number of frames (per channel) left to read.
+func (mst *MultiStream) Len() int { return mst.st.Len() }
+
+// Channels returns the number of channels.
+func (mst *MultiStream) Channels() int { return len(mst.ms) }
+
+// Rate returns the sample rate of the stream (in frames per second).
+func (mst *MultiStream) Rate() int { return mst.st.Rate() }
@@ -0,0 +1,74 @@
+package synth
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+)
+
+// CompressorBand sets the compression of a band of MultibandCompress.
+// The zero value leaves the band untouched.
+type CompressorBand struct {
+	Threshold float64 // In decibels.
+	Ratio     float64 // Below 1 means no compression.
+	Attack    time.Duration
+	Release   time.Duration
+	Gain      float64 // Makeup gain, in decibels.
+}
+
+// MultibandCompress splits the input into bands at the crossover frequencies (in increasing order, in Hertz),
+// compresses each band on its own and sums them back, to control the dynamics of the low end of a mix
+// without pumping the highs (and the other way around). There is one more band than crossovers,
+// bands without settings are left untouched.
+//
+// The crossovers are 4th order Linkwitz-Riley filters, so the bands sum back to the input
+// (with only a phase shift around the crossovers) when they aren't compressed.
+func MultibandCompress(in Signal, crossovers []float64, bands ...CompressorBand) Signal {
+	n := len(crossovers) + 1
+	bands = append(bands, make([]CompressorBand, max(n-len(bands), 0))...)[:n]
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var lows, highs, allpasses []Biquad
+		// Each crossover filter is two cascaded biquads, and the bands below a crossover go through its all-pass filter
+		// so they stay in phase with the bands split by it.
+		lowStates, highStates := make([][2]biquadState, n-1), make([][2]biquadState, n-1)
+		allpassStates := make([][]biquadState, n)
+		for i := range allpassStates {
+			allpassStates[i] = make([]biquadState, n-1)
+		}
+		compressors := make([]compressor, n)
+		for i, b := range bands {
+			compressors[i] = compressor{threshold: b.Threshold, ratio: b.Ratio, attack: b.Attack, release: b.Release}
+		}
+		return func(x time.Duration, dt float64) float64 {
+			v := in(x)
+			rate := meter.Tick(x)
+			if rate == 0 {
+				return 0 // The sample rate isn't known until the second sample.
+			}
+			if lows == nil {
+				for _, f := range crossovers {
+					lows = append(lows, LowPassBiquad(rate, f, math.Sqrt2/2, 0))
+					highs = append(highs, HighPassBiquad(rate, f, math.Sqrt2/2, 0))
+					allpasses = append(allpasses, AllPassBiquad(rate, f, math.Sqrt2/2, 0))
+				}
+			}
+
+			var out float64
+			rest := v // Part of the input above the previous crossovers.
+			for i := range bands {
+				band := rest
+				if i < n-1 {
+					band = lowStates[i][1].process(lows[i], lowStates[i][0].process(lows[i], rest))
+					rest = highStates[i][1].process(highs[i], highStates[i][0].process(highs[i], rest))
+					for j := i + 1; j < n-1; j++ {
+						band = allpassStates[i][j].process(allpasses[j], band)
+					}
+				}
+				out += band * compressors[i].gain(band, rate) * DBToAmp(bands[i].Gain)
+			}
+			return out
+		}
+	})
+}
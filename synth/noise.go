@@ -0,0 +1,57 @@
+package synth
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Noise generators draw their values from a random source created from the given seed,
+// so a render using the same seeds is identical from one run to the next.
+
+// WhiteNoise returns uniformly distributed random values between -1 and 1 (with a flat spectrum).
+func WhiteNoise(seed int64) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		rng := rand.New(rand.NewSource(seed))
+		return func(x time.Duration, dt float64) float64 {
+			return 2*rng.Float64() - 1
+		}
+	})
+}
+
+// PinkNoise returns noise whose power decreases by 3 dB per octave,
+// which sounds more balanced than white noise to our ears.
+//
+// It is obtained by filtering white noise (using Paul Kellet's refined method).
+func PinkNoise(seed int64) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		rng := rand.New(rand.NewSource(seed))
+		var b0, b1, b2, b3, b4, b5, b6 float64
+		return func(x time.Duration, dt float64) float64 {
+			white := 2*rng.Float64() - 1
+			b0 = 0.99886*b0 + white*0.0555179
+			b1 = 0.99332*b1 + white*0.0750759
+			b2 = 0.96900*b2 + white*0.1538520
+			b3 = 0.86650*b3 + white*0.3104856
+			b4 = 0.55000*b4 + white*0.5329522
+			b5 = -0.7616*b5 - white*0.0168980
+			pink := b0 + b1 + b2 + b3 + b4 + b5 + b6 + white*0.5362
+			b6 = white * 0.115926
+			return pink * 0.11 // Roughly back between -1 and 1.
+		}
+	})
+}
+
+// BrownNoise returns noise whose power decreases by 6 dB per octave (a deep rumble, like a waterfall).
+//
+// It is obtained by integrating white noise, with a slight leak so it doesn't drift away from zero.
+func BrownNoise(seed int64) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		rng := rand.New(rand.NewSource(seed))
+		var y float64
+		return func(x time.Duration, dt float64) float64 {
+			white := 2*rng.Float64() - 1
+			y = (y + 0.02*white) / 1.02
+			return y * 3.5 // Roughly back between -1 and 1.
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package synth
+
+import (
+	"math"
+	"time"
+)
+
+// Phase returns the phase (in cycles, between 0 and 1) of an oscillator running at the given frequency (in Hertz).
+//
+// The phase is accumulated sample after sample from the instantaneous frequency,
+// so the output stays continuous when the frequency changes over time (sweeps, vibrato, FM).
+// For a constant frequency, the phase is identical to x*freq.
+func Phase(freq Signal) Signal {
+	return oscillator(freq, func(x time.Duration, phase, inc float64) float64 { return phase })
+}
+
+// oscillator returns a signal that accumulates the phase of the given frequency
+// and passes it to shape along with the phase increment since the previous sample (in cycles).
+func oscillator(freq Signal, shape func(x time.Duration, phase, inc float64) float64) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var phase float64
+		return func(x time.Duration, dt float64) float64 {
+			f := freq(x)
+			inc := f * dt
+			if dt == 0 {
+				phase = frac(x.Seconds() * f)
+			} else {
+				phase = frac(phase + inc)
+			}
+			return shape(x, phase, math.Abs(inc))
+		}
+	})
+}
+
+// Sine returns a sine wave oscillating at the given frequency (in Hertz).
+func Sine(freq Signal) Signal {
+	phase := Phase(freq)
+	return func(x time.Duration) (y float64) {
+		return math.Sin(2 * math.Pi * phase(x))
+	}
+}
+
+// Saw returns a band-limited sawtooth wave (rising from -1 to 1) at the given frequency (in Hertz).
+//
+// Like the other band-limited oscillators, it uses PolyBLEP to smooth the discontinuities of the waveform,
+// which greatly reduces aliasing at audio rates. Use NaiveSaw for low-frequency modulation.
+func Saw(freq Signal) Signal {
+	return oscillator(freq, func(x time.Duration, phase, inc float64) float64 {
+		return saw(phase) - polyBLEP(phase, inc)
+	})
+}
+
+// Square returns a band-limited square wave at the given frequency (in Hertz).
+func Square(freq Signal) Signal {
+	return Pulse(freq, Constant(0.5))
+}
+
+// Pulse returns a band-limited pulse wave at the given frequency (in Hertz).
+// The width (between 0 and 1) is the portion of each cycle where the wave is high.
+func Pulse(freq, width Signal) Signal {
+	return oscillator(freq, func(x time.Duration, phase, inc float64) float64 {
+		w := pulseWidth(width(x))
+		return pulse(phase, w) + polyBLEP(phase, inc) - polyBLEP(frac(phase+1-w), inc)
+	})
+}
+
+// Triangle returns a band-limited triangle wave at the given frequency (in Hertz).
+//
+// It is obtained by integrating a band-limited square wave.
+func Triangle(freq Signal) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		square := Square(freq)
+		var y float64
+		return func(x time.Duration, dt float64) float64 {
+			if dt == 0 {
+				y = triangle(frac(x.Seconds() * freq(x)))
+				square(x)
+				return y
+			}
+			// The integrator leaks slightly so numerical errors don't accumulate into a DC offset.
+			inc := math.Abs(freq(x) * dt)
+			y = (1-triangleLeak)*y + 4*inc*square(x)
+			return y
+		}
+	})
+}
+
+const triangleLeak = 1e-4
+
+// NaiveSaw returns a sawtooth wave without band-limiting, for use as a modulation source.
+func NaiveSaw(freq Signal) Signal {
+	phase := Phase(freq)
+	return func(x time.Duration) float64 { return saw(phase(x)) }
+}
+
+// NaiveSquare returns a square wave without band-limiting, for use as a modulation source.
+func NaiveSquare(freq Signal) Signal {
+	return NaivePulse(freq, Constant(0.5))
+}
+
+// NaivePulse returns a pulse wave without band-limiting, for use as a modulation source.
+func NaivePulse(freq, width Signal) Signal {
+	phase := Phase(freq)
+	return func(x time.Duration) float64 { return pulse(phase(x), pulseWidth(width(x))) }
+}
+
+// NaiveTriangle returns a triangle wave without band-limiting, for use as a modulation source.
+func NaiveTriangle(freq Signal) Signal {
+	phase := Phase(freq)
+	return func(x time.Duration) float64 { return triangle(phase(x)) }
+}
+
+// Naive waveforms, for a given phase between 0 and 1.
+
+func saw(phase float64) float64 { return 2*phase - 1 }
+
+func pulse(phase, width float64) float64 {
+	if phase < width {
+		return 1
+	}
+	return -1
+}
+
+func triangle(phase float64) float64 {
+	if phase < 0.5 {
+		return 4*phase - 1
+	}
+	return 3 - 4*phase
+}
+
+// pulseWidth keeps the pulse width away from 0 and 1 where the wave would become silent.
+func pulseWidth(w float64) float64 {
+	return math.Max(0.01, math.Min(0.99, w))
+}
+
+// polyBLEP returns the correction to apply around a discontinuity of height 2 located at phase 0,
+// inc being the phase increment per sample.
+func polyBLEP(phase, inc float64) float64 {
+	switch {
+	case inc <= 0:
+		return 0
+	case phase < inc:
+		t := phase / inc
+		return t + t - t*t - 1
+	case phase > 1-inc:
+		t := (phase - 1) / inc
+		return t*t + t + t + 1
+	default:
+		return 0
+	}
+}
+
+// frac returns the fractional part of v, between 0 and 1 (for negative values too).
+func frac(v float64) float64 {
+	return v - math.Floor(v)
+}
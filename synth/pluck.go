@@ -0,0 +1,58 @@
+package synth
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+)
+
+// Pluck returns a plucked string sound (like a guitar or a harp) at the given frequency, using the Karplus-Strong algorithm.
+// The string is plucked once at the start, the damping (between 0 and 1) controls how fast the sound dies out.
+func Pluck(freq Signal, damping float64) Signal {
+	return PluckGate(freq, Constant(1), damping, 0.5, 1)
+}
+
+// PluckGate plucks the string each time the gate opens.
+//
+// A burst of noise (drawn from the given seed) is fed into a delay line as long as the period of the note,
+// which is then averaged with itself over and over, losing its high frequencies like a real string.
+// The stretch (between 0 and 1, 0.5 being the original algorithm) is the weight of the averaging filter:
+// values away from 0.5 make high notes ring longer.
+func PluckGate(freq, gate Signal, damping, stretch float64, seed int64) Signal {
+	decay := 1 - 0.01*math.Max(0, math.Min(damping, 1))
+	stretch = math.Max(0.01, math.Min(stretch, 0.99))
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		rng := rand.New(rand.NewSource(seed))
+		var meter dsp.RateMeter
+		var line delayLine
+		wasOpen, pending := false, false
+		burst := 0 // Remaining samples of the noise burst.
+		return func(x time.Duration, dt float64) float64 {
+			f, open := freq(x), isOpen(gate(x))
+			rate := meter.Tick(x)
+			if open && !wasOpen {
+				pending = true
+			}
+			wasOpen = open
+			if rate <= 0 || f <= 0 {
+				return 0
+			}
+			period := math.Max(2, rate/f)
+			if pending {
+				pending, burst = false, int(period)
+			}
+
+			// The averaging filter delays the signal by stretch samples, the delay line makes up the rest of the period.
+			d := period - stretch
+			y := decay * ((1-stretch)*line.read(d) + stretch*line.read(d+1))
+			if burst > 0 {
+				y += 2*rng.Float64() - 1
+				burst--
+			}
+			line.write(y)
+			return y
+		}
+	})
+}
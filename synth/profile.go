@@ -0,0 +1,146 @@
+package synth
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Profiler measures the time spent computing the signals wrapped by its nodes,
+// to find which part of a large graph uses up the real-time budget.
+type Profiler struct {
+	mu     sync.Mutex
+	nodes  []*NodeProfile
+	byName map[string]*NodeProfile
+	wraps  []*wrapped
+	stack  []time.Duration // Time spent in the wrapped inputs of each running node.
+	busy   time.Duration   // Time spent in the outermost nodes since the last call to Load.
+}
+
+// wrapped is a signal wrapped by a profiler.
+type wrapped struct {
+	node     *NodeProfile
+	last     time.Duration // Time of the last frame computed.
+	started  bool
+	advanced time.Duration // Span of time computed since the last call to Load, if it is an outermost node.
+}
+
+// NodeProfile holds the measures of a signal wrapped by a profiler.
+type NodeProfile struct {
+	Name  string
+	Calls int
+	Total time.Duration // Time spent computing the signal, including its inputs.
+	Self  time.Duration // Time spent computing the signal, excluding the wrapped signals it reads.
+}
+
+// Wrap returns the signal unchanged, measuring the time spent computing it under the given name.
+// Wrapping the nodes of a graph splits the time between them: the self time of a node excludes
+// the time spent in the wrapped nodes it reads, so it is the cost of the node itself.
+// Signals wrapped under the same name (like the modules of a patch built again after a change) share their measures.
+//
+// Measuring costs time too (two clock readings per frame and node), so profiled graphs are a bit slower.
+func (p *Profiler) Wrap(name string, in Signal) Signal {
+	p.mu.Lock()
+	n, ok := p.byName[name]
+	if !ok {
+		n = &NodeProfile{Name: name}
+		if p.byName == nil {
+			p.byName = map[string]*NodeProfile{}
+		}
+		p.byName[name] = n
+		p.nodes = append(p.nodes, n)
+	}
+	w := &wrapped{node: n}
+	p.wraps = append(p.wraps, w)
+	p.mu.Unlock()
+	return func(x time.Duration) float64 {
+		p.mu.Lock()
+		p.stack = append(p.stack, 0)
+		p.mu.Unlock()
+		start := time.Now()
+		v := in(x)
+		elapsed := time.Since(start)
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		inputs := p.stack[len(p.stack)-1]
+		p.stack = p.stack[:len(p.stack)-1]
+		if len(p.stack) > 0 {
+			p.stack[len(p.stack)-1] += elapsed
+		} else { // An outermost node.
+			p.busy += elapsed
+			if w.started && x > w.last {
+				w.advanced += x - w.last
+			}
+		}
+		w.last, w.started = x, true
+		n.Calls++
+		n.Total += elapsed
+		n.Self += elapsed - inputs
+		return v
+	}
+}
+
+// Load returns the DSP load since the last call: the time spent computing the outermost nodes
+// divided by the duration of the audio they computed. Above 1, they can't be played in real time.
+// It returns 0 if no audio was computed.
+//
+// The duration of the audio is measured by each signal from the frames it computed, so signals computed at
+// different times (like patches crossfaded after a reload, each one starting at 0) are measured correctly.
+func (p *Profiler) Load() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var span time.Duration
+	for _, w := range p.wraps {
+		span = max(span, w.advanced) // Outermost nodes computed together (like the stems of a mix) cover the same span.
+		w.advanced = 0
+	}
+	busy := p.busy
+	p.busy = 0
+	if span <= 0 {
+		return 0
+	}
+	return busy.Seconds() / span.Seconds()
+}
+
+// Nodes returns a copy of the measures of all nodes, from the most expensive (by self time).
+func (p *Profiler) Nodes() []NodeProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	nodes := make([]NodeProfile, len(p.nodes))
+	for i, n := range p.nodes {
+		nodes[i] = *n
+	}
+	slices.SortStableFunc(nodes, func(a, b NodeProfile) int { return cmp.Compare(b.Self, a.Self) })
+	return nodes
+}
+
+// Report returns a table of the measures of all nodes, from the most expensive,
+// with their share of the time spent in all nodes.
+func (p *Profiler) Report() string {
+	nodes := p.Nodes()
+	var all time.Duration
+	for _, n := range nodes {
+		all += n.Self
+	}
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "node\tself\tshare\ttotal\tcalls\tper call\t")
+	for _, n := range nodes {
+		share, perCall := 0.0, time.Duration(0)
+		if all > 0 {
+			share = 100 * n.Self.Seconds() / all.Seconds()
+		}
+		if n.Calls > 0 {
+			perCall = n.Self / time.Duration(n.Calls)
+		}
+		fmt.Fprintf(w, "%s\t%v\t%.1f%%\t%v\t%d\t%v\t\n", n.Name, n.Self.Round(time.Microsecond), share,
+			n.Total.Round(time.Microsecond), n.Calls, perCall)
+	}
+	w.Flush()
+	return b.String()
+}
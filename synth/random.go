@@ -0,0 +1,58 @@
+package synth
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// SampleHold samples the input each time the trigger opens (goes from 0 or less to a positive value)
+// and holds that value until the next trigger. It is 0 until the first trigger.
+// With noise as input and a clock as trigger, it gives the classic random steps of analog synthesizers
+// (LFOSampleHold does the same from a rate).
+func SampleHold(in, trigger Signal) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var held float64
+		wasOpen := false
+		return func(x time.Duration, dt float64) float64 {
+			open := isOpen(trigger(x))
+			if open && !wasOpen {
+				held = in(x)
+			}
+			wasOpen = open
+			return held
+		}
+	})
+}
+
+// RandomWalk returns a smooth random modulation wandering between offset-depth and offset+depth.
+// At the given rate (in Hertz, use Sync to follow a tempo), it takes a random step from its previous position
+// (bouncing off the bounds), and glides smoothly to it, so it drifts around instead of jumping like LFOSampleHold.
+// The random values are drawn from the given seed, so renders are reproducible.
+func RandomWalk(rate Signal, depth, offset float64, seed int64) Signal {
+	phase := Phase(rate)
+	return lfo(Stateful(func() func(x time.Duration, dt float64) float64 {
+		rng := rand.New(rand.NewSource(seed))
+		step := func(from float64) float64 {
+			v := from + rng.Float64() - 0.5
+			if v > 1 {
+				v = 2 - v
+			} else if v < -1 {
+				v = -2 - v
+			}
+			return v
+		}
+		from := 2*rng.Float64() - 1
+		to := step(from)
+		last := math.Inf(1)
+		return func(x time.Duration, dt float64) float64 {
+			p := phase(x)
+			if p < last && dt > 0 {
+				from, to = to, step(to)
+			}
+			last = p
+			t := p * p * (3 - 2*p) // Smooth steps, without corners between them.
+			return from + (to-from)*t
+		}
+	}), depth, offset)
+}
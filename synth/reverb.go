@@ -0,0 +1,85 @@
+package synth
+
+import (
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+)
+
+// Freeverb tunings, in samples at 44100 Hz.
+var (
+	freeverbCombs     = []int{1116, 1188, 1277, 1356, 1422, 1491, 1557, 1617}
+	freeverbAllPasses = []int{556, 441, 341, 225}
+)
+
+// Reverb is an algorithmic reverb (Jezar's Freeverb, based on Schroeder's design):
+// the input goes through eight parallel feedback comb filters simulating the room's echoes,
+// then through four all-pass filters that diffuse them.
+//
+// The room size and damping go from 0 to 1: larger rooms ring longer and damping absorbs high frequencies.
+// The mix goes from 0 (only the input) to 1 (only the reverb).
+func Reverb(in Signal, roomSize, damping, mix float64) Signal {
+	feedback := 0.7 + 0.28*roomSize
+	damp := 0.4 * damping
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var combs []*comb
+		var allPasses []*allPass
+		return func(x time.Duration, dt float64) float64 {
+			v := in(x)
+			if combs == nil {
+				rate := meter.Tick(x)
+				if rate == 0 {
+					return (1 - mix) * v
+				}
+				for _, n := range freeverbCombs {
+					combs = append(combs, &comb{buf: make([]float64, scaleTuning(n, rate))})
+				}
+				for _, n := range freeverbAllPasses {
+					allPasses = append(allPasses, &allPass{buf: make([]float64, scaleTuning(n, rate))})
+				}
+			}
+			const inputGain = 0.015
+			var wet float64
+			for _, c := range combs {
+				wet += c.process(v*inputGain, feedback, damp)
+			}
+			for _, a := range allPasses {
+				wet = a.process(wet)
+			}
+			return (1-mix)*v + mix*wet*3 // Roughly back to the input level.
+		}
+	})
+}
+
+func scaleTuning(samples int, rate float64) int {
+	return max(1, int(float64(samples)*rate/44100))
+}
+
+// comb is a feedback comb filter with a low-pass filter in its feedback loop.
+type comb struct {
+	buf   []float64
+	pos   int
+	store float64 // Low-pass filter state.
+}
+
+func (c *comb) process(v, feedback, damp float64) float64 {
+	out := c.buf[c.pos]
+	c.store = out*(1-damp) + c.store*damp
+	c.buf[c.pos] = v + c.store*feedback
+	c.pos = (c.pos + 1) % len(c.buf)
+	return out
+}
+
+// allPass is Freeverb's all-pass filter (actually a Schroeder all-pass approximation with a gain of 0.5).
+type allPass struct {
+	buf []float64
+	pos int
+}
+
+func (a *allPass) process(v float64) float64 {
+	delayed := a.buf[a.pos]
+	a.buf[a.pos] = v + delayed*0.5
+	a.pos = (a.pos + 1) % len(a.buf)
+	return delayed - v
+}
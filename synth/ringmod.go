@@ -0,0 +1,34 @@
+package synth
+
+import (
+	"math"
+	"time"
+)
+
+// Modulators are either bipolar (between -1 and 1, like oscillators) or unipolar (between 0 and 1, like envelopes).
+// RingMod and AM expect bipolar modulators: convert unipolar ones with Bipolar first.
+
+// Bipolar maps a unipolar signal (between 0 and 1) to a bipolar one (between -1 and 1).
+func Bipolar(s Signal) Signal { return func(x time.Duration) float64 { return 2*s(x) - 1 } }
+
+// Unipolar maps a bipolar signal (between -1 and 1) to a unipolar one (between 0 and 1).
+func Unipolar(s Signal) Signal { return func(x time.Duration) float64 { return (s(x) + 1) / 2 } }
+
+// RingMod multiplies the carrier by the modulator, which only keeps the sum and difference of their frequencies
+// (the inharmonic, bell-like sound of ring modulators).
+// The DC offset of the modulator is removed first, since it would let the carrier through.
+func RingMod(carrier, modulator Signal) Signal {
+	m := DCBlock(modulator)
+	return func(x time.Duration) float64 { return carrier(x) * m(x) }
+}
+
+// AM modulates the amplitude of the carrier by a bipolar modulator: the output is carrier × (level + modulator),
+// scaled by 1/(1 + level) to stay within the range of the carrier.
+// The level is the amount of carrier kept in the output: 0 is a ring modulator,
+// 1 is classic amplitude modulation (the carrier plus two sidebands at half its amplitude).
+func AM(carrier, modulator, level Signal) Signal {
+	return func(x time.Duration) float64 {
+		l := math.Max(0, level(x))
+		return carrier(x) * (l + modulator(x)) / (1 + l)
+	}
+}
@@ -0,0 +1,117 @@
+package synth
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Sample measures the signal s at the given rate (in frames per second), for the given length from the given time,
+// and returns the resulting audio frames: exactly FrameCount(0, length, rate) of them, wherever they start.
+func Sample(s Signal, rate int, from, length time.Duration) (frames []float64) {
+	st := SampleStream(s, rate, from, length)
+	frames = make([]float64, st.Len())
+	st.Read(frames)
+	return frames
+}
+
+// SampleInto is like Sample, but measures the signal into the given frames (from the given time), without allocating.
+// Successive blocks of a render are measured by starting each one at the time of the frame after the previous block
+// (see AtFrame).
+func SampleInto[T Float](frames []T, s Signal, rate int, from time.Duration) {
+	first := FrameAt(from, rate)
+	for i := range frames {
+		frames[i] = T(s(AtFrame(first+i, rate)))
+	}
+}
+
+// SampleContext is like Sample, but stops with the error of the context once it is done
+// (cancelled or past its deadline), so long renders can be stopped.
+func SampleContext(ctx context.Context, s Signal, rate int, from, length time.Duration) (frames []float64, err error) {
+	st := SampleStreamContext(ctx, s, rate, from, length)
+	frames = make([]float64, st.Len())
+	for n := 0; n < len(frames); {
+		read, err := st.Read(frames[n:])
+		if err != nil {
+			return nil, err
+		}
+		n += read
+	}
+	return frames, nil
+}
+
+// DefaultBlockSize is a reasonable number of frames to read from a stream at once.
+const DefaultBlockSize = 4096
+
+// Stream measures a signal block by block, so that long renders run in constant memory.
+//
+// Frames are measured on the grid of frame indexes (see AtFrame),
+// the first frame being the first one at or after the start of the stream.
+type Stream struct {
+	s     Signal
+	rate  int
+	first int // Index of the first frame of the stream.
+	frame int // Index of the next frame, relative to the first one.
+	total int
+	ctx   context.Context // Nil if the stream can't be cancelled.
+	done  atomic.Int64    // Copy of frame for Progress.
+}
+
+// SampleStream returns a stream of the same frames as Sample, without computing them upfront.
+func SampleStream(s Signal, rate int, from, length time.Duration) *Stream {
+	return &Stream{s: s, rate: rate, first: FrameAt(from, rate), total: FrameCount(0, length, rate)}
+}
+
+// SampleStreamContext is like SampleStream, but reading the stream fails with the error of the context once it is done.
+func SampleStreamContext(ctx context.Context, s Signal, rate int, from, length time.Duration) *Stream {
+	st := SampleStream(s, rate, from, length)
+	st.ctx = ctx
+	return st
+}
+
+// Read fills frames with the next measurements of the signal and returns the number of frames read.
+// It returns io.EOF once all frames have been read.
+func (st *Stream) Read(frames []float64) (n int, err error) {
+	return readStream(st, frames)
+}
+
+// ReadFloat32 is like Read with float32 frames.
+func (st *Stream) ReadFloat32(frames []float32) (n int, err error) {
+	return readStream(st, frames)
+}
+
+func readStream[T Float](st *Stream, frames []T) (n int, err error) {
+	if st.frame >= st.total {
+		return 0, io.EOF
+	}
+	n = min(len(frames), st.total-st.frame)
+	for i := range frames[:n] {
+		if st.ctx != nil && i%DefaultBlockSize == 0 {
+			if err := st.ctx.Err(); err != nil {
+				st.frame += i
+				st.done.Store(int64(st.frame))
+				return i, err
+			}
+		}
+		frames[i] = T(st.s(st.At(st.frame + i)))
+	}
+	st.frame += n
+	st.done.Store(int64(st.frame))
+	return n, nil
+}
+
+// Progress returns the number of frames read and the total number of frames of the stream.
+// It can be called from any goroutine, for example to show the progress of a long render.
+func (st *Stream) Progress() (done, total int) { return int(st.done.Load()), st.total }
+
+// At returns the time of the given frame of the stream.
+func (st *Stream) At(frame int) time.Duration {
+	return AtFrame(st.first+frame, st.rate)
+}
+
+// Len returns the number of frames left to read.
+func (st *Stream) Len() int { return st.total - st.frame }
+
+// Rate returns the sample rate of the stream (in frames per second).
+func (st *Stream) Rate() int { return st.rate }
@@ -0,0 +1,16 @@
+package synth
+
+import "testing"
+
+// BenchmarkSampleInto measures a render block by block into a reused buffer, which must not allocate.
+func BenchmarkSampleInto(b *testing.B) {
+	const rate = 44100
+	s := Sine(Constant(440))
+	frames := make([]float64, DefaultBlockSize)
+	SampleInto(frames, s, rate, 0) // Warm-up.
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		SampleInto(frames, s, rate, AtFrame((i+1)*len(frames), rate))
+	}
+}
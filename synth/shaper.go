@@ -0,0 +1,100 @@
+package synth
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+)
+
+// Curve is the transfer function of a waveshaper, mapping input values to output values.
+type Curve func(v float64) float64
+
+// SoftClip saturates smoothly towards -1 and 1 (hyperbolic tangent).
+func SoftClip(v float64) float64 { return math.Tanh(v) }
+
+// HardClip clips values outside of -1 and 1.
+func HardClip(v float64) float64 { return math.Max(-1, math.Min(v, 1)) }
+
+// Foldback folds values going beyond -1 and 1 back into that range, adding bright harmonics.
+func Foldback(v float64) float64 {
+	v = math.Mod(v+1, 4)
+	if v < 0 {
+		v += 4
+	}
+	if v > 2 {
+		v = 4 - v
+	}
+	return v - 1
+}
+
+// Bitcrush returns a curve quantizing values between -1 and 1 to the given number of bits.
+func Bitcrush(bits int) Curve {
+	steps := math.Pow(2, float64(max(1, bits))-1)
+	return func(v float64) float64 {
+		return math.Round(HardClip(v)*steps) / steps
+	}
+}
+
+// Shape distorts the input through the curve.
+// The drive (in decibels) amplifies the input before the curve, the output gain (in decibels) is applied after it.
+func Shape(in Signal, curve Curve, drive, output float64) Signal {
+	d, o := DBToAmp(drive), DBToAmp(output)
+	return func(x time.Duration) float64 { return o * curve(d*in(x)) }
+}
+
+// ShapeOversampled is like Shape but runs the curve at a multiple of the sample rate (like 2 or 4)
+// and filters the result before coming back to the sample rate, which reduces the aliasing of harsh curves.
+// The input is interpolated linearly between samples, which delays the output by one sample.
+func ShapeOversampled(in Signal, curve Curve, drive, output float64, factor int) Signal {
+	if factor <= 1 {
+		return Shape(in, curve, drive, output)
+	}
+	d, o := DBToAmp(drive), DBToAmp(output)
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var rate, last float64
+		var lowpass Biquad
+		var stages [2]biquadState // Two stages for a steeper anti-aliasing filter.
+		return func(x time.Duration, dt float64) float64 {
+			v := d * in(x)
+			if r := meter.Tick(x); r != rate && r > 0 {
+				rate = r
+				lowpass = LowPassBiquad(rate*float64(factor), 0.45*rate, 0.707, 0)
+			}
+			var y float64
+			for i := 1; i <= factor; i++ {
+				y = curve(last + (v-last)*float64(i)/float64(factor))
+				if rate > 0 {
+					for s := range stages {
+						y = stages[s].process(lowpass, y)
+					}
+				}
+			}
+			last = v
+			return o * y
+		}
+	})
+}
+
+// Decimate reduces the sample rate of the input to the given rate (in Hertz),
+// holding each sample until the next one for a lo-fi, aliased sound.
+func Decimate(in, rate Signal) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var phase, held float64
+		first := true
+		return func(x time.Duration, dt float64) float64 {
+			v := in(x)
+			sr := meter.Tick(x)
+			if sr > 0 {
+				phase += rate(x) / sr
+			}
+			if first || phase >= 1 {
+				held, first = v, false
+				phase -= math.Floor(phase)
+			}
+			return held
+		}
+	})
+}
@@ -0,0 +1,14 @@
+// Package synth provides the building blocks of our audio synthesis:
+// signals, oscillators and sampling.
+package synth
+
+import "time"
+
+// Signal is a function that returns a value (usually between -1 and 1)
+// that fluctuates over time.
+type Signal func(x time.Duration) (y float64)
+
+// Constant returns a signal that always returns v.
+func Constant(v float64) Signal {
+	return func(x time.Duration) float64 { return v }
+}
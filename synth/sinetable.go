@@ -0,0 +1,48 @@
+package synth
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// SineTable is a precomputed cycle of a sine wave, read with linear interpolation.
+// It is faster than math.Sin, for large polyphony in real time: the error is below -100 dB from 1024 entries
+// (and shrinks by 12 dB each time the size doubles).
+type SineTable struct {
+	table []float64 // One cycle and the first entry again, so interpolation doesn't wrap.
+	size  float64
+	mask  int
+}
+
+// DefaultSineTable has 4096 entries (32 KiB, an error below -130 dB).
+var DefaultSineTable = NewSineTable(4096)
+
+// NewSineTable returns a table of the given size, rounded up to a power of two (at least 4).
+func NewSineTable(size int) *SineTable {
+	size = 1 << bits.Len(uint(max(size, 4)-1))
+	t := &SineTable{table: make([]float64, size+1), size: float64(size), mask: size - 1}
+	for i := range t.table {
+		t.table[i] = math.Sin(2 * math.Pi * float64(i) / float64(size))
+	}
+	return t
+}
+
+// Sin returns the sine of a phase in cycles (sin(2π*phase)).
+func (t *SineTable) Sin(phase float64) float64 {
+	pos := phase * t.size
+	i := math.Floor(pos)
+	f := pos - i
+	j := int(i) & t.mask
+	return t.table[j] + f*(t.table[j+1]-t.table[j])
+}
+
+// Oscillator returns a sine wave at the given frequency (in Hertz) read from the table, like Sine.
+func (t *SineTable) Oscillator(freq Signal) Signal {
+	return oscillator(freq, func(x time.Duration, phase, inc float64) float64 { return t.Sin(phase) })
+}
+
+// TableSine is like Sine, reading the sine from DefaultSineTable instead of computing it.
+func TableSine(freq Signal) Signal {
+	return DefaultSineTable.Oscillator(freq)
+}
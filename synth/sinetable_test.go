@@ -0,0 +1,44 @@
+package synth
+
+import (
+	"math"
+	"testing"
+)
+
+// sink keeps the results of benchmarks, so the compiler doesn't drop the computations.
+var sink float64
+
+// phases are the phases read by the benchmarks of SineTable.Sin and math.Sin.
+var phases = func() []float64 {
+	p := make([]float64, 1024)
+	for i := range p {
+		p[i] = float64(i) * 0.01237
+	}
+	return p
+}()
+
+func BenchmarkSineTableSin(b *testing.B) {
+	t := DefaultSineTable
+	for i := range b.N {
+		sink += t.Sin(phases[i%len(phases)])
+	}
+}
+
+func BenchmarkMathSin(b *testing.B) {
+	for i := range b.N {
+		sink += math.Sin(2 * math.Pi * phases[i%len(phases)])
+	}
+}
+
+// benchmarkOscillator measures an oscillator per sample.
+func benchmarkOscillator(b *testing.B, osc func(freq Signal) Signal) {
+	const rate = 44100
+	s := osc(Constant(440))
+	for i := range b.N {
+		sink += s(AtFrame(i, rate))
+	}
+}
+
+func BenchmarkTableSine(b *testing.B) { benchmarkOscillator(b, TableSine) }
+
+func BenchmarkSine(b *testing.B) { benchmarkOscillator(b, Sine) }
@@ -0,0 +1,29 @@
+package synth
+
+import "time"
+
+// Stateful returns a signal backed by a step function that is called once per new value of x,
+// with dt the number of seconds elapsed since the previous call (0 on the first call).
+//
+// Signals that keep state (like oscillators integrating their frequency or filters)
+// expect to be evaluated with increasing values of x:
+// evaluating the signal again at the same x returns the previous value,
+// and going back in time restarts the signal from a new state created by init.
+//
+// Stateful signals must not be used from multiple goroutines concurrently.
+func Stateful(init func() func(x time.Duration, dt float64) float64) Signal {
+	var step func(x time.Duration, dt float64) float64
+	var last time.Duration
+	var y float64
+	return func(x time.Duration) float64 {
+		switch {
+		case step == nil || x < last:
+			step = init()
+			y = step(x, 0)
+		case x > last:
+			y = step(x, (x - last).Seconds())
+		}
+		last = x
+		return y
+	}
+}
@@ -0,0 +1,29 @@
+package synth
+
+import "time"
+
+// MidSide encodes a stereo signal as mid (what both sides share, (L+R)/2) and side (their difference, (L-R)/2),
+// to process the center and the width of the stereo image separately (see LeftRight to decode it).
+func MidSide(stereo MultiSignal) MultiSignal {
+	left, right := stereo[0], stereo[1]
+	return Stereo(
+		func(x time.Duration) float64 { return (left(x) + right(x)) / 2 },
+		func(x time.Duration) float64 { return (left(x) - right(x)) / 2 },
+	)
+}
+
+// LeftRight decodes a mid/side signal (see MidSide) back to left and right.
+func LeftRight(midSide MultiSignal) MultiSignal {
+	mid, side := midSide[0], midSide[1]
+	return Stereo(
+		func(x time.Duration) float64 { return mid(x) + side(x) },
+		func(x time.Duration) float64 { return mid(x) - side(x) },
+	)
+}
+
+// Width scales the side of a stereo signal: 0 collapses it to mono (the same mid on both sides),
+// 1 leaves it untouched and above 1 widens it (which also makes it less mono-compatible).
+func Width(stereo MultiSignal, amount Signal) MultiSignal {
+	ms := MidSide(stereo)
+	return LeftRight(Stereo(ms[0], func(x time.Duration) float64 { return ms[1](x) * amount(x) }))
+}
@@ -0,0 +1,147 @@
+package synth
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// SharedStream is one of the streams returned by SampleStreams.
+type SharedStream struct {
+	shared *sharedStreams
+	index  int
+}
+
+// sharedStreams measures the signals of streams together.
+type sharedStreams struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	signals []Signal
+	rate    int
+	first   int
+	total   int
+	next    int         // Index of the next frame to measure, relative to the first one.
+	pending [][]float64 // Frames measured but not read yet, by stream.
+	read    []int       // Number of frames read, by stream.
+	closed  []bool
+	ctx     context.Context
+}
+
+// maxPending is the number of frames a stream can be ahead of the others.
+const maxPending = 4 * DefaultBlockSize
+
+// SampleStreams returns streams of the signals sampled together, like SampleStream:
+// each frame is measured for all signals before the next one,
+// so signals sharing stateful signals (like the stems of a mix) are rendered in one pass.
+//
+// The streams must be read concurrently, each by its own goroutine:
+// a stream that gets too far ahead of the others waits for them (or for them to be closed).
+func SampleStreams(signals []Signal, rate int, from, length time.Duration) []*SharedStream {
+	return SampleStreamsContext(context.Background(), signals, rate, from, length)
+}
+
+// SampleStreamsContext is like SampleStreams, but reading the streams fails with the error of the context once it is done
+// (cancelled or past its deadline).
+func SampleStreamsContext(ctx context.Context, signals []Signal, rate int, from, length time.Duration) []*SharedStream {
+	sh := &sharedStreams{
+		ctx:     ctx,
+		signals: signals,
+		rate:    rate,
+		first:   FrameAt(from, rate),
+		total:   FrameCount(0, length, rate),
+		pending: make([][]float64, len(signals)),
+		read:    make([]int, len(signals)),
+		closed:  make([]bool, len(signals)),
+	}
+	sh.changed = sync.NewCond(&sh.mu)
+	context.AfterFunc(ctx, func() {
+		sh.mu.Lock()
+		defer sh.mu.Unlock()
+		sh.changed.Broadcast() // Wake up the waiting streams.
+	})
+	streams := make([]*SharedStream, len(signals))
+	for i := range streams {
+		streams[i] = &SharedStream{shared: sh, index: i}
+	}
+	return streams
+}
+
+// Read fills frames with the next measurements of the signal and returns the number of frames read.
+// It returns io.EOF once all frames have been read.
+func (st *SharedStream) Read(frames []float64) (n int, err error) {
+	sh := st.shared
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	for len(sh.pending[st.index]) == 0 {
+		if err := sh.ctx.Err(); err != nil {
+			return 0, err
+		}
+		if sh.next >= sh.total || sh.closed[st.index] {
+			return 0, io.EOF
+		}
+		if sh.full() {
+			sh.changed.Wait()
+			continue
+		}
+		sh.measure()
+	}
+	n = copy(frames, sh.pending[st.index])
+	sh.pending[st.index] = sh.pending[st.index][n:]
+	sh.read[st.index] += n
+	sh.changed.Broadcast()
+	return n, nil
+}
+
+// full reports whether a stream that isn't closed has too many pending frames to measure more.
+func (sh *sharedStreams) full() bool {
+	for i, p := range sh.pending {
+		if !sh.closed[i] && len(p) >= maxPending {
+			return true
+		}
+	}
+	return false
+}
+
+// measure measures the next block of frames of all streams.
+func (sh *sharedStreams) measure() {
+	n := min(DefaultBlockSize, sh.total-sh.next)
+	for i := 0; i < n; i++ {
+		x := AtFrame(sh.first+sh.next+i, sh.rate)
+		for j, s := range sh.signals {
+			v := s(x) // Even for closed streams, so the signals they share with the others keep up.
+			if !sh.closed[j] {
+				sh.pending[j] = append(sh.pending[j], v)
+			}
+		}
+	}
+	sh.next += n
+	sh.changed.Broadcast()
+}
+
+// Close stops reading the stream, so the other streams don't wait for it.
+func (st *SharedStream) Close() error {
+	sh := st.shared
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.closed[st.index], sh.pending[st.index] = true, nil
+	sh.changed.Broadcast()
+	return nil
+}
+
+// Len returns the number of frames left to read.
+func (st *SharedStream) Len() int {
+	st.shared.mu.Lock()
+	defer st.shared.mu.Unlock()
+	return st.shared.total - st.shared.read[st.index]
+}
+
+// Progress returns the number of frames read and the total number of frames of the stream.
+func (st *SharedStream) Progress() (done, total int) {
+	st.shared.mu.Lock()
+	defer st.shared.mu.Unlock()
+	return st.shared.read[st.index], st.shared.total
+}
+
+// Rate returns the sample rate of the stream (in frames per second).
+func (st *SharedStream) Rate() int { return st.shared.rate }
@@ -0,0 +1,125 @@
+package synth
+
+import (
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+	"github.com/ejuju/poc-go-audio-synthesis/internal/fft"
+)
+
+// pitchShiftWindow is the length of the delay sweeps of PitchShift.
+const pitchShiftWindow = 50 * time.Millisecond
+
+// PitchShift shifts the pitch of the input by the given number of semitones without changing its speed.
+//
+// It reads the input through two delay lines whose delay sweeps faster or slower than time
+// (like a tape played at another speed), each jumping back once per window of 50ms while faded out,
+// so it works in real time but smears transients a bit and gets grainy for large shifts.
+func PitchShift(in, semitones Signal) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var buf []float64
+		var pos int       // Index of the next write.
+		var phase float64 // Of the first tap, from 0 to 1 over the window.
+		return func(x time.Duration, dt float64) float64 {
+			v, s := in(x), semitones(x)
+			rate := meter.Tick(x)
+			if rate == 0 {
+				return 0 // The sample rate isn't known until the second sample.
+			}
+			if buf == nil {
+				buf = make([]float64, int(pitchShiftWindow.Seconds()*rate)+2)
+			}
+			buf[pos] = v
+			pos = (pos + 1) % len(buf)
+
+			window := float64(len(buf) - 2)
+			phase = frac(phase + (1-math.Exp2(s/12))/window)
+			var out float64
+			for _, p := range [2]float64{phase, frac(phase + 0.5)} {
+				delay := 1 + p*window // In frames.
+				i, t := math.Modf(delay)
+				at := func(back int) float64 { return buf[(pos-back+2*len(buf))%len(buf)] }
+				level := math.Sin(math.Pi * p) // Both levels squared sum to 1.
+				out += level * level * ((1-t)*at(int(i)) + t*at(int(i)+1))
+			}
+			return out
+		}
+	})
+}
+
+// TimeStretch returns the frames played slower or faster without changing their pitch:
+// the output is ratio times as long as the input (2 lasts twice as long, 0.5 half as long).
+//
+// It is a phase vocoder: overlapping windows of the input are moved apart or closer together,
+// with the phases of their frequencies adjusted so they still add up.
+// This keeps tonal sounds clean, but softens transients (drums sound a bit "phasey").
+func TimeStretch(frames []float64, ratio float64) []float64 {
+	const size, hop = 2048, 512 // Window size and hop between output windows.
+	if ratio <= 0 || len(frames) == 0 {
+		return nil
+	}
+	window := make([]float64, size)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/size)
+	}
+	n := int(math.Round(float64(len(frames)) * ratio))
+	out := make([]float64, n+size)
+	weights := make([]float64, n+size) // Sum of the squared windows, to normalize the overlap.
+
+	phases := make([]float64, size/2+1)    // Of the previous analysis window.
+	synthesis := make([]float64, size/2+1) // Phases of the output.
+	buf := make([]complex128, size)
+	prev := 0
+	for k := 0; k*hop < n; k++ {
+		start := int(math.Round(float64(k*hop) / ratio))
+		for i := range buf {
+			v := 0.0
+			if start+i < len(frames) {
+				v = frames[start+i]
+			}
+			buf[i] = complex(v*window[i], 0)
+		}
+		fft.Forward(buf)
+
+		analysisHop := float64(start - prev)
+		prev = start
+		for b := range phases {
+			magnitude, phase := cmplx.Abs(buf[b]), cmplx.Phase(buf[b])
+			if k == 0 {
+				synthesis[b] = phase
+			} else {
+				omega := 2 * math.Pi * float64(b) / size // Frequency of the bin, in radians per frame.
+				deviation := wrapPhase(phase - phases[b] - omega*analysisHop)
+				freq := omega
+				if analysisHop > 0 {
+					freq += deviation / analysisHop // Actual frequency in the bin.
+				}
+				synthesis[b] += freq * hop
+			}
+			phases[b] = phase
+			buf[b] = cmplx.Rect(magnitude, synthesis[b])
+			if b > 0 && b < size/2 {
+				buf[size-b] = cmplx.Conj(buf[b])
+			}
+		}
+		fft.Inverse(buf)
+		for i, w := range window {
+			out[k*hop+i] += real(buf[i]) * w
+			weights[k*hop+i] += w * w
+		}
+	}
+	for i := range out {
+		if weights[i] > 1e-3 {
+			out[i] /= weights[i]
+		}
+	}
+	return out[:n]
+}
+
+// wrapPhase returns the phase between -π and π.
+func wrapPhase(p float64) float64 {
+	return p - 2*math.Pi*math.Round(p/(2*math.Pi))
+}
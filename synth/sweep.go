@@ -0,0 +1,56 @@
+package synth
+
+import (
+	"math"
+	"time"
+)
+
+// Test signals, to measure filters and effects (see package analysis).
+
+// LinSweep returns a sine sweeping linearly from f0 to f1 (in Hertz) over the duration, silent afterwards.
+// Linear sweeps spend as much time on each frequency, so their spectrum is flat (white).
+func LinSweep(f0, f1 float64, dur time.Duration) Signal {
+	T := dur.Seconds()
+	return sweep(dur, func(t float64) float64 { return f0*t + (f1-f0)*t*t/(2*T) })
+}
+
+// LogSweep returns a sine sweeping exponentially from f0 to f1 (in Hertz) over the duration, silent afterwards:
+// the same time for each octave (a pink spectrum), which is the usual sweep to measure impulse responses.
+func LogSweep(f0, f1 float64, dur time.Duration) Signal {
+	T := dur.Seconds()
+	k := math.Log(f1 / f0)
+	if k == 0 {
+		return sweep(dur, func(t float64) float64 { return f0 * t })
+	}
+	return sweep(dur, func(t float64) float64 { return f0 * T / k * (math.Exp(t*k/T) - 1) })
+}
+
+// sweep returns a sine with the given phase (in cycles, at a time in seconds) until the end of the duration.
+func sweep(dur time.Duration, phase func(t float64) float64) Signal {
+	return func(x time.Duration) float64 {
+		if x < 0 || x >= dur {
+			return 0
+		}
+		return math.Sin(2 * math.Pi * frac(phase(x.Seconds())))
+	}
+}
+
+// Impulse returns a single frame at 1 at the given time (the first frame sampled at or after it), 0 elsewhere.
+// The impulse response of a system is its output for this input.
+func Impulse(at time.Duration) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		first := true
+		return func(x time.Duration, dt float64) float64 {
+			prev := x - time.Duration(dt*float64(time.Second))
+			fire := x >= at && (first && x == at || !first && prev < at)
+			first = false
+			if fire {
+				return 1
+			}
+			return 0
+		}
+	})
+}
+
+// Silence returns a signal that is always 0 (use Constant for a DC offset).
+func Silence() Signal { return Constant(0) }
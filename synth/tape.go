@@ -0,0 +1,51 @@
+package synth
+
+import "math"
+
+// Tape is the character of an analog tape machine, for lo-fi renders.
+// Each part is off when its setting is zero, see NewTape for settings scaled by a single intensity.
+type Tape struct {
+	Wow     float64 // Depth of the slow pitch drift (around 0.5 Hz), in cents.
+	Flutter float64 // Depth of the fast, irregular pitch wobble (around 6 Hz), in cents.
+	Drive   float64 // Saturation, in decibels of drive into a soft clipper (small signals keep their level).
+	Rolloff float64 // Cutoff of the loss of high frequencies, in Hertz.
+	Hiss    float64 // Level of the tape hiss (pink noise), in decibels.
+	Seed    int64   // Of the flutter and the hiss.
+}
+
+// NewTape returns the settings of a tape machine from an intensity between 0 (clean) and 1 (worn out cassette).
+func NewTape(intensity float64) Tape {
+	i := math.Max(0, math.Min(intensity, 1))
+	if i == 0 {
+		return Tape{}
+	}
+	return Tape{
+		Wow:     12 * i,
+		Flutter: 4 * i,
+		Drive:   6 * i,
+		Rolloff: 18000 * math.Pow(5000.0/18000, i), // From 18 kHz to 5 kHz.
+		Hiss:    -75 + 25*i,
+		Seed:    1,
+	}
+}
+
+// Apply returns the input played through the tape machine.
+func (t Tape) Apply(in Signal) Signal {
+	out := in
+	if t.Wow != 0 {
+		out = Vibrato(out, Hz(0.5), t.Wow)
+	}
+	if t.Flutter != 0 {
+		out = Vibrato(out, LFOSampleHold(Hz(3), 1.5, 6, t.Seed), t.Flutter) // The rate wanders between 4.5 and 7.5 Hz.
+	}
+	if t.Drive != 0 {
+		out = Shape(out, SoftClip, t.Drive, -t.Drive)
+	}
+	if t.Hiss != 0 {
+		out = Add(out, Gain(PinkNoise(t.Seed), t.Hiss))
+	}
+	if t.Rolloff > 0 {
+		out = LowPass(out, Constant(t.Rolloff), Constant(0.707))
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package synth
+
+import (
+	"math"
+	"time"
+)
+
+// Shift returns the signal delayed by the given duration (or moved earlier if negative): Shift(s, by)(x) = s(x-by).
+func Shift(s Signal, by time.Duration) Signal {
+	return func(x time.Duration) float64 { return s(x - by) }
+}
+
+// Clip is a signal played for the given length, starting from its time 0.
+type Clip struct {
+	Signal Signal
+	Length time.Duration
+}
+
+// Concat plays the clips one after another: each clip starts (from its own time 0) when the previous one ends,
+// and is silent (and not evaluated) outside of its slot. Use Crossfade for smooth transitions.
+func Concat(clips ...Clip) Signal {
+	starts := make([]time.Duration, len(clips))
+	var end time.Duration
+	for i, c := range clips {
+		starts[i] = end
+		end += max(c.Length, 0)
+	}
+	return func(x time.Duration) float64 {
+		for i, c := range clips {
+			if x >= starts[i] && x < starts[i]+c.Length {
+				return c.Signal(x - starts[i])
+			}
+		}
+		return 0
+	}
+}
+
+// Crossfade plays a until the given time, then fades it out while fading b in, over the given duration,
+// and plays b afterwards. Both signals keep their own timing (use Shift to move b).
+// The fade is equal power, so the level stays constant when crossfading uncorrelated sounds.
+func Crossfade(a, b Signal, at, over time.Duration) Signal {
+	return func(x time.Duration) float64 {
+		switch {
+		case x < at:
+			return a(x)
+		case x >= at+over:
+			return b(x)
+		}
+		t := float64(x-at) / float64(over) * math.Pi / 2
+		return math.Cos(t)*a(x) + math.Sin(t)*b(x)
+	}
+}
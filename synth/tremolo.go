@@ -0,0 +1,56 @@
+package synth
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+)
+
+// Ready-made modulation effects: an LFO wired to the gain, the pitch or the position of the input.
+// Rates are in Hertz, use Hz for a fixed rate or Sync for note divisions (like Sync(120, Eighth)).
+
+// Tremolo modulates the gain of the input with a sine LFO, between 0 dB and -depth dB.
+func Tremolo(in, rate Signal, depth float64) Signal {
+	lfo := Sine(rate)
+	return func(x time.Duration) float64 {
+		return in(x) * DBToAmp(-depth*(1-lfo(x))/2)
+	}
+}
+
+// Vibrato modulates the pitch of the input with a sine LFO, up to depth cents above and below
+// (through a delay line whose delay follows the LFO, so it adds a latency of half the sweep).
+func Vibrato(in, rate Signal, depth float64) Signal {
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var line delayLine
+		var phase float64
+		return func(x time.Duration, dt float64) float64 {
+			v, f := in(x), rate(x)
+			sr := meter.Tick(x)
+			line.write(v)
+			if dt == 0 {
+				phase = frac(x.Seconds() * f) // Aligned on the beat grid like oscillators.
+			} else {
+				phase = frac(phase + f*dt)
+			}
+			if sr == 0 {
+				return 0 // The sample rate isn't known until the second sample.
+			}
+			// The pitch changes with the slope of the delay: a sweep of amplitude a (in seconds)
+			// at f Hertz reaches a ratio of 1 ± 2π·f·a.
+			var a float64
+			if f > 0 {
+				a = math.Min((math.Exp2(math.Abs(depth)/1200)-1)/(2*math.Pi*f), MaxDelay.Seconds()/2)
+			}
+			return line.read(1 + a*sr*(1+math.Sin(2*math.Pi*phase)))
+		}
+	})
+}
+
+// AutoPan moves the input between the sides with a sine LFO, from -depth to depth (1 for hard left to hard right),
+// with equal-power panning like Pan.
+func AutoPan(in, rate Signal, depth float64) MultiSignal {
+	lfo := Sine(rate)
+	return Pan(in, func(x time.Duration) float64 { return depth * lfo(x) })
+}
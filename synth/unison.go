@@ -0,0 +1,33 @@
+package synth
+
+import (
+	"math"
+	"time"
+)
+
+// Unison stacks n copies of a voice, detuned over the given range (in cents, from -detune/2 to +detune/2)
+// and spread in the stereo field (from 0, all centered, to 1, from left to right), like a supersaw.
+// Copies are panned alternately left and right so the most detuned ones don't all end up on the same side,
+// and the sum is scaled by 1/√n to keep a similar level whatever the number of copies.
+func Unison(n int, detune, spread float64, voice func(freq Signal) Signal) func(freq Signal) MultiSignal {
+	n = max(n, 1)
+	scale := 1 / math.Sqrt(float64(n))
+	return func(freq Signal) MultiSignal {
+		var left, right []Signal
+		for i := 0; i < n; i++ {
+			pos := 0.0
+			if n > 1 {
+				pos = float64(i)/float64(n-1) - 0.5 // Between -0.5 and 0.5.
+			}
+			ratio := math.Pow(2, detune*pos/1200)
+			copyFreq := func(x time.Duration) float64 { return ratio * freq(x) }
+			pan := 2 * pos * spread
+			if i%2 == 1 {
+				pan = -pan
+			}
+			st := Pan(voice(copyFreq), Constant(pan))
+			left, right = append(left, st[0]), append(right, st[1])
+		}
+		return Stereo(Mul(Constant(scale), Add(left...)), Mul(Constant(scale), Add(right...)))
+	}
+}
@@ -0,0 +1,67 @@
+package synth
+
+import (
+	"math"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/internal/dsp"
+)
+
+// Vocoder imposes the spectral envelope of the modulator (like a voice) on the carrier (like a saw chord):
+// both are split into bands (8 to 32 usually) spaced logarithmically between low and high (in Hertz),
+// and each band of the carrier is played at the level of the same band of the modulator.
+//
+// The carrier needs energy in all bands, so bright sounds (saws, pulses, noise) work best.
+// The output is quieter than the inputs, since only a part of the carrier goes through each band.
+func Vocoder(carrier, modulator Signal, bands int, low, high float64) Signal {
+	bands = max(bands, 1)
+	return Stateful(func() func(x time.Duration, dt float64) float64 {
+		var meter dsp.RateMeter
+		var filters []Biquad
+		analysis := make([][2]biquadState, bands) // Two filters per band, for steeper slopes.
+		synthesis := make([][2]biquadState, bands)
+		envelopes := make([]float64, bands)
+		var attack, release float64
+		return func(x time.Duration, dt float64) float64 {
+			c, m := carrier(x), modulator(x)
+			rate := meter.Tick(x)
+			if rate == 0 {
+				return 0 // The sample rate isn't known until the second sample.
+			}
+			if filters == nil {
+				filters = vocoderBands(rate, bands, low, high)
+				attack, release = smoothing(5*time.Millisecond, rate), smoothing(30*time.Millisecond, rate)
+			}
+
+			var out float64
+			for i, b := range filters {
+				level := math.Abs(analysis[i][1].process(b, analysis[i][0].process(b, m)))
+				k := release
+				if level > envelopes[i] {
+					k = attack
+				}
+				envelopes[i] += (level - envelopes[i]) * k
+				out += envelopes[i] * synthesis[i][1].process(b, synthesis[i][0].process(b, c))
+			}
+			return out * math.Pi / 2 // The average of a rectified sine is 2/π of its peak.
+		}
+	})
+}
+
+// vocoderBands returns band-pass filters with logarithmically spaced centers,
+// each as wide as the space between centers.
+func vocoderBands(rate float64, bands int, low, high float64) []Biquad {
+	low = math.Max(low, 20)
+	high = math.Max(low, math.Min(high, 0.45*rate))
+	ratio := math.Pow(high/low, 1/float64(bands)) // Between the edges of a band.
+	q := math.Sqrt(ratio) / (ratio - 1)
+	if bands == 1 || ratio <= 1 {
+		q = 1
+	}
+	filters := make([]Biquad, bands)
+	for i := range filters {
+		center := low * math.Pow(ratio, float64(i)+0.5)
+		filters[i] = BandPassBiquad(rate, center, q, 0)
+	}
+	return filters
+}
@@ -0,0 +1,73 @@
+package synth
+
+import (
+	"math"
+	"time"
+)
+
+// Interpolation returns the value of a single-cycle table at a fractional index
+// (wrapping around the end of the table).
+type Interpolation func(table []float64, index float64) float64
+
+// Linear interpolates between the two nearest samples, it is cheap but dulls high frequencies a bit.
+func Linear(table []float64, index float64) float64 {
+	n := len(table)
+	i := int(index)
+	t := index - float64(i)
+	return (1-t)*table[i%n] + t*table[(i+1)%n]
+}
+
+// Cubic interpolates using the four nearest samples (Catmull-Rom spline), it is smoother than Linear.
+func Cubic(table []float64, index float64) float64 {
+	n := len(table)
+	i := int(index)
+	t := index - float64(i)
+	y0, y1, y2, y3 := table[(i-1+n)%n], table[i%n], table[(i+1)%n], table[(i+2)%n]
+	return y1 + 0.5*t*(y2-y0+t*(2*y0-5*y1+4*y2-y3+t*(3*(y1-y2)+y3-y0)))
+}
+
+// Wavetable plays a single-cycle waveform at the given frequency (in Hertz), using cubic interpolation.
+func Wavetable(table []float64, freq Signal) Signal {
+	return WavetableInterp(table, freq, Cubic)
+}
+
+// WavetableInterp plays a single-cycle waveform at the given frequency (in Hertz),
+// using the given interpolation between samples.
+func WavetableInterp(table []float64, freq Signal, interp Interpolation) Signal {
+	if len(table) == 0 {
+		return Constant(0)
+	}
+	phase := Phase(freq)
+	return func(x time.Duration) float64 {
+		return interp(table, phase(x)*float64(len(table)))
+	}
+}
+
+// WavetableMorph plays a series of single-cycle waveforms (all of the same length) at the given frequency (in Hertz),
+// crossfading between neighboring tables as the position goes from 0 (first table) to 1 (last table).
+func WavetableMorph(tables [][]float64, freq, position Signal) Signal {
+	if len(tables) == 0 || len(tables[0]) == 0 {
+		return Constant(0)
+	}
+	phase := Phase(freq)
+	return func(x time.Duration) float64 {
+		index := phase(x) * float64(len(tables[0]))
+		pos := math.Max(0, math.Min(1, position(x))) * float64(len(tables)-1)
+		i := int(pos)
+		if i == len(tables)-1 {
+			return Cubic(tables[i], index)
+		}
+		t := pos - float64(i)
+		return (1-t)*Cubic(tables[i], index) + t*Cubic(tables[i+1], index)
+	}
+}
+
+// SplitTables splits frames containing consecutive single-cycle waveforms of the given size
+// (like wavetable files made for other synthesizers, often 2048 samples per cycle).
+// Remaining frames that don't fill a whole table are ignored.
+func SplitTables(frames []float64, size int) (tables [][]float64) {
+	for i := 0; i+size <= len(frames); i += size {
+		tables = append(tables, frames[i:i+size])
+	}
+	return tables
+}
@@ -0,0 +1,215 @@
+// Package transport keeps the musical time of a song (tempo, bars and beats, play, stop and loop),
+// so that sequencers, LFOs, delays and scheduled events all follow the same clock.
+package transport
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ejuju/poc-go-audio-synthesis/param"
+	"github.com/ejuju/poc-go-audio-synthesis/seq"
+	"github.com/ejuju/poc-go-audio-synthesis/synth"
+)
+
+// Transport is a master clock counting beats while it is playing.
+//
+// Its position advances as its Position signal is evaluated (once per sample by the renderer or the player),
+// all other signals derive from it. Methods may be called from other goroutines while playing:
+// changes take effect at the next sample.
+type Transport struct {
+	BeatsPerBar int // 4 by default.
+
+	bpm     *param.Param
+	playing atomic.Bool
+	seek    atomic.Pointer[float64] // Position to jump to at the next sample, if any.
+
+	mu                 sync.Mutex
+	loop               bool
+	loopStart, loopEnd float64
+	events             []event // Sorted by beat.
+	lastBeat           float64 // Position recorded for LastPosition.
+	lastAt             time.Time
+
+	position synth.Signal
+}
+
+type event struct {
+	beat float64
+	fn   func()
+}
+
+// New returns a stopped transport at the given tempo (in beats per minute), positioned at beat 0.
+func New(bpm float64) *Transport {
+	t := &Transport{bpm: param.New(bpm)}
+	t.position = synth.Stateful(func() func(x time.Duration, dt float64) float64 {
+		var pos float64
+		first := true // Events at the current position are dispatched when starting.
+		samples := 0
+		return func(x time.Duration, dt float64) float64 {
+			if samples++; samples%positionPeriod == 0 {
+				defer func() { t.record(pos) }()
+			}
+			if p := t.seek.Swap(nil); p != nil {
+				pos, first = *p, true
+			}
+			if !t.playing.Load() {
+				first = true
+				return pos
+			}
+			next := pos
+			if !first {
+				next += dt * t.BPM() / 60
+			}
+			pos = t.advance(pos, next, first)
+			first = false
+			return pos
+		}
+	})
+	return t
+}
+
+// positionPeriod is the number of samples between the positions recorded for LastPosition.
+const positionPeriod = 64
+
+func (t *Transport) record(pos float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastBeat, t.lastAt = pos, time.Now()
+}
+
+// LastPosition returns a recent position of the transport (in beats) and when it was computed,
+// to follow an external clock (see package link). The time is zero until the transport starts being evaluated.
+// Signals are computed ahead of time by the player (by its latency), so the position is heard later.
+func (t *Transport) LastPosition() (beat float64, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lastBeat, t.lastAt
+}
+
+// advance dispatches the events between from (excluded unless inclusive) and to (included),
+// wrapping around the loop, and returns the new position.
+func (t *Transport) advance(from, to float64, inclusive bool) float64 {
+	t.mu.Lock()
+	loop, start, end := t.loop && t.loopEnd > t.loopStart, t.loopStart, t.loopEnd
+	var due []func()
+	collect := func(from, to float64, inclusive bool) {
+		for _, e := range t.events {
+			if (e.beat > from || inclusive && e.beat == from) && e.beat <= to {
+				due = append(due, e.fn)
+			}
+		}
+	}
+	if loop && from < end && to >= end {
+		collect(from, math.Nextafter(end, math.Inf(-1)), inclusive)
+		to = start + math.Mod(to-end, end-start)
+		collect(start, to, true)
+	} else {
+		collect(from, to, inclusive)
+	}
+	t.mu.Unlock()
+
+	for _, fn := range due {
+		fn()
+	}
+	return to
+}
+
+// Play starts (or resumes) the transport.
+func (t *Transport) Play() { t.playing.Store(true) }
+
+// Stop pauses the transport, keeping its position.
+func (t *Transport) Stop() { t.playing.Store(false) }
+
+// Playing reports whether the transport is playing.
+func (t *Transport) Playing() bool { return t.playing.Load() }
+
+// Locate moves the transport to the given position (in beats).
+func (t *Transport) Locate(beat float64) { t.seek.Store(&beat) }
+
+// BPM returns the tempo (in beats per minute).
+func (t *Transport) BPM() float64 { return t.bpm.Get() }
+
+// SetBPM changes the tempo (in beats per minute).
+func (t *Transport) SetBPM(bpm float64) { t.bpm.Set(bpm) }
+
+// SetLoop loops the transport between two positions (in beats), or stops looping if end isn't after start.
+func (t *Transport) SetLoop(start, end float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.loop, t.loopStart, t.loopEnd = end > start, start, end
+}
+
+// At schedules fn to be called when the transport reaches the given position (in beats),
+// on the goroutine evaluating the signals, at the first sample at or after the position.
+// The event is dispatched again each time the transport goes over the position (when looping for example).
+func (t *Transport) At(beat float64, fn func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i, _ := slices.BinarySearchFunc(t.events, beat, func(e event, beat float64) int { return cmp.Compare(e.beat, beat) })
+	t.events = slices.Insert(t.events, i, event{beat: beat, fn: fn})
+}
+
+// Position returns the position of the transport (in beats).
+func (t *Transport) Position() synth.Signal { return t.position }
+
+// Running returns 1 while the transport is playing and 0 when it is stopped.
+func (t *Transport) Running() synth.Signal {
+	return func(x time.Duration) float64 {
+		t.position(x) // Applies pending changes at this sample.
+		if t.Playing() {
+			return 1
+		}
+		return 0
+	}
+}
+
+// Rate returns the frequency (in Hertz) of a cycle lasting the given number of beats at the current tempo,
+// or 0 when the transport is stopped, so synced LFOs freeze with it.
+func (t *Transport) Rate(beats float64) synth.Signal {
+	running := t.Running()
+	return func(x time.Duration) float64 { return running(x) * t.BPM() / 60 / beats }
+}
+
+// Time returns the duration of the given number of beats at the current tempo (in seconds), for example for delays.
+func (t *Transport) Time(beats float64) synth.Signal {
+	return func(x time.Duration) float64 { return beats * 60 / t.BPM() }
+}
+
+// Clock returns a gate opening at the start of each division (in beats, like 0.25 for sixteenth notes)
+// and closing halfway through, while the transport is playing.
+func (t *Transport) Clock(division float64) synth.Signal {
+	running := t.Running()
+	return func(x time.Duration) float64 {
+		if running(x) == 0 || math.Mod(t.position(x), division) >= division/2 {
+			return 0
+		}
+		return 1
+	}
+}
+
+// Sequence plays the notes (timed in beats) following the transport, in 12-tone equal temperament.
+// The gate closes while the transport is stopped.
+func (t *Transport) Sequence(notes []seq.Note) seq.Voice {
+	v := seq.SequenceAt(notes, t.position, seq.TwelveTone)
+	running := t.Running()
+	gate := v.Gate
+	v.Gate = func(x time.Duration) float64 { return running(x) * gate(x) }
+	return v
+}
+
+// BarsBeats returns the bar and the beat in the bar (both starting at 1, like in DAWs)
+// and the fraction of the beat of a position (in beats).
+func (t *Transport) BarsBeats(position float64) (bar, beat int, frac float64) {
+	perBar := t.BeatsPerBar
+	if perBar <= 0 {
+		perBar = 4
+	}
+	whole := math.Floor(position)
+	bar = int(whole)/perBar + 1
+	beat = int(whole)%perBar + 1
+	return bar, beat, position - whole
+}